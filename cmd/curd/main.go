@@ -7,10 +7,12 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/wraient/curd/internal"
+	"github.com/wraient/curd/internal/hooks"
 	_ "github.com/wraient/curd/internal/loadproviders"
 )
 
@@ -44,6 +46,72 @@ func main() {
 	internal.SetGlobalLogFile(logFile)
 	internal.ClearLogFile(logFile)
 
+	if err := internal.ConfigureHTTPClient(&userCurdConfig); err != nil {
+		internal.Log(fmt.Sprintf("Invalid network settings, using defaults: %v", err))
+		internal.CurdOut(fmt.Sprintf("Invalid network settings, using defaults: %v", err))
+	}
+
+	// The account in curd.conf is the one curd starts with; -account below
+	// overrides it for a session.
+	if userCurdConfig.Account != "" {
+		if err := internal.UseAccount(&userCurdConfig, userCurdConfig.Account); err != nil {
+			internal.CurdOut(fmt.Sprintf("%v; using the default account", err))
+			internal.UseAccount(&userCurdConfig, "")
+		}
+	}
+
+	// "curd account ..." manages the accounts sharing this machine and exits.
+	if len(os.Args) > 1 && os.Args[1] == "account" {
+		if err := internal.RunAccountCommand(&userCurdConfig, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// "curd mapping ..." manages stored provider mappings and exits.
+	// Plugins are loaded first so their providers can be mapped too.
+	if len(os.Args) > 1 && os.Args[1] == "mapping" {
+		internal.LoadProviderPlugins(&userCurdConfig)
+		err := internal.RunMappingCommand(&userCurdConfig, os.Args[2:])
+		internal.CloseProviderPlugins()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// "curd auth ..." reports on the stored tracker logins and exits.
+	if len(os.Args) > 1 && os.Args[1] == "auth" {
+		if err := internal.RunAuthCommand(&userCurdConfig, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// "curd party host [addr]" and "curd party join <addr>" are subcommands;
+	// strip them so the remaining arguments parse as regular flags.
+	partyMode, partyAddr := "", ""
+	if len(os.Args) > 1 && os.Args[1] == "party" {
+		if len(os.Args) < 3 || (os.Args[2] != "host" && os.Args[2] != "join") {
+			fmt.Fprintf(os.Stderr, "Usage: %s party host [addr] | party join <addr>\n", os.Args[0])
+			os.Exit(2)
+		}
+		partyMode = os.Args[2]
+		rest := os.Args[3:]
+		if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+			partyAddr = rest[0]
+			rest = rest[1:]
+		}
+		if partyMode == "join" && partyAddr == "" {
+			fmt.Fprintf(os.Stderr, "Usage: %s party join <addr>\n", os.Args[0])
+			os.Exit(2)
+		}
+		os.Args = append([]string{os.Args[0]}, rest...)
+	}
+
 	// Flags configured here cause userconfig needs to be changed.
 	flag.StringVar(&userCurdConfig.Player, "player", userCurdConfig.Player, "Player to use for playback (Only mpv supported currently)")
 	flag.StringVar(&userCurdConfig.StoragePath, "storage-path", userCurdConfig.StoragePath, "Path to the storage directory")
@@ -58,6 +126,10 @@ func main() {
 	flag.BoolVar(&userCurdConfig.SkipRecap, "skip-recap", userCurdConfig.SkipRecap, "Skip recap (true/false)")
 	flag.BoolVar(&userCurdConfig.ScoreOnCompletion, "score-on-completion", userCurdConfig.ScoreOnCompletion, "Score on episode completion (true/false)")
 	flag.BoolVar(&userCurdConfig.SaveMpvSpeed, "save-mpv-speed", userCurdConfig.SaveMpvSpeed, "Save MPV speed setting (true/false)")
+	flag.StringVar(&userCurdConfig.MenuBackend, "menu", userCurdConfig.MenuBackend, "Menu backend: auto, tui, rofi, fzf, dmenu, wmenu, bemenu, fuzzel, tofi or walker")
+	flag.StringVar(&userCurdConfig.Theme, "theme", userCurdConfig.Theme, "Colour theme: dark, light, high-contrast or none")
+	flag.BoolVar(&userCurdConfig.EpisodeBrowser, "episodes", userCurdConfig.EpisodeBrowser, "Pick the episodes to play or download from a list (true/false)")
+	flag.BoolVar(&userCurdConfig.WarmCache, "warm-cache", userCurdConfig.WarmCache, "Buffer upcoming episodes to a disk cache (true/false)")
 	flag.BoolVar(&userCurdConfig.DiscordPresence, "discord-presence", userCurdConfig.DiscordPresence, "Enable Discord presence (true/false)")
 	flag.StringVar(&userCurdConfig.DiscordClientId, "discord-client-id", userCurdConfig.DiscordClientId, "Discord client ID for Rich Presence")
 	continueLast := flag.Bool("c", false, "Continue last episode")
@@ -74,6 +146,7 @@ func main() {
 	subFlag := flag.Bool("sub", false, "Watch sub version")
 	dubFlag := flag.Bool("dub", false, "Watch dub version")
 	versionFlag := flag.Bool("v", false, "Print version information")
+	accountFlag := flag.String("account", "", "Account to use for this session (see 'curd account list')")
 
 	// Custom help/usage function
 	flag.Usage = func() {
@@ -81,6 +154,14 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Curd is a CLI tool to manage anime playback with advanced features like skipping intro, outro, filler, recap, tracking progress, and integrating with Discord.\n")
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
 		flag.PrintDefaults() // This prints the default flag information
+		fmt.Fprintf(os.Stderr, "\nWatch party:\n")
+		fmt.Fprintf(os.Stderr, "  %s party host [addr]\tHost a LAN watch party (default :7717)\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s party join <addr>\tJoin a watch party and follow the host playback\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nProvider mappings:\n")
+		fmt.Fprintf(os.Stderr, "  %s mapping [list|show|set|remove|edit|export|import]\tManage stored provider mappings (see '%s mapping help')\n", os.Args[0], os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nAccounts:\n")
+		fmt.Fprintf(os.Stderr, "  %s auth status [account]\tShow the tracker logins and token expiry of every account, or of one\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s account [list|add|use|remove]\tManage the accounts sharing this machine (see '%s account help')\n", os.Args[0], os.Args[0])
 	}
 
 	flag.Parse()
@@ -95,6 +176,19 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *accountFlag != "" {
+		if err := internal.UseAccount(&userCurdConfig, *accountFlag); err != nil {
+			internal.RestoreScreen()
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if err := internal.ApplyTheme(&userCurdConfig); err != nil {
+		internal.Log(err.Error())
+		internal.CurdOut(fmt.Sprintf("%v, using the default colours", err))
+	}
+
 	anime.Ep.ContinueLast = *continueLast
 
 	if *updateScript {
@@ -130,10 +224,19 @@ func main() {
 
 	if *rofiSelection {
 		userCurdConfig.RofiSelection = true
+		userCurdConfig.MenuBackend = "rofi"
 	}
 
 	if *noRofi || runtime.GOOS == "windows" {
 		userCurdConfig.RofiSelection = false
+		if strings.EqualFold(userCurdConfig.MenuBackend, "rofi") {
+			userCurdConfig.MenuBackend = "auto"
+		}
+	}
+
+	if err := internal.ApplyMenuBackend(&userCurdConfig); err != nil {
+		internal.Log(err.Error())
+		internal.CurdOut(fmt.Sprintf("%v, using the default menus", err))
 	}
 
 	if *imagePreview {
@@ -156,64 +259,20 @@ func main() {
 		userCurdConfig.SubOrDub = "dub"
 	}
 
-	// Determine which tracking service to use
-	trackingService := internal.GetTrackingService(&userCurdConfig)
+	internal.LoadProviderPlugins(&userCurdConfig)
 
-	// Get the appropriate token based on tracking service (for displaying anime list)
-	if trackingService == "mal" || trackingService == "myanimelist" {
-		user.Token, err = internal.GetMALTokenFromFile(filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "mal_token.json"))
-		if err != nil {
-			internal.Log("Error reading MAL token")
-		}
-		if user.Token == "" {
-			internal.ChangeMALToken(&userCurdConfig, &user)
-		}
-		user.MalToken = user.Token
-	} else {
-		// Default to AniList
-		user.Token, err = internal.GetTokenFromFile(filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "anilist_token.json"))
-		if err != nil {
-			internal.Log("Error reading AniList token")
-		}
-		if user.Token == "" {
-			internal.ChangeToken(&userCurdConfig, &user)
+	if partyMode == "join" {
+		if err := internal.RunPartyJoin(&userCurdConfig, &anime, partyAddr); err != nil {
+			internal.ExitCurd(err)
 		}
-		user.AnilistToken = user.Token
+		internal.ExitCurd(nil)
 	}
 
-	// If dual tracking is enabled, load the secondary token as well
-	if userCurdConfig.DualTracking {
-		internal.Log("Dual tracking is enabled in config")
-		if trackingService == "mal" || trackingService == "myanimelist" {
-			// Primary is MAL, load AniList as secondary
-			internal.Log("Primary service is MAL, loading AniList token as secondary...")
-			anilistToken, err := internal.GetTokenFromFile(filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "anilist_token.json"))
-			if err != nil {
-				internal.Log(fmt.Sprintf("Dual tracking enabled but AniList token not found: %v", err))
-				internal.CurdOut("Warning: Dual tracking enabled but AniList token not found. Run './curd -change-token' to set up AniList.")
-			} else {
-				user.AnilistToken = anilistToken
-				internal.Log(fmt.Sprintf("AniList token loaded successfully (length: %d)", len(anilistToken)))
-				internal.Log("Dual tracking enabled: will update both MAL and AniList")
-			}
-		} else {
-			// Primary is AniList, load MAL as secondary
-			internal.Log("Primary service is AniList, loading MAL token as secondary...")
-			malToken, err := internal.GetMALTokenFromFile(filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "mal_token.json"))
-			if err != nil {
-				internal.Log(fmt.Sprintf("Dual tracking enabled but MAL token not found: %v", err))
-				internal.CurdOut("Warning: Dual tracking enabled but MAL token not found. Run './curd -change-mal-token' to set up MAL.")
-			} else {
-				user.MalToken = malToken
-				internal.Log(fmt.Sprintf("MAL token loaded successfully (length: %d)", len(malToken)))
-				internal.Log("Dual tracking enabled: will update both AniList and MAL")
-			}
-		}
-	} else {
-		internal.Log("Dual tracking is disabled in config")
-	}
+	// Load the active account's tokens, logging in where none are stored
+	internal.SetGlobalUser(&user)
+	internal.LoadUserTokens(&userCurdConfig, &user)
 
-	if userCurdConfig.RofiSelection {
+	if userCurdConfig.MenuBackend == "rofi" {
 		// Define a slice of file names to check and download
 		filesToCheck := []string{
 			"selectanimepreview.rasi",
@@ -228,10 +287,15 @@ func main() {
 			internal.CurdOut(fmt.Sprintf("Error checking and downloading files: %v\n", err))
 			internal.ExitCurd(err)
 		}
+
+		// Recolour the rofi themes with the configured theme
+		if err := internal.GenerateRofiThemes(&userCurdConfig, filesToCheck); err != nil {
+			internal.Log(fmt.Sprintf("Error generating rofi themes: %v", err))
+		}
 	}
 
 	// Load animes in database
-	databaseFile := filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "curd_history.txt")
+	databaseFile := internal.HistoryFilePath(&userCurdConfig)
 	databaseAnimes := internal.LocalGetAllAnime(databaseFile)
 
 	if *addNewAnime {
@@ -240,7 +304,9 @@ func main() {
 	}
 
 	internal.SetupCurd(&userCurdConfig, &anime, &user, &databaseAnimes, databaseFile)
-	trackingService = internal.GetTrackingService(&userCurdConfig)
+	// The account may have been switched from the menu
+	databaseFile = internal.HistoryFilePath(&userCurdConfig)
+	trackingService := internal.GetTrackingService(&userCurdConfig)
 
 	// Find anime in user's list using the correct ID based on tracking service
 	var idToFind string
@@ -265,6 +331,12 @@ func main() {
 
 	anime.Ep.Player.Speed = 1.0
 
+	if partyMode == "host" {
+		if _, err := internal.StartPartyHost(partyAddr, &anime); err != nil {
+			internal.ExitCurd(err)
+		}
+	}
+
 	// Get filler list concurrently
 	go func() {
 		// Get MAL ID first if not already set
@@ -326,16 +398,19 @@ func main() {
 		if !retryProvider {
 			// Get MalId and CoverImage (only if discord presence is enabled)
 			if userCurdConfig.DiscordPresence {
-				if trackingService == "mal" || trackingService == "myanimelist" {
-					if anime.MalId == 0 {
-						anime.MalId = anime.AnilistId
-					}
+				byMalID := trackingService == "mal" || trackingService == "myanimelist"
+				info, err := internal.GetAnimePresenceInfo(anime.AnilistId, byMalID)
+				if err != nil {
+					internal.Log("Error getting anime presence details: " + err.Error())
 				} else {
-					anime.MalId, anime.CoverImage, err = internal.GetAnimeIDAndImage(anime.AnilistId)
-					if err != nil {
-						internal.Log("Error getting anime ID and image: " + err.Error())
+					anime.CoverImage, anime.IsAdult, anime.Season = info.CoverImage, info.IsAdult, info.Season
+					if !byMalID {
+						anime.MalId = info.MalID
 					}
 				}
+				if byMalID && anime.MalId == 0 {
+					anime.MalId = anime.AnilistId
+				}
 				// Skip initial Discord presence - wait for MPV to provide real duration
 				// This avoids showing the default 25-minute duration before the video starts
 				internal.Log("Waiting for MPV to start to get actual video duration before showing Discord presence")
@@ -360,7 +435,7 @@ func main() {
 
 				anime.Ep.IsFiller = internal.IsEpisodeFiller(anime.FillerEpisodes, anime.Ep.Number)
 
-				if !((anime.Ep.IsFiller && userCurdConfig.SkipFiller) || (anime.Ep.IsRecap && userCurdConfig.SkipRecap)) {
+				if internal.InPlaylist(&anime) || !((anime.Ep.IsFiller && userCurdConfig.SkipFiller) || (anime.Ep.IsRecap && userCurdConfig.SkipRecap)) {
 					if anime.Ep.LastWasSkipped && !anime.Rewatching {
 						go internal.UpdateAnimeProgressDual(user.AnilistToken, user.MalToken, anime.AnilistId, anime.MalId, anime.Ep.Number-1, &userCurdConfig)
 					}
@@ -447,7 +522,7 @@ func main() {
 				internal.Log("Error getting episode data: " + err.Error())
 			} else {
 				internal.Log(anime)
-				if (anime.Ep.IsFiller && userCurdConfig.SkipFiller) || (anime.Ep.IsRecap && userCurdConfig.SkipRecap) {
+				if !internal.InPlaylist(&anime) && ((anime.Ep.IsFiller && userCurdConfig.SkipFiller) || (anime.Ep.IsRecap && userCurdConfig.SkipRecap)) {
 					if anime.Ep.IsFiller && userCurdConfig.SkipFiller {
 						internal.CurdOut(fmt.Sprint("Filler Episode, starting next episode: ", anime.Ep.Number+1))
 					} else {
@@ -478,9 +553,7 @@ func main() {
 		go func() {
 			defer wg.Done()
 			if userCurdConfig.DiscordPresence {
-				var lastKnownPauseState bool = false
 				var lastKnownPosition int = 0
-				var lastStateCheck time.Time
 				var discordPresenceInitialized bool = false // Track if Discord presence has been set with real duration
 
 				for {
@@ -524,57 +597,24 @@ func main() {
 							}
 						}
 
-						hasPlayPauseEvent := currentPauseState != lastKnownPauseState
-
-						// Determine if we should update Discord presence
-						shouldUpdate := false
-
-						// Force update every 30 seconds for Discord keep-alive
-						if lastStateCheck.IsZero() || time.Since(lastStateCheck) >= 30*time.Second {
-							shouldUpdate = true
-						}
-
-						// Update on pause state change
-						if hasPlayPauseEvent {
-							shouldUpdate = true
-						}
-
-						// Update on seek events
-						if hasSeekEvent {
-							shouldUpdate = true
-						}
-
-						if shouldUpdate {
-							// Only update Discord if we have real duration OR if presence was already initialized
-							totalDuration := anime.Ep.Duration
-							if totalDuration == 0 {
-								// Skip Discord updates until we have real duration from MPV
-								if !discordPresenceInitialized {
-									lastKnownPauseState = currentPauseState
-									lastKnownPosition = currentPos
-									lastStateCheck = time.Now()
-									time.Sleep(2 * time.Second)
-									continue
-								}
-								totalDuration = currentPos + 1 // Small duration to avoid divide by zero
-							} else {
-								discordPresenceInitialized = true // Mark as initialized once we have real duration
-							}
-
-							// Force update on seek events to bypass Discord's internal filtering
-							var presenceErr error
-							if hasSeekEvent {
-								presenceErr = internal.DiscordPresenceWithForce(anime, currentPauseState, currentPos, totalDuration, userCurdConfig.DiscordClientId, true)
-							} else {
-								presenceErr = internal.DiscordPresence(anime, currentPauseState, currentPos, totalDuration, userCurdConfig.DiscordClientId)
-							}
-
-							if presenceErr != nil {
-								internal.Log("Error setting Discord presence: " + presenceErr.Error())
+						// Only update Discord if we have real duration OR if presence was already initialized
+						totalDuration := anime.Ep.Duration
+						if totalDuration == 0 {
+							// Skip Discord updates until we have real duration from MPV
+							if !discordPresenceInitialized {
+								lastKnownPosition = currentPos
+								time.Sleep(2 * time.Second)
+								continue
 							}
+							totalDuration = currentPos + 1 // Small duration to avoid divide by zero
+						} else {
+							discordPresenceInitialized = true // Mark as initialized once we have real duration
+						}
 
-							lastKnownPauseState = currentPauseState
-							lastStateCheck = time.Now()
+						// The presence limiter decides whether this goes out; seeks
+						// force an update so the progress bar moves with them.
+						if presenceErr := internal.DiscordPresenceWithForce(anime, currentPauseState, currentPos, totalDuration, userCurdConfig.DiscordClientId, hasSeekEvent); presenceErr != nil {
+							internal.Log("Error setting Discord presence: " + presenceErr.Error())
 						}
 
 						// Always update position for next comparison
@@ -642,6 +682,8 @@ func main() {
 			}
 		}()
 
+		failover := internal.NewPlaybackFailover(&userCurdConfig, &anime)
+
 		wg.Add(1)
 		// Playback monitoring goroutine
 		go func() {
@@ -654,6 +696,19 @@ func main() {
 				default:
 					time.Sleep(1000 * time.Millisecond) // Checked every second
 
+					// ── Move to another source if the stream died ─────────────────
+					if reason, failed := failover.Check(); failed {
+						if err := failover.Recover(reason); err == nil {
+							continue
+						} else {
+							internal.Log("Stream failover exhausted: " + err.Error())
+							// Close mpv so the premature-close path offers another provider.
+							if err2 := internal.ExitMPV(anime.Ep.Player.SocketPath); err2 != nil {
+								internal.Log("Error closing MPV after failover: " + err2.Error())
+							}
+						}
+					}
+
 					timePos, err := internal.MPVSendCommand(anime.Ep.Player.SocketPath, []interface{}{"get_property", "time-pos"})
 
 					// ── Check if mpv is still reachable ──────────────────────────────
@@ -669,6 +724,7 @@ func main() {
 							isCompleted := int(percentageWatched) >= userCurdConfig.PercentageToMarkComplete
 							if isCompleted {
 								anime.Ep.IsCompleted = true
+								internal.FireAnimeHook(hooks.EpisodeComplete, &anime)
 								internal.LocalUpdateAnime(databaseFile, anime.AnilistId, anime.ProviderId, anime.Ep.Number, anime.Ep.Player.PlaybackTime, internal.ConvertSecondsToMinutes(anime.Ep.Duration), internal.GetAnimeName(anime), anime.ProviderName)
 
 								if !anime.Rewatching {
@@ -686,16 +742,13 @@ func main() {
 								} else if userCurdConfig.RofiSelection {
 									if internal.NextEpisodePromptRofi(&userCurdConfig) {
 										internal.StartNextEpisode(&anime, &userCurdConfig, databaseFile, &user)
-									} else {
-										if anime.TotalEpisodes > 0 && anime.Ep.Number == anime.TotalEpisodes && !anime.IsAiring {
-											internal.HandleLastEpisodeCompletion(&userCurdConfig, &anime, &user)
-										}
+									} else if !internal.OfferSequelAfterCompletion(&userCurdConfig, &anime, &user, databaseFile) {
 										internal.ExitCurd(nil)
 									}
 									retryProviderCh <- false
 								} else {
 									options := []internal.SelectionOption{
-										{Key: "yes", Label: fmt.Sprintf("Continue to next episode (%d)", anime.Ep.Number+1)},
+										{Key: "yes", Label: internal.NextEpisodeLabel(&anime)},
 									}
 									internal.CurdOut(fmt.Sprintf("Episode %d finished!", anime.Ep.Number))
 									sel, _ := internal.DynamicSelect(options)
@@ -703,10 +756,10 @@ func main() {
 										internal.StartNextEpisode(&anime, &userCurdConfig, databaseFile, &user)
 										retryProviderCh <- false
 									} else {
-										if anime.TotalEpisodes > 0 && anime.Ep.Number == anime.TotalEpisodes && !anime.IsAiring {
-											internal.HandleLastEpisodeCompletion(&userCurdConfig, &anime, &user)
+										if !internal.OfferSequelAfterCompletion(&userCurdConfig, &anime, &user, databaseFile) {
+											internal.ExitCurd(nil)
 										}
-										internal.ExitCurd(nil)
+										retryProviderCh <- false
 									}
 								}
 							} else {
@@ -734,6 +787,7 @@ func main() {
 
 							if !anime.Ep.Started {
 								anime.Ep.Started = true
+								internal.FireAnimeHook(hooks.EpisodeStart, &anime)
 								if userCurdConfig.SaveMpvSpeed {
 									_, err2 := internal.MPVSendCommand(anime.Ep.Player.SocketPath, []interface{}{"set_property", "speed", anime.Ep.Player.Speed})
 									if err2 != nil {
@@ -751,6 +805,13 @@ func main() {
 							}
 
 							anime.Ep.Player.PlaybackTime = int(currentPos + 0.5)
+							if internal.ActivityHooksWant(hooks.Pause, hooks.Resume) {
+								if paused, err2 := internal.MPVSendCommand(anime.Ep.Player.SocketPath, []interface{}{"get_property", "pause"}); err2 == nil {
+									if value, ok := paused.(bool); ok {
+										internal.ReportPlayerPause(&anime, value)
+									}
+								}
+							}
 							internal.LocalUpdateAnime(databaseFile, anime.AnilistId, anime.ProviderId, anime.Ep.Number, anime.Ep.Player.PlaybackTime, internal.ConvertSecondsToMinutes(anime.Ep.Duration), internal.GetAnimeName(anime), anime.ProviderName)
 						}
 					}
@@ -769,6 +830,7 @@ func main() {
 								internal.Log(fmt.Sprintf("Playback ended at EOF. Watched: %.1f%%, Required: %d%%", percentageWatched, userCurdConfig.PercentageToMarkComplete))
 
 								anime.Ep.IsCompleted = true
+								internal.FireAnimeHook(hooks.EpisodeComplete, &anime)
 
 								// Update local DB
 								internal.LocalUpdateAnime(databaseFile, anime.AnilistId, anime.ProviderId, anime.Ep.Number, anime.Ep.Player.PlaybackTime, internal.ConvertSecondsToMinutes(anime.Ep.Duration), internal.GetAnimeName(anime), anime.ProviderName)
@@ -794,16 +856,13 @@ func main() {
 								} else if userCurdConfig.RofiSelection {
 									if internal.NextEpisodePromptRofi(&userCurdConfig) {
 										internal.StartNextEpisode(&anime, &userCurdConfig, databaseFile, &user)
-									} else {
-										if anime.TotalEpisodes > 0 && anime.Ep.Number == anime.TotalEpisodes && !anime.IsAiring {
-											internal.HandleLastEpisodeCompletion(&userCurdConfig, &anime, &user)
-										}
+									} else if !internal.OfferSequelAfterCompletion(&userCurdConfig, &anime, &user, databaseFile) {
 										internal.ExitCurd(nil)
 									}
 									retryProviderCh <- false
 								} else {
 									options := []internal.SelectionOption{
-										{Key: "yes", Label: fmt.Sprintf("Continue to next episode (%d)", anime.Ep.Number+1)},
+										{Key: "yes", Label: internal.NextEpisodeLabel(&anime)},
 									}
 									internal.CurdOut(fmt.Sprintf("Episode %d finished!", anime.Ep.Number))
 									sel, _ := internal.DynamicSelect(options)
@@ -811,10 +870,10 @@ func main() {
 										internal.StartNextEpisode(&anime, &userCurdConfig, databaseFile, &user)
 										retryProviderCh <- false
 									} else {
-										if anime.TotalEpisodes > 0 && anime.Ep.Number == anime.TotalEpisodes && !anime.IsAiring {
-											internal.HandleLastEpisodeCompletion(&userCurdConfig, &anime, &user)
+										if !internal.OfferSequelAfterCompletion(&userCurdConfig, &anime, &user, databaseFile) {
+											internal.ExitCurd(nil)
 										}
-										internal.ExitCurd(nil)
+										retryProviderCh <- false
 									}
 								}
 
@@ -857,6 +916,7 @@ func main() {
 
 		wg.Wait()
 		wg = sync.WaitGroup{}
+		internal.EndPlayback()
 
 		// Read the retry signal
 		retryProvider = false
@@ -889,7 +949,8 @@ func main() {
 				} else if !updatedAnime.IsAiring {
 					anime.Ep.Number = anime.Ep.Number - 1
 					internal.CurdOut("Completed anime.")
-					err = internal.RateAnime(user.Token, anime.AnilistId)
+					internal.FireAnimeHook(hooks.ShowComplete, &anime)
+					err = internal.RateAnimeDual(user.AnilistToken, user.MalToken, anime.AnilistId, anime.MalId, &userCurdConfig)
 					if err != nil {
 						internal.Log("Error rating anime: " + err.Error())
 						internal.CurdOut("Error rating anime: " + err.Error())
@@ -0,0 +1,464 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/wraient/curd/internal/accounts"
+)
+
+const (
+	historyFile      = "curd_history.txt"
+	lastWatchedFile  = "curd_id"
+	accountConfigKey = "Account"
+)
+
+// accountBase remembers the tracker settings from curd.conf, which account
+// profiles are applied on top of, so switching back restores them.
+var accountBase struct {
+	sync.Mutex
+	saved           bool
+	trackingService string
+	dualTracking    bool
+}
+
+// trackerGuard holds whether the active account was confirmed for this
+// session and whether the user chose to keep the trackers untouched.
+var trackerGuard struct {
+	sync.Mutex
+	confirmed bool
+	paused    bool
+	announced bool
+}
+
+var errTrackerUpdatesPaused = errors.New("tracker updates are off for this session")
+
+func accountStorageRoot(config *CurdConfig) string {
+	storagePath := ""
+	if config != nil {
+		storagePath = config.StoragePath
+	}
+	if storagePath == "" {
+		storagePath = os.ExpandEnv("${HOME}/.local/share/curd")
+	}
+	return os.ExpandEnv(storagePath)
+}
+
+// activeAccount returns the name of the account in use.
+func activeAccount(config *CurdConfig) string {
+	if config == nil || accounts.IsDefault(config.Account) {
+		return accounts.Default
+	}
+	return config.Account
+}
+
+// accountDataDir is where the active account keeps its tokens and history.
+func accountDataDir(config *CurdConfig) string {
+	return accounts.Dir(accountStorageRoot(config), activeAccount(config))
+}
+
+// HistoryFilePath returns the active account's local watch history.
+func HistoryFilePath(config *CurdConfig) string {
+	return filepath.Join(accountDataDir(config), historyFile)
+}
+
+func lastWatchedIDPath(config *CurdConfig) string {
+	return filepath.Join(accountDataDir(config), lastWatchedFile)
+}
+
+// UseAccount makes name the active account for this session and applies its
+// tracker selection over the one in curd.conf.
+func UseAccount(config *CurdConfig, name string) error {
+	name = strings.TrimSpace(name)
+	profile, err := accounts.Load(accountStorageRoot(config), name)
+	if err != nil {
+		if errors.Is(err, accounts.ErrNotFound) {
+			return fmt.Errorf("no account named %q; create it with 'curd account add %s'", name, name)
+		}
+		return err
+	}
+
+	accountBase.Lock()
+	if !accountBase.saved {
+		accountBase.saved = true
+		accountBase.trackingService = config.TrackingService
+		accountBase.dualTracking = config.DualTracking
+	}
+	config.TrackingService = accountBase.trackingService
+	config.DualTracking = accountBase.dualTracking
+	accountBase.Unlock()
+
+	if profile.TrackingService != "" {
+		config.TrackingService = profile.TrackingService
+	}
+	if profile.DualTracking != nil {
+		config.DualTracking = *profile.DualTracking
+	}
+	config.Account = name
+	if accounts.IsDefault(name) {
+		config.Account = ""
+	}
+	resetTrackerGuard()
+	Log(fmt.Sprintf("Using account %s (tracking: %s, dual: %v)", activeAccount(config), GetTrackingService(config), config.DualTracking))
+	return nil
+}
+
+// persistActiveAccount makes name the account curd starts with.
+func persistActiveAccount(name string) error {
+	if GlobalConfigPath == "" {
+		return nil
+	}
+	if accounts.IsDefault(name) {
+		name = ""
+	}
+	m, err := LoadConfigFromFile(GlobalConfigPath)
+	if err != nil {
+		return err
+	}
+	m[accountConfigKey] = name
+	return SaveConfigToFile(GlobalConfigPath, m)
+}
+
+// LoadUserTokens reads the active account's tracker tokens into user and
+// starts a login when the primary tracker has none.
+func LoadUserTokens(config *CurdConfig, user *User) {
+	var err error
+	user.Token, user.AnilistToken, user.MalToken = "", "", ""
+	user.Id, user.Username = 0, ""
+
+	if GetTrackingService(config) == "mal" {
+		user.Token, err = GetMALToken(config)
+		if err != nil {
+			Log(fmt.Sprintf("Error reading MAL token: %v", err))
+		}
+		if user.Token == "" {
+			ChangeMALToken(config, user)
+		}
+		user.MalToken = user.Token
+	} else {
+		user.Token, err = GetAnilistToken(config)
+		if err != nil {
+			Log(fmt.Sprintf("Error reading AniList token: %v", err))
+		}
+		if user.Token == "" {
+			ChangeToken(config, user)
+		}
+		user.AnilistToken = user.Token
+	}
+
+	if !config.DualTracking {
+		Log("Dual tracking is disabled in config")
+		return
+	}
+	Log("Dual tracking is enabled in config")
+	if GetTrackingService(config) == "mal" {
+		Log("Primary service is MAL, loading AniList token as secondary...")
+		anilistToken, err := GetAnilistToken(config)
+		if err != nil {
+			Log(fmt.Sprintf("Dual tracking enabled but AniList token not found: %v", err))
+			CurdOut("Warning: Dual tracking enabled but AniList token not found. Run './curd -change-token' to set up AniList.")
+			return
+		}
+		user.AnilistToken = anilistToken
+		Log(fmt.Sprintf("AniList token loaded successfully (length: %d)", len(anilistToken)))
+		Log("Dual tracking enabled: will update both MAL and AniList")
+		return
+	}
+	Log("Primary service is AniList, loading MAL token as secondary...")
+	malToken, err := GetMALToken(config)
+	if err != nil {
+		Log(fmt.Sprintf("Dual tracking enabled but MAL token not found: %v", err))
+		CurdOut("Warning: Dual tracking enabled but MAL token not found. Run './curd -change-mal-token' to set up MAL.")
+		return
+	}
+	user.MalToken = malToken
+	Log(fmt.Sprintf("MAL token loaded successfully (length: %d)", len(malToken)))
+	Log("Dual tracking enabled: will update both AniList and MAL")
+}
+
+// hasNamedAccounts reports whether anyone has added an account besides the
+// default one, which is when the switcher and the confirmation show up.
+func hasNamedAccounts(config *CurdConfig) bool {
+	names, err := accounts.List(accountStorageRoot(config))
+	return err == nil && len(names) > 1
+}
+
+// switchAccountMenu lets the user pick another account and loads its tokens
+// and history. It reports whether the account changed.
+func switchAccountMenu(config *CurdConfig, user *User, databaseAnimes *[]Anime) bool {
+	names, err := accounts.List(accountStorageRoot(config))
+	if err != nil {
+		CurdOut(fmt.Sprintf("Failed to list accounts: %v", err))
+		return false
+	}
+	current := activeAccount(config)
+	options := []SelectionOption{{Key: "back", Label: "<- Back"}}
+	for _, name := range names {
+		label := name
+		if name == current {
+			label += " (active)"
+		}
+		options = append(options, SelectionOption{Key: name, Label: label})
+	}
+
+	ClearScreen()
+	CurdOut("Switch to which account?")
+	selected, err := DynamicSelectOrdered(options)
+	if err != nil {
+		Log(fmt.Sprintf("Failed to select account: %v", err))
+		return false
+	}
+	if selected.Key == "-1" {
+		ExitCurd(nil)
+	}
+	if selected.Key == "back" || selected.Key == current {
+		return false
+	}
+
+	if err := UseAccount(config, selected.Key); err != nil {
+		CurdOut(fmt.Sprintf("Failed to switch account: %v", err))
+		return false
+	}
+	if err := persistActiveAccount(selected.Key); err != nil {
+		Log(fmt.Sprintf("Failed to remember the active account: %v", err))
+	}
+	LoadUserTokens(config, user)
+	*databaseAnimes = LocalGetAllAnime(HistoryFilePath(config))
+	// Picking the account just now is confirmation enough.
+	trackerGuard.Lock()
+	trackerGuard.confirmed = true
+	trackerGuard.Unlock()
+	CurdOut(fmt.Sprintf("Switched to account %s", selected.Key))
+	return true
+}
+
+func resetTrackerGuard() {
+	trackerGuard.Lock()
+	trackerGuard.confirmed, trackerGuard.paused, trackerGuard.announced = false, false, false
+	trackerGuard.Unlock()
+}
+
+// confirmActiveAccount asks, once per session on machines with several
+// accounts, whether the tracker lists about to be changed are the right
+// ones. It reports whether the user asked to switch accounts instead.
+func confirmActiveAccount(config *CurdConfig, user *User) (switchAccount bool) {
+	trackerGuard.Lock()
+	confirmed := trackerGuard.confirmed
+	trackerGuard.Unlock()
+	if confirmed {
+		return false
+	}
+	if !config.ConfirmAccount || !hasNamedAccounts(config) {
+		trackerGuard.Lock()
+		trackerGuard.confirmed = true
+		trackerGuard.Unlock()
+		return false
+	}
+
+	active := fmt.Sprintf("%s (%s on %s)", activeAccount(config), user.Username, GetServiceName(config))
+	ClearScreen()
+	CurdOut("Active account: " + active)
+	selected, err := DynamicSelectOrdered([]SelectionOption{
+		{Key: "continue", Label: "Continue as " + active},
+		{Key: "switch", Label: "Switch account"},
+		{Key: "pause", Label: "Continue without updating trackers"},
+	})
+	if err != nil || selected.Key == "-1" {
+		ExitCurd(err)
+	}
+	if selected.Key == "switch" {
+		return true
+	}
+
+	trackerGuard.Lock()
+	trackerGuard.confirmed = true
+	trackerGuard.paused = selected.Key == "pause"
+	trackerGuard.Unlock()
+	if selected.Key == "pause" {
+		CurdOut("Tracker updates are off for this session; progress is kept in the local history only.")
+	}
+	return false
+}
+
+// guardTrackerUpdate is called before anything is sent to a tracker. It
+// names the account being updated the first time, and refuses when the
+// user chose to leave the trackers alone this session.
+func guardTrackerUpdate(config *CurdConfig) error {
+	trackerGuard.Lock()
+	defer trackerGuard.Unlock()
+	if trackerGuard.paused {
+		return errTrackerUpdatesPaused
+	}
+	if !trackerGuard.announced {
+		trackerGuard.announced = true
+		who := activeAccount(config)
+		if user := GetGlobalUser(); user != nil && user.Username != "" {
+			who = fmt.Sprintf("%s (%s)", who, user.Username)
+		}
+		services := GetServiceName(config)
+		if config.DualTracking {
+			services = "AniList and MyAnimeList"
+		}
+		CurdOut(fmt.Sprintf("Updating %s as account %s", services, who))
+	}
+	return nil
+}
+
+const accountCommandUsage = `Usage: curd account <command>
+
+  list                                     List accounts; * marks the active one
+  add <name> [-tracker anilist|mal] [-dual true|false]
+                                           Add an account with its own tokens and history
+  use <name>                               Make <name> the account curd starts with
+  remove <name>                            Delete an account with its tokens and history
+
+Run 'curd -account <name>' to use an account once, and log it in the first time.`
+
+// RunAccountCommand runs "curd account" with args.
+func RunAccountCommand(config *CurdConfig, args []string) error {
+	return runAccountCommand(config, args, os.Stdout)
+}
+
+func runAccountCommand(config *CurdConfig, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	root := accountStorageRoot(config)
+	switch args[0] {
+	case "list":
+		names, err := accounts.List(root)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "\tACCOUNT\tTRACKER\tDUAL")
+		for _, name := range names {
+			profile, err := accounts.Load(root, name)
+			if err != nil {
+				fmt.Fprintf(w, "\t%s\tunreadable: %v\t\n", name, err)
+				continue
+			}
+			tracker, dual := config.TrackingService, config.DualTracking
+			if profile.TrackingService != "" {
+				tracker = profile.TrackingService
+			}
+			if profile.DualTracking != nil {
+				dual = *profile.DualTracking
+			}
+			marker := ""
+			if name == activeAccount(config) {
+				marker = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%v\n", marker, name, tracker, dual)
+		}
+		return w.Flush()
+	case "add":
+		if len(args) < 2 {
+			return fmt.Errorf("missing account name\n%s", accountCommandUsage)
+		}
+		profile, err := parseAccountProfile(args[2:])
+		if err != nil {
+			return err
+		}
+		if err := accounts.Create(root, args[1], profile); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Added account %s. Run 'curd -account %s' to log it in.\n", args[1], args[1])
+		return nil
+	case "use":
+		if len(args) < 2 {
+			return fmt.Errorf("missing account name\n%s", accountCommandUsage)
+		}
+		if err := UseAccount(config, args[1]); err != nil {
+			return err
+		}
+		if err := persistActiveAccount(args[1]); err != nil {
+			return fmt.Errorf("failed to save the active account: %w", err)
+		}
+		fmt.Fprintf(out, "curd now starts with account %s\n", activeAccount(config))
+		return nil
+	case "remove":
+		if len(args) < 2 {
+			return fmt.Errorf("missing account name\n%s", accountCommandUsage)
+		}
+		name := args[1]
+		if _, err := accounts.Load(root, name); err != nil {
+			return err
+		}
+		forgetAccountCredentials(config, name)
+		if err := accounts.Remove(root, name); err != nil {
+			return err
+		}
+		if name == activeAccount(config) {
+			if err := persistActiveAccount(accounts.Default); err != nil {
+				Log(fmt.Sprintf("Failed to reset the active account: %v", err))
+			}
+		}
+		fmt.Fprintf(out, "Removed account %s\n", name)
+		return nil
+	case "help", "-h", "--help":
+		fmt.Fprintln(out, accountCommandUsage)
+		return nil
+	}
+	return fmt.Errorf("unknown account command %q\n%s", args[0], accountCommandUsage)
+}
+
+func parseAccountProfile(args []string) (accounts.Profile, error) {
+	var profile accounts.Profile
+	for i := 0; i < len(args); i++ {
+		flagName, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !hasValue {
+			if i+1 >= len(args) {
+				return profile, fmt.Errorf("missing value for %s", args[i])
+			}
+			i++
+			value = args[i]
+		}
+		switch flagName {
+		case "tracker":
+			switch strings.ToLower(value) {
+			case "anilist":
+				profile.TrackingService = "anilist"
+			case "mal", "myanimelist":
+				profile.TrackingService = "mal"
+			default:
+				return profile, fmt.Errorf("unknown tracker %q (want anilist or mal)", value)
+			}
+		case "dual":
+			dual, err := strconv.ParseBool(value)
+			if err != nil {
+				return profile, fmt.Errorf("invalid -dual value %q: %w", value, err)
+			}
+			profile.DualTracking = &dual
+		default:
+			return profile, fmt.Errorf("unknown option %s\n%s", args[i], accountCommandUsage)
+		}
+	}
+	return profile, nil
+}
+
+// forgetAccountCredentials drops name's tokens from a keyring, where they
+// would otherwise outlive the account directory.
+func forgetAccountCredentials(config *CurdConfig, name string) {
+	if !strings.EqualFold(strings.TrimSpace(config.CredentialStore), "keyring") {
+		return
+	}
+	scoped := *config
+	scoped.Account = name
+	store, err := credentialStore(&scoped)
+	if err != nil {
+		return
+	}
+	for _, key := range []string{anilistTokenKey, malTokenKey} {
+		if err := store.Delete(key); err != nil {
+			Log(fmt.Sprintf("Failed to delete %s of account %s: %v", key, name, err))
+		}
+	}
+}
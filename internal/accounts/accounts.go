@@ -0,0 +1,177 @@
+// Package accounts keeps the named curd accounts people sharing a machine
+// switch between.
+//
+// Every account has a directory of its own holding its tracker tokens, its
+// local watch history and a profile.json with its tracker selection:
+//
+//	<storage>/accounts/alice/profile.json
+//	<storage>/accounts/alice/anilist_token.json
+//	<storage>/accounts/alice/curd_history.txt
+//
+// The default account is the storage directory itself, where curd kept
+// everything before accounts existed, so upgrading moves nothing.
+package accounts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Default names the account that lives at the top of the storage directory.
+const Default = "default"
+
+const (
+	accountsDir = "accounts"
+	profileFile = "profile.json"
+)
+
+// ErrNotFound is returned for an account that has not been created.
+var ErrNotFound = errors.New("account not found")
+
+// Profile is an account's own tracker selection. Empty fields fall back to
+// the values in curd.conf.
+type Profile struct {
+	TrackingService string `json:"tracking_service,omitempty"`
+	DualTracking    *bool  `json:"dual_tracking,omitempty"`
+}
+
+// IsDefault reports whether name refers to the default account.
+func IsDefault(name string) bool {
+	name = strings.TrimSpace(name)
+	return name == "" || strings.EqualFold(name, Default)
+}
+
+// ValidName checks that name can be used as a directory name everywhere:
+// letters, digits, '-', '_' and '.', not starting with a dot.
+func ValidName(name string) error {
+	if name == "" {
+		return fmt.Errorf("account name is empty")
+	}
+	if len(name) > 64 {
+		return fmt.Errorf("account name %q is longer than 64 characters", name)
+	}
+	if strings.HasPrefix(name, ".") {
+		return fmt.Errorf("account name %q must not start with a dot", name)
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return fmt.Errorf("account name %q may only contain letters, digits, '-', '_' and '.'", name)
+		}
+	}
+	return nil
+}
+
+// Dir returns where name keeps its tokens and history.
+func Dir(storagePath, name string) string {
+	if IsDefault(name) {
+		return storagePath
+	}
+	return filepath.Join(storagePath, accountsDir, name)
+}
+
+// List returns the default account followed by the named ones, sorted.
+func List(storagePath string) ([]string, error) {
+	names := []string{Default}
+	entries, err := os.ReadDir(filepath.Join(storagePath, accountsDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return names, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+	var named []string
+	for _, entry := range entries {
+		if entry.IsDir() && ValidName(entry.Name()) == nil && !IsDefault(entry.Name()) {
+			named = append(named, entry.Name())
+		}
+	}
+	sort.Strings(named)
+	return append(names, named...), nil
+}
+
+// Create adds a named account with profile.
+func Create(storagePath, name string, profile Profile) error {
+	if IsDefault(name) {
+		return fmt.Errorf("the %s account always exists", Default)
+	}
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	dir := Dir(storagePath, name)
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("account %q already exists", name)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create account directory: %w", err)
+	}
+	return Save(storagePath, name, profile)
+}
+
+// Load returns name's profile. The default account's profile is always
+// empty, since curd.conf is its profile.
+func Load(storagePath, name string) (Profile, error) {
+	var profile Profile
+	if IsDefault(name) {
+		return profile, nil
+	}
+	if err := ValidName(name); err != nil {
+		return profile, err
+	}
+	dir := Dir(storagePath, name)
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return profile, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, profileFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return profile, nil
+	}
+	if err != nil {
+		return profile, fmt.Errorf("failed to read profile of %s: %w", name, err)
+	}
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return profile, fmt.Errorf("failed to parse profile of %s: %w", name, err)
+	}
+	return profile, nil
+}
+
+// Save replaces name's profile.
+func Save(storagePath, name string, profile Profile) error {
+	if IsDefault(name) {
+		return fmt.Errorf("the %s account is configured in curd.conf", Default)
+	}
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(Dir(storagePath, name), profileFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to save profile of %s: %w", name, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Remove deletes a named account together with its tokens and history.
+func Remove(storagePath, name string) error {
+	if IsDefault(name) {
+		return fmt.Errorf("the %s account cannot be removed", Default)
+	}
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	dir := Dir(storagePath, name)
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return os.RemoveAll(dir)
+}
@@ -0,0 +1,77 @@
+package accounts
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDefaultAccountUsesStorageRoot(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"", "default", "Default"} {
+		if got := Dir(root, name); got != root {
+			t.Fatalf("Dir(%q) = %q, want the storage root", name, got)
+		}
+	}
+	if got := Dir(root, "alice"); got != filepath.Join(root, "accounts", "alice") {
+		t.Fatalf("unexpected account dir %q", got)
+	}
+	names, err := List(root)
+	if err != nil || !reflect.DeepEqual(names, []string{Default}) {
+		t.Fatalf("expected only the default account, got %v %v", names, err)
+	}
+	if err := Remove(root, Default); err == nil {
+		t.Fatal("expected the default account to be kept")
+	}
+}
+
+func TestCreateLoadRemove(t *testing.T) {
+	root := t.TempDir()
+	dual := false
+	if err := Create(root, "bob", Profile{TrackingService: "anilist", DualTracking: &dual}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Create(root, "alice", Profile{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Create(root, "alice", Profile{}); err == nil {
+		t.Fatal("expected a duplicate account to be refused")
+	}
+
+	names, err := List(root)
+	if err != nil || !reflect.DeepEqual(names, []string{Default, "alice", "bob"}) {
+		t.Fatalf("unexpected accounts %v %v", names, err)
+	}
+
+	profile, err := Load(root, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.TrackingService != "anilist" || profile.DualTracking == nil || *profile.DualTracking {
+		t.Fatalf("unexpected profile %+v", profile)
+	}
+	if profile, err := Load(root, "alice"); err != nil || profile.DualTracking != nil {
+		t.Fatalf("expected an empty profile, got %+v %v", profile, err)
+	}
+
+	if err := Remove(root, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(root, "bob"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after removal, got %v", err)
+	}
+}
+
+func TestValidName(t *testing.T) {
+	for _, name := range []string{"alice", "Bob_2", "kid.one"} {
+		if err := ValidName(name); err != nil {
+			t.Errorf("ValidName(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "../etc", ".hidden", "a b", "a/b"} {
+		if err := ValidName(name); err == nil {
+			t.Errorf("ValidName(%q) accepted an unsafe name", name)
+		}
+	}
+}
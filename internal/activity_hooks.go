@@ -0,0 +1,189 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wraient/curd/internal/hooks"
+)
+
+// hookFlushTimeout is how long curd waits on exit for hooks still running.
+const hookFlushTimeout = 5 * time.Second
+
+var (
+	activityHooksMu   sync.Mutex
+	activityHooks     *hooks.Dispatcher
+	activityHooksPath string
+
+	// hookFired remembers the last start and completion events per kind, so
+	// the several code paths that finish an episode report it only once.
+	hookFired = map[string]string{}
+	// hookPaused is the player's pause state last reported, for telling a
+	// pause from a resume; nil until the episode's first report.
+	hookPaused *bool
+)
+
+func hooksFilePath(config *CurdConfig) string {
+	if config == nil || strings.TrimSpace(config.HooksFile) == "" {
+		return ""
+	}
+	return os.ExpandEnv(strings.TrimSpace(config.HooksFile))
+}
+
+// sharedActivityHooks returns the dispatcher for the configured hooks file,
+// or nil when there are no hooks.
+func sharedActivityHooks() *hooks.Dispatcher {
+	path := hooksFilePath(GetGlobalConfig())
+	activityHooksMu.Lock()
+	defer activityHooksMu.Unlock()
+	if path == activityHooksPath {
+		return activityHooks
+	}
+	activityHooksPath, activityHooks = path, nil
+	if path == "" {
+		return nil
+	}
+	list, err := hooks.Load(path)
+	if err != nil {
+		Log(fmt.Sprintf("Activity hooks disabled: %v", err))
+		CurdOut(fmt.Sprintf("Activity hooks disabled: %v", err))
+		return nil
+	}
+	if len(list) > 0 {
+		Log(fmt.Sprintf("Loaded %d activity hooks from %s", len(list), path))
+		activityHooks = hooks.NewDispatcher(list, sharedHTTPClient, func(line string) { Log(line) })
+	}
+	return activityHooks
+}
+
+// ActivityHooksWant reports whether a hook listens for any of eventTypes.
+func ActivityHooksWant(eventTypes ...string) bool {
+	return sharedActivityHooks().Wants(eventTypes...)
+}
+
+func closeActivityHooks() {
+	activityHooksMu.Lock()
+	dispatcher := activityHooks
+	activityHooksMu.Unlock()
+	if !dispatcher.Wait(hookFlushTimeout) {
+		Log("Gave up waiting for activity hooks")
+	}
+}
+
+// animeHookEvent describes anime for a hook.
+func animeHookEvent(eventType string, anime *Anime) hooks.Event {
+	config := GetGlobalConfig()
+	event := hooks.Event{Type: eventType, Account: activeAccount(config)}
+	if anime == nil {
+		return event
+	}
+	event.AnilistID = anime.AnilistId
+	event.MalID = anime.MalId
+	event.Title = GetAnimeName(*anime)
+	event.Episode = anime.Ep.Number
+	event.TotalEpisodes = anime.TotalEpisodes
+	event.EpisodeTitle = anime.Ep.Title.English
+	if event.EpisodeTitle == "" {
+		event.EpisodeTitle = anime.Ep.Title.Romaji
+	}
+	event.Provider = anime.ProviderName
+	event.Position = anime.Ep.Player.PlaybackTime
+	event.Duration = anime.Ep.Duration
+	if config != nil {
+		event.Mode = config.SubOrDub
+	}
+	return event
+}
+
+// FireAnimeHook runs the hooks for a playback event of anime. Episode starts
+// and completions fire once per episode, and show completions once per show.
+func FireAnimeHook(eventType string, anime *Anime) {
+	dispatcher := sharedActivityHooks()
+	if dispatcher == nil || anime == nil {
+		return
+	}
+	var key string
+	switch eventType {
+	case hooks.EpisodeStart, hooks.EpisodeComplete:
+		key = fmt.Sprintf("%d/%d", anime.AnilistId, anime.Ep.Number)
+	case hooks.ShowComplete:
+		key = fmt.Sprintf("%d", anime.AnilistId)
+	}
+	if key != "" {
+		activityHooksMu.Lock()
+		if hookFired[eventType] == key {
+			activityHooksMu.Unlock()
+			return
+		}
+		hookFired[eventType] = key
+		if eventType == hooks.EpisodeStart {
+			hookPaused = nil
+		}
+		activityHooksMu.Unlock()
+	}
+	dispatcher.Fire(animeHookEvent(eventType, anime))
+}
+
+// ReportPlayerPause fires the pause and resume hooks when paused differs
+// from the state last reported for the episode.
+func ReportPlayerPause(anime *Anime, paused bool) {
+	activityHooksMu.Lock()
+	previous := hookPaused
+	hookPaused = &paused
+	activityHooksMu.Unlock()
+	if previous == nil || *previous == paused {
+		return
+	}
+	if paused {
+		FireAnimeHook(hooks.Pause, anime)
+	} else {
+		FireAnimeHook(hooks.Resume, anime)
+	}
+}
+
+// fireServiceTrackerHook reports an update sent to the configured tracker
+// alone. mediaID is an id of that tracker: a MAL id in MAL mode, else an
+// AniList id.
+func fireServiceTrackerHook(config *CurdConfig, action string, mediaID, progress int, status string, err error) {
+	if GetTrackingService(config) == "mal" {
+		fireTrackerHook(config, false, action, 0, mediaID, progress, status, err)
+		return
+	}
+	fireTrackerHook(config, false, action, mediaID, 0, progress, status, err)
+}
+
+// fireTrackerHook reports a tracker update that was just sent, failed or
+// not, to the hooks and the dashboard's status bar. dual is set for the
+// updates that go to both trackers under DualTracking.
+func fireTrackerHook(config *CurdConfig, dual bool, action string, anilistID, malID, progress int, status string, err error) {
+	noteTrackerSync(err)
+	dispatcher := sharedActivityHooks()
+	if dispatcher == nil || !dispatcher.Wants(hooks.TrackerUpdate) {
+		return
+	}
+	var anime *Anime
+	if current := GetGlobalAnime(); current != nil &&
+		((anilistID != 0 && current.AnilistId == anilistID) || (malID != 0 && current.MalId == malID)) {
+		anime = current
+	}
+	event := animeHookEvent(hooks.TrackerUpdate, anime)
+	if anilistID != 0 {
+		event.AnilistID = anilistID
+	}
+	if malID != 0 {
+		event.MalID = malID
+	}
+
+	services := []string{GetTrackingService(config)}
+	if dual && config.DualTracking {
+		services = []string{"anilist", "mal"}
+	}
+	event.Tracker = &hooks.Tracker{Services: services, Action: action, Progress: progress, Status: status}
+	if err != nil {
+		event.Tracker.Error = err.Error()
+	}
+	dispatcher.Fire(event)
+}
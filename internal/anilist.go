@@ -175,7 +175,7 @@ func SearchAnimeAnilistPreview(query, token string) (map[string]RofiSelectPrevie
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
+	client := sharedHTTPClient
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
@@ -268,7 +268,7 @@ func SearchAnimeAnilist(query, token string) ([]SelectionOption, error) {
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
+	client := sharedHTTPClient
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
@@ -382,6 +382,35 @@ func AddAnimeToWatchingList(animeID int, token string) error {
 	return nil
 }
 
+// AddAnimeToPlanningList adds an anime to the user's AniList planning list
+func AddAnimeToPlanningList(animeID int, token string) error {
+	url := "https://graphql.anilist.co"
+	mutation := `
+	mutation ($mediaId: Int) {
+		SaveMediaListEntry (mediaId: $mediaId, status: PLANNING) {
+			id
+			status
+		}
+	}`
+
+	variables := map[string]interface{}{
+		"mediaId": animeID,
+	}
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + token,
+		"Content-Type":  "application/json",
+	}
+
+	_, err := makePostRequest(url, mutation, variables, headers)
+	if err != nil {
+		return fmt.Errorf("failed to add anime: %w", err)
+	}
+
+	CurdOut(fmt.Sprintf("Anime with ID %d has been added to your planning list.", animeID))
+	return nil
+}
+
 // Function to get MAL ID using AniList media ID
 func GetAnimeMalID(anilistMediaID int) (int, error) {
 	url := "https://graphql.anilist.co"
@@ -434,6 +463,128 @@ func GetAnimeIDAndImage(anilistMediaID int) (int, string, error) {
 	return malID, imageURL, nil
 }
 
+// AnimePresenceInfo is what Discord presence shows about an anime beyond
+// its title.
+type AnimePresenceInfo struct {
+	MalID      int
+	CoverImage string
+	IsAdult    bool
+	Season     string
+}
+
+// GetAnimePresenceInfo looks up the presence details of an anime by its
+// AniList id, or by its MAL id when byMalID is set.
+func GetAnimePresenceInfo(id int, byMalID bool) (AnimePresenceInfo, error) {
+	var info AnimePresenceInfo
+	selector := "id: $id"
+	if byMalID {
+		selector = "idMal: $id, type: ANIME"
+	}
+	query := fmt.Sprintf(`
+	query ($id: Int) {
+		Media(%s) {
+			idMal
+			isAdult
+			season
+			seasonYear
+			coverImage {
+				large
+			}
+		}
+	}`, selector)
+
+	response, err := makePostRequest("https://graphql.anilist.co", query, map[string]interface{}{"id": id}, nil)
+	if err != nil {
+		return info, err
+	}
+	data, _ := response["data"].(map[string]interface{})
+	media, ok := data["Media"].(map[string]interface{})
+	if !ok {
+		return info, fmt.Errorf("no AniList media for id %d", id)
+	}
+	if malID, ok := media["idMal"].(float64); ok {
+		info.MalID = int(malID)
+	}
+	info.IsAdult, _ = media["isAdult"].(bool)
+	if cover, ok := media["coverImage"].(map[string]interface{}); ok {
+		info.CoverImage, _ = cover["large"].(string)
+	}
+	if season, ok := media["season"].(string); ok && season != "" {
+		info.Season = strings.ToUpper(season[:1]) + strings.ToLower(season[1:])
+	}
+	if year, ok := media["seasonYear"].(float64); ok && year > 0 {
+		info.Season = strings.TrimSpace(fmt.Sprintf("%s %d", info.Season, int(year)))
+	}
+	return info, nil
+}
+
+// AnimeOverview is what the dashboard shows about an anime beside the
+// user's list entry.
+type AnimeOverview struct {
+	MalID       int
+	CoverImage  string
+	Description string
+	Format      string
+	Genres      []string
+	NextEpisode int
+	NextAiring  time.Time
+}
+
+// GetAnimeOverview looks up the description and airing schedule of an anime
+// by its AniList id, or by its MAL id when byMalID is set.
+func GetAnimeOverview(id int, byMalID bool) (AnimeOverview, error) {
+	selector := "id: $id"
+	if byMalID {
+		selector = "idMal: $id"
+	}
+	query := fmt.Sprintf(`query ($id: Int) {
+		Media(%s, type: ANIME) {
+			idMal format genres
+			description(asHtml: false)
+			coverImage { large }
+			nextAiringEpisode { episode airingAt }
+		}
+	}`, selector)
+
+	var result struct {
+		Data struct {
+			Media *struct {
+				IDMal       int      `json:"idMal"`
+				Format      string   `json:"format"`
+				Genres      []string `json:"genres"`
+				Description string   `json:"description"`
+				CoverImage  struct {
+					Large string `json:"large"`
+				} `json:"coverImage"`
+				NextAiringEpisode *struct {
+					Episode  int   `json:"episode"`
+					AiringAt int64 `json:"airingAt"`
+				} `json:"nextAiringEpisode"`
+			} `json:"Media"`
+		} `json:"data"`
+	}
+	if err := queryAniList(query, map[string]interface{}{"id": id}, "", &result); err != nil {
+		return AnimeOverview{}, err
+	}
+	media := result.Data.Media
+	if media == nil {
+		return AnimeOverview{}, fmt.Errorf("no AniList media for id %d", id)
+	}
+
+	overview := AnimeOverview{
+		MalID:       media.IDMal,
+		CoverImage:  media.CoverImage.Large,
+		Description: media.Description,
+		Format:      strings.ReplaceAll(media.Format, "_", " "),
+		Genres:      media.Genres,
+	}
+	if next := media.NextAiringEpisode; next != nil {
+		overview.NextEpisode = next.Episode
+		overview.NextAiring = time.Unix(next.AiringAt, 0)
+	}
+	return overview, nil
+}
+
 // Function to get user data from AniList
 func GetUserData(token string, userID int) (map[string]interface{}, error) {
 	query := fmt.Sprintf(`
@@ -652,7 +803,7 @@ func RateAnime(token string, mediaID int) error {
 	}
 
 	if userCurdConfig.RofiSelection {
-		userInput, err := GetUserInputFromRofi("Enter a score for the anime (0-10)")
+		userInput, err := GetUserInput("Enter a score for the anime (0-10)")
 		if err != nil {
 			return err
 		}
@@ -714,7 +865,7 @@ func makePostRequest(url, query string, variables map[string]interface{}, header
 		req.Header.Set(key, value)
 	}
 
-	client := &http.Client{}
+	client := sharedHTTPClient
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
@@ -801,7 +952,7 @@ func ParseAnimeList(input map[string]interface{}) AnimeList {
 				Status:   safeString(entryData["status"]), // Ensure status is fetched safely
 			}
 
-			if userCurdConfig.RofiSelection && userCurdConfig.ImagePreview {
+			if usePreviewMenu(userCurdConfig) {
 				animeEntry.CoverImage = safeString(media["coverImage"].(map[string]interface{})["large"])
 			}
 
@@ -1056,3 +1207,138 @@ func SearchAniListTitles(query string) (AnimeTitle, error) {
 		Japanese: t.Native,
 	}, nil
 }
+
+type aniListMediaNode struct {
+	ID           int      `json:"id"`
+	IDMal        int      `json:"idMal"`
+	Type         string   `json:"type"`
+	Format       string   `json:"format"`
+	Episodes     int      `json:"episodes"`
+	Status       string   `json:"status"`
+	SeasonYear   int      `json:"seasonYear"`
+	AverageScore int      `json:"averageScore"`
+	Genres       []string `json:"genres"`
+	Title        struct {
+		English string `json:"english"`
+		Romaji  string `json:"romaji"`
+		Native  string `json:"native"`
+	} `json:"title"`
+	CoverImage struct {
+		Large string `json:"large"`
+	} `json:"coverImage"`
+}
+
+func (n aniListMediaNode) toAnimeRelation(relationType string) AnimeRelation {
+	return AnimeRelation{
+		RelationType: relationType,
+		ID:           n.ID,
+		MalID:        n.IDMal,
+		Title: AnimeTitle{
+			English:  n.Title.English,
+			Romaji:   n.Title.Romaji,
+			Japanese: n.Title.Native,
+		},
+		Format:     n.Format,
+		Episodes:   n.Episodes,
+		Status:     n.Status,
+		CoverImage: n.CoverImage.Large,
+		Year:       n.SeasonYear,
+	}
+}
+
+// queryAniList posts a GraphQL query to AniList through the shared client and
+// decodes the JSON response into out. The token is optional.
+func queryAniList(query string, variables map[string]interface{}, token string, out interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "https://graphql.anilist.co", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := sharedHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed with status %d: %s", resp.StatusCode, raw)
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("parse anilist response: %w", err)
+	}
+	return nil
+}
+
+// FetchAnimeRelations retrieves an anime and its related anime entries from
+// the public AniList GraphQL API. Non-anime relations (manga, novels) are
+// dropped. No auth token is required.
+func FetchAnimeRelations(anilistID int) (AnimeRelation, []AnimeRelation, error) {
+	if anilistID <= 0 {
+		return AnimeRelation{}, nil, fmt.Errorf("invalid anilist id %d", anilistID)
+	}
+
+	query := `query ($id: Int) {
+		Media(id: $id, type: ANIME) {
+			id idMal type format episodes status seasonYear
+			title { english romaji native }
+			coverImage { large }
+			relations {
+				edges {
+					relationType
+					node {
+						id idMal type format episodes status seasonYear
+						title { english romaji native }
+						coverImage { large }
+					}
+				}
+			}
+		}
+	}`
+
+	var result struct {
+		Data struct {
+			Media *struct {
+				aniListMediaNode
+				Relations struct {
+					Edges []struct {
+						RelationType string           `json:"relationType"`
+						Node         aniListMediaNode `json:"node"`
+					} `json:"edges"`
+				} `json:"relations"`
+			} `json:"Media"`
+		} `json:"data"`
+	}
+	if err := queryAniList(query, map[string]interface{}{"id": anilistID}, "", &result); err != nil {
+		return AnimeRelation{}, nil, err
+	}
+	if result.Data.Media == nil {
+		return AnimeRelation{}, nil, fmt.Errorf("anilist media id %d not found", anilistID)
+	}
+
+	media := result.Data.Media
+	relations := make([]AnimeRelation, 0, len(media.Relations.Edges))
+	for _, edge := range media.Relations.Edges {
+		if edge.Node.Type != "ANIME" || edge.Node.ID == 0 {
+			continue
+		}
+		relations = append(relations, edge.Node.toAnimeRelation(edge.RelationType))
+	}
+	return media.aniListMediaNode.toAnimeRelation(""), relations, nil
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/wraient/curd/internal/challenge"
+	"github.com/wraient/curd/internal/curdhost"
+)
+
+const challengeClearancesFile = "challenge_clearances.json"
+
+var (
+	challengeServiceMu     sync.Mutex
+	challengeService       *challenge.Service
+	challengeServiceConfig challenge.Config
+)
+
+func challengeConfig(config *CurdConfig) challenge.Config {
+	storagePath := ""
+	if config != nil {
+		storagePath = config.StoragePath
+	}
+	if storagePath == "" {
+		storagePath = os.ExpandEnv("${HOME}/.local/share/curd")
+	}
+	result := challenge.Config{
+		Path: filepath.Join(os.ExpandEnv(storagePath), challengeClearancesFile),
+		Log:  func(line string) { Log(line) },
+	}
+	if config != nil {
+		result.BrowserPath = os.ExpandEnv(strings.TrimSpace(config.ChallengeBrowserPath))
+		result.FlareSolverrURL = strings.TrimSpace(config.FlareSolverrURL)
+	}
+	return result
+}
+
+// sharedChallengeService returns the challenge service every provider
+// shares, rebuilding it when the challenge settings change.
+func sharedChallengeService() *challenge.Service {
+	config := challengeConfig(GetGlobalConfig())
+	challengeServiceMu.Lock()
+	defer challengeServiceMu.Unlock()
+	if challengeService != nil &&
+		challengeServiceConfig.Path == config.Path &&
+		challengeServiceConfig.BrowserPath == config.BrowserPath &&
+		challengeServiceConfig.FlareSolverrURL == config.FlareSolverrURL {
+		return challengeService
+	}
+	if challengeService != nil {
+		challengeService.Close()
+	}
+	challengeService, challengeServiceConfig = challenge.New(config), config
+	return challengeService
+}
+
+// closeChallengeService shuts down the challenge browser, if one was
+// started.
+func closeChallengeService() {
+	challengeServiceMu.Lock()
+	defer challengeServiceMu.Unlock()
+	if challengeService != nil {
+		if err := challengeService.Close(); err != nil {
+			Log(fmt.Sprintf("Failed to close challenge browser: %v", err))
+		}
+		challengeService = nil
+	}
+}
+
+func solveSiteChallenge(ctx context.Context, siteURL string) (curdhost.Clearance, error) {
+	clearance, err := sharedChallengeService().Solve(ctx, siteURL)
+	if err != nil {
+		return curdhost.Clearance{}, err
+	}
+	return applyClearance(siteURL, clearance), nil
+}
+
+func cachedSiteChallenge(siteURL string) (curdhost.Clearance, bool) {
+	clearance, ok := sharedChallengeService().Cached(siteURL)
+	if !ok {
+		return curdhost.Clearance{}, false
+	}
+	return applyClearance(siteURL, clearance), true
+}
+
+func forgetSiteChallenge(siteURL string) {
+	sharedChallengeService().Forget(siteURL)
+}
+
+// applyClearance adds clearance's cookies to the shared cookie jar so every
+// later request to the site carries them.
+func applyClearance(siteURL string, clearance challenge.Clearance) curdhost.Clearance {
+	if u, err := url.Parse(siteURL); err == nil && sharedHTTPClient.Jar != nil {
+		sharedHTTPClient.Jar.SetCookies(u, clearance.Cookies)
+	}
+	return curdhost.Clearance{
+		UserAgent: clearance.UserAgent,
+		Cookies:   clearance.Cookies,
+		Expires:   clearance.Expires,
+	}
+}
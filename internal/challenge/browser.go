@@ -0,0 +1,162 @@
+package challenge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/stealth"
+)
+
+// browserSolveTimeout bounds how long a page may show its challenge before
+// the solve gives up.
+const browserSolveTimeout = 30 * time.Second
+
+// challengeTitles are the page titles challenge interstitials show while
+// they check the browser.
+var challengeTitles = []string{
+	"ddos-guard",
+	"just a moment...",
+	"attention required! | cloudflare",
+	"checking your browser",
+}
+
+// browserSolver solves challenges in one browser, started on the first solve
+// and kept for later ones so every provider shares it.
+type browserSolver struct {
+	binary string
+
+	mu       sync.Mutex
+	launcher *launcher.Launcher
+	browser  *rod.Browser
+}
+
+func newBrowserSolver(binary string) *browserSolver {
+	return &browserSolver{binary: strings.TrimSpace(binary)}
+}
+
+func (b *browserSolver) Solve(ctx context.Context, siteURL string) (clearance Clearance, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	browser, err := b.startLocked(ctx)
+	if err != nil {
+		return Clearance{}, err
+	}
+	err = rod.Try(func() {
+		clearance = solveInBrowser(ctx, browser, siteURL)
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return Clearance{}, ctx.Err()
+		}
+		// The browser may have crashed or been closed by the user; start a
+		// fresh one next time.
+		b.closeLocked()
+		return Clearance{}, fmt.Errorf("browser challenge failed: %w", err)
+	}
+	return clearance, nil
+}
+
+// startLocked returns the running browser, launching it if needed.
+func (b *browserSolver) startLocked(ctx context.Context) (*rod.Browser, error) {
+	if b.browser != nil {
+		return b.browser, nil
+	}
+	// The visible window gets past checks that reject headless browsers.
+	l := launcher.New().Context(ctx).Headless(false)
+	if b.binary != "" {
+		l = l.Bin(b.binary)
+	}
+	controlURL, err := l.Launch()
+	if err != nil {
+		return nil, fmt.Errorf("failed to launch browser: %w", err)
+	}
+	browser := rod.New().ControlURL(controlURL)
+	if err := browser.Connect(); err != nil {
+		l.Kill()
+		l.Cleanup()
+		return nil, fmt.Errorf("failed to connect to browser: %w", err)
+	}
+	b.launcher, b.browser = l, browser
+	return browser, nil
+}
+
+// solveInBrowser opens siteURL in a new tab and waits for the challenge to
+// clear. It panics on failure, for rod.Try.
+func solveInBrowser(ctx context.Context, browser *rod.Browser, siteURL string) Clearance {
+	tab := stealth.MustPage(browser)
+	defer tab.Close()
+	page := tab.Context(ctx)
+
+	page.MustNavigate(siteURL)
+	page.MustWaitLoad()
+	userAgent := page.MustEval("() => navigator.userAgent").Str()
+
+	deadline := time.Now().Add(browserSolveTimeout)
+	for {
+		info, err := page.Info()
+		if err == nil && info.Title != "" && !isChallengeTitle(info.Title) {
+			break
+		}
+		if time.Now().After(deadline) {
+			panic(fmt.Errorf("challenge on %s did not clear within %s", siteURL, browserSolveTimeout))
+		}
+		select {
+		case <-ctx.Done():
+			panic(ctx.Err())
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+
+	var cookies []*http.Cookie
+	for _, cookie := range page.MustCookies(siteURL) {
+		httpCookie := &http.Cookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HTTPOnly,
+		}
+		if cookie.Expires > 0 {
+			httpCookie.Expires = cookie.Expires.Time()
+		}
+		cookies = append(cookies, httpCookie)
+	}
+	return Clearance{UserAgent: userAgent, Cookies: cookies}
+}
+
+func isChallengeTitle(title string) bool {
+	title = strings.ToLower(strings.TrimSpace(title))
+	for _, challengeTitle := range challengeTitles {
+		if title == challengeTitle {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *browserSolver) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closeLocked()
+	return nil
+}
+
+func (b *browserSolver) closeLocked() {
+	if b.browser != nil {
+		_ = b.browser.Close()
+		b.browser = nil
+	}
+	if b.launcher != nil {
+		b.launcher.Kill()
+		b.launcher.Cleanup()
+		b.launcher = nil
+	}
+}
@@ -0,0 +1,200 @@
+// Package challenge clears the browser checks, such as Cloudflare's and
+// DDoS-Guard's, that some provider sites put in front of their pages.
+//
+// A Service solves a site's challenge once, either in a real browser it
+// keeps open for later solves or through a FlareSolverr-compatible endpoint,
+// and remembers the cookies and user agent it was given per host until they
+// expire. Concurrent requests for the same host share one solve.
+package challenge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLifetime is how long a clearance is trusted when none of its
+// cookies says when it expires.
+const DefaultLifetime = 24 * time.Hour
+
+// Clearance is what a solved challenge leaves behind: the cookies the site
+// set and the user agent they are bound to.
+type Clearance struct {
+	UserAgent string         `json:"user_agent"`
+	Cookies   []*http.Cookie `json:"cookies"`
+	Expires   time.Time      `json:"expires"`
+}
+
+// Valid reports whether c has cookies and has not expired at now.
+func (c Clearance) Valid(now time.Time) bool {
+	return len(c.Cookies) > 0 && now.Before(c.Expires)
+}
+
+// Solver clears the challenge in front of a site.
+type Solver interface {
+	Solve(ctx context.Context, siteURL string) (Clearance, error)
+	Close() error
+}
+
+// Config describes a Service.
+type Config struct {
+	// Path is the file clearances are kept in between runs. When empty they
+	// only live in memory.
+	Path string
+	// BrowserPath is the Chromium or Chrome binary to solve with. When
+	// empty a system browser is looked up, and downloaded if none is found.
+	BrowserPath string
+	// FlareSolverrURL is the base URL of a FlareSolverr-compatible service,
+	// such as http://localhost:8191. When set it is used instead of a
+	// local browser.
+	FlareSolverrURL string
+	// Log receives a line for every solve. It may be nil.
+	Log func(string)
+}
+
+// Service solves challenges and keeps their clearances.
+type Service struct {
+	solver Solver
+	store  *store
+	log    func(string)
+	now    func() time.Time
+
+	mu       sync.Mutex
+	inflight map[string]*flight
+}
+
+type flight struct {
+	done      chan struct{}
+	clearance Clearance
+	err       error
+}
+
+// New builds a Service from config. Nothing is launched until the first
+// solve.
+func New(config Config) *Service {
+	var solver Solver
+	if strings.TrimSpace(config.FlareSolverrURL) != "" {
+		solver = newFlareSolverr(config.FlareSolverrURL)
+	} else {
+		solver = newBrowserSolver(config.BrowserPath)
+	}
+	return newService(config, solver)
+}
+
+func newService(config Config, solver Solver) *Service {
+	log := config.Log
+	if log == nil {
+		log = func(string) {}
+	}
+	return &Service{
+		solver:   solver,
+		store:    openStore(config.Path, log),
+		log:      log,
+		now:      time.Now,
+		inflight: map[string]*flight{},
+	}
+}
+
+// Cached returns the unexpired clearance remembered for siteURL's host.
+func (s *Service) Cached(siteURL string) (Clearance, bool) {
+	host, err := hostKey(siteURL)
+	if err != nil {
+		return Clearance{}, false
+	}
+	clearance, ok := s.store.get(host)
+	if !ok || !clearance.Valid(s.now()) {
+		return Clearance{}, false
+	}
+	return clearance, true
+}
+
+// Solve clears the challenge in front of siteURL and remembers the result
+// for its host. A caller arriving while another solve for the same host is
+// running waits for that one instead of starting its own.
+func (s *Service) Solve(ctx context.Context, siteURL string) (Clearance, error) {
+	host, err := hostKey(siteURL)
+	if err != nil {
+		return Clearance{}, err
+	}
+
+	s.mu.Lock()
+	if f, ok := s.inflight[host]; ok {
+		s.mu.Unlock()
+		select {
+		case <-f.done:
+			return f.clearance, f.err
+		case <-ctx.Done():
+			return Clearance{}, ctx.Err()
+		}
+	}
+	f := &flight{done: make(chan struct{})}
+	s.inflight[host] = f
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.inflight, host)
+		s.mu.Unlock()
+		close(f.done)
+	}()
+
+	s.log(fmt.Sprintf("Solving browser challenge for %s", host))
+	clearance, err := s.solver.Solve(ctx, siteURL)
+	if err == nil && len(clearance.Cookies) == 0 {
+		err = fmt.Errorf("challenge for %s returned no cookies", host)
+	}
+	if err != nil {
+		f.err = err
+		return Clearance{}, err
+	}
+	clearance.Expires = clearanceExpiry(clearance.Cookies, s.now())
+	s.store.put(host, clearance)
+	s.log(fmt.Sprintf("Cleared browser challenge for %s until %s", host, clearance.Expires.Format(time.RFC3339)))
+	f.clearance = clearance
+	return clearance, nil
+}
+
+// Forget drops the clearance remembered for siteURL's host, for when the
+// site stops accepting it.
+func (s *Service) Forget(siteURL string) {
+	if host, err := hostKey(siteURL); err == nil {
+		s.store.remove(host)
+	}
+}
+
+// Close shuts down the browser, if one was started.
+func (s *Service) Close() error {
+	return s.solver.Close()
+}
+
+// clearanceExpiry returns when the first of cookies expires, or
+// DefaultLifetime from now when none of them says.
+func clearanceExpiry(cookies []*http.Cookie, now time.Time) time.Time {
+	expires := now.Add(DefaultLifetime)
+	for _, cookie := range cookies {
+		cookieExpiry := cookie.Expires
+		if cookie.MaxAge > 0 {
+			cookieExpiry = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		}
+		if !cookieExpiry.IsZero() && cookieExpiry.After(now) && cookieExpiry.Before(expires) {
+			expires = cookieExpiry
+		}
+	}
+	return expires
+}
+
+func hostKey(siteURL string) (string, error) {
+	u, err := url.Parse(siteURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid challenge URL %q: %w", siteURL, err)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return "", fmt.Errorf("invalid challenge URL %q: no host", siteURL)
+	}
+	return host, nil
+}
@@ -0,0 +1,192 @@
+package challenge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeSolver struct {
+	calls   atomic.Int32
+	release chan struct{}
+	cookies []*http.Cookie
+}
+
+func (f *fakeSolver) Solve(ctx context.Context, siteURL string) (Clearance, error) {
+	f.calls.Add(1)
+	if f.release != nil {
+		select {
+		case <-f.release:
+		case <-ctx.Done():
+			return Clearance{}, ctx.Err()
+		}
+	}
+	return Clearance{UserAgent: "test-agent", Cookies: f.cookies}, nil
+}
+
+func (f *fakeSolver) Close() error { return nil }
+
+func TestSolveRemembersClearancePerHost(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clearances.json")
+	solver := &fakeSolver{cookies: []*http.Cookie{{Name: "__ddg2_", Value: "token"}}}
+	service := newService(Config{Path: path}, solver)
+
+	if _, ok := service.Cached("https://animepahe.pw/"); ok {
+		t.Fatal("expected no clearance before solving")
+	}
+	clearance, err := service.Solve(context.Background(), "https://animepahe.pw/")
+	if err != nil {
+		t.Fatalf("Solve: %v", err)
+	}
+	if clearance.UserAgent != "test-agent" || len(clearance.Cookies) != 1 {
+		t.Fatalf("unexpected clearance %+v", clearance)
+	}
+
+	reopened := newService(Config{Path: path}, &fakeSolver{})
+	cached, ok := reopened.Cached("https://ANIMEPAHE.pw/api?m=search")
+	if !ok || cached.Cookies[0].Value != "token" || cached.UserAgent != "test-agent" {
+		t.Fatalf("expected the clearance to survive a restart, got %+v %v", cached, ok)
+	}
+	if _, ok := reopened.Cached("https://example.com/"); ok {
+		t.Fatal("a clearance should only answer for its own host")
+	}
+
+	reopened.Forget("https://animepahe.pw/")
+	if _, ok := newService(Config{Path: path}, &fakeSolver{}).Cached("https://animepahe.pw/"); ok {
+		t.Fatal("expected the forgotten clearance to be gone")
+	}
+}
+
+func TestCachedIgnoresExpiredClearance(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	solver := &fakeSolver{cookies: []*http.Cookie{
+		{Name: "cf_clearance", Value: "a", Expires: now.Add(time.Hour)},
+		{Name: "session", Value: "b"},
+	}}
+	service := newService(Config{}, solver)
+	service.now = func() time.Time { return now }
+
+	clearance, err := service.Solve(context.Background(), "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !clearance.Expires.Equal(now.Add(time.Hour)) {
+		t.Fatalf("expected the clearance to expire with its first cookie, got %s", clearance.Expires)
+	}
+	if _, ok := service.Cached("https://example.com"); !ok {
+		t.Fatal("expected a fresh clearance to be cached")
+	}
+	service.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if _, ok := service.Cached("https://example.com"); ok {
+		t.Fatal("expected an expired clearance to be ignored")
+	}
+}
+
+func TestSolveSharesConcurrentSolves(t *testing.T) {
+	solver := &fakeSolver{release: make(chan struct{}), cookies: []*http.Cookie{{Name: "a", Value: "b"}}}
+	service := newService(Config{}, solver)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = service.Solve(context.Background(), "https://example.com/page")
+		}(i)
+	}
+	for solver.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// Give the other callers time to join the running solve.
+	time.Sleep(20 * time.Millisecond)
+	close(solver.release)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("Solve: %v", err)
+		}
+	}
+	if calls := solver.calls.Load(); calls != 1 {
+		t.Fatalf("expected one solve for concurrent callers, got %d", calls)
+	}
+}
+
+func TestSolveRejectsEmptyClearance(t *testing.T) {
+	service := newService(Config{}, &fakeSolver{})
+	if _, err := service.Solve(context.Background(), "https://example.com"); err == nil {
+		t.Fatal("expected a solve without cookies to fail")
+	}
+	if _, ok := service.Cached("https://example.com"); ok {
+		t.Fatal("a failed solve should not be cached")
+	}
+}
+
+func TestFlareSolverr(t *testing.T) {
+	expires := time.Now().Add(30 * time.Minute).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var req flareSolverrRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		if req.Cmd != "request.get" || req.URL != "https://animepahe.pw/" || req.MaxTimeout <= 0 {
+			t.Errorf("unexpected FlareSolverr request %+v", req)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":  "ok",
+			"message": "Challenge solved!",
+			"solution": map[string]any{
+				"url":       req.URL,
+				"status":    200,
+				"userAgent": "Mozilla/5.0 FlareSolverr",
+				"cookies": []map[string]any{
+					{"name": "__ddg2_", "value": "solved", "domain": ".animepahe.pw", "path": "/", "expires": expires, "httpOnly": true, "secure": true},
+					{"name": "session", "value": "s", "domain": "animepahe.pw", "path": "/", "expires": -1},
+				},
+			},
+		})
+	}))
+	defer server.Close()
+
+	service := newService(Config{}, newFlareSolverr(server.URL+"/"))
+	clearance, err := service.Solve(context.Background(), "https://animepahe.pw/")
+	if err != nil {
+		t.Fatalf("Solve: %v", err)
+	}
+	if clearance.UserAgent != "Mozilla/5.0 FlareSolverr" || len(clearance.Cookies) != 2 {
+		t.Fatalf("unexpected clearance %+v", clearance)
+	}
+	if cookie := clearance.Cookies[0]; cookie.Value != "solved" || !cookie.HttpOnly || cookie.Expires.Unix() != expires {
+		t.Fatalf("unexpected cookie %+v", cookie)
+	}
+	if !clearance.Cookies[1].Expires.IsZero() {
+		t.Fatalf("session cookie should not expire: %+v", clearance.Cookies[1])
+	}
+	if clearance.Expires.Unix() != expires {
+		t.Fatalf("expected the clearance to expire with __ddg2_, got %s", clearance.Expires)
+	}
+}
+
+func TestFlareSolverrReportsFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"status":"error","message":"Error: Maximum timeout reached"}`))
+	}))
+	defer server.Close()
+
+	_, err := newFlareSolverr(server.URL).Solve(context.Background(), "https://example.com")
+	if err == nil || !strings.Contains(err.Error(), "Maximum timeout reached") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package challenge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// flareSolverrTimeout is the maxTimeout handed to FlareSolverr. The HTTP
+// request itself is allowed a little longer so FlareSolverr can report its
+// own timeout.
+const flareSolverrTimeout = 60 * time.Second
+
+// flareSolverr solves challenges through a FlareSolverr-compatible HTTP
+// endpoint instead of a local browser.
+type flareSolverr struct {
+	endpoint string
+	client   *http.Client
+}
+
+func newFlareSolverr(baseURL string) *flareSolverr {
+	endpoint := strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if !strings.HasSuffix(endpoint, "/v1") {
+		endpoint += "/v1"
+	}
+	return &flareSolverr{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: flareSolverrTimeout + 15*time.Second},
+	}
+}
+
+type flareSolverrRequest struct {
+	Cmd        string `json:"cmd"`
+	URL        string `json:"url"`
+	MaxTimeout int    `json:"maxTimeout"`
+}
+
+type flareSolverrResponse struct {
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+	Solution struct {
+		UserAgent string `json:"userAgent"`
+		Cookies   []struct {
+			Name     string  `json:"name"`
+			Value    string  `json:"value"`
+			Domain   string  `json:"domain"`
+			Path     string  `json:"path"`
+			Expires  float64 `json:"expires"`
+			HTTPOnly bool    `json:"httpOnly"`
+			Secure   bool    `json:"secure"`
+		} `json:"cookies"`
+	} `json:"solution"`
+}
+
+func (f *flareSolverr) Solve(ctx context.Context, siteURL string) (Clearance, error) {
+	body, err := json.Marshal(flareSolverrRequest{
+		Cmd:        "request.get",
+		URL:        siteURL,
+		MaxTimeout: int(flareSolverrTimeout / time.Millisecond),
+	})
+	if err != nil {
+		return Clearance{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return Clearance{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Clearance{}, fmt.Errorf("FlareSolverr request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Clearance{}, fmt.Errorf("failed to read FlareSolverr response: %w", err)
+	}
+
+	var result flareSolverrResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return Clearance{}, fmt.Errorf("FlareSolverr returned status %d and an unreadable body: %w", resp.StatusCode, err)
+	}
+	if result.Status != "ok" {
+		return Clearance{}, fmt.Errorf("FlareSolverr could not solve %s: %s", siteURL, result.Message)
+	}
+
+	clearance := Clearance{UserAgent: result.Solution.UserAgent}
+	for _, cookie := range result.Solution.Cookies {
+		httpCookie := &http.Cookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HTTPOnly,
+		}
+		if cookie.Expires > 0 {
+			httpCookie.Expires = time.Unix(int64(cookie.Expires), 0)
+		}
+		clearance.Cookies = append(clearance.Cookies, httpCookie)
+	}
+	return clearance, nil
+}
+
+func (f *flareSolverr) Close() error {
+	return nil
+}
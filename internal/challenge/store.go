@@ -0,0 +1,100 @@
+package challenge
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// store keeps clearances per host, persisted as JSON at path.
+type store struct {
+	path string
+	log  func(string)
+
+	mu         sync.Mutex
+	clearances map[string]Clearance
+}
+
+func openStore(path string, log func(string)) *store {
+	s := &store{path: path, log: log, clearances: map[string]Clearance{}}
+	if path == "" {
+		return s
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log(fmt.Sprintf("Failed to read challenge clearances: %v", err))
+		}
+		return s
+	}
+	if err := json.Unmarshal(data, &s.clearances); err != nil {
+		log(fmt.Sprintf("Ignoring unreadable challenge clearances in %s: %v", path, err))
+		s.clearances = map[string]Clearance{}
+	}
+	return s
+}
+
+func (s *store) get(host string) (Clearance, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clearance, ok := s.clearances[host]
+	return clearance, ok
+}
+
+func (s *store) put(host string, clearance Clearance) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clearances[host] = clearance
+	s.saveLocked()
+}
+
+func (s *store) remove(host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clearances[host]; !ok {
+		return
+	}
+	delete(s.clearances, host)
+	s.saveLocked()
+}
+
+// saveLocked writes the clearances through a temporary file so a crash
+// never leaves a half-written store behind.
+func (s *store) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	if err := s.writeLocked(); err != nil {
+		s.log(fmt.Sprintf("Failed to save challenge clearances: %v", err))
+	}
+}
+
+func (s *store) writeLocked() error {
+	data, err := json.MarshalIndent(s.clearances, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/pkg/browser"
+	"github.com/wraient/curd/internal/menu"
 )
 
 const (
@@ -39,7 +40,7 @@ type AnilistToken struct {
 // CurdConfig struct with field names that match the config keys
 type CurdConfig struct {
 	Player                   string   `config:"Player"`
-	MpvArgs                  []string `config:MpvArgs`
+	MpvArgs                  []string `config:"MpvArgs"`
 	SubsLanguage             string   `config:"SubsLanguage"`
 	SubOrDub                 string   `config:"SubOrDub"`
 	StoragePath              string   `config:"StoragePath"`
@@ -56,17 +57,55 @@ type CurdConfig struct {
 	ImagePreview             bool     `config:"ImagePreview"`
 	SkipRecap                bool     `config:"SkipRecap"`
 	RofiSelection            bool     `config:"RofiSelection"`
+	MenuBackend              string   `config:"MenuBackend"`
+	FzfPreviewCommand        string   `config:"FzfPreviewCommand"`
 	CurrentCategory          bool     `config:"CurrentCategory"`
+	Dashboard                bool     `config:"Dashboard"`
+	EpisodeBrowser           bool     `config:"EpisodeBrowser"`
+	MenuKeys                 string   `config:"MenuKeys"`
+	Theme                    string   `config:"Theme"`
+	ThemeFile                string   `config:"ThemeFile"`
 	ScoreOnCompletion        bool     `config:"ScoreOnCompletion"`
 	SaveMpvSpeed             bool     `config:"SaveMpvSpeed"`
 	AddMissingOptions        bool     `config:"AddMissingOptions"`
 	AlternateScreen          bool     `config:"AlternateScreen"`
 	DiscordPresence          bool     `config:"DiscordPresence"`
 	DiscordClientId          string   `config:"DiscordClientId"`
+	DiscordDetails           string   `config:"DiscordDetails"`
+	DiscordState             string   `config:"DiscordState"`
+	DiscordLargeText         string   `config:"DiscordLargeText"`
+	DiscordMenuPresence      bool     `config:"DiscordMenuPresence"`
+	DiscordMenuDetails       string   `config:"DiscordMenuDetails"`
+	DiscordHideAdult         bool     `config:"DiscordHideAdult"`
+	DiscordIncognito         string   `config:"DiscordIncognito"`
+	HooksFile                string   `config:"HooksFile"`
 	Provider                 string   `config:"Provider"`
 	DisabledProviders        string   `config:"DisabledProviders"`
 	ManualProviderSearch     bool     `config:"ManualProviderSearch"`
 	SubStyle                 string   `config:"SubStyle"`
+	PreferredQuality         string   `config:"PreferredQuality"`
+	MaxResolution            int      `config:"MaxResolution"`
+	PreferredServers         string   `config:"PreferredServers"`
+	StreamPicker             bool     `config:"StreamPicker"`
+	StreamPickerKey          string   `config:"StreamPickerKey"`
+	WarmCache                bool     `config:"WarmCache"`
+	WarmCacheDir             string   `config:"WarmCacheDir"`
+	WarmCacheSize            int      `config:"WarmCacheSize"`
+	WarmCacheMinutes         int      `config:"WarmCacheMinutes"`
+	HTTPProxy                string   `config:"HTTPProxy"`
+	DNSOverHTTPS             string   `config:"DNSOverHTTPS"`
+	HTTPTimeout              int      `config:"HTTPTimeout"`
+	HTTPRetries              int      `config:"HTTPRetries"`
+	RateLimits               string   `config:"RateLimits"`
+	ChallengeBrowserPath     string   `config:"ChallengeBrowserPath"`
+	FlareSolverrURL          string   `config:"FlareSolverrURL"`
+	CredentialStore          string   `config:"CredentialStore"`
+	CredentialKeyFile        string   `config:"CredentialKeyFile"`
+	OAuthLogin               string   `config:"OAuthLogin"`
+	OAuthBindAddress         string   `config:"OAuthBindAddress"`
+	OAuthCallbackPort        int      `config:"OAuthCallbackPort"`
+	Account                  string   `config:"Account"`
+	ConfirmAccount           bool     `config:"ConfirmAccount"`
 }
 
 // Default configuration values as a map
@@ -78,7 +117,7 @@ func defaultConfigMap() map[string]string {
 		"DownloadPath":             "$HOME",
 		"AnimeNameLanguage":        "english",
 		"SubsLanguage":             "english",
-		"MenuOrder":                "CURRENT,ALL,UNTRACKED,UPDATE,DOWNLOAD,CONTINUE_LAST",
+		"MenuOrder":                "CURRENT,ALL,DISCOVER,UNTRACKED,UPDATE,DOWNLOAD,FRANCHISE,CONTINUE_LAST,ACCOUNT",
 		"TrackingService":          "mal",
 		"DualTracking":             "true",
 		"SubOrDub":                 "sub",
@@ -89,17 +128,55 @@ func defaultConfigMap() map[string]string {
 		"SkipFiller":               "true",
 		"SkipRecap":                "true",
 		"RofiSelection":            "false",
+		"MenuBackend":              "auto",
+		"FzfPreviewCommand":        menu.DefaultFzfPreview,
 		"ImagePreview":             "false",
+		"Dashboard":                "false",
+		"EpisodeBrowser":           "false",
+		"MenuKeys":                 "[]",
+		"Theme":                    "dark",
+		"ThemeFile":                "$HOME/.config/curd/theme.json",
 		"ScoreOnCompletion":        "true",
 		"SaveMpvSpeed":             "true",
 		"AddMissingOptions":        "true",
 		"AlternateScreen":          "true",
 		"DiscordPresence":          "true",
 		"DiscordClientId":          "1287457464148820089",
+		"DiscordDetails":           "{title}",
+		"DiscordState":             "Episode {episode}",
+		"DiscordLargeText":         "{title}",
+		"DiscordMenuPresence":      "true",
+		"DiscordMenuDetails":       "Browsing {menu}",
+		"DiscordHideAdult":         "true",
+		"DiscordIncognito":         "[]",
+		"HooksFile":                "$HOME/.config/curd/hooks.json",
 		"Provider":                 "[\"anineko\"]",
 		"DisabledProviders":        "[]",
 		"ManualProviderSearch":     "false",
 		"SubStyle":                 "ask",
+		"PreferredQuality":         "best",
+		"MaxResolution":            "0",
+		"PreferredServers":         "[]",
+		"StreamPicker":             "false",
+		"StreamPickerKey":          "Ctrl+Alt+s",
+		"WarmCache":                "false",
+		"WarmCacheDir":             "$HOME/.cache/curd/warm",
+		"WarmCacheSize":            "1024",
+		"WarmCacheMinutes":         "3",
+		"HTTPProxy":                "",
+		"DNSOverHTTPS":             "",
+		"HTTPTimeout":              "15",
+		"HTTPRetries":              "3",
+		"RateLimits":               "[\"api.jikan.moe=60\",\"graphql.anilist.co=90\"]",
+		"ChallengeBrowserPath":     "",
+		"FlareSolverrURL":          "",
+		"CredentialStore":          "file",
+		"CredentialKeyFile":        "",
+		"OAuthLogin":               "auto",
+		"OAuthBindAddress":         "",
+		"OAuthCallbackPort":        "0",
+		"Account":                  "",
+		"ConfirmAccount":           "true",
 	}
 }
 
@@ -231,23 +308,36 @@ func createDefaultConfig(path string) error {
 }
 
 // authenticateWithBrowser performs OAuth authentication using browser
-func authenticateWithBrowser(tokenPath string, forceReauth bool) (string, error) {
+func authenticateWithBrowser(config *CurdConfig, forceReauth bool) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
 	// Try to load existing token first (skip if forcing re-authentication)
 	if !forceReauth {
-		if token, err := loadToken(tokenPath); err == nil && isTokenValid(token) {
+		if token, err := loadToken(config); err == nil && isTokenValid(token) {
 			return token.AccessToken, nil
 		}
 	}
 
+	authURL := anilistAuthorizeURL()
+	if oauthHeadless(config) {
+		code, err := readAuthorizationCode(config, "AniList", authURL)
+		if err != nil {
+			return "", err
+		}
+		token, err := exchangeAnilistCode(code)
+		if err != nil {
+			return "", fmt.Errorf("authentication failed: %w", err)
+		}
+		return saveAnilistLogin(config, token)
+	}
+
 	// Start local server to handle OAuth callback
-	callbackCh := make(chan string, 1)
+	callbackCh := make(chan *AnilistToken, 1)
 	errCh := make(chan error, 1)
 	mux := http.NewServeMux()
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", anilistServerPort),
+		Addr:    oauthListenAddr(config, anilistServerPort),
 		Handler: mux,
 	}
 
@@ -302,44 +392,12 @@ func authenticateWithBrowser(tokenPath string, forceReauth bool) (string, error)
 
 		// Exchange authorization code for access token
 		go func() {
-			tokenURL := fmt.Sprintf("%s/token", anilistOAuthURL)
-			data := url.Values{
-				"grant_type":    {"authorization_code"},
-				"client_id":     {anilistClientID},
-				"client_secret": {anilistClientSecret},
-				"redirect_uri":  {anilistRedirectURI},
-				"code":          {code},
-			}
-
-			resp, err := http.PostForm(tokenURL, data)
+			token, err := exchangeAnilistCode(code)
 			if err != nil {
-				errCh <- fmt.Errorf("failed to exchange code for token: %w", err)
-				return
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode != http.StatusOK {
-				errCh <- fmt.Errorf("token exchange failed with status: %d", resp.StatusCode)
-				return
-			}
-
-			var tokenResponse struct {
-				AccessToken string `json:"access_token"`
-				TokenType   string `json:"token_type"`
-				ExpiresIn   int    `json:"expires_in"`
-			}
-
-			if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
-				errCh <- fmt.Errorf("failed to parse token response: %w", err)
-				return
-			}
-
-			if tokenResponse.AccessToken == "" {
-				errCh <- fmt.Errorf("no access token in response")
+				errCh <- err
 				return
 			}
-
-			callbackCh <- tokenResponse.AccessToken
+			callbackCh <- token
 		}()
 
 		// Show success page immediately
@@ -372,11 +430,6 @@ func authenticateWithBrowser(tokenPath string, forceReauth bool) (string, error)
 	time.Sleep(100 * time.Millisecond)
 
 	// Open browser for authentication using Authorization Code Grant flow (response_type=code)
-	authURL := fmt.Sprintf("%s/authorize?client_id=%s&redirect_uri=%s&response_type=code",
-		anilistOAuthURL,
-		anilistClientID,
-		url.QueryEscape(anilistRedirectURI))
-
 	fmt.Println("Opening browser for AniList authentication...")
 	fmt.Printf("If the browser doesn't open automatically, visit: %s\n", authURL)
 
@@ -386,25 +439,71 @@ func authenticateWithBrowser(tokenPath string, forceReauth bool) (string, error)
 	}
 
 	// Wait for token
-	var accessToken string
+	var token *AnilistToken
 	select {
-	case accessToken = <-callbackCh:
+	case token = <-callbackCh:
 	case err := <-errCh:
 		return "", fmt.Errorf("authentication failed: %w", err)
 	case <-ctx.Done():
 		return "", fmt.Errorf("authentication timeout after 5 minutes")
 	}
 
-	// Create token object and save
-	token := &AnilistToken{
-		AccessToken: accessToken,
-		TokenType:   "Bearer",
-		ExpiresIn:   31536000, // AniList tokens are valid for 1 year
-		ExpiresAt:   time.Now().Add(365 * 24 * time.Hour),
+	return saveAnilistLogin(config, token)
+}
+
+// anilistAuthorizeURL returns the Authorization Code Grant login page
+func anilistAuthorizeURL() string {
+	return fmt.Sprintf("%s/authorize?client_id=%s&redirect_uri=%s&response_type=code",
+		anilistOAuthURL,
+		anilistClientID,
+		url.QueryEscape(anilistRedirectURI))
+}
+
+// exchangeAnilistCode exchanges an authorization code for an access token
+func exchangeAnilistCode(code string) (*AnilistToken, error) {
+	tokenURL := fmt.Sprintf("%s/token", anilistOAuthURL)
+	data := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {anilistClientID},
+		"client_secret": {anilistClientSecret},
+		"redirect_uri":  {anilistRedirectURI},
+		"code":          {code},
+	}
+
+	resp, err := sharedHTTPClient.PostForm(tokenURL, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token exchange failed with status: %d", resp.StatusCode)
+	}
+
+	var token AnilistToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("failed to parse token response: %w", err)
 	}
 
-	// Save token to file
-	if err := saveToken(tokenPath, token); err != nil {
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("no access token in response")
+	}
+
+	return &token, nil
+}
+
+// saveAnilistLogin stores a freshly obtained token and returns its access token
+func saveAnilistLogin(config *CurdConfig, token *AnilistToken) (string, error) {
+	if token.TokenType == "" {
+		token.TokenType = "Bearer"
+	}
+	if token.ExpiresIn <= 0 {
+		token.ExpiresIn = 31536000 // AniList tokens are valid for 1 year
+	}
+	token.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+
+	// Save token to the credential store
+	if err := saveToken(config, token); err != nil {
 		return "", fmt.Errorf("failed to save token: %w", err)
 	}
 
@@ -412,34 +511,35 @@ func authenticateWithBrowser(tokenPath string, forceReauth bool) (string, error)
 	return token.AccessToken, nil
 }
 
-// loadToken loads the token from the token file
-func loadToken(tokenPath string) (*AnilistToken, error) {
-	data, err := os.ReadFile(tokenPath)
+// loadToken loads the token from the credential store (supports both old text format and new JSON format)
+func loadToken(config *CurdConfig) (*AnilistToken, error) {
+	data, err := readCredential(config, anilistTokenKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read token file: %w", err)
+		return nil, err
 	}
 
+	// Try to parse as JSON first (new format)
 	var token AnilistToken
-	if err := json.Unmarshal(data, &token); err != nil {
-		return nil, fmt.Errorf("failed to parse token file: %w", err)
+	if err := json.Unmarshal(data, &token); err == nil {
+		return &token, nil
 	}
 
-	return &token, nil
-}
-
-// saveToken saves the token to the token file
-func saveToken(tokenPath string, token *AnilistToken) error {
-	// Ensure directory exists
-	if err := os.MkdirAll(filepath.Dir(tokenPath), 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+	// Fall back to plain text format (old format), whose expiry is unknown
+	plainToken := strings.TrimSpace(string(data))
+	if plainToken == "" {
+		return nil, fmt.Errorf("empty token file")
 	}
+	return &AnilistToken{AccessToken: plainToken, TokenType: "Bearer"}, nil
+}
 
+// saveToken saves the token to the credential store
+func saveToken(config *CurdConfig, token *AnilistToken) error {
 	data, err := json.Marshal(token)
 	if err != nil {
 		return fmt.Errorf("failed to marshal token: %w", err)
 	}
 
-	return os.WriteFile(tokenPath, data, 0600)
+	return writeCredential(config, anilistTokenKey, data)
 }
 
 // isTokenValid checks if the token is still valid
@@ -447,65 +547,48 @@ func isTokenValid(token *AnilistToken) bool {
 	return token != nil && token.AccessToken != "" && time.Now().Before(token.ExpiresAt)
 }
 
-// GetTokenFromFile loads the token from the token file (supports both old text format and new JSON format)
-func GetTokenFromFile(tokenPath string) (string, error) {
-	data, err := os.ReadFile(tokenPath)
+// GetAnilistToken returns the stored AniList access token
+func GetAnilistToken(config *CurdConfig) (string, error) {
+	token, err := loadToken(config)
 	if err != nil {
-		return "", fmt.Errorf("failed to read token from file: %w", err)
+		return "", fmt.Errorf("failed to read token: %w", err)
 	}
-
-	// Try to parse as JSON first (new format)
-	var token AnilistToken
-	if err := json.Unmarshal(data, &token); err == nil {
-		// It's JSON format, check if token is valid
-		if isTokenValid(&token) {
-			return token.AccessToken, nil
-		}
-		return "", fmt.Errorf("token has expired")
+	// Plain text tokens carry no expiry and are used as is
+	if token.ExpiresAt.IsZero() {
+		return token.AccessToken, nil
 	}
-
-	// Fall back to plain text format (old format)
-	plainToken := strings.TrimSpace(string(data))
-	if plainToken == "" {
-		return "", fmt.Errorf("empty token file")
+	if !isTokenValid(token) {
+		return "", fmt.Errorf("token has expired")
 	}
-
-	return plainToken, nil
+	return token.AccessToken, nil
 }
 
 func ChangeToken(config *CurdConfig, user *User) {
 	var err error
-	tokenPath := filepath.Join(os.ExpandEnv(config.StoragePath), "anilist_token.json")
 
 	// Try browser-based OAuth (force re-authentication since user explicitly wants to change token)
 	fmt.Println("Starting browser-based authentication...")
-	user.Token, err = authenticateWithBrowser(tokenPath, true)
+	user.Token, err = authenticateWithBrowser(config, true)
 
-	if err != nil {
+	if err != nil && !oauthHeadless(config) {
 		Log("Browser authentication failed: " + err.Error())
 		fmt.Printf("Browser authentication failed: %v\n", err)
-		fmt.Println("Falling back to manual token entry...")
-
-		// Simple CLI fallback
-		fmt.Println("Please visit: https://anilist.co/api/v2/oauth/authorize?client_id=20686&response_type=token&redirect_uri=http://localhost:8000/oauth/callback")
-		fmt.Print("Copy and paste your access token here: ")
-		fmt.Scanln(&user.Token)
-
-		if user.Token == "" {
-			ExitCurd(fmt.Errorf("no token provided"))
-		}
-
-		// Save the manually entered token as JSON format
-		token := &AnilistToken{
-			AccessToken: user.Token,
-			TokenType:   "Bearer",
-			ExpiresIn:   31536000, // AniList tokens are valid for 1 year
-			ExpiresAt:   time.Now().Add(365 * 24 * time.Hour),
+		fmt.Println("Falling back to pasting the redirect URL...")
+
+		// Headless fallback: the user opens the link anywhere and pastes back where it redirected to
+		var code string
+		code, err = readAuthorizationCode(config, "AniList", anilistAuthorizeURL())
+		if err == nil {
+			var token *AnilistToken
+			if token, err = exchangeAnilistCode(code); err == nil {
+				user.Token, err = saveAnilistLogin(config, token)
+			}
 		}
+	}
 
-		if err := saveToken(tokenPath, token); err != nil {
-			ExitCurd(fmt.Errorf("failed to save token: %w", err))
-		}
+	if err != nil {
+		Log("AniList authentication failed: " + err.Error())
+		ExitCurd(fmt.Errorf("AniList authentication failed: %w", err))
 	}
 
 	if user.Token == "" {
@@ -602,14 +685,17 @@ func PopulateConfig(configMap map[string]string) CurdConfig {
 
 func getOrderedCategories(userCurdConfig *CurdConfig) []SelectionOption {
 	// Define the default categories and their labels
-	defaultOrder := []string{"CURRENT", "ALL", "UNTRACKED", "UPDATE", "DOWNLOAD", "CONTINUE_LAST"}
+	defaultOrder := []string{"CURRENT", "ALL", "DISCOVER", "UNTRACKED", "UPDATE", "DOWNLOAD", "FRANCHISE", "CONTINUE_LAST", "ACCOUNT"}
 	defaultLabels := map[string]string{
 		"CURRENT":        "Currently Watching",
 		"ALL":            "Show All",
+		"DISCOVER":       "Discover (Trending, Seasonal, Recommended)",
 		"UNTRACKED":      "Untracked Watching",
 		"UPDATE":         "Update (Episode, Status, Score)",
 		"DOWNLOAD":       "Download Episodes",
+		"FRANCHISE":      "Franchise Watch Order",
 		"CONTINUE_LAST":  "Continue Last Session",
+		"ACCOUNT":        "Switch Account",
 	}
 
 	// Create ordered list to store final result
@@ -646,9 +732,17 @@ func getOrderedCategories(userCurdConfig *CurdConfig) []SelectionOption {
 		if key == "DOWNLOAD" && userCurdConfig.RofiSelection {
 			continue
 		}
+		// The switcher only matters once a second account exists
+		if key == "ACCOUNT" && !hasNamedAccounts(userCurdConfig) {
+			continue
+		}
+		label := defaultLabels[key]
+		if key == "ACCOUNT" {
+			label = fmt.Sprintf("%s (%s)", label, activeAccount(userCurdConfig))
+		}
 		orderedCategories = append(orderedCategories, SelectionOption{
 			Key:   key,
-			Label: defaultLabels[key],
+			Label: label,
 		})
 	}
 
@@ -0,0 +1,283 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+
+	"github.com/wraient/curd/internal/accounts"
+	"github.com/wraient/curd/internal/credstore"
+)
+
+const (
+	anilistTokenKey = "anilist_token"
+	malTokenKey     = "mal_token"
+
+	encryptedCredentialsFile = "credentials.enc"
+	// credentialPassphraseEnv supplies the encrypted store's passphrase
+	// without a prompt, for rofi mode and scripts.
+	credentialPassphraseEnv = "CURD_CREDENTIAL_PASSPHRASE"
+)
+
+var (
+	credentialStoreMu     sync.Mutex
+	credentialStoreCached credstore.Store
+	credentialStoreKey    string
+)
+
+// credentialDir is where the file and encrypted stores keep the active
+// account's tokens.
+func credentialDir(config *CurdConfig) string {
+	return accountDataDir(config)
+}
+
+// credentialStore returns the store tokens are kept in, as chosen by the
+// CredentialStore setting: file (the default), encrypted or keyring.
+func credentialStore(config *CurdConfig) (credstore.Store, error) {
+	backend := ""
+	keyFile := ""
+	if config != nil {
+		backend = strings.ToLower(strings.TrimSpace(config.CredentialStore))
+		keyFile = os.ExpandEnv(strings.TrimSpace(config.CredentialKeyFile))
+	}
+	dir := credentialDir(config)
+	account := activeAccount(config)
+	cacheKey := strings.Join([]string{backend, dir, keyFile, account}, "\x00")
+
+	credentialStoreMu.Lock()
+	defer credentialStoreMu.Unlock()
+	if credentialStoreCached != nil && credentialStoreKey == cacheKey {
+		return credentialStoreCached, nil
+	}
+
+	var store credstore.Store
+	switch backend {
+	case "", "file":
+		store = credstore.NewFileStore(dir)
+	case "encrypted":
+		store = credstore.NewEncryptedStore(filepath.Join(dir, encryptedCredentialsFile), func() (string, error) {
+			return credentialPassphrase(keyFile)
+		})
+	case "keyring":
+		application := "curd"
+		if !accounts.IsDefault(account) {
+			application += "/" + account
+		}
+		keyring, err := credstore.NewKeyringStore(application)
+		if err != nil {
+			return nil, err
+		}
+		store = keyring
+	default:
+		return nil, fmt.Errorf("unknown CredentialStore %q (want file, encrypted or keyring)", backend)
+	}
+	credentialStoreCached, credentialStoreKey = store, cacheKey
+	return store, nil
+}
+
+// credentialPassphrase returns the encrypted store's passphrase from the key
+// file, the environment or, failing both, a prompt on the terminal.
+func credentialPassphrase(keyFile string) (string, error) {
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read credential key file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	if passphrase := os.Getenv(credentialPassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return "", fmt.Errorf("the encrypted credential store needs a passphrase: set CredentialKeyFile or %s", credentialPassphraseEnv)
+	}
+	fmt.Print("Credential store passphrase: ")
+	passphrase, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return string(passphrase), nil
+}
+
+// readCredential returns the secret stored under key. A token still in its
+// plain file from before the encrypted or keyring store was chosen is moved
+// into the store on first read.
+func readCredential(config *CurdConfig, key string) ([]byte, error) {
+	store, err := credentialStore(config)
+	if err != nil {
+		return nil, err
+	}
+	data, err := store.Get(key)
+	if !errors.Is(err, credstore.ErrNotFound) {
+		return data, err
+	}
+	if _, plain := store.(*credstore.FileStore); plain {
+		return nil, err
+	}
+
+	legacy := credstore.NewFileStore(credentialDir(config))
+	data, legacyErr := legacy.Get(key)
+	if legacyErr != nil {
+		return nil, err
+	}
+	if err := store.Set(key, data); err != nil {
+		Log(fmt.Sprintf("Failed to move %s into the %s: %v", key, store.Name(), err))
+		return data, nil
+	}
+	if err := legacy.Delete(key); err != nil {
+		Log(fmt.Sprintf("Failed to remove plain %s: %v", legacy.Path(key), err))
+	}
+	Log(fmt.Sprintf("Moved %s into the %s", key, store.Name()))
+	return data, nil
+}
+
+func writeCredential(config *CurdConfig, key string, data []byte) error {
+	store, err := credentialStore(config)
+	if err != nil {
+		return err
+	}
+	return store.Set(key, data)
+}
+
+const authCommandUsage = `Usage: curd auth <command>
+
+  status [account]    Show which tracker accounts are logged in and when their
+                      tokens expire, for every curd account or the one named`
+
+// RunAuthCommand runs "curd auth" with args.
+func RunAuthCommand(config *CurdConfig, args []string) error {
+	return runAuthCommand(config, args, os.Stdout)
+}
+
+func runAuthCommand(config *CurdConfig, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		args = []string{"status"}
+	}
+	switch args[0] {
+	case "status":
+		if len(args) > 2 {
+			return fmt.Errorf("%s", authCommandUsage)
+		}
+		names, err := accounts.List(accountStorageRoot(config))
+		if err != nil {
+			return err
+		}
+		if len(args) == 2 {
+			name := strings.TrimSpace(args[1])
+			if accounts.IsDefault(name) {
+				name = accounts.Default
+			}
+			if !containsString(names, name) {
+				return fmt.Errorf("no account named %q", args[1])
+			}
+			names = []string{name}
+		}
+		return writeAuthStatus(config, names, out)
+	case "help", "-h", "--help":
+		fmt.Fprintln(out, authCommandUsage)
+		return nil
+	}
+	return fmt.Errorf("unknown auth command %q\n%s", args[0], authCommandUsage)
+}
+
+// writeAuthStatus prints a block of tracker logins for each of the named
+// curd accounts.
+func writeAuthStatus(config *CurdConfig, names []string, out io.Writer) error {
+	active := activeAccount(config)
+	for i, name := range names {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		account := *config
+		account.Account = name
+		if accounts.IsDefault(name) {
+			account.Account = ""
+		}
+		marker := ""
+		if name == active {
+			marker = " (active)"
+		}
+		if err := writeAccountAuthStatus(&account, name+marker, out); err != nil {
+			return fmt.Errorf("account %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func writeAccountAuthStatus(config *CurdConfig, title string, out io.Writer) error {
+	store, err := credentialStore(config)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Account: %s\nCredential store: %s\n\n", title, store.Name())
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tACCOUNT\tSTATUS\tEXPIRES\tREFRESH")
+
+	anilistToken, err := loadToken(config)
+	switch {
+	case err != nil:
+		fmt.Fprintf(w, "AniList\t-\t%s\t-\t-\n", credentialErrorStatus(err))
+	default:
+		account, status := "-", "expired"
+		if isTokenValid(anilistToken) || anilistToken.ExpiresAt.IsZero() {
+			status = "logged in"
+			if _, name, err := GetAnilistUserID(anilistToken.AccessToken); err == nil {
+				account = name
+			} else {
+				status = "rejected by AniList"
+			}
+		}
+		fmt.Fprintf(w, "AniList\t%s\t%s\t%s\t%s\n", account, status, formatTokenExpiry(anilistToken.ExpiresAt), "not supported")
+	}
+
+	malToken, err := loadMALToken(config)
+	switch {
+	case err != nil:
+		fmt.Fprintf(w, "MyAnimeList\t-\t%s\t-\t-\n", credentialErrorStatus(err))
+	default:
+		account, status := "-", "expired"
+		if isMALTokenValid(malToken) {
+			status = "logged in"
+			if _, name, err := GetMALUserInfo(malToken.AccessToken); err == nil {
+				account = name
+			} else {
+				status = "rejected by MyAnimeList"
+			}
+		}
+		refresh := "no refresh token"
+		if malToken.RefreshToken != "" {
+			refresh = "automatic"
+		}
+		fmt.Fprintf(w, "MyAnimeList\t%s\t%s\t%s\t%s\n", account, status, formatTokenExpiry(malToken.ExpiresAt), refresh)
+	}
+	return w.Flush()
+}
+
+func credentialErrorStatus(err error) string {
+	if errors.Is(err, credstore.ErrNotFound) {
+		return "not logged in"
+	}
+	return "unreadable: " + err.Error()
+}
+
+func formatTokenExpiry(expiresAt time.Time) string {
+	if expiresAt.IsZero() {
+		return "unknown"
+	}
+	remaining := time.Until(expiresAt)
+	when := expiresAt.Local().Format("2006-01-02 15:04")
+	if remaining <= 0 {
+		return when
+	}
+	return fmt.Sprintf("%s (in %d days)", when, int(remaining.Hours()/24))
+}
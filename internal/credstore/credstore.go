@@ -0,0 +1,54 @@
+// Package credstore keeps the tokens curd signs in with.
+//
+// A Store maps a key such as "mal_token" to an opaque secret. Three backends
+// are provided: plain files, the historical layout with one JSON file per key;
+// a single file encrypted with AES-256-GCM under a key derived from a
+// passphrase; and the desktop keyring through the freedesktop Secret Service.
+package credstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrNotFound is returned by Get for a key that holds nothing.
+var ErrNotFound = errors.New("credential not found")
+
+// Store holds secrets by key.
+type Store interface {
+	// Name describes the backend for status output.
+	Name() string
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte) error
+	Delete(key string) error
+}
+
+// writeFileAtomic writes data to path through a temporary file so a crash
+// never leaves a half-written credential behind.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
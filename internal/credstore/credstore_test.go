@@ -0,0 +1,107 @@
+package credstore
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStoreKeepsLegacyLayout(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir)
+
+	if _, err := store.Get("mal_token"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := store.Set("mal_token", []byte(`{"access_token":"a"}`)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "mal_token.json"))
+	if err != nil || string(data) != `{"access_token":"a"}` {
+		t.Fatalf("expected the token in mal_token.json, got %q %v", data, err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "mal_token.json"))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected a user-only file, got %v %v", info.Mode(), err)
+	}
+	if err := store.Delete("mal_token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("mal_token"); err != nil {
+		t.Fatalf("deleting a missing key should succeed: %v", err)
+	}
+}
+
+func newTestEncryptedStore(path, passphrase string, asked *int) *EncryptedStore {
+	store := NewEncryptedStore(path, func() (string, error) {
+		if asked != nil {
+			*asked++
+		}
+		return passphrase, nil
+	})
+	store.iterations = 1000
+	return store
+}
+
+func TestEncryptedStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.enc")
+	asked := 0
+	store := newTestEncryptedStore(path, "correct horse", &asked)
+
+	secret := []byte(`{"access_token":"very-secret"}`)
+	if err := store.Set("anilist_token", secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("mal_token", []byte("other")); err != nil {
+		t.Fatal(err)
+	}
+	if asked != 1 {
+		t.Fatalf("expected the passphrase to be asked once, got %d", asked)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("very-secret")) {
+		t.Fatal("the credential file holds the secret in plain text")
+	}
+
+	reopened := newTestEncryptedStore(path, "correct horse", nil)
+	got, err := reopened.Get("anilist_token")
+	if err != nil || !bytes.Equal(got, secret) {
+		t.Fatalf("unexpected secret %q %v", got, err)
+	}
+	if err := reopened.Delete("mal_token"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTestEncryptedStore(path, "correct horse", nil).Get("mal_token"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the deleted key to be gone, got %v", err)
+	}
+}
+
+func TestEncryptedStoreRejectsWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.enc")
+	if err := newTestEncryptedStore(path, "right", nil).Set("mal_token", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	wrong := newTestEncryptedStore(path, "wrong", nil)
+	if _, err := wrong.Get("mal_token"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("expected ErrWrongPassphrase, got %v", err)
+	}
+	if err := wrong.Set("mal_token", []byte("y")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("a wrong passphrase must not overwrite the file, got %v", err)
+	}
+	if got, err := newTestEncryptedStore(path, "right", nil).Get("mal_token"); err != nil || string(got) != "x" {
+		t.Fatalf("expected the original secret to survive, got %q %v", got, err)
+	}
+}
+
+func TestEncryptedStoreRejectsEmptyPassphrase(t *testing.T) {
+	store := newTestEncryptedStore(filepath.Join(t.TempDir(), "credentials.enc"), "", nil)
+	if err := store.Set("mal_token", []byte("x")); err == nil {
+		t.Fatal("expected an empty passphrase to be refused")
+	}
+}
@@ -0,0 +1,194 @@
+package credstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// defaultIterations is the PBKDF2-SHA256 work factor for new files.
+const defaultIterations = 600000
+
+// ErrWrongPassphrase is returned when the encrypted file cannot be opened
+// with the given passphrase.
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted credential file")
+
+// EncryptedStore keeps every secret in one file, encrypted with AES-256-GCM
+// under a key derived from a passphrase. The passphrase is asked for once,
+// on first use.
+type EncryptedStore struct {
+	path       string
+	passphrase func() (string, error)
+	iterations int
+
+	mu   sync.Mutex
+	key  []byte
+	salt []byte
+}
+
+type encryptedFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// NewEncryptedStore returns an EncryptedStore at path. passphrase is called
+// when the file is first read or written; a key file's contents work as
+// well as a typed passphrase.
+func NewEncryptedStore(path string, passphrase func() (string, error)) *EncryptedStore {
+	return &EncryptedStore{path: path, passphrase: passphrase, iterations: defaultIterations}
+}
+
+func (s *EncryptedStore) Name() string {
+	return "encrypted file (" + s.path + ")"
+}
+
+func (s *EncryptedStore) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.readLocked()
+	if err != nil {
+		return nil, err
+	}
+	value, ok := secrets[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *EncryptedStore) Set(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.readLocked()
+	if err != nil {
+		return err
+	}
+	secrets[key] = value
+	return s.writeLocked(secrets)
+}
+
+func (s *EncryptedStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.readLocked()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[key]; !ok {
+		return nil
+	}
+	delete(secrets, key)
+	return s.writeLocked(secrets)
+}
+
+// readLocked decrypts the file. A missing file is an empty store.
+func (s *EncryptedStore) readLocked() (map[string][]byte, error) {
+	raw, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return map[string][]byte{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file encryptedFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.path, err)
+	}
+	if file.Version != 1 || file.KDF != "pbkdf2-sha256" || file.Iterations <= 0 {
+		return nil, fmt.Errorf("unsupported credential file format in %s", s.path)
+	}
+	key, err := s.keyLocked(file.Salt, file.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		s.key = nil
+		return nil, ErrWrongPassphrase
+	}
+	secrets := map[string][]byte{}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse decrypted credentials: %w", err)
+	}
+	return secrets, nil
+}
+
+func (s *EncryptedStore) writeLocked(secrets map[string][]byte) error {
+	if s.salt == nil {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		if _, err := s.keyLocked(salt, s.iterations); err != nil {
+			return err
+		}
+	}
+	gcm, err := newGCM(s.key)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	data, err := json.Marshal(encryptedFile{
+		Version:    1,
+		KDF:        "pbkdf2-sha256",
+		Iterations: s.iterations,
+		Salt:       s.salt,
+		Nonce:      nonce,
+		Data:       gcm.Seal(nil, nonce, plain, nil),
+	})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// keyLocked derives the key for salt, asking for the passphrase the first
+// time. The derived key is kept for the rest of the session.
+func (s *EncryptedStore) keyLocked(salt []byte, iterations int) ([]byte, error) {
+	if s.key != nil && string(s.salt) == string(salt) && s.iterations == iterations {
+		return s.key, nil
+	}
+	passphrase, err := s.passphrase()
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("an empty passphrase cannot protect credentials")
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	s.key, s.salt, s.iterations = key, salt, iterations
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
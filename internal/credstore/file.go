@@ -0,0 +1,45 @@
+package credstore
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// FileStore keeps each secret unencrypted in <dir>/<key>.json, readable only
+// by the user.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a FileStore rooted at dir.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+func (s *FileStore) Name() string {
+	return "file (" + s.dir + ")"
+}
+
+// Path returns the file key is kept in.
+func (s *FileStore) Path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+func (s *FileStore) Get(key string) ([]byte, error) {
+	data, err := os.ReadFile(s.Path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *FileStore) Set(key string, value []byte) error {
+	return writeFileAtomic(s.Path(key), value)
+}
+
+func (s *FileStore) Delete(key string) error {
+	if err := os.Remove(s.Path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+//go:build linux
+// +build linux
+
+package credstore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	secretService       = "org.freedesktop.secrets"
+	secretServicePath   = "/org/freedesktop/secrets"
+	secretDefaultAlias  = "/org/freedesktop/secrets/aliases/default"
+	secretPromptTimeout = 2 * time.Minute
+)
+
+// secretValue is the Secret Service (oayays) secret struct.
+type secretValue struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// KeyringStore keeps secrets in the desktop keyring, such as GNOME Keyring
+// or KWallet, through the freedesktop Secret Service. Items are labelled
+// "curd <key>" and found by their application and key attributes.
+type KeyringStore struct {
+	application string
+}
+
+// NewKeyringStore returns a KeyringStore whose items carry application as
+// their application attribute.
+func NewKeyringStore(application string) (*KeyringStore, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("keyring unavailable: %w", err)
+	}
+	var owned bool
+	if err := conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, secretService).Store(&owned); err != nil {
+		return nil, fmt.Errorf("keyring unavailable: %w", err)
+	}
+	if !owned && !secretServiceActivatable(conn) {
+		return nil, fmt.Errorf("keyring unavailable: no Secret Service provider is running")
+	}
+	return &KeyringStore{application: application}, nil
+}
+
+// secretServiceActivatable reports whether D-Bus can start a Secret Service
+// provider on demand.
+func secretServiceActivatable(conn *dbus.Conn) bool {
+	var names []string
+	if err := conn.BusObject().Call("org.freedesktop.DBus.ListActivatableNames", 0).Store(&names); err != nil {
+		return false
+	}
+	for _, name := range names {
+		if name == secretService {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *KeyringStore) Name() string {
+	return "keyring (Secret Service)"
+}
+
+func (s *KeyringStore) attributes(key string) map[string]string {
+	return map[string]string{"application": s.application, "key": key}
+}
+
+func (s *KeyringStore) Get(key string) ([]byte, error) {
+	conn, session, err := s.openSession()
+	if err != nil {
+		return nil, err
+	}
+	defer closeSecretSession(conn, session)
+
+	item, err := s.findItem(conn, key)
+	if err != nil || item == "" {
+		if err == nil {
+			err = ErrNotFound
+		}
+		return nil, err
+	}
+	var secret secretValue
+	if err := conn.Object(secretService, item).Call("org.freedesktop.Secret.Item.GetSecret", 0, session).Store(&secret); err != nil {
+		return nil, fmt.Errorf("failed to read %s from the keyring: %w", key, err)
+	}
+	return secret.Value, nil
+}
+
+func (s *KeyringStore) Set(key string, value []byte) error {
+	conn, session, err := s.openSession()
+	if err != nil {
+		return err
+	}
+	defer closeSecretSession(conn, session)
+
+	collection := conn.Object(secretService, secretDefaultAlias)
+	if err := unlock(conn, []dbus.ObjectPath{secretDefaultAlias}); err != nil {
+		return err
+	}
+	properties := map[string]dbus.Variant{
+		"org.freedesktop.Secret.Item.Label":      dbus.MakeVariant(s.application + " " + key),
+		"org.freedesktop.Secret.Item.Attributes": dbus.MakeVariant(s.attributes(key)),
+	}
+	secret := secretValue{Session: session, Value: value, ContentType: "application/json"}
+	var item, prompt dbus.ObjectPath
+	if err := collection.Call("org.freedesktop.Secret.Collection.CreateItem", 0, properties, secret, true).Store(&item, &prompt); err != nil {
+		return fmt.Errorf("failed to save %s to the keyring: %w", key, err)
+	}
+	return runPrompt(conn, prompt)
+}
+
+func (s *KeyringStore) Delete(key string) error {
+	conn, session, err := s.openSession()
+	if err != nil {
+		return err
+	}
+	defer closeSecretSession(conn, session)
+
+	item, err := s.findItem(conn, key)
+	if err != nil || item == "" {
+		return err
+	}
+	var prompt dbus.ObjectPath
+	if err := conn.Object(secretService, item).Call("org.freedesktop.Secret.Item.Delete", 0).Store(&prompt); err != nil {
+		return fmt.Errorf("failed to delete %s from the keyring: %w", key, err)
+	}
+	return runPrompt(conn, prompt)
+}
+
+// openSession opens an unencrypted transfer session. The session bus is
+// local to the user, so the secret never leaves the machine.
+func (s *KeyringStore) openSession() (*dbus.Conn, dbus.ObjectPath, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, "", fmt.Errorf("keyring unavailable: %w", err)
+	}
+	var output dbus.Variant
+	var session dbus.ObjectPath
+	err = conn.Object(secretService, secretServicePath).
+		Call("org.freedesktop.Secret.Service.OpenSession", 0, "plain", dbus.MakeVariant("")).
+		Store(&output, &session)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open a keyring session: %w", err)
+	}
+	return conn, session, nil
+}
+
+func closeSecretSession(conn *dbus.Conn, session dbus.ObjectPath) {
+	conn.Object(secretService, session).Call("org.freedesktop.Secret.Session.Close", 0)
+}
+
+// findItem returns key's item, unlocking it if needed, or "" when there is
+// none.
+func (s *KeyringStore) findItem(conn *dbus.Conn, key string) (dbus.ObjectPath, error) {
+	var unlocked, locked []dbus.ObjectPath
+	err := conn.Object(secretService, secretServicePath).
+		Call("org.freedesktop.Secret.Service.SearchItems", 0, s.attributes(key)).
+		Store(&unlocked, &locked)
+	if err != nil {
+		return "", fmt.Errorf("failed to search the keyring: %w", err)
+	}
+	if len(unlocked) > 0 {
+		return unlocked[0], nil
+	}
+	if len(locked) == 0 {
+		return "", nil
+	}
+	if err := unlock(conn, locked[:1]); err != nil {
+		return "", err
+	}
+	return locked[0], nil
+}
+
+func unlock(conn *dbus.Conn, objects []dbus.ObjectPath) error {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	err := conn.Object(secretService, secretServicePath).
+		Call("org.freedesktop.Secret.Service.Unlock", 0, objects).
+		Store(&unlocked, &prompt)
+	if err != nil {
+		return fmt.Errorf("failed to unlock the keyring: %w", err)
+	}
+	return runPrompt(conn, prompt)
+}
+
+// runPrompt shows a Secret Service prompt, such as the keyring's unlock
+// dialog, and waits for the user to answer it.
+func runPrompt(conn *dbus.Conn, prompt dbus.ObjectPath) error {
+	if prompt == "" || prompt == "/" {
+		return nil
+	}
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(prompt),
+		dbus.WithMatchInterface("org.freedesktop.Secret.Prompt"),
+		dbus.WithMatchMember("Completed"),
+	}
+	if err := conn.AddMatchSignal(match...); err != nil {
+		return err
+	}
+	defer conn.RemoveMatchSignal(match...)
+	signals := make(chan *dbus.Signal, 4)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	if err := conn.Object(secretService, prompt).Call("org.freedesktop.Secret.Prompt.Prompt", 0, "").Err; err != nil {
+		return fmt.Errorf("failed to show the keyring prompt: %w", err)
+	}
+	timeout := time.After(secretPromptTimeout)
+	for {
+		select {
+		case signal := <-signals:
+			if signal.Path != prompt || signal.Name != "org.freedesktop.Secret.Prompt.Completed" {
+				continue
+			}
+			if len(signal.Body) > 0 && signal.Body[0] == true {
+				return fmt.Errorf("the keyring prompt was dismissed")
+			}
+			return nil
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for the keyring prompt")
+		}
+	}
+}
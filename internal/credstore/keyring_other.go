@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package credstore
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// KeyringStore is only available on Linux, through the Secret Service.
+type KeyringStore struct{}
+
+// NewKeyringStore reports that no keyring backend exists on this platform.
+func NewKeyringStore(application string) (*KeyringStore, error) {
+	return nil, fmt.Errorf("keyring credential store is not supported on %s", runtime.GOOS)
+}
+
+func (s *KeyringStore) Name() string                       { return "keyring" }
+func (s *KeyringStore) Get(key string) ([]byte, error)     { return nil, ErrNotFound }
+func (s *KeyringStore) Set(key string, value []byte) error { return fmt.Errorf("keyring unavailable") }
+func (s *KeyringStore) Delete(key string) error            { return nil }
@@ -2,6 +2,7 @@ package internal
 
 import (
 	"bufio"
+	"context"
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
@@ -17,6 +18,9 @@ import (
 	"time"
 
 	"github.com/gen2brain/beeep"
+
+	"github.com/wraient/curd/internal/hooks"
+	"github.com/wraient/curd/internal/keymap"
 )
 
 func EditConfig(configFilePath string) {
@@ -138,6 +142,11 @@ func ExitCurd(err error) {
 		}
 	}
 
+	closePartyHost()
+	CloseProviderPlugins()
+	closeChallengeService()
+	closeActivityHooks()
+
 	CurdOut("Have a great day!")
 	// If the error is not about the connection refused, print the error
 	if err != nil && !strings.Contains(err.Error(), "dial unix "+anime.Ep.Player.SocketPath+": connect: connection refused") {
@@ -239,7 +248,7 @@ func UpdateAnimeEntry(userCurdConfig *CurdConfig, user *User) bool {
 	var animeListOptions []SelectionOption
 	var animeListMapPreview map[string]RofiSelectPreview
 
-	if userCurdConfig.RofiSelection && userCurdConfig.ImagePreview {
+	if usePreviewMenu(userCurdConfig) {
 		animeListMapPreview = make(map[string]RofiSelectPreview)
 		// Include anime from all categories
 		for _, entry := range user.AnimeList.Watching {
@@ -311,8 +320,9 @@ func UpdateAnimeEntry(userCurdConfig *CurdConfig, user *User) bool {
 				title = entry.Media.Title.Romaji
 			}
 			animeListOptions = append(animeListOptions, SelectionOption{
-				Key:   strconv.Itoa(entry.Media.ID),
-				Label: title,
+				Key:     strconv.Itoa(entry.Media.ID),
+				Label:   title,
+				Aliases: titleAliases(entry.Media.Title),
 			})
 		}
 		for _, entry := range user.AnimeList.Completed {
@@ -321,8 +331,9 @@ func UpdateAnimeEntry(userCurdConfig *CurdConfig, user *User) bool {
 				title = entry.Media.Title.Romaji
 			}
 			animeListOptions = append(animeListOptions, SelectionOption{
-				Key:   strconv.Itoa(entry.Media.ID),
-				Label: title,
+				Key:     strconv.Itoa(entry.Media.ID),
+				Label:   title,
+				Aliases: titleAliases(entry.Media.Title),
 			})
 		}
 		for _, entry := range user.AnimeList.Paused {
@@ -331,8 +342,9 @@ func UpdateAnimeEntry(userCurdConfig *CurdConfig, user *User) bool {
 				title = entry.Media.Title.Romaji
 			}
 			animeListOptions = append(animeListOptions, SelectionOption{
-				Key:   strconv.Itoa(entry.Media.ID),
-				Label: title,
+				Key:     strconv.Itoa(entry.Media.ID),
+				Label:   title,
+				Aliases: titleAliases(entry.Media.Title),
 			})
 		}
 		for _, entry := range user.AnimeList.Dropped {
@@ -341,8 +353,9 @@ func UpdateAnimeEntry(userCurdConfig *CurdConfig, user *User) bool {
 				title = entry.Media.Title.Romaji
 			}
 			animeListOptions = append(animeListOptions, SelectionOption{
-				Key:   strconv.Itoa(entry.Media.ID),
-				Label: title,
+				Key:     strconv.Itoa(entry.Media.ID),
+				Label:   title,
+				Aliases: titleAliases(entry.Media.Title),
 			})
 		}
 		for _, entry := range user.AnimeList.Planning {
@@ -351,8 +364,9 @@ func UpdateAnimeEntry(userCurdConfig *CurdConfig, user *User) bool {
 				title = entry.Media.Title.Romaji
 			}
 			animeListOptions = append(animeListOptions, SelectionOption{
-				Key:   strconv.Itoa(entry.Media.ID),
-				Label: title,
+				Key:     strconv.Itoa(entry.Media.ID),
+				Label:   title,
+				Aliases: titleAliases(entry.Media.Title),
 			})
 		}
 		for _, entry := range user.AnimeList.Rewatching {
@@ -361,8 +375,9 @@ func UpdateAnimeEntry(userCurdConfig *CurdConfig, user *User) bool {
 				title = entry.Media.Title.Romaji
 			}
 			animeListOptions = append(animeListOptions, SelectionOption{
-				Key:   strconv.Itoa(entry.Media.ID),
-				Label: title,
+				Key:     strconv.Itoa(entry.Media.ID),
+				Label:   title,
+				Aliases: titleAliases(entry.Media.Title),
 			})
 		}
 		// Add "Back" option at the beginning
@@ -371,7 +386,7 @@ func UpdateAnimeEntry(userCurdConfig *CurdConfig, user *User) bool {
 
 	// Select anime to update
 	var selectedAnime SelectionOption
-	if userCurdConfig.RofiSelection && userCurdConfig.ImagePreview {
+	if usePreviewMenu(userCurdConfig) {
 		selectedAnime, err = DynamicSelectPreviewWithBack(animeListMapPreview, false, "<- Back")
 	} else {
 		selectedAnime, err = DynamicSelect(animeListOptions)
@@ -438,47 +453,7 @@ func UpdateAnimeEntry(userCurdConfig *CurdConfig, user *User) bool {
 		}
 
 	case "PROGRESS":
-		currentProgress := "None"
-		if selectedAnilistAnime.Progress > 0 {
-			currentProgress = strconv.Itoa(selectedAnilistAnime.Progress)
-		}
-
-		var progress string
-		if userCurdConfig.RofiSelection {
-			progress, err = GetUserInputFromRofi(fmt.Sprintf("Current progress: %s\nEnter new progress (episode number)", currentProgress))
-			if err != nil {
-				Log(fmt.Sprintf("Failed to get progress input: %v", err))
-				ExitCurd(fmt.Errorf("Failed to get progress input"))
-			}
-		} else {
-			CurdOut(fmt.Sprintf("Current progress: %s", currentProgress))
-			CurdOut("Enter new progress (episode number):")
-			fmt.Scanln(&progress)
-		}
-
-		progressNum, err := strconv.Atoi(progress)
-		if err != nil {
-			Log(fmt.Sprintf("Failed to convert progress to number: %v", err))
-			ExitCurd(fmt.Errorf("Failed to convert progress to number"))
-		}
-
-		// Get the correct IDs for both services
-		anilistID := selectedAnilistAnime.Media.ID
-		malID := selectedAnilistAnime.Media.ID
-		service := GetTrackingService(userCurdConfig)
-		if service == "mal" {
-			// selectedAnilistAnime.Media.ID is MAL ID, convert to AniList ID
-			anilistID, _ = ConvertMALIDToAnilist(malID, "")
-		} else {
-			// selectedAnilistAnime.Media.ID is AniList ID, convert to MAL ID
-			malID, _ = GetAnimeMalID(anilistID)
-		}
-
-		err = UpdateAnimeProgressDual(user.AnilistToken, user.MalToken, anilistID, malID, progressNum, userCurdConfig)
-		if err != nil {
-			Log(fmt.Sprintf("Failed to update anime progress: %v", err))
-			ExitCurd(fmt.Errorf("Failed to update anime progress"))
-		}
+		changeAnimeProgress(userCurdConfig, user, selectedAnilistAnime)
 
 	case "SCORE":
 		currentScore := "None"
@@ -510,6 +485,53 @@ func UpdateAnimeEntry(userCurdConfig *CurdConfig, user *User) bool {
 	return false // Return false to indicate normal completion
 }
 
+// changeAnimeProgress asks for the new progress of a list entry and sends it
+// to the trackers.
+func changeAnimeProgress(userCurdConfig *CurdConfig, user *User, selectedAnilistAnime *Entry) {
+	currentProgress := "None"
+	if selectedAnilistAnime.Progress > 0 {
+		currentProgress = strconv.Itoa(selectedAnilistAnime.Progress)
+	}
+
+	var progress string
+	var err error
+	if userCurdConfig.RofiSelection {
+		progress, err = GetUserInput(fmt.Sprintf("Current progress: %s\nEnter new progress (episode number)", currentProgress))
+		if err != nil {
+			Log(fmt.Sprintf("Failed to get progress input: %v", err))
+			ExitCurd(fmt.Errorf("Failed to get progress input"))
+		}
+	} else {
+		CurdOut(fmt.Sprintf("Current progress: %s", currentProgress))
+		CurdOut("Enter new progress (episode number):")
+		fmt.Scanln(&progress)
+	}
+
+	progressNum, err := strconv.Atoi(progress)
+	if err != nil {
+		Log(fmt.Sprintf("Failed to convert progress to number: %v", err))
+		ExitCurd(fmt.Errorf("Failed to convert progress to number"))
+	}
+
+	// Get the correct IDs for both services
+	anilistID := selectedAnilistAnime.Media.ID
+	malID := selectedAnilistAnime.Media.ID
+	service := GetTrackingService(userCurdConfig)
+	if service == "mal" {
+		// selectedAnilistAnime.Media.ID is MAL ID, convert to AniList ID
+		anilistID, _ = ConvertMALIDToAnilist(malID, "")
+	} else {
+		// selectedAnilistAnime.Media.ID is AniList ID, convert to MAL ID
+		malID, _ = GetAnimeMalID(anilistID)
+	}
+
+	err = UpdateAnimeProgressDual(user.AnilistToken, user.MalToken, anilistID, malID, progressNum, userCurdConfig)
+	if err != nil {
+		Log(fmt.Sprintf("Failed to update anime progress: %v", err))
+		ExitCurd(fmt.Errorf("Failed to update anime progress"))
+	}
+}
+
 func UpdateCurd(repo, fileName string) error {
 	// Get the path of the currently running executable
 	executablePath, err := os.Executable()
@@ -555,7 +577,7 @@ func UpdateCurd(repo, fileName string) error {
 	tmpPath := executablePath + ".tmp"
 
 	// Download the curd executable
-	resp, err := http.Get(url)
+	resp, err := sharedHTTPClient.Get(url)
 	if err != nil {
 		return fmt.Errorf("failed to download file: %v", err)
 	}
@@ -619,11 +641,12 @@ func AddNewAnime(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseA
 	var err error
 	var anilistSelectedOption SelectionOption
 
+	DiscordMenuPresence(userCurdConfig, "anime search")
 	if userCurdConfig.RofiSelection {
-		userInput, err := GetUserInputFromRofi("Enter the anime name")
+		userInput, err := GetUserInput("Enter the anime name")
 		if err != nil {
 			Log("Error getting user input: " + err.Error())
-			ExitCurd(fmt.Errorf("Error getting user input: " + err.Error()))
+			ExitCurd(fmt.Errorf("Error getting user input: %w", err))
 		}
 		query = userInput
 	} else {
@@ -632,7 +655,7 @@ func AddNewAnime(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseA
 		input, _ := reader.ReadString('\n')
 		query = strings.TrimSpace(input)
 	}
-	if userCurdConfig.RofiSelection && userCurdConfig.ImagePreview {
+	if usePreviewMenu(userCurdConfig) {
 		animeMapPreview, err = SearchAnimeAnilistPreview(query, user.Token)
 	} else {
 		result, err := SearchAnimeUnified(query, user.Token, userCurdConfig, false)
@@ -646,7 +669,7 @@ func AddNewAnime(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseA
 		Log(fmt.Sprintf("Failed to search anime: %v", err))
 		ExitCurd(fmt.Errorf("Failed to search anime"))
 	}
-	if userCurdConfig.RofiSelection && userCurdConfig.ImagePreview {
+	if usePreviewMenu(userCurdConfig) {
 		anilistSelectedOption, err = DynamicSelectPreview(animeMapPreview, false)
 	} else {
 		anilistSelectedOption, err = DynamicSelect(animeOptions)
@@ -696,7 +719,7 @@ func AddNewAnime(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseA
 	}
 
 	// Refresh user's anime list after adding
-	if userCurdConfig.RofiSelection && userCurdConfig.ImagePreview {
+	if usePreviewMenu(userCurdConfig) {
 		anilistUserDataPreview, err := GetUserDataPreview(user.Token, user.Id)
 		if err != nil {
 			Log(fmt.Sprintf("Failed to refresh anime list: %v", err))
@@ -723,6 +746,9 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 	// Filter anime list based on selected category
 	var animeListOptions []SelectionOption
 	var animeListMapPreview map[string]RofiSelectPreview
+	var menuSelectedAnime SelectionOption
+	var dashboardEpisode int
+	var listCategory SelectionOption
 
 	// Get user id, username and anime list.
 	// If AniList is temporarily down and MAL is available, fall back for this session.
@@ -753,8 +779,16 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 		}
 	}
 
+	// With several accounts on the machine, make sure the right one is about
+	// to be updated.
+	if confirmActiveAccount(userCurdConfig, user) {
+		switchAccountMenu(userCurdConfig, user, databaseAnimes)
+		SetupCurd(userCurdConfig, anime, user, databaseAnimes, HistoryFilePath(userCurdConfig))
+		return
+	}
+
 	// Get the anime list data
-	if userCurdConfig.RofiSelection && userCurdConfig.ImagePreview {
+	if usePreviewMenu(userCurdConfig) {
 		anilistUserDataPreview, err = GetUserDataUnified(user.Token, user.Id, userCurdConfig, true)
 		if err != nil {
 			Log(fmt.Sprintf("Failed to get user data preview: %v", err))
@@ -773,7 +807,7 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 	// If continueLast flag is set, directly get the last watched anime
 	if anime.Ep.ContinueLast {
 		// Get the last anime ID from the curd_id file
-		idFilePath := filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "curd_id")
+		idFilePath := lastWatchedIDPath(userCurdConfig)
 		idBytes, err := os.ReadFile(idFilePath)
 		if err != nil {
 			Log("Error reading curd_id file: " + err.Error())
@@ -803,18 +837,23 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 	} else {
 		// Skip category selection if Current flag is set
 		var categorySelection SelectionOption
-		if userCurdConfig.CurrentCategory {
+		if reopenCategory.Key != "" {
+			// Back from an action on one of the list's shows
+			categorySelection, reopenCategory = reopenCategory, SelectionOption{}
+		} else if userCurdConfig.CurrentCategory {
 			categorySelection = SelectionOption{
 				Key:   "CURRENT",
 				Label: "Currently Watching",
 			}
 		} else {
-			// Create category selection map
-			// Get ordered categories
-			orderedCategories := getOrderedCategories(userCurdConfig)
-
-			// Use DynamicSelect with ordered categories directly
-			categorySelection, err = DynamicSelect(orderedCategories)
+			DiscordMenuPresence(userCurdConfig, "the main menu")
+			if useDashboard(userCurdConfig) {
+				// The dashboard may pick the anime and episode as well
+				categorySelection, menuSelectedAnime, dashboardEpisode, err = dashboardSelect(userCurdConfig, user)
+			} else {
+				// Use DynamicSelect with ordered categories directly
+				categorySelection, err = DynamicSelect(getOrderedCategories(userCurdConfig))
+			}
 
 			if err != nil {
 				Log(fmt.Sprintf("Failed to select category: %v", err))
@@ -844,6 +883,35 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 			} else if categorySelection.Key == "UNTRACKED" {
 				ClearScreen()
 				WatchUntracked(userCurdConfig)
+			} else if categorySelection.Key == "FRANCHISE" {
+				ClearScreen()
+				menuSelectedAnime, err = FranchiseMenu(userCurdConfig, user)
+				if err != nil {
+					Log(fmt.Sprintf("Failed to show franchise watch order: %v", err))
+					ExitCurd(fmt.Errorf("Failed to show franchise watch order: %v", err))
+				}
+				if menuSelectedAnime.Key == "back" {
+					SetupCurd(userCurdConfig, anime, user, databaseAnimes, databaseFile)
+					return
+				}
+			} else if categorySelection.Key == "DISCOVER" {
+				ClearScreen()
+				menuSelectedAnime, err = DiscoverMenu(userCurdConfig, user)
+				if err != nil {
+					Log(fmt.Sprintf("Failed to show discover menu: %v", err))
+					ExitCurd(fmt.Errorf("Failed to show discover menu: %v", err))
+				}
+				if menuSelectedAnime.Key == "-1" {
+					ExitCurd(nil)
+				}
+				if menuSelectedAnime.Key == "back" {
+					SetupCurd(userCurdConfig, anime, user, databaseAnimes, databaseFile)
+					return
+				}
+			} else if categorySelection.Key == "ACCOUNT" {
+				switchAccountMenu(userCurdConfig, user, databaseAnimes)
+				SetupCurd(userCurdConfig, anime, user, databaseAnimes, HistoryFilePath(userCurdConfig))
+				return
 			} else if categorySelection.Key == "CONTINUE_LAST" {
 				anime.Ep.ContinueLast = true
 			}
@@ -851,7 +919,9 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 			ClearScreen()
 		}
 
-		if userCurdConfig.RofiSelection && userCurdConfig.ImagePreview {
+		DiscordMenuPresence(userCurdConfig, categorySelection.Label)
+		listCategory = categorySelection
+		if usePreviewMenu(userCurdConfig) {
 			animeListMapPreview = make(map[string]RofiSelectPreview)
 			for _, entry := range getEntriesByCategory(user.AnimeList, categorySelection.Key) {
 				title := entry.Media.Title.English
@@ -871,8 +941,9 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 					title = entry.Media.Title.Romaji
 				}
 				animeListOptions = append(animeListOptions, SelectionOption{
-					Key:   strconv.Itoa(entry.Media.ID),
-					Label: title,
+					Key:     strconv.Itoa(entry.Media.ID),
+					Label:   title,
+					Aliases: titleAliases(entry.Media.Title),
 				})
 			}
 			// Add "Back" option at the beginning
@@ -885,7 +956,7 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 
 	if anime.Ep.ContinueLast {
 		// Get the last watched anime ID from the curd_id file
-		curdIDPath := filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "curd_id")
+		curdIDPath := lastWatchedIDPath(userCurdConfig)
 		curdIDBytes, err := os.ReadFile(curdIDPath)
 		if err != nil {
 			Log(fmt.Sprintf("Error reading curd_id file: %v", err))
@@ -900,10 +971,12 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 
 		anime.AnilistId = lastWatchedID
 		anilistSelectedOption.Key = strconv.Itoa(lastWatchedID)
+	} else if menuSelectedAnime.Key != "" {
+		anilistSelectedOption = menuSelectedAnime
 	} else {
 		// Select anime to watch (Anilist)
 		var err error
-		if userCurdConfig.RofiSelection && userCurdConfig.ImagePreview {
+		if usePreviewMenu(userCurdConfig) {
 			anilistSelectedOption, err = DynamicSelectPreviewWithBack(animeListMapPreview, true, "<- Back")
 		} else {
 			// Add "Add new anime" option to the slice
@@ -912,7 +985,14 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 				Label: "Add new anime",
 			})
 
-			anilistSelectedOption, err = DynamicSelect(animeListOptions)
+			var action keymap.Action
+			anilistSelectedOption, action, err = DynamicSelectWithActions(animeListOptions, animeListActions...)
+			if err == nil && action != "" {
+				runAnimeListAction(userCurdConfig, user, databaseAnimes, databaseFile, action, anilistSelectedOption.Key)
+				reopenCategory = listCategory
+				SetupCurd(userCurdConfig, anime, user, databaseAnimes, databaseFile)
+				return
+			}
 		}
 		if err != nil {
 			Log(fmt.Sprintf("Error selecting anime: %v", err))
@@ -985,6 +1065,12 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 	anime.TotalEpisodes = selectedAnilistAnime.Media.Episodes
 	anime.CoverImage = selectedAnilistAnime.CoverImage
 	anime.Ep.Number = selectedAnilistAnime.Progress + 1
+	// An episode picked in the dashboard replaces the next one. Watching an
+	// earlier one again must not lower the tracked progress.
+	if dashboardEpisode > 0 {
+		anime.Ep.Number = dashboardEpisode
+		anime.Rewatching = dashboardEpisode <= selectedAnilistAnime.Progress
+	}
 	userQuery = anime.Title.Romaji
 
 	// Find anime in Local history
@@ -1073,7 +1159,7 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 				ExitCurd(err)
 			}
 			// Refresh user's anime list after adding
-			if userCurdConfig.RofiSelection && userCurdConfig.ImagePreview {
+			if usePreviewMenu(userCurdConfig) {
 				anilistUserDataPreview, err := GetUserDataUnified(user.Token, user.Id, userCurdConfig, true)
 				if err != nil {
 					Log("Error refreshing anime list: " + err.Error())
@@ -1100,7 +1186,7 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 		idStrToFind = strconv.Itoa(anime.MalId)
 	}
 	if temp_anime, err := FindAnimeByAnilistID(user.AnimeList, idStrToFind); err == nil {
-		if temp_anime.Progress > anime.Ep.Number {
+		if temp_anime.Progress > anime.Ep.Number && !anime.Rewatching {
 			anime.Ep.Number = temp_anime.Progress
 			anime.Ep.Player.PlaybackTime = 0
 			anime.Ep.Resume = false
@@ -1156,10 +1242,10 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 				CurdOut(fmt.Sprintf("Your AniList progress: %d", selectedAnilistAnime.Progress))
 				var episodeNumber int
 				if userCurdConfig.RofiSelection {
-					userInput, err := GetUserInputFromRofi("Enter the episode you want to start from")
+					userInput, err := GetUserInput("Enter the episode you want to start from")
 					if err != nil {
 						Log("Error getting user input: " + err.Error())
-						ExitCurd(fmt.Errorf("Error getting user input: " + err.Error()))
+						ExitCurd(fmt.Errorf("Error getting user input: %w", err))
 					}
 					episodeNumber, err = strconv.Atoi(userInput)
 				} else {
@@ -1175,10 +1261,10 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 		Log(fmt.Sprintf("Weird case: anime.TotalEpisodes < anime.Ep.Number: %v < %v", anime.TotalEpisodes, anime.Ep.Number))
 		var answer string
 		if userCurdConfig.RofiSelection {
-			userInput, err := GetUserInputFromRofi("Would like to start the anime from beginning? (y/n)")
+			userInput, err := GetUserInput("Would like to start the anime from beginning? (y/n)")
 			if err != nil {
 				Log("Error getting user input: " + err.Error())
-				ExitCurd(fmt.Errorf("Error getting user input: " + err.Error()))
+				ExitCurd(fmt.Errorf("Error getting user input: %w", err))
 			}
 			answer = userInput
 		} else {
@@ -1191,6 +1277,29 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 			anime.Ep.Number = anime.TotalEpisodes
 		}
 	}
+
+	// Let the user pick episodes unless the dashboard already picked one
+	if userCurdConfig.EpisodeBrowser && dashboardEpisode == 0 && !anime.Ep.ContinueLast {
+		action, picked, err := BrowseEpisodes(userCurdConfig, anime)
+		if err != nil {
+			Log(fmt.Sprintf("Episode browser failed: %v", err))
+			CurdOut(fmt.Sprintf("Episode browser failed, continuing with episode %d: %v", anime.Ep.Number, err))
+			return
+		}
+		switch action {
+		case "play":
+			startPlaylist(userCurdConfig, anime, picked)
+		case "download":
+			downloadEpisodes(anime, userCurdConfig, picked)
+			*anime = Anime{}
+			SetupCurd(userCurdConfig, anime, user, databaseAnimes, databaseFile)
+		case "-1":
+			ExitCurd(nil)
+		default:
+			*anime = Anime{}
+			SetupCurd(userCurdConfig, anime, user, databaseAnimes, databaseFile)
+		}
+	}
 }
 
 // CreateOrWriteTokenFile creates the token file if it doesn't exist and writes the token to it
@@ -1213,6 +1322,7 @@ func WriteTokenToFile(token string, filePath string) error {
 }
 
 func StartCurd(userCurdConfig *CurdConfig, anime *Anime) string {
+	trackPlaylistEpisode(anime)
 	if err := resolveRuntimeProviderID(userCurdConfig, anime); err != nil {
 		Log(fmt.Sprintf("Failed to resolve provider id: %v", err))
 		CurdOut("Failed to resolve anime provider id: " + err.Error())
@@ -1288,7 +1398,7 @@ func StartCurd(userCurdConfig *CurdConfig, anime *Anime) string {
 						Log(fmt.Sprintf("EpisodesList returned no episodes for provider %s and id %s after ResolveEpisodeURL error: %v", episodeProviderName, episodeProviderID, linkErr))
 						continue
 					}
-					episodeNumber, promptErr := promptPositiveEpisodeNumber(userCurdConfig, fmt.Sprintf("Enter the episode (%v episodes)", episodeList[len(episodeList)-1]))
+					episodeNumber, promptErr := pickEpisodeNumber(userCurdConfig, anime, episodeList)
 					if promptErr != nil {
 						Log("Invalid episode input: " + promptErr.Error())
 						CurdOut("Invalid episode number")
@@ -1331,14 +1441,15 @@ episodeLinksReady:
 
 	// Modify the goroutine in main.go where next episode links are fetched
 	// Get next episode link in parallel
+	playback := beginPlayback()
 	go func() {
-		nextEpNum := anime.Ep.Number + 1
-		if nextEpNum <= anime.TotalEpisodes {
+		nextEpNum := NextEpisodeNumber(anime)
+		if nextEpNum > 0 && nextEpNum <= anime.TotalEpisodes {
 			// Get next canon episode number if filler skip is enabled
-			if userCurdConfig.SkipFiller && IsEpisodeFiller(anime.FillerEpisodes, anime.Ep.Number) {
+			if userCurdConfig.SkipFiller && !InPlaylist(anime) && IsEpisodeFiller(anime.FillerEpisodes, anime.Ep.Number) {
 				nextEpNum = GetNextCanonEpisode(anime.FillerEpisodes, nextEpNum)
 			}
-			nextResult, err := ResolveEpisodeURLForPlayback(*userCurdConfig, anime, nextEpNum)
+			nextResult, err := ResolveEpisodeURLForPlaybackContext(navigationContext(), *userCurdConfig, anime, nextEpNum)
 			if err != nil {
 				Log(fmt.Sprintf("Error getting next episode link for ep %d: %v", nextEpNum, err))
 			} else {
@@ -1350,14 +1461,15 @@ episodeLinksReady:
 					Mode:         nextResult.Mode,
 					LinkHints:    nextResult.LinkHints,
 				}
+				warmNextEpisode(playback, userCurdConfig, anime.Ep.NextEpisode)
 			}
 		} else {
 			Log(fmt.Sprintf("Next episode %d exceeds total episodes %d, skipping prefetch", nextEpNum, anime.TotalEpisodes))
 		}
 	}()
 
-	// Write anime.AnilistId to curd_id in the storage path
-	idFilePath := filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "curd_id")
+	// Write anime.AnilistId to curd_id in the account directory
+	idFilePath := lastWatchedIDPath(userCurdConfig)
 	Log(fmt.Sprintf("idFilePath: %v", idFilePath))
 	if err := os.MkdirAll(filepath.Dir(idFilePath), 0755); err != nil {
 		Log(fmt.Sprintf("Failed to create directory for curd_id: %v", err))
@@ -1391,13 +1503,17 @@ episodeLinksReady:
 	} else {
 		CurdOut(fmt.Sprintf("%s - Episode %d", GetAnimeName(*anime), anime.Ep.Number))
 	}
-	mpvSocketPath, err := StartVideo(PrioritizeLink(anime.Ep.Links), []string{}, fmt.Sprintf("%s - Episode %d", GetAnimeName(*anime), anime.Ep.Number), anime)
+	playerRunning := anime.Ep.Player.SocketPath != "" && IsMPVRunning(anime.Ep.Player.SocketPath)
+	mpvSocketPath, err := StartVideo(selectPlaybackLink(userCurdConfig, anime), []string{}, fmt.Sprintf("%s - Episode %d", GetAnimeName(*anime), anime.Ep.Number), anime)
 
 	if err != nil {
 		Log("Failed to start mpv")
 		RestoreScreen()
 		os.Exit(1)
 	}
+	if !playerRunning {
+		watchStreamPickerKey(userCurdConfig, anime, mpvSocketPath)
+	}
 
 	return mpvSocketPath
 }
@@ -1428,7 +1544,9 @@ func resolveRuntimeProviderID(userCurdConfig *CurdConfig, anime *Anime) error {
 		query = anime.Title.English
 	}
 
-	resolved, err := resolveProviderID(provider, providerID, query)
+	resolved, err := interruptible(func(ctx context.Context) (string, error) {
+		return resolveProviderID(ctx, provider, providerID, query)
+	})
 	if err != nil {
 		return err
 	}
@@ -1513,7 +1631,7 @@ func CheckAndDownloadFiles(storagePath string, filesToCheck []string) error {
 		}
 
 		// Download file if it doesn't exist
-		resp, err := http.Get(baseURL + fileName)
+		resp, err := sharedHTTPClient.Get(baseURL + fileName)
 		if err != nil {
 			return fmt.Errorf("failed to download %s: %v", fileName, err)
 		}
@@ -1568,7 +1686,11 @@ func NextEpisodePromptCLI(userCurdConfig *CurdConfig) bool {
 	anime := GetGlobalAnime()
 
 	// Show the next episode number that will be started
-	nextEpisodeNum := anime.Ep.Number + 1
+	nextEpisodeNum := NextEpisodeNumber(anime)
+	if nextEpisodeNum == 0 {
+		CurdOut("Playlist finished!")
+		return false
+	}
 	CurdOut(fmt.Sprintf("Start next episode (%d)?", nextEpisodeNum))
 
 	// Create options for the selection - no "quit" option since it's built into selection menu
@@ -1611,8 +1733,12 @@ func NextEpisodePromptContinuous(userCurdConfig *CurdConfig, databaseFile string
 			return
 		}
 
-		// Show the next episode number that will be started
-		nextEpisodeNum := anime.Ep.Number + 1
+		// Show the next episode number that will be started; the last
+		// episode of a playlist has none to offer
+		nextEpisodeNum := NextEpisodeNumber(anime)
+		if nextEpisodeNum == 0 {
+			return
+		}
 		CurdOut(fmt.Sprintf("Continue to next episode (%d) or quit?", nextEpisodeNum))
 
 		// Create options for the selection - no "quit" option since it's built into selection menu
@@ -1638,6 +1764,7 @@ func NextEpisodePromptContinuous(userCurdConfig *CurdConfig, databaseFile string
 			if int(percentageWatched) >= userCurdConfig.PercentageToMarkComplete {
 				// Episode is considered completed, mark it and update progress
 				anime.Ep.IsCompleted = true
+				FireAnimeHook(hooks.EpisodeComplete, anime)
 
 				// Update local database
 				err = LocalUpdateAnime(databaseFile, anime.AnilistId, anime.ProviderId, anime.Ep.Number, anime.Ep.Player.PlaybackTime, ConvertSecondsToMinutes(anime.Ep.Duration), GetAnimeName(*anime), anime.ProviderName)
@@ -1668,6 +1795,7 @@ func NextEpisodePromptContinuous(userCurdConfig *CurdConfig, databaseFile string
 		if selectedOption.Key == "yes" {
 			// User wants to start next episode immediately
 			anime.Ep.IsCompleted = true
+			FireAnimeHook(hooks.EpisodeComplete, anime)
 
 			// Update database with completed episode first
 			err = LocalUpdateAnime(databaseFile, anime.AnilistId, anime.ProviderId, anime.Ep.Number, anime.Ep.Player.PlaybackTime, ConvertSecondsToMinutes(anime.Ep.Duration), GetAnimeName(*anime), anime.ProviderName)
@@ -1687,8 +1815,8 @@ func NextEpisodePromptContinuous(userCurdConfig *CurdConfig, databaseFile string
 				}()
 			}
 
-			// Increment to next episode and update database with next episode number and 0 playback time
-			anime.Ep.Number++
+			// Move to the next episode and update database with next episode number and 0 playback time
+			anime.Ep.Number = nextEpisodeNum
 
 			// Use prefetched links if available for the next episode
 			if (anime.Ep.NextEpisode.Number == anime.Ep.Number) && (len(anime.Ep.NextEpisode.Links) > 0) {
@@ -1718,7 +1846,10 @@ func NextEpisodePromptRofi(userCurdConfig *CurdConfig) bool {
 	anime := GetGlobalAnime()
 
 	// Show the next episode number that will be started
-	nextEpisodeNum := anime.Ep.Number + 1
+	nextEpisodeNum := NextEpisodeNumber(anime)
+	if nextEpisodeNum == 0 {
+		return false
+	}
 
 	// Create options for the selection
 	options := []SelectionOption{
@@ -1762,6 +1893,9 @@ func StartNextEpisode(anime *Anime, userCurdConfig *CurdConfig, databaseFile str
 		}
 
 		CurdOut("Series completed!")
+		if ContinueWithSequel(userCurdConfig, anime, user, databaseFile) {
+			return
+		}
 		ExitCurd(nil)
 		return
 	}
@@ -1782,8 +1916,21 @@ func StartNextEpisode(anime *Anime, userCurdConfig *CurdConfig, databaseFile str
 		return
 	}
 
-	// Increment episode number
-	anime.Ep.Number++
+	// Move to the next episode, or along the playlist picked in the
+	// episode browser
+	nextEpisode := NextEpisodeNumber(anime)
+	if nextEpisode == 0 {
+		if !anime.Rewatching {
+			err := UpdateAnimeProgressDual(user.AnilistToken, user.MalToken, anime.AnilistId, anime.MalId, prevEpisode, userCurdConfig)
+			if err != nil {
+				Log("Error updating progress: " + err.Error())
+			}
+		}
+		CurdOut("Playlist finished!")
+		ExitCurd(nil)
+		return
+	}
+	anime.Ep.Number = nextEpisode
 
 	// Check if we've reached the end of the series
 	if anime.TotalEpisodes > 0 && anime.Ep.Number > anime.TotalEpisodes {
@@ -1842,6 +1989,9 @@ func HandleLastEpisodeCompletion(userCurdConfig *CurdConfig, anime *Anime, user
 		Log("HandleLastEpisodeCompletion called for an airing anime – skipping rating/completion.")
 		return
 	}
+	if anime.TotalEpisodes > 0 && anime.Ep.Number == anime.TotalEpisodes {
+		FireAnimeHook(hooks.ShowComplete, anime)
+	}
 
 	// Check if this is the last episode and scoring is enabled
 	if userCurdConfig.ScoreOnCompletion && anime.TotalEpisodes > 0 && anime.Ep.Number == anime.TotalEpisodes {
@@ -1,10 +1,11 @@
 package curdhost
 
 import (
+	"context"
 	"fmt"
 	"net/http"
-	"net/url"
 	"strings"
+	"time"
 )
 
 // PromptOption is a minimal menu item for provider-driven prompts.
@@ -13,6 +14,15 @@ type PromptOption struct {
 	Label string
 }
 
+// Clearance is what a solved browser challenge leaves behind: the cookies
+// the site set, already added to HTTPClient's jar, and the user agent
+// requests must send for the site to accept them.
+type Clearance struct {
+	UserAgent string
+	Cookies   []*http.Cookie
+	Expires   time.Time
+}
+
 // Host hooks are wired from the main application package during init.
 var (
 	HTTPClient             func() *http.Client
@@ -23,7 +33,14 @@ var (
 	PersistSubStylePreference func(style string) error
 	StoragePath             func() string
 	AnimeNameLanguage      func() string
-	SetCookiesForAnimepahe func(u *url.URL, cookies []*http.Cookie)
+	// SolveChallenge clears the Cloudflare or DDoS-Guard check in front of
+	// siteURL through the shared challenge service.
+	SolveChallenge func(ctx context.Context, siteURL string) (Clearance, error)
+	// CachedChallenge returns the unexpired clearance from an earlier solve
+	// for siteURL's host.
+	CachedChallenge func(siteURL string) (Clearance, bool)
+	// ForgetChallenge drops siteURL's clearance once the site rejects it.
+	ForgetChallenge func(siteURL string)
 )
 
 func HTTPStatusOK(statusCode int) bool {
@@ -0,0 +1,220 @@
+package internal
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/wraient/curd/internal/dashboard"
+)
+
+var (
+	htmlTagRegex = regexp.MustCompile(`<[^>]*>`)
+
+	dashboardMu sync.Mutex
+	// trackerSync is the outcome of the last tracker update, for the status
+	// bar.
+	trackerSync struct {
+		at  time.Time
+		err error
+	}
+	downloadsActive, downloadsDone int
+	// dashboardCategory is where the dashboard opens again after a menu it
+	// started returns to it.
+	dashboardCategory = "CURRENT"
+)
+
+// useDashboard reports whether the main menu is the full-screen dashboard
+// rather than the chained selection prompts. The other menu backends always
+// use the prompts.
+func useDashboard(config *CurdConfig) bool {
+	return config.Dashboard && menuBackend(config) == menuBackendTUI
+}
+
+// noteTrackerSync records the outcome of a tracker update.
+func noteTrackerSync(err error) {
+	dashboardMu.Lock()
+	defer dashboardMu.Unlock()
+	trackerSync.at, trackerSync.err = time.Now(), err
+}
+
+// trackDownload counts a download as running until the returned function
+// is called with its outcome.
+func trackDownload() func(error) {
+	dashboardMu.Lock()
+	downloadsActive++
+	dashboardMu.Unlock()
+	return func(err error) {
+		dashboardMu.Lock()
+		defer dashboardMu.Unlock()
+		downloadsActive--
+		if err == nil {
+			downloadsDone++
+		}
+	}
+}
+
+// dashboardSource feeds the dashboard from the user's list, AniList and
+// Jikan.
+type dashboardSource struct {
+	config *CurdConfig
+	user   *User
+}
+
+func (s *dashboardSource) Entries(category string) []dashboard.Entry {
+	entries := getEntriesByCategory(s.user.AnimeList, category)
+	list := make([]dashboard.Entry, 0, len(entries))
+	for _, entry := range entries {
+		title := entry.Media.Title.English
+		if title == "" || s.config.AnimeNameLanguage == "romaji" {
+			title = entry.Media.Title.Romaji
+		}
+		list = append(list, dashboard.Entry{
+			Key:      strconv.Itoa(entry.Media.ID),
+			Title:    title,
+			Status:   entry.Status,
+			Progress: entry.Progress,
+			Total:    entry.Media.Episodes,
+		})
+	}
+	return list
+}
+
+func (s *dashboardSource) Details(entry dashboard.Entry) (dashboard.Details, error) {
+	id, err := strconv.Atoi(entry.Key)
+	if err != nil {
+		return dashboard.Details{}, fmt.Errorf("invalid anime id %q", entry.Key)
+	}
+	byMalID := GetTrackingService(s.config) == "mal"
+	overview, err := GetAnimeOverview(id, byMalID)
+	if err != nil {
+		Log(fmt.Sprintf("Dashboard: failed to get details of %d: %v", id, err))
+		return dashboard.Details{}, fmt.Errorf("failed to get details: %w", err)
+	}
+
+	details := dashboard.Details{
+		CoverImage:  overview.CoverImage,
+		Synopsis:    cleanSynopsis(overview.Description),
+		Format:      overview.Format,
+		Genres:      overview.Genres,
+		NextEpisode: overview.NextEpisode,
+		NextAiring:  overview.NextAiring,
+	}
+
+	malID := overview.MalID
+	if byMalID {
+		malID = id
+	}
+	if malID == 0 {
+		return details, nil
+	}
+	// Episode titles and markers are extras; the details stand without them.
+	episodes, err := FetchJikanEpisodes(malID)
+	if err != nil {
+		Log(fmt.Sprintf("Dashboard: failed to get episodes of MAL %d: %v", malID, err))
+		return details, nil
+	}
+	for _, episode := range episodes {
+		details.Episodes = append(details.Episodes, dashboard.Episode{
+			Number: episode.EpisodeID,
+			Title:  episode.Title,
+			Filler: episode.IsFiller,
+			Recap:  episode.IsRecap,
+		})
+	}
+	return details, nil
+}
+
+func (s *dashboardSource) Status() dashboard.Status {
+	var status dashboard.Status
+	if anime := GetGlobalAnime(); anime != nil && anime.ProviderName != "" {
+		status.Provider = anime.ProviderName
+	} else {
+		status.Provider = strings.Join(ConfiguredProviderNames(s.config), ", ")
+	}
+
+	tracker := GetServiceName(s.config)
+	if s.config.DualTracking {
+		tracker = "AniList + MyAnimeList"
+	}
+
+	dashboardMu.Lock()
+	defer dashboardMu.Unlock()
+	switch {
+	case trackerSync.at.IsZero():
+		status.Tracker = tracker + ": no updates yet"
+	case trackerSync.err != nil:
+		status.Tracker = tracker + ": last update failed"
+	default:
+		status.Tracker = fmt.Sprintf("%s: synced %s ago", tracker, time.Since(trackerSync.at).Round(time.Second))
+	}
+
+	switch {
+	case downloadsActive > 0:
+		status.Downloads = fmt.Sprintf("%d running, %d done", downloadsActive, downloadsDone)
+	case downloadsDone > 0:
+		status.Downloads = fmt.Sprintf("%d done", downloadsDone)
+	default:
+		status.Downloads = "none"
+	}
+	return status
+}
+
+// Preview shows the cover through the image previewer when ImagePreview is
+// on.
+func (s *dashboardSource) Preview(coverImage string) {
+	if !s.config.ImagePreview {
+		return
+	}
+	if err := showCachedImagePreview(coverImage); err != nil {
+		Log(fmt.Sprintf("Dashboard: cover preview failed: %v", err))
+	}
+}
+
+func cleanSynopsis(description string) string {
+	description = strings.ReplaceAll(description, "<br>", "\n")
+	return strings.TrimSpace(html.UnescapeString(htmlTagRegex.ReplaceAllString(description, "")))
+}
+
+// dashboardSelect runs the dashboard and translates its outcome into the
+// main menu's selections: the category, the anime chosen in it if any, and
+// the episode to play, zero for the next one. A quit is category "-1".
+func dashboardSelect(config *CurdConfig, user *User) (SelectionOption, SelectionOption, int, error) {
+	options := getOrderedCategories(config)
+	categories := make([]dashboard.Category, 0, len(options))
+	labels := make(map[string]string, len(options))
+	for _, option := range options {
+		categories = append(categories, dashboard.Category{
+			Key:   option.Key,
+			Label: option.Label,
+			List:  option.Key == "CURRENT" || option.Key == "ALL",
+		})
+		labels[option.Key] = option.Label
+	}
+
+	model := dashboard.New(&dashboardSource{config: config, user: user}, categories, dashboardCategory)
+	p := tea.NewProgram(model, tea.WithAltScreen())
+	activeProgram = p
+	defer func() { activeProgram = nil }()
+	if _, err := p.Run(); err != nil {
+		return SelectionOption{}, SelectionOption{}, 0, fmt.Errorf("dashboard failed: %w", err)
+	}
+
+	choice := model.Choice()
+	if choice.Quit {
+		cancelNavigation()
+		return SelectionOption{Key: "-1", Label: "Quit"}, SelectionOption{}, 0, nil
+	}
+	dashboardCategory = choice.Category
+	category := SelectionOption{Key: choice.Category, Label: labels[choice.Category]}
+	var anime SelectionOption
+	if choice.Entry != "" {
+		anime = SelectionOption{Key: choice.Entry}
+	}
+	return category, anime, choice.Episode, nil
+}
@@ -0,0 +1,790 @@
+// Package dashboard is curd's full-screen terminal interface. Categories are
+// listed on the left, the anime of the chosen category next to them, and the
+// highlighted anime's details and episodes on the right, above a status bar.
+//
+// The model only draws and navigates. What it shows comes from a Source, and
+// it ends by returning a Choice for curd to act on, such as playing an
+// episode or opening one of the menus it does not draw itself.
+package dashboard
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/wraient/curd/internal/theme"
+)
+
+// Category is an entry of the category pane.
+type Category struct {
+	Key   string
+	Label string
+	// List marks categories whose anime are listed in the dashboard.
+	// Choosing any other category ends the dashboard.
+	List bool
+}
+
+// Entry is an anime of a listed category.
+type Entry struct {
+	Key      string
+	Title    string
+	Status   string
+	Progress int
+	Total    int
+}
+
+// Details is what the details pane shows about an entry. It is fetched in
+// the background, so it may take a moment to arrive.
+type Details struct {
+	CoverImage  string
+	Synopsis    string
+	Format      string
+	Genres      []string
+	NextEpisode int
+	NextAiring  time.Time
+	Episodes    []Episode
+}
+
+// Episode is a row of the episode list.
+type Episode struct {
+	Number  int
+	Title   string
+	Watched bool
+	Filler  bool
+	Recap   bool
+}
+
+// Status is the content of the status bar.
+type Status struct {
+	Provider  string
+	Tracker   string
+	Downloads string
+}
+
+// Source supplies the dashboard's data. Details and Status are called off
+// the UI goroutine.
+type Source interface {
+	Entries(category string) []Entry
+	Details(entry Entry) (Details, error)
+	Status() Status
+}
+
+// Previewer is implemented by sources that can show a cover image outside
+// the terminal text, such as through an image previewer.
+type Previewer interface {
+	Preview(coverImage string)
+}
+
+// Choice is how the dashboard ended.
+type Choice struct {
+	Quit     bool
+	Category string
+	// Entry is set when an anime was chosen, and Episode when a specific
+	// episode was; zero means the next unwatched one.
+	Entry   string
+	Episode int
+}
+
+type pane int
+
+const (
+	categoryPane pane = iota
+	entryPane
+	episodePane
+)
+
+const (
+	// detailsDelay lets the cursor settle before details are fetched, so
+	// scrolling through a list does not fire a request per row.
+	detailsDelay   = 250 * time.Millisecond
+	statusInterval = time.Second
+	categoryWidth  = 26
+)
+
+type detailsMsg struct {
+	key     string
+	details Details
+	err     error
+}
+
+type settledMsg struct{ key string }
+
+type statusMsg Status
+
+type details struct {
+	Details
+	err     error
+	loading bool
+}
+
+// Model is the dashboard's Bubble Tea model.
+type Model struct {
+	source     Source
+	categories []Category
+	entries    []Entry
+	details    map[string]*details
+	status     Status
+
+	focus    pane
+	category int
+	entry    int
+	episode  int
+
+	width  int
+	height int
+	choice Choice
+	now    func() time.Time
+}
+
+// New returns a dashboard over categories, starting on the category keyed
+// start when there is one.
+func New(source Source, categories []Category, start string) *Model {
+	m := &Model{
+		source:     source,
+		categories: categories,
+		details:    make(map[string]*details),
+		choice:     Choice{Quit: true},
+		now:        time.Now,
+	}
+	for i, category := range categories {
+		if category.Key == start {
+			m.category = i
+		}
+	}
+	m.loadEntries()
+	return m
+}
+
+// Choice returns how the dashboard ended.
+func (m *Model) Choice() Choice {
+	return m.choice
+}
+
+// Init starts the status bar and fetches the first entry's details.
+func (m *Model) Init() tea.Cmd {
+	return tea.Batch(m.refreshStatus(0), m.settle())
+}
+
+// Update handles input and background results.
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case statusMsg:
+		m.status = Status(msg)
+		return m, m.refreshStatus(statusInterval)
+	case settledMsg:
+		if entry, ok := m.currentEntry(); ok && entry.Key == msg.key {
+			return m, m.fetchDetails(entry, false)
+		}
+	case detailsMsg:
+		m.details[msg.key] = &details{Details: msg.details, err: msg.err}
+		if entry, ok := m.currentEntry(); ok && entry.Key == msg.key {
+			if m.focus != episodePane {
+				m.resetEpisode()
+			}
+			m.clampEpisode()
+			return m, m.preview(msg.details.CoverImage)
+		}
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+	}
+	return m, nil
+}
+
+func (m *Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		m.choice = Choice{Quit: true}
+		return m, tea.Quit
+	case "esc", "shift+tab", "left", "h":
+		if m.focus == categoryPane {
+			if msg.String() == "esc" {
+				m.choice = Choice{Quit: true}
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		m.focus--
+	case "tab", "right", "l":
+		m.focusNext()
+	case "up", "k", "ctrl+p":
+		return m, m.move(-1)
+	case "down", "j", "ctrl+n":
+		return m, m.move(1)
+	case "pgup":
+		return m, m.move(-m.listHeight())
+	case "pgdown":
+		return m, m.move(m.listHeight())
+	case "home", "g":
+		return m, m.move(-m.cursorLimit())
+	case "end", "G":
+		return m, m.move(m.cursorLimit())
+	case "r":
+		if entry, ok := m.currentEntry(); ok {
+			return m, m.fetchDetails(entry, true)
+		}
+	case "enter":
+		return m.choose()
+	}
+	return m, nil
+}
+
+func (m *Model) focusNext() {
+	switch m.focus {
+	case categoryPane:
+		if len(m.entries) > 0 {
+			m.focus = entryPane
+		}
+	case entryPane:
+		if len(m.episodes()) > 0 {
+			m.focus = episodePane
+		}
+	}
+}
+
+func (m *Model) choose() (tea.Model, tea.Cmd) {
+	if len(m.categories) == 0 {
+		return m, nil
+	}
+	category := m.categories[m.category]
+	switch m.focus {
+	case categoryPane:
+		if category.List {
+			m.focusNext()
+			return m, nil
+		}
+		m.choice = Choice{Category: category.Key}
+	case entryPane:
+		entry, ok := m.currentEntry()
+		if !ok {
+			return m, nil
+		}
+		m.choice = Choice{Category: category.Key, Entry: entry.Key}
+	case episodePane:
+		entry, ok := m.currentEntry()
+		episodes := m.episodes()
+		if !ok || m.episode >= len(episodes) {
+			return m, nil
+		}
+		m.choice = Choice{Category: category.Key, Entry: entry.Key, Episode: episodes[m.episode].Number}
+	}
+	return m, tea.Quit
+}
+
+// move shifts the focused pane's cursor by delta rows.
+func (m *Model) move(delta int) tea.Cmd {
+	switch m.focus {
+	case categoryPane:
+		next := clamp(m.category+delta, len(m.categories))
+		if next == m.category {
+			return nil
+		}
+		m.category = next
+		m.loadEntries()
+		return m.settle()
+	case entryPane:
+		next := clamp(m.entry+delta, len(m.entries))
+		if next == m.entry {
+			return nil
+		}
+		m.entry = next
+		m.resetEpisode()
+		return m.settle()
+	case episodePane:
+		m.episode = clamp(m.episode+delta, len(m.episodes()))
+	}
+	return nil
+}
+
+func (m *Model) cursorLimit() int {
+	return len(m.categories) + len(m.entries) + len(m.episodes())
+}
+
+func clamp(index, length int) int {
+	if index >= length {
+		index = length - 1
+	}
+	if index < 0 {
+		index = 0
+	}
+	return index
+}
+
+func (m *Model) loadEntries() {
+	m.entries, m.entry, m.episode = nil, 0, 0
+	if len(m.categories) > 0 && m.categories[m.category].List {
+		m.entries = m.source.Entries(m.categories[m.category].Key)
+	}
+	m.resetEpisode()
+}
+
+func (m *Model) currentEntry() (Entry, bool) {
+	if m.entry < len(m.entries) {
+		return m.entries[m.entry], true
+	}
+	return Entry{}, false
+}
+
+func (m *Model) currentDetails() *details {
+	if entry, ok := m.currentEntry(); ok {
+		return m.details[entry.Key]
+	}
+	return nil
+}
+
+func (m *Model) episodes() []Episode {
+	entry, ok := m.currentEntry()
+	if !ok {
+		return nil
+	}
+	var known Details
+	if d := m.currentDetails(); d != nil {
+		known = d.Details
+	}
+	return EpisodeRows(entry, known)
+}
+
+// resetEpisode puts the episode cursor on the next unwatched episode.
+func (m *Model) resetEpisode() {
+	m.episode = 0
+	if entry, ok := m.currentEntry(); ok {
+		m.episode = entry.Progress
+	}
+	m.clampEpisode()
+}
+
+func (m *Model) clampEpisode() {
+	m.episode = clamp(m.episode, len(m.episodes()))
+	if m.focus == episodePane && len(m.episodes()) == 0 {
+		m.focus = entryPane
+	}
+}
+
+// settle asks for the current entry's details once the cursor has rested.
+func (m *Model) settle() tea.Cmd {
+	entry, ok := m.currentEntry()
+	if !ok {
+		return nil
+	}
+	if d := m.details[entry.Key]; d != nil {
+		return m.preview(d.CoverImage)
+	}
+	return tea.Tick(detailsDelay, func(time.Time) tea.Msg { return settledMsg{key: entry.Key} })
+}
+
+func (m *Model) fetchDetails(entry Entry, reload bool) tea.Cmd {
+	if d := m.details[entry.Key]; d != nil && (d.loading || !reload) {
+		return nil
+	}
+	m.details[entry.Key] = &details{loading: true}
+	source := m.source
+	return func() tea.Msg {
+		found, err := source.Details(entry)
+		return detailsMsg{key: entry.Key, details: found, err: err}
+	}
+}
+
+func (m *Model) preview(coverImage string) tea.Cmd {
+	previewer, ok := m.source.(Previewer)
+	if !ok || coverImage == "" {
+		return nil
+	}
+	return func() tea.Msg {
+		previewer.Preview(coverImage)
+		return nil
+	}
+}
+
+func (m *Model) refreshStatus(after time.Duration) tea.Cmd {
+	source := m.source
+	if after <= 0 {
+		return func() tea.Msg { return statusMsg(source.Status()) }
+	}
+	return tea.Tick(after, func(time.Time) tea.Msg { return statusMsg(source.Status()) })
+}
+
+// EpisodeRows lists the episodes of entry known so far: every episode up to
+// the total, or up to the last aired one while the total is unknown, marked
+// with what details says about them.
+func EpisodeRows(entry Entry, details Details) []Episode {
+	count := entry.Total
+	if count == 0 && details.NextEpisode > 0 {
+		count = details.NextEpisode - 1
+	}
+	for _, episode := range details.Episodes {
+		if entry.Total == 0 && episode.Number > count {
+			count = episode.Number
+		}
+	}
+	if count < entry.Progress {
+		count = entry.Progress
+	}
+	if count == 0 {
+		return nil
+	}
+
+	rows := make([]Episode, count)
+	for i := range rows {
+		rows[i].Number = i + 1
+	}
+	for _, episode := range details.Episodes {
+		if episode.Number >= 1 && episode.Number <= count {
+			row := &rows[episode.Number-1]
+			row.Title, row.Filler, row.Recap = episode.Title, episode.Filler, episode.Recap
+		}
+	}
+	for i := 0; i < entry.Progress && i < count; i++ {
+		rows[i].Watched = true
+	}
+	return rows
+}
+
+// Until describes how long until t, as in "2d 4h" or "35m".
+func Until(t, now time.Time) string {
+	d := t.Sub(now)
+	if d <= 0 {
+		return "now"
+	}
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm", minutes)
+	default:
+		return "1m"
+	}
+}
+
+var (
+	paneStyle        lipgloss.Style
+	focusedPaneStyle lipgloss.Style
+	headingStyle     lipgloss.Style
+	cursorStyle      lipgloss.Style
+	rowStyle         lipgloss.Style
+	dimStyle         lipgloss.Style
+	fillerStyle      lipgloss.Style
+	recapStyle       lipgloss.Style
+	errorStyle       lipgloss.Style
+	statusBarStyle   lipgloss.Style
+)
+
+func init() {
+	SetTheme(theme.Dark)
+}
+
+// SetTheme colours the dashboard with p.
+func SetTheme(p theme.Palette) {
+	paneStyle = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(theme.Color(p.Border)).
+		Padding(0, 1)
+
+	focusedPaneStyle = paneStyle.
+		BorderForeground(theme.Color(p.Title))
+	if p.Title == "" {
+		focusedPaneStyle = focusedPaneStyle.Border(lipgloss.ThickBorder())
+	}
+
+	headingStyle = lipgloss.NewStyle().
+		Foreground(theme.Color(p.Title)).
+		Bold(true)
+
+	cursorStyle = lipgloss.NewStyle().
+		Foreground(theme.Color(p.SelectedText)).
+		Background(theme.Color(p.SelectedBackground)).
+		Bold(true).
+		Reverse(p.SelectedBackground == "")
+
+	rowStyle = lipgloss.NewStyle().
+		Foreground(theme.Color(p.Text))
+
+	dimStyle = lipgloss.NewStyle().
+		Foreground(theme.Color(p.Dim)).
+		Faint(p.Dim == "")
+
+	fillerStyle = lipgloss.NewStyle().
+		Foreground(theme.Color(p.Filler))
+
+	recapStyle = lipgloss.NewStyle().
+		Foreground(theme.Color(p.Recap))
+
+	errorStyle = lipgloss.NewStyle().
+		Foreground(theme.Color(p.Error)).
+		Italic(true)
+
+	statusBarStyle = lipgloss.NewStyle().
+		Foreground(theme.Color(p.StatusText)).
+		Background(theme.Color(p.StatusBackground)).
+		Reverse(p.StatusBackground == "")
+}
+
+// View draws the panes and the status bar.
+func (m *Model) View() string {
+	if m.width == 0 || m.height == 0 {
+		return "Loading…"
+	}
+	bodyHeight := m.height - 1
+	innerHeight := bodyHeight - 2
+	if innerHeight < 3 {
+		return m.statusBar()
+	}
+
+	// Each pane adds two columns of border to its width.
+	rest := m.width - categoryWidth - 6
+	entryWidth := rest * 2 / 5
+	detailWidth := rest - entryWidth
+	if entryWidth < 12 || detailWidth < 20 {
+		entryWidth, detailWidth = m.width-categoryWidth-4, 0
+	}
+
+	columns := []string{
+		m.frame(categoryPane, categoryWidth, innerHeight, m.categoryLines(categoryWidth-2, innerHeight)),
+		m.frame(entryPane, entryWidth, innerHeight, m.entryLines(entryWidth-2, innerHeight)),
+	}
+	if detailWidth > 0 {
+		columns = append(columns, m.frame(episodePane, detailWidth, innerHeight, m.detailLines(detailWidth-2, innerHeight)))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lipgloss.JoinHorizontal(lipgloss.Top, columns...), m.statusBar())
+}
+
+// frame draws a pane width columns wide inside its border; the lines must
+// fit in the two columns less left by the padding.
+func (m *Model) frame(p pane, width, height int, lines []string) string {
+	style := paneStyle
+	if m.focus == p {
+		style = focusedPaneStyle
+	}
+	return style.Width(width).Height(height).MaxHeight(height + 2).Render(strings.Join(lines, "\n"))
+}
+
+func (m *Model) listHeight() int {
+	if m.height < 6 {
+		return 1
+	}
+	return m.height - 5
+}
+
+func (m *Model) categoryLines(width, height int) []string {
+	lines := []string{headingStyle.Render("Curd")}
+	start, end := window(m.category, len(m.categories), height-1)
+	for i := start; i < end; i++ {
+		label := truncate(m.categories[i].Label, width)
+		if i == m.category {
+			label = cursorStyle.Render(label)
+		} else {
+			label = rowStyle.Render(label)
+		}
+		lines = append(lines, label)
+	}
+	return lines
+}
+
+func (m *Model) entryLines(width, height int) []string {
+	title := "Anime"
+	if len(m.categories) > 0 {
+		title = m.categories[m.category].Label
+	}
+	lines := []string{headingStyle.Render(truncate(title, width))}
+	if len(m.entries) == 0 {
+		hint := "Nothing here."
+		if len(m.categories) > 0 && !m.categories[m.category].List {
+			hint = "Press enter to open."
+		}
+		return append(lines, dimStyle.Render(hint))
+	}
+
+	start, end := window(m.entry, len(m.entries), height-1)
+	for i := start; i < end; i++ {
+		entry := m.entries[i]
+		progress := fmt.Sprintf(" %d/%s", entry.Progress, totalText(entry.Total))
+		label := truncate(entry.Title, width-lipgloss.Width(progress)) + progress
+		if i == m.entry && m.focus != categoryPane {
+			label = cursorStyle.Render(label)
+		} else {
+			label = rowStyle.Render(label)
+		}
+		lines = append(lines, label)
+	}
+	return lines
+}
+
+func (m *Model) detailLines(width, height int) []string {
+	entry, ok := m.currentEntry()
+	if !ok {
+		return []string{dimStyle.Render("Pick an anime to see its details.")}
+	}
+	lines := wrap(headingStyle, entry.Title, width, 2)
+
+	summary := fmt.Sprintf("%s · %d/%s watched", statusText(entry.Status), entry.Progress, totalText(entry.Total))
+	d := m.currentDetails()
+	if d != nil && d.Format != "" {
+		summary = d.Format + " · " + summary
+	}
+	lines = append(lines, truncate(summary, width))
+
+	switch {
+	case d == nil || d.loading:
+		return append(lines, "", dimStyle.Render("Loading details…"))
+	case d.err != nil:
+		lines = append(lines, "", errorStyle.Render(truncate(d.err.Error(), width)), dimStyle.Render("Press r to retry."))
+	default:
+		if len(d.Genres) > 0 {
+			lines = append(lines, dimStyle.Render(truncate(strings.Join(d.Genres, ", "), width)))
+		}
+		if d.NextEpisode > 0 && !d.NextAiring.IsZero() {
+			lines = append(lines, truncate(fmt.Sprintf("Episode %d airs in %s", d.NextEpisode, Until(d.NextAiring, m.now())), width))
+		}
+		if d.Synopsis != "" {
+			lines = append(lines, "")
+			lines = append(lines, wrap(rowStyle, d.Synopsis, width, max(3, (height-len(lines))/3))...)
+		}
+	}
+
+	episodes := m.episodes()
+	if len(episodes) == 0 {
+		return lines
+	}
+	lines = append(lines, "", headingStyle.Render("Episodes")+dimStyle.Render("  ✓ watched  F filler  R recap"))
+	room := height - len(lines)
+	if room < 1 {
+		return lines
+	}
+	start, end := window(m.episode, len(episodes), room)
+	for i := start; i < end; i++ {
+		lines = append(lines, m.episodeLine(episodes[i], i == m.episode && m.focus == episodePane, width))
+	}
+	return lines
+}
+
+func (m *Model) episodeLine(episode Episode, current bool, width int) string {
+	mark := " "
+	if episode.Watched {
+		mark = "✓"
+	}
+	flag := " "
+	style := rowStyle
+	switch {
+	case episode.Filler:
+		flag, style = "F", fillerStyle
+	case episode.Recap:
+		flag, style = "R", recapStyle
+	}
+	prefix := fmt.Sprintf("%s %s %4d  ", mark, flag, episode.Number)
+	line := prefix + truncate(episode.Title, width-lipgloss.Width(prefix))
+	if current {
+		return cursorStyle.Render(line)
+	}
+	if episode.Watched && !episode.Filler && !episode.Recap {
+		style = dimStyle
+	}
+	return style.Render(line)
+}
+
+func (m *Model) statusBar() string {
+	var parts []string
+	if m.status.Provider != "" {
+		parts = append(parts, "Provider: "+m.status.Provider)
+	}
+	if m.status.Tracker != "" {
+		parts = append(parts, m.status.Tracker)
+	}
+	if m.status.Downloads != "" {
+		parts = append(parts, "Downloads: "+m.status.Downloads)
+	}
+	left := " " + strings.Join(parts, " │ ")
+	right := "tab pane · enter select · r reload · q quit "
+	gap := m.width - lipgloss.Width(left) - lipgloss.Width(right)
+	if gap < 1 {
+		return statusBarStyle.Render(truncate(left, m.width))
+	}
+	return statusBarStyle.Render(left + strings.Repeat(" ", gap) + right)
+}
+
+func totalText(total int) string {
+	if total == 0 {
+		return "?"
+	}
+	return fmt.Sprint(total)
+}
+
+func statusText(status string) string {
+	switch status {
+	case "":
+		return "Not on list"
+	case "CURRENT", "watching":
+		return "Watching"
+	case "REPEATING":
+		return "Rewatching"
+	}
+	status = strings.ReplaceAll(strings.ToLower(status), "_", " ")
+	return strings.ToUpper(status[:1]) + status[1:]
+}
+
+// window returns the rows of a list of length rows to draw in height lines
+// so that cursor stays visible.
+func window(cursor, length, height int) (int, int) {
+	if height <= 0 || length == 0 {
+		return 0, 0
+	}
+	if length <= height {
+		return 0, length
+	}
+	start := cursor - height/2
+	if start < 0 {
+		start = 0
+	}
+	if start+height > length {
+		start = length - height
+	}
+	return start, start + height
+}
+
+func truncate(text string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if lipgloss.Width(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && lipgloss.Width(string(runes)) > width-1 {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+// wrap word-wraps text to width, keeping at most limit lines.
+func wrap(style lipgloss.Style, text string, width, limit int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		switch {
+		case line == "":
+			line = word
+		case lipgloss.Width(line)+1+lipgloss.Width(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	if len(lines) > limit {
+		lines = lines[:limit]
+		lines[limit-1] = truncate(lines[limit-1]+" …", width)
+	}
+	for i := range lines {
+		lines[i] = style.Render(truncate(lines[i], width))
+	}
+	return lines
+}
@@ -0,0 +1,160 @@
+package dashboard
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type fakeSource struct {
+	entries map[string][]Entry
+	details Details
+	calls   int
+}
+
+func (s *fakeSource) Entries(category string) []Entry { return s.entries[category] }
+
+func (s *fakeSource) Details(Entry) (Details, error) {
+	s.calls++
+	return s.details, nil
+}
+
+func (s *fakeSource) Status() Status {
+	return Status{Provider: "anineko", Tracker: "AniList synced", Downloads: "none"}
+}
+
+func key(k string) tea.KeyMsg {
+	switch k {
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "tab":
+		return tea.KeyMsg{Type: tea.KeyTab}
+	case "down":
+		return tea.KeyMsg{Type: tea.KeyDown}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+}
+
+func newTestModel(source *fakeSource) *Model {
+	m := New(source, []Category{
+		{Key: "CURRENT", Label: "Currently Watching", List: true},
+		{Key: "UPDATE", Label: "Update"},
+	}, "CURRENT")
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 30})
+	return m
+}
+
+// deliver runs cmd and feeds the message back to the model, the way the
+// Bubble Tea runtime would, skipping timers.
+func deliver(m *Model, cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+	switch msg := cmd().(type) {
+	case settledMsg, detailsMsg:
+		_, next := m.Update(msg)
+		deliver(m, next)
+	}
+}
+
+func TestEpisodeRows(t *testing.T) {
+	rows := EpisodeRows(Entry{Progress: 2, Total: 4}, Details{Episodes: []Episode{
+		{Number: 3, Title: "Beach episode", Filler: true},
+		{Number: 4, Recap: true},
+		{Number: 9, Title: "out of range"},
+	}})
+	if len(rows) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(rows))
+	}
+	if !rows[0].Watched || !rows[1].Watched || rows[2].Watched {
+		t.Fatalf("watched marks do not follow the progress: %+v", rows)
+	}
+	if !rows[2].Filler || rows[2].Title != "Beach episode" || !rows[3].Recap {
+		t.Fatalf("markers were not merged: %+v", rows)
+	}
+
+	airing := EpisodeRows(Entry{Progress: 1}, Details{NextEpisode: 6})
+	if len(airing) != 5 {
+		t.Fatalf("an airing show should list the aired episodes, got %d", len(airing))
+	}
+	if rows := EpisodeRows(Entry{}, Details{}); rows != nil {
+		t.Fatalf("expected no rows for an unknown show, got %v", rows)
+	}
+}
+
+func TestUntil(t *testing.T) {
+	now := time.Unix(0, 0)
+	cases := map[time.Duration]string{
+		-time.Minute:                    "now",
+		20 * time.Second:                "1m",
+		90 * time.Second:                "1m",
+		3*time.Hour + 20*time.Minute:    "3h 20m",
+		50*time.Hour + 30*time.Minute:   "2d 2h",
+		24*time.Hour + 59*time.Minute:   "1d 0h",
+		59*time.Minute + 30*time.Second: "59m",
+	}
+	for d, want := range cases {
+		if got := Until(now.Add(d), now); got != want {
+			t.Errorf("Until(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestChoosingAnEpisode(t *testing.T) {
+	source := &fakeSource{
+		entries: map[string][]Entry{"CURRENT": {
+			{Key: "1", Title: "Frieren", Progress: 1, Total: 28},
+			{Key: "2", Title: "Dandadan", Progress: 3, Total: 12},
+		}},
+		details: Details{Synopsis: "An elf mage", Episodes: []Episode{{Number: 4, Title: "Turbo Granny", Recap: true}}},
+	}
+	m := newTestModel(source)
+	deliver(m, m.settle())
+
+	m.Update(key("tab"))
+	_, cmd := m.Update(key("down"))
+	deliver(m, cmd)
+	if source.calls != 2 {
+		t.Fatalf("expected details for both entries, got %d calls", source.calls)
+	}
+	if m.episode != 3 {
+		t.Fatalf("the episode cursor should start on the next unwatched episode, got index %d", m.episode)
+	}
+
+	view := m.View()
+	for _, want := range []string{"Dandadan", "Turbo Granny", "An elf mage"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view is missing %q", want)
+		}
+	}
+
+	m.Update(key("tab"))
+	m.Update(key("down"))
+	if _, cmd := m.Update(key("enter")); cmd == nil {
+		t.Fatal("choosing an episode should end the dashboard")
+	}
+	if got := m.Choice(); got != (Choice{Category: "CURRENT", Entry: "2", Episode: 5}) {
+		t.Fatalf("unexpected choice %+v", got)
+	}
+}
+
+func TestOtherCategoriesEndTheDashboard(t *testing.T) {
+	m := newTestModel(&fakeSource{})
+	if m.Choice() != (Choice{Quit: true}) {
+		t.Fatal("a dashboard closed without a choice should quit")
+	}
+	if _, cmd := m.Update(key("enter")); cmd != nil {
+		t.Fatal("entering an empty listed category should not end the dashboard")
+	}
+	m.Update(key("j"))
+	m.Update(key("enter"))
+	if got := m.Choice(); got != (Choice{Category: "UPDATE"}) {
+		t.Fatalf("unexpected choice %+v", got)
+	}
+	m.Update(statusMsg(m.source.Status()))
+	if !strings.Contains(m.View(), "Provider: anineko │ AniList synced │ Downloads: none") {
+		t.Fatalf("the status bar is missing:\n%s", m.View())
+	}
+}
@@ -2,29 +2,68 @@ package internal
 
 import (
 	"fmt"
-	"github.com/tr1xem/go-discordrpc/client"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/tr1xem/go-discordrpc/client"
+
+	"github.com/wraient/curd/internal/presence"
 )
 
 var discordClient *client.Client
 var isLoggedIn bool
-var lastPausedState bool
-var lastEpisodeNumber int
-var lastAnimeTitle string
-var lastUpdateTime time.Time
-var lastForceUpdateTime time.Time
+
+// discordMu serialises the presence updates from the playback loop and the
+// menus.
+var discordMu sync.Mutex
+
+// discordLoginFailedAt delays new login attempts while Discord is not
+// running, so every menu does not wait for a failing connection.
+var discordLoginFailedAt time.Time
+
+const discordLoginRetry = time.Minute
+
+// presenceLimiter is the single place deciding when an activity is sent.
+var presenceLimiter = presence.NewLimiter()
+
+// heldActivity is the latest activity the limiter held back. The timer
+// sends it once the limiter allows, so the last change is never lost.
+// Guarded by discordMu.
+var (
+	heldActivity      *queuedActivity
+	heldActivityTimer *time.Timer
+)
+
+type queuedActivity struct {
+	clientId string
+	activity client.Activity
+	key      string
+}
+
+// menuPresenceStart is when the user started browsing, for the elapsed time
+// shown with menu presence. Guarded by discordMu.
+var menuPresenceStart time.Time
+
+const discordFallbackImage = "https://anilist.co/img/icons/icon.svg"
 
 func LoginClient(clientId string) error {
 	if discordClient != nil && isLoggedIn {
 		return nil // Already logged in
 	}
+	if !discordLoginFailedAt.IsZero() && time.Since(discordLoginFailedAt) < discordLoginRetry {
+		return fmt.Errorf("login failed recently, retrying after %s", discordLoginRetry)
+	}
 
 	discordClient = client.NewClient(clientId)
 
 	if err := discordClient.Login(); err != nil {
+		discordLoginFailedAt = time.Now()
 		return fmt.Errorf("login failed: %w", err)
 	}
 
+	discordLoginFailedAt = time.Time{}
 	isLoggedIn = true
 	return nil
 }
@@ -33,108 +72,219 @@ func DiscordPresence(anime Anime, IsPaused bool, currentPosition int, totalDurat
 	return DiscordPresenceWithForce(anime, IsPaused, currentPosition, totalDuration, clientId, false)
 }
 
+// DiscordPresenceWithForce shows anime's playback on Discord. forceUpdate
+// asks for an update even when nothing but the position changed, as after a
+// seek.
 func DiscordPresenceWithForce(anime Anime, IsPaused bool, currentPosition int, totalDuration int, clientId string, forceUpdate bool) error {
-	// Ensure client is logged in
-	if discordClient == nil || !isLoggedIn {
-		if err := LoginClient(clientId); err != nil {
-			return err
+	config := GetGlobalConfig()
+	if config == nil {
+		config = &CurdConfig{}
+	}
+	discordMu.Lock()
+	menuPresenceStart = time.Time{}
+	discordMu.Unlock()
+
+	if discordIncognito(config, anime) {
+		discordMu.Lock()
+		defer discordMu.Unlock()
+		if isLoggedIn {
+			Log(fmt.Sprintf("Discord presence hidden for incognito show %d", anime.AnilistId))
+			presenceLimiter.Reset()
+			return logoutClientLocked()
 		}
+		return nil
 	}
 
-	currentAnimeTitle := GetAnimeName(anime)
 	now := time.Now()
+	activity := playbackActivity(config, anime, IsPaused, currentPosition, totalDuration, now)
+	key := fmt.Sprintf("%s|%s|%s|%v", activity.Details, activity.State, activity.LargeText, IsPaused)
+	return setDiscordActivity(clientId, activity, key, forceUpdate, now)
+}
 
-	shouldUpdate := false
-
-	if lastForceUpdateTime.IsZero() || time.Since(lastForceUpdateTime) >= 2*time.Minute {
-		shouldUpdate = true
-		lastForceUpdateTime = now
+// playbackActivity builds the activity for anime from the Discord templates.
+// Adult titles are replaced by a generic activity when DiscordHideAdult is
+// set.
+func playbackActivity(config *CurdConfig, anime Anime, paused bool, position, duration int, now time.Time) client.Activity {
+	startTime := now.Add(-time.Duration(position) * time.Second)
+	timestamps := &client.Timestamps{Start: &startTime}
+	smallImage, smallText := "", ""
+	if paused {
+		smallImage, smallText = "pause-button", "Paused"
+	} else if duration > 60 && duration > position {
+		// Without a known duration only the elapsed time is shown
+		endTime := now.Add(time.Duration(duration-position) * time.Second)
+		timestamps.End = &endTime
 	}
 
-	if lastUpdateTime.IsZero() ||
-		lastPausedState != IsPaused ||
-		lastEpisodeNumber != anime.Ep.Number ||
-		lastAnimeTitle != currentAnimeTitle ||
-		forceUpdate {
-		shouldUpdate = true
+	if anime.IsAdult && config.DiscordHideAdult {
+		return client.Activity{
+			Type:       3, // Watching
+			Name:       "anime",
+			Details:    "Watching anime",
+			LargeImage: discordFallbackImage,
+			SmallImage: smallImage,
+			SmallText:  smallText,
+			Timestamps: timestamps,
+		}
 	}
 
-	if !shouldUpdate {
-		return nil // Skip update
+	fields := presenceFields(config, anime)
+	title := fields["title"]
+	largeImage := anime.CoverImage
+	if largeImage == "" {
+		largeImage = discordFallbackImage
+	}
+	buttons := []*client.Button{
+		{
+			Label: "View on AniList",
+			Url:   fmt.Sprintf("https://anilist.co/anime/%d", anime.AnilistId),
+		},
+	}
+	if anime.MalId > 0 {
+		buttons = append(buttons, &client.Button{
+			Label: "View on MAL",
+			Url:   fmt.Sprintf("https://myanimelist.net/anime/%d", anime.MalId),
+		})
 	}
 
-	var timestamps *client.Timestamps
-	var SmallImage = "pause-button"
-	var SmallText = "pause-button"
-
-	startTime := now.Add(-time.Duration(currentPosition) * time.Second)
+	return client.Activity{
+		Type:       3, // Watching
+		Name:       title,
+		Details:    presence.Render(config.DiscordDetails, fields),
+		State:      presence.Render(config.DiscordState, fields),
+		LargeImage: largeImage,
+		LargeText:  presence.Render(config.DiscordLargeText, fields), // Shown while hovering over the large image
+		SmallImage: smallImage,
+		SmallText:  smallText,
+		Timestamps: timestamps,
+		Buttons:    buttons,
+	}
+}
 
-	if IsPaused {
-		timestamps = &client.Timestamps{
-			Start: &startTime,
-			End:   nil, // No end time when paused
-		}
-		SmallImage = "pause-button"
-		SmallText = "Paused"
-	} else {
-		if totalDuration > 60 && totalDuration > currentPosition {
-			remainingSeconds := totalDuration - currentPosition
-			endTime := now.Add(time.Duration(remainingSeconds) * time.Second)
-			timestamps = &client.Timestamps{
-				Start: &startTime,
-				End:   &endTime,
-			}
-		} else {
-			// Duration unknown, show elapsed time only
-			timestamps = &client.Timestamps{
-				Start: &startTime,
-				End:   nil,
-			}
+// presenceFields returns the values the Discord templates can refer to.
+func presenceFields(config *CurdConfig, anime Anime) map[string]string {
+	fields := map[string]string{
+		"title":    GetAnimeName(anime),
+		"episode":  strconv.Itoa(anime.Ep.Number),
+		"total":    "?",
+		"season":   anime.Season,
+		"provider": anime.ProviderName,
+	}
+	if anime.TotalEpisodes > 0 {
+		fields["total"] = strconv.Itoa(anime.TotalEpisodes)
+	}
+	episodeTitle := anime.Ep.Title.English
+	if episodeTitle == "" || config.AnimeNameLanguage == "romaji" {
+		if anime.Ep.Title.Romaji != "" {
+			episodeTitle = anime.Ep.Title.Romaji
 		}
-		SmallImage = ""
-		SmallText = ""
 	}
+	fields["episode_title"] = episodeTitle
+	switch strings.ToLower(config.SubOrDub) {
+	case "sub":
+		fields["mode"] = "Sub"
+	case "dub":
+		fields["mode"] = "Dub"
+	}
+	// Normal speed is left empty so "[{speed}x]" only shows when it differs
+	if speed := anime.Ep.Player.Speed; speed > 0 && speed != 1 {
+		fields["speed"] = strconv.FormatFloat(speed, 'f', -1, 64)
+	}
+	return fields
+}
 
-	largeImage := anime.CoverImage
-	if largeImage == "" {
-		largeImage = "https://anilist.co/img/icons/icon.svg" // fallback image
+// discordIncognito reports whether anime is listed in DiscordIncognito.
+func discordIncognito(config *CurdConfig, anime Anime) bool {
+	id := strconv.Itoa(anime.AnilistId)
+	for _, entry := range parseStringArray(config.DiscordIncognito) {
+		if strings.TrimSpace(entry) == id {
+			return true
+		}
 	}
+	return false
+}
 
-	err := discordClient.SetActivity(client.Activity{
+// DiscordMenuPresence shows that the user is browsing menu, when menu
+// presence is enabled. Errors are only logged, as presence never blocks the
+// menus.
+func DiscordMenuPresence(config *CurdConfig, menu string) {
+	if config == nil || !config.DiscordPresence || !config.DiscordMenuPresence {
+		return
+	}
+	now := time.Now()
+	discordMu.Lock()
+	if menuPresenceStart.IsZero() {
+		menuPresenceStart = now
+	}
+	start := menuPresenceStart
+	discordMu.Unlock()
+	activity := client.Activity{
 		Type:       3, // Watching
-		Name:       currentAnimeTitle,
-		Details:    currentAnimeTitle, // Large text
-		LargeImage: largeImage,
-		LargeText:  currentAnimeTitle, // Would display while hovering over the large image
-		State:      fmt.Sprintf("Episode %d", anime.Ep.Number),
-		SmallImage: SmallImage,
-		SmallText:  SmallText,
-		Timestamps: timestamps,
-		Buttons: []*client.Button{
-			{
-				Label: "View on AniList",                                           // Button label
-				Url:   fmt.Sprintf("https://anilist.co/anime/%d", anime.AnilistId), // Button link
-			},
-			{
-				Label: "View on MAL",                                                // Button label
-				Url:   fmt.Sprintf("https://myanimelist.net/anime/%d", anime.MalId), // Button link
-			},
-		},
-	})
+		Name:       "curd",
+		Details:    presence.Render(config.DiscordMenuDetails, map[string]string{"menu": menu}),
+		LargeImage: discordFallbackImage,
+		LargeText:  "curd",
+		Timestamps: &client.Timestamps{Start: &start},
+	}
+	go func() {
+		if err := setDiscordActivity(config.DiscordClientId, activity, "menu|"+activity.Details, false, now); err != nil {
+			Log("Error setting Discord menu presence: " + err.Error())
+		}
+	}()
+}
+
+// setDiscordActivity sends activity if the limiter allows it, or holds it
+// to be sent as soon as the limiter does.
+func setDiscordActivity(clientId string, activity client.Activity, key string, force bool, now time.Time) error {
+	discordMu.Lock()
+	defer discordMu.Unlock()
+	return setDiscordActivityLocked(clientId, activity, key, force, now)
+}
 
-	if err != nil {
+func setDiscordActivityLocked(clientId string, activity client.Activity, key string, force bool, now time.Time) error {
+	if !presenceLimiter.Allow(key, force, now) {
+		if wait, held := presenceLimiter.Pending(now); held {
+			heldActivity = &queuedActivity{clientId: clientId, activity: activity, key: key}
+			if heldActivityTimer == nil {
+				heldActivityTimer = time.AfterFunc(wait, sendHeldActivity)
+			}
+		}
+		return nil
+	}
+	heldActivity = nil
+	if err := LoginClient(clientId); err != nil {
+		presenceLimiter.Reset()
+		return err
+	}
+	if err := discordClient.SetActivity(activity); err != nil {
+		presenceLimiter.Reset()
 		return fmt.Errorf("failed to set Discord activity: %w", err)
 	}
-
-	lastPausedState = IsPaused
-	lastEpisodeNumber = anime.Ep.Number
-	lastAnimeTitle = currentAnimeTitle
-	lastUpdateTime = now
-	// fmt.Println("Discord presence updated!", time.Now())
 	return nil
 }
 
+// sendHeldActivity sends the activity held back by setDiscordActivity.
+func sendHeldActivity() {
+	discordMu.Lock()
+	defer discordMu.Unlock()
+	heldActivityTimer = nil
+	held := heldActivity
+	if held == nil {
+		return
+	}
+	if err := setDiscordActivityLocked(held.clientId, held.activity, held.key, false, time.Now()); err != nil {
+		Log("Error setting held Discord presence: " + err.Error())
+	}
+}
+
 func LogoutClient() error {
+	discordMu.Lock()
+	defer discordMu.Unlock()
+	return logoutClientLocked()
+}
+
+func logoutClientLocked() error {
+	heldActivity = nil
 	if discordClient != nil && isLoggedIn {
 		if err := discordClient.Logout(); err != nil {
 			return fmt.Errorf("logout failed: %w", err)
@@ -0,0 +1,583 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wraient/curd/internal/keymap"
+)
+
+// DiscoverFilter narrows the discovery listings. Empty fields match anything.
+type DiscoverFilter struct {
+	Genre  string
+	Format string
+	Year   int
+}
+
+// DiscoverEntry is an anime listed by one of the discovery menus.
+type DiscoverEntry struct {
+	AnimeRelation
+	AverageScore int
+	Genres       []string
+}
+
+// discoverFilter holds the filters chosen in the discover menu for this session.
+var discoverFilter DiscoverFilter
+
+var discoverFormats = []string{"TV", "TV_SHORT", "MOVIE", "SPECIAL", "OVA", "ONA"}
+
+const discoverPageSize = 30
+
+const discoverMediaFields = `id idMal type format episodes status seasonYear averageScore genres
+	title { english romaji native }
+	coverImage { large }`
+
+func (f DiscoverFilter) matches(entry DiscoverEntry) bool {
+	if f.Format != "" && entry.Format != f.Format {
+		return false
+	}
+	if f.Year > 0 && entry.Year != f.Year {
+		return false
+	}
+	if f.Genre != "" {
+		for _, genre := range entry.Genres {
+			if strings.EqualFold(genre, f.Genre) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (f DiscoverFilter) summary() string {
+	parts := []string{}
+	if f.Genre != "" {
+		parts = append(parts, f.Genre)
+	}
+	if f.Format != "" {
+		parts = append(parts, f.Format)
+	}
+	if f.Year > 0 {
+		parts = append(parts, strconv.Itoa(f.Year))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func toDiscoverEntry(node aniListMediaNode) DiscoverEntry {
+	return DiscoverEntry{
+		AnimeRelation: node.toAnimeRelation(""),
+		AverageScore:  node.AverageScore,
+		Genres:        node.Genres,
+	}
+}
+
+// currentAniListSeason returns the AniList season and year containing t.
+func currentAniListSeason(t time.Time) (string, int) {
+	switch t.Month() {
+	case time.January, time.February, time.March:
+		return "WINTER", t.Year()
+	case time.April, time.May, time.June:
+		return "SPRING", t.Year()
+	case time.July, time.August, time.September:
+		return "SUMMER", t.Year()
+	default:
+		return "FALL", t.Year()
+	}
+}
+
+// nextAniListSeason returns the season following season/year.
+func nextAniListSeason(season string, year int) (string, int) {
+	switch season {
+	case "WINTER":
+		return "SPRING", year
+	case "SPRING":
+		return "SUMMER", year
+	case "SUMMER":
+		return "FALL", year
+	default:
+		return "WINTER", year + 1
+	}
+}
+
+func seasonLabel(season string, year int) string {
+	return fmt.Sprintf("%s%s %d", season[:1], strings.ToLower(season[1:]), year)
+}
+
+// fetchDiscoverPage lists anime from AniList sorted by sort, optionally
+// restricted to a season, with filter applied server-side.
+func fetchDiscoverPage(sortBy, season string, seasonYear int, filter DiscoverFilter) ([]DiscoverEntry, error) {
+	query := fmt.Sprintf(`query ($sort: [MediaSort], $season: MediaSeason, $seasonYear: Int, $genre: String, $format: MediaFormat, $perPage: Int) {
+		Page(page: 1, perPage: $perPage) {
+			media(type: ANIME, isAdult: false, sort: $sort, season: $season, seasonYear: $seasonYear, genre: $genre, format: $format) {
+				%s
+			}
+		}
+	}`, discoverMediaFields)
+
+	variables := map[string]interface{}{
+		"sort":    []string{sortBy},
+		"perPage": discoverPageSize,
+	}
+	if season != "" {
+		variables["season"] = season
+		variables["seasonYear"] = seasonYear
+	} else if filter.Year > 0 {
+		variables["seasonYear"] = filter.Year
+	}
+	if filter.Genre != "" {
+		variables["genre"] = filter.Genre
+	}
+	if filter.Format != "" {
+		variables["format"] = filter.Format
+	}
+
+	var result struct {
+		Data struct {
+			Page struct {
+				Media []aniListMediaNode `json:"media"`
+			} `json:"Page"`
+		} `json:"data"`
+	}
+	if err := queryAniList(query, variables, "", &result); err != nil {
+		return nil, err
+	}
+
+	entries := make([]DiscoverEntry, 0, len(result.Data.Page.Media))
+	for _, node := range result.Data.Page.Media {
+		entries = append(entries, toDiscoverEntry(node))
+	}
+	return entries, nil
+}
+
+// FetchTrendingAnime lists the anime currently trending on AniList.
+func FetchTrendingAnime(filter DiscoverFilter) ([]DiscoverEntry, error) {
+	return fetchDiscoverPage("TRENDING_DESC", "", 0, filter)
+}
+
+// FetchSeasonalAnime lists the most popular anime of an AniList season.
+func FetchSeasonalAnime(season string, year int, filter DiscoverFilter) ([]DiscoverEntry, error) {
+	return fetchDiscoverPage("POPULARITY_DESC", season, year, filter)
+}
+
+// FetchTopRatedAnime lists the highest scored anime on AniList.
+func FetchTopRatedAnime(filter DiscoverFilter) ([]DiscoverEntry, error) {
+	return fetchDiscoverPage("SCORE_DESC", "", 0, filter)
+}
+
+// FetchAniListGenres returns the genres known to AniList.
+func FetchAniListGenres() ([]string, error) {
+	var result struct {
+		Data struct {
+			GenreCollection []string `json:"GenreCollection"`
+		} `json:"data"`
+	}
+	if err := queryAniList(`query { GenreCollection }`, nil, "", &result); err != nil {
+		return nil, err
+	}
+	return result.Data.GenreCollection, nil
+}
+
+// recommendationSeeds picks the user's best scored completed entries.
+func recommendationSeeds(list AnimeList, limit int) []int {
+	completed := make([]Entry, 0, len(list.Completed))
+	for _, entry := range list.Completed {
+		if entry.Score > 0 {
+			completed = append(completed, entry)
+		}
+	}
+	if len(completed) == 0 {
+		return nil
+	}
+	sort.SliceStable(completed, func(i, j int) bool {
+		return completed[i].Score > completed[j].Score
+	})
+
+	// Only seed from entries close to the user's top score, whatever scale they use.
+	threshold := completed[0].Score * 0.8
+	seeds := make([]int, 0, limit)
+	for _, entry := range completed {
+		if entry.Score < threshold || len(seeds) >= limit {
+			break
+		}
+		seeds = append(seeds, entry.Media.ID)
+	}
+	return seeds
+}
+
+// FetchRecommendedAnime builds recommendations from AniList community
+// recommendations of the user's highest scored completed anime, skipping
+// anything already on the user's list.
+func FetchRecommendedAnime(config *CurdConfig, user *User, filter DiscoverFilter) ([]DiscoverEntry, error) {
+	seeds := recommendationSeeds(user.AnimeList, 8)
+	if len(seeds) == 0 {
+		return nil, fmt.Errorf("score some completed anime to get recommendations")
+	}
+
+	idField := "id_in"
+	if GetTrackingService(config) == "mal" {
+		idField = "idMal_in"
+	}
+	query := fmt.Sprintf(`query ($ids: [Int], $perPage: Int) {
+		Page(page: 1, perPage: $perPage) {
+			media(%s: $ids, type: ANIME) {
+				id
+				recommendations(sort: RATING_DESC, perPage: 15) {
+					nodes {
+						rating
+						mediaRecommendation {
+							isAdult
+							%s
+						}
+					}
+				}
+			}
+		}
+	}`, idField, discoverMediaFields)
+
+	var result struct {
+		Data struct {
+			Page struct {
+				Media []struct {
+					Recommendations struct {
+						Nodes []struct {
+							Rating              int `json:"rating"`
+							MediaRecommendation *struct {
+								IsAdult bool `json:"isAdult"`
+								aniListMediaNode
+							} `json:"mediaRecommendation"`
+						} `json:"nodes"`
+					} `json:"recommendations"`
+				} `json:"media"`
+			} `json:"Page"`
+		} `json:"data"`
+	}
+	variables := map[string]interface{}{"ids": seeds, "perPage": len(seeds)}
+	if err := queryAniList(query, variables, "", &result); err != nil {
+		return nil, err
+	}
+
+	scores := make(map[int]int)
+	entries := make(map[int]DiscoverEntry)
+	for _, media := range result.Data.Page.Media {
+		for _, node := range media.Recommendations.Nodes {
+			rec := node.MediaRecommendation
+			if rec == nil || rec.IsAdult || rec.Type != "ANIME" {
+				continue
+			}
+			entry := toDiscoverEntry(rec.aniListMediaNode)
+			if status, _ := relationListStatus(config, user, entry.AnimeRelation); status != "" {
+				continue
+			}
+			if !filter.matches(entry) {
+				continue
+			}
+			entries[entry.ID] = entry
+			// Titles recommended from several favourites rank higher.
+			scores[entry.ID] += node.Rating + 1
+		}
+	}
+
+	ranked := make([]DiscoverEntry, 0, len(entries))
+	for _, entry := range entries {
+		ranked = append(ranked, entry)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if scores[ranked[i].ID] != scores[ranked[j].ID] {
+			return scores[ranked[i].ID] > scores[ranked[j].ID]
+		}
+		return ranked[i].AverageScore > ranked[j].AverageScore
+	})
+	if len(ranked) > discoverPageSize {
+		ranked = ranked[:discoverPageSize]
+	}
+	return ranked, nil
+}
+
+func discoverEntryLabel(config *CurdConfig, user *User, index int, entry DiscoverEntry) string {
+	details := []string{}
+	if entry.Format != "" {
+		details = append(details, entry.Format)
+	}
+	if entry.Year > 0 {
+		details = append(details, strconv.Itoa(entry.Year))
+	}
+	if entry.Episodes > 0 {
+		details = append(details, fmt.Sprintf("%d eps", entry.Episodes))
+	}
+	if entry.AverageScore > 0 {
+		details = append(details, fmt.Sprintf("%d%%", entry.AverageScore))
+	}
+	label := fmt.Sprintf("%d. %s", index, animeDisplayTitle(config, entry.Title))
+	if len(details) > 0 {
+		label += fmt.Sprintf(" (%s)", strings.Join(details, ", "))
+	}
+	if status, _ := relationListStatus(config, user, entry.AnimeRelation); status != "" {
+		label += " [on your list]"
+	}
+	return label
+}
+
+// discoverActions are the single-key actions a listing offers on the
+// highlighted show. Picking a show starts watching it.
+var discoverActions = []keymap.Action{keymap.Plan}
+
+// selectDiscoverEntry lists entries in their ranked order, with cover
+// previews when enabled, and returns the one the user picked and the key
+// action they picked it with, if any.
+func selectDiscoverEntry(config *CurdConfig, user *User, entries []DiscoverEntry) (DiscoverEntry, string, keymap.Action, error) {
+	byKey := make(map[string]DiscoverEntry, len(entries))
+	for _, entry := range entries {
+		byKey[strconv.Itoa(entry.ID)] = entry
+	}
+
+	var selected SelectionOption
+	var action keymap.Action
+	var err error
+	if usePreviewMenu(config) {
+		previews := make([]PreviewOption, 0, len(entries))
+		for i, entry := range entries {
+			previews = append(previews, PreviewOption{
+				Key: strconv.Itoa(entry.ID),
+				RofiSelectPreview: RofiSelectPreview{
+					Title:      discoverEntryLabel(config, user, i+1, entry),
+					CoverImage: entry.CoverImage,
+				},
+			})
+		}
+		selected, err = DynamicSelectPreviewOrdered(previews, false, "<- Back")
+	} else {
+		options := []SelectionOption{{Key: "back", Label: "<- Back"}}
+		for i, entry := range entries {
+			options = append(options, SelectionOption{
+				Key:     strconv.Itoa(entry.ID),
+				Label:   discoverEntryLabel(config, user, i+1, entry),
+				Aliases: titleAliases(entry.Title),
+			})
+		}
+		selected, action, err = DynamicSelectOrderedWithActions(options, discoverActions...)
+	}
+	if err != nil {
+		return DiscoverEntry{}, "", "", err
+	}
+	return byKey[selected.Key], selected.Key, action, nil
+}
+
+func editDiscoverFilter(config *CurdConfig) {
+	for {
+		options := []SelectionOption{
+			{Key: "back", Label: "<- Back"},
+			{Key: "genre", Label: fmt.Sprintf("Genre: %s", valueOrAny(discoverFilter.Genre))},
+			{Key: "format", Label: fmt.Sprintf("Format: %s", valueOrAny(discoverFilter.Format))},
+			{Key: "year", Label: fmt.Sprintf("Year: %s", valueOrAny(yearString(discoverFilter.Year)))},
+			{Key: "clear", Label: "Clear filters"},
+		}
+		selected, err := DynamicSelectOrdered(options)
+		if err != nil {
+			Log(fmt.Sprintf("Error selecting discover filter: %v", err))
+			return
+		}
+
+		switch selected.Key {
+		case "genre":
+			genres, err := FetchAniListGenres()
+			if err != nil {
+				Log(fmt.Sprintf("Error fetching genres: %v", err))
+				CurdOut(fmt.Sprintf("Failed to fetch genres: %v", err))
+				continue
+			}
+			if value, ok := selectFilterValue(genres); ok {
+				discoverFilter.Genre = value
+			}
+		case "format":
+			if value, ok := selectFilterValue(discoverFormats); ok {
+				discoverFilter.Format = value
+			}
+		case "year":
+			input, err := promptText(config, "Enter a year (leave empty for any):", true)
+			if err != nil {
+				Log(fmt.Sprintf("Error reading year: %v", err))
+				continue
+			}
+			if input == "" {
+				discoverFilter.Year = 0
+				continue
+			}
+			year, err := parsePositiveIntInput(input, "year")
+			if err != nil {
+				CurdOut(err.Error())
+				continue
+			}
+			discoverFilter.Year = year
+		case "clear":
+			discoverFilter = DiscoverFilter{}
+		default:
+			return
+		}
+	}
+}
+
+func selectFilterValue(values []string) (string, bool) {
+	options := []SelectionOption{{Key: "any", Label: "Any"}}
+	for _, value := range values {
+		options = append(options, SelectionOption{Key: value, Label: value})
+	}
+	selected, err := DynamicSelectOrdered(options)
+	if err != nil || selected.Key == "-1" || selected.Key == "" {
+		return "", false
+	}
+	if selected.Key == "any" {
+		return "", true
+	}
+	return selected.Key, true
+}
+
+func valueOrAny(value string) string {
+	if value == "" {
+		return "any"
+	}
+	return value
+}
+
+func yearString(year int) string {
+	if year <= 0 {
+		return ""
+	}
+	return strconv.Itoa(year)
+}
+
+// DiscoverMenu browses trending, seasonal, top rated and recommended anime.
+// Picked titles can be added to the planning list or started right away; in
+// the latter case the returned option is keyed by the tracker ID. It returns
+// a "back" or "-1" key when the user leaves the menu.
+func DiscoverMenu(config *CurdConfig, user *User) (SelectionOption, error) {
+	DiscordMenuPresence(config, "Discover")
+	season, year := currentAniListSeason(time.Now())
+	nextSeason, nextYear := nextAniListSeason(season, year)
+
+	for {
+		options := []SelectionOption{
+			{Key: "back", Label: "<- Back"},
+			{Key: "TRENDING", Label: "Trending Now"},
+			{Key: "SEASON", Label: fmt.Sprintf("This Season (%s)", seasonLabel(season, year))},
+			{Key: "NEXT_SEASON", Label: fmt.Sprintf("Next Season (%s)", seasonLabel(nextSeason, nextYear))},
+			{Key: "TOP_RATED", Label: "Top Rated"},
+			{Key: "RECOMMENDED", Label: "Recommended For You"},
+			{Key: "FILTERS", Label: fmt.Sprintf("Filters (%s)", discoverFilter.summary())},
+		}
+		selected, err := DynamicSelectOrdered(options)
+		if err != nil {
+			return SelectionOption{}, err
+		}
+
+		var entries []DiscoverEntry
+		switch selected.Key {
+		case "TRENDING":
+			entries, err = FetchTrendingAnime(discoverFilter)
+		case "SEASON":
+			entries, err = FetchSeasonalAnime(season, year, discoverFilter)
+		case "NEXT_SEASON":
+			entries, err = FetchSeasonalAnime(nextSeason, nextYear, discoverFilter)
+		case "TOP_RATED":
+			entries, err = FetchTopRatedAnime(discoverFilter)
+		case "RECOMMENDED":
+			entries, err = FetchRecommendedAnime(config, user, discoverFilter)
+		case "FILTERS":
+			editDiscoverFilter(config)
+			continue
+		case "-1":
+			return selected, nil
+		default:
+			return SelectionOption{Key: "back"}, nil
+		}
+		if err != nil {
+			Log(fmt.Sprintf("Error fetching %s: %v", selected.Key, err))
+			CurdOut(fmt.Sprintf("Failed to load %s: %v", selected.Label, err))
+			continue
+		}
+		if len(entries) == 0 {
+			CurdOut("No anime found for the current filters.")
+			continue
+		}
+
+		if option, done := discoverEntryActions(config, user, entries); done {
+			return option, nil
+		}
+	}
+}
+
+// discoverEntryActions lets the user pick entries from a listing until they
+// start watching one or go back. In the terminal menu picking a show starts
+// it and the plan key adds it to the planning list; the launchers, which
+// take no keys, ask which of the two to do.
+func discoverEntryActions(config *CurdConfig, user *User, entries []DiscoverEntry) (SelectionOption, bool) {
+	for {
+		entry, key, action, err := selectDiscoverEntry(config, user, entries)
+		if err != nil {
+			Log(fmt.Sprintf("Error selecting discovered anime: %v", err))
+			return SelectionOption{}, false
+		}
+		if key == "-1" {
+			return SelectionOption{Key: "-1"}, true
+		}
+		if entry.ID == 0 {
+			return SelectionOption{}, false
+		}
+
+		title := animeDisplayTitle(config, entry.Title)
+		choice := "watch"
+		if action == keymap.Plan {
+			choice = "plan"
+		} else if !isTUIMenu(activeMenu()) {
+			choice = askDiscoverAction(title)
+		}
+
+		switch choice {
+		case "watch":
+			mediaID, err := ensureRelationWatching(config, user, entry.AnimeRelation)
+			if err != nil {
+				Log(fmt.Sprintf("Error adding %d to watching list: %v", entry.ID, err))
+				CurdOut(fmt.Sprintf("Failed to add %s to your watching list: %v", title, err))
+				continue
+			}
+			return SelectionOption{Key: strconv.Itoa(mediaID), Label: title}, true
+		case "plan":
+			mediaID := relationTrackerID(config, entry.AnimeRelation)
+			if mediaID == 0 {
+				CurdOut(fmt.Sprintf("%s has no %s ID", title, GetServiceName(config)))
+				continue
+			}
+			if err := AddAnimeToPlanningListUnified(mediaID, user.Token, config); err != nil {
+				Log(fmt.Sprintf("Error adding %d to planning list: %v", mediaID, err))
+				CurdOut(fmt.Sprintf("Failed to add %s to your planning list: %v", title, err))
+				continue
+			}
+			if err := refreshUserAnimeList(config, user); err != nil {
+				Log(fmt.Sprintf("Error refreshing anime list: %v", err))
+			}
+		case "-1":
+			return SelectionOption{Key: "-1"}, true
+		}
+	}
+}
+
+// askDiscoverAction asks what to do with a show picked in a launcher.
+func askDiscoverAction(title string) string {
+	actions := []SelectionOption{
+		{Key: "watch", Label: fmt.Sprintf("Start watching %s", title)},
+		{Key: "plan", Label: fmt.Sprintf("Add %s to planning list", title)},
+		{Key: "back", Label: "<- Back"},
+	}
+	action, err := DynamicSelectOrdered(actions)
+	if err != nil {
+		Log(fmt.Sprintf("Error selecting discover action: %v", err))
+		return "back"
+	}
+	return action.Key
+}
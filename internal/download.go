@@ -14,7 +14,10 @@ import (
 )
 
 // DownloadEpisode downloads an episode to the configured download path using ffmpeg for m3u8 URLs
-func DownloadEpisode(anime *Anime, config *CurdConfig) error {
+func DownloadEpisode(anime *Anime, config *CurdConfig) (err error) {
+	done := trackDownload()
+	defer func() { done(err) }()
+
 	// Get the anime name (sanitized for filename)
 	animeName := GetAnimeName(*anime)
 	animeName = sanitizeFilename(animeName)
@@ -166,9 +169,7 @@ func getVideoInfo(url string) (duration int, fileSize int64, err error) {
 
 // getFileSize tries to get the content length from HTTP headers
 func getFileSize(url string) (int64, error) {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+	client := httpClientWithTimeout(10 * time.Second)
 
 	resp, err := client.Head(url)
 	if err != nil {
@@ -203,9 +204,7 @@ func getFileSize(url string) (int64, error) {
 
 // estimateM3U8Size attempts to estimate the total size from m3u8 playlist
 func estimateM3U8Size(playlistURL string) (int64, error) {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+	client := httpClientWithTimeout(10 * time.Second)
 
 	resp, err := client.Get(playlistURL)
 	if err != nil {
@@ -391,7 +390,7 @@ func downloadFile(filepath string, url string) error {
 	defer out.Close()
 
 	// Get the data
-	resp, err := http.Get(url)
+	resp, err := sharedHTTPClient.Get(url)
 	if err != nil {
 		return err
 	}
@@ -486,8 +485,9 @@ func DownloadAnimeMenu(config *CurdConfig, user *User, databaseAnimes *[]Anime)
 	// Get all anime from watching list
 	for _, entry := range user.AnimeList.Watching {
 		option := SelectionOption{
-			Key:   fmt.Sprintf("%d", entry.Media.ID),
-			Label: fmt.Sprintf("%s (%d episodes)", getAnimeNameFromEntry(entry.Media.Title), entry.Media.Episodes),
+			Key:     fmt.Sprintf("%d", entry.Media.ID),
+			Label:   fmt.Sprintf("%s (%d episodes)", getAnimeNameFromEntry(entry.Media.Title), entry.Media.Episodes),
+			Aliases: titleAliases(entry.Media.Title),
 		}
 		animeListOptions = append(animeListOptions, option)
 		animeListMapPreview[option.Key] = RofiSelectPreview{
@@ -499,8 +499,9 @@ func DownloadAnimeMenu(config *CurdConfig, user *User, databaseAnimes *[]Anime)
 	// Add all other categories
 	for _, entry := range user.AnimeList.Completed {
 		option := SelectionOption{
-			Key:   fmt.Sprintf("%d", entry.Media.ID),
-			Label: fmt.Sprintf("%s (%d episodes)", getAnimeNameFromEntry(entry.Media.Title), entry.Media.Episodes),
+			Key:     fmt.Sprintf("%d", entry.Media.ID),
+			Label:   fmt.Sprintf("%s (%d episodes)", getAnimeNameFromEntry(entry.Media.Title), entry.Media.Episodes),
+			Aliases: titleAliases(entry.Media.Title),
 		}
 		animeListOptions = append(animeListOptions, option)
 		animeListMapPreview[option.Key] = RofiSelectPreview{
@@ -511,8 +512,9 @@ func DownloadAnimeMenu(config *CurdConfig, user *User, databaseAnimes *[]Anime)
 
 	for _, entry := range user.AnimeList.Paused {
 		option := SelectionOption{
-			Key:   fmt.Sprintf("%d", entry.Media.ID),
-			Label: fmt.Sprintf("%s (%d episodes)", getAnimeNameFromEntry(entry.Media.Title), entry.Media.Episodes),
+			Key:     fmt.Sprintf("%d", entry.Media.ID),
+			Label:   fmt.Sprintf("%s (%d episodes)", getAnimeNameFromEntry(entry.Media.Title), entry.Media.Episodes),
+			Aliases: titleAliases(entry.Media.Title),
 		}
 		animeListOptions = append(animeListOptions, option)
 		animeListMapPreview[option.Key] = RofiSelectPreview{
@@ -523,8 +525,9 @@ func DownloadAnimeMenu(config *CurdConfig, user *User, databaseAnimes *[]Anime)
 
 	for _, entry := range user.AnimeList.Planning {
 		option := SelectionOption{
-			Key:   fmt.Sprintf("%d", entry.Media.ID),
-			Label: fmt.Sprintf("%s (%d episodes)", getAnimeNameFromEntry(entry.Media.Title), entry.Media.Episodes),
+			Key:     fmt.Sprintf("%d", entry.Media.ID),
+			Label:   fmt.Sprintf("%s (%d episodes)", getAnimeNameFromEntry(entry.Media.Title), entry.Media.Episodes),
+			Aliases: titleAliases(entry.Media.Title),
 		}
 		animeListOptions = append(animeListOptions, option)
 		animeListMapPreview[option.Key] = RofiSelectPreview{
@@ -587,6 +590,12 @@ func DownloadAnimeMenu(config *CurdConfig, user *User, databaseAnimes *[]Anime)
 		return
 	}
 
+	downloadAnime(config, animeEntry, databaseAnimes)
+}
+
+// downloadAnime maps a list entry to a provider if needed and shows its
+// episodes to download
+func downloadAnime(config *CurdConfig, animeEntry *Entry, databaseAnimes *[]Anime) {
 	// Create anime object
 	anime := &Anime{
 		Title:         animeEntry.Media.Title,
@@ -632,70 +641,50 @@ func downloadEpisodeSelection(anime *Anime, config *CurdConfig) {
 		return
 	}
 
-	// Create episode list
-	episodes := make([]SelectionOption, 0)
-
-	// Add back button
-	episodes = append(episodes, SelectionOption{
-		Label: "<- Back",
-		Key:   "back",
-	})
-
-	// Get current episode from anime progress
-	currentEp := anime.Ep.Number
-
-	for i := 1; i <= anime.TotalEpisodes; i++ {
-		label := fmt.Sprintf("Episode %d", i)
-		if i == currentEp {
-			label += " (Current)"
-		}
-		episodes = append(episodes, SelectionOption{
-			Label: label,
-			Key:   fmt.Sprintf("%d", i),
-		})
-	}
+	// Create episode list, marked with titles, filler and recaps
+	options := []SelectionOption{{Label: "<- Back", Key: "back"}}
+	options = append(options, episodeOptions(episodeRows(config, anime, nil))...)
 
 	// Show multi-selection menu
-	selectedEpisodes, err := DynamicMultiSelect(episodes)
+	selectedEpisodes, err := DynamicMultiSelectOrdered(options)
 	if err != nil {
 		CurdOut(fmt.Sprintf("Selection cancelled: %v", err))
 		return
 	}
 
-	// Check if no episodes were selected or back was selected
-	if len(selectedEpisodes) == 0 {
+	// Back, quit and no selection all leave the menu
+	validEpisodes := selectedEpisodeNumbers(selectedEpisodes)
+	if len(validEpisodes) == 0 {
 		return
 	}
 
-	// Filter out the back button if it was somehow included
-	validEpisodes := make([]SelectionOption, 0)
-	for _, ep := range selectedEpisodes {
-		if ep.Key != "back" && ep.Key != "-1" {
-			validEpisodes = append(validEpisodes, ep)
-		}
+	downloadEpisodes(anime, config, validEpisodes)
+
+	// Ask if user wants to download more episodes
+	continueOptions := []SelectionOption{
+		{Key: "yes", Label: "Download more episodes"},
+		{Key: "no", Label: "Back to main menu"},
 	}
 
-	if len(validEpisodes) == 0 {
+	continueChoice, err := DynamicSelect(continueOptions)
+	if err != nil || continueChoice.Key == "no" || continueChoice.Key == "-1" {
 		return
 	}
 
+	// Loop back to episode selection
+	downloadEpisodeSelection(anime, config)
+}
+
+// downloadEpisodes downloads the given episodes of anime in order and
+// reports how many succeeded.
+func downloadEpisodes(anime *Anime, config *CurdConfig, numbers []int) {
 	// Download each episode in order
 	successCount := 0
 	failCount := 0
 
-	for i, selected := range validEpisodes {
-		// Parse episode number
-		var epNum int
-		fmt.Sscanf(selected.Key, "%d", &epNum)
-
-		if epNum <= 0 {
-			CurdOut(fmt.Sprintf("Invalid episode selection: %s", selected.Key))
-			failCount++
-			continue
-		}
-
+	for i, epNum := range numbers {
 		// Show progress
-		CurdOut(fmt.Sprintf("\n[%d/%d] Downloading Episode %d...", i+1, len(validEpisodes), epNum))
+		CurdOut(fmt.Sprintf("\n[%d/%d] Downloading Episode %d...", i+1, len(numbers), epNum))
 
 		// Set the selected episode
 		anime.Ep.Number = epNum
@@ -729,18 +718,4 @@ func downloadEpisodeSelection(anime *Anime, config *CurdConfig) {
 
 	// Show summary
 	CurdOut(fmt.Sprintf("\n✓ Download complete! Success: %d, Failed: %d", successCount, failCount))
-
-	// Ask if user wants to download more episodes
-	continueOptions := []SelectionOption{
-		{Key: "yes", Label: "Download more episodes"},
-		{Key: "no", Label: "Back to main menu"},
-	}
-
-	continueChoice, err := DynamicSelect(continueOptions)
-	if err != nil || continueChoice.Key == "no" || continueChoice.Key == "-1" {
-		return
-	}
-
-	// Loop back to episode selection
-	downloadEpisodeSelection(anime, config)
 }
@@ -0,0 +1,215 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/wraient/curd/internal/episodes"
+)
+
+// episodeMalID returns anime's MAL id for Jikan lookups. Under MAL tracking
+// list entries carry the MAL id in AnilistId until it is converted.
+func episodeMalID(config *CurdConfig, anime *Anime) int {
+	if anime.MalId != 0 {
+		return anime.MalId
+	}
+	if GetTrackingService(config) == "mal" {
+		return anime.AnilistId
+	}
+	malID, err := GetAnimeMalID(anime.AnilistId)
+	if err != nil {
+		Log(fmt.Sprintf("Episode browser: no MAL id for %d: %v", anime.AnilistId, err))
+	}
+	return malID
+}
+
+// trackedProgress returns how many episodes of anime the tracker has as
+// watched.
+func trackedProgress(config *CurdConfig, anime *Anime) int {
+	user := GetGlobalUser()
+	if user == nil {
+		return 0
+	}
+	id := anime.AnilistId
+	if GetTrackingService(config) == "mal" && anime.MalId != 0 {
+		id = anime.MalId
+	}
+	if entry, err := FindAnimeByIDUnified(user.AnimeList, strconv.Itoa(id)); err == nil {
+		return entry.Progress
+	}
+	return 0
+}
+
+// episodeRows lists anime's episodes for the browser. available is the
+// provider's episode list when the caller already has it; otherwise it is
+// fetched when anime is mapped to a provider.
+func episodeRows(config *CurdConfig, anime *Anime, available []string) []episodes.Row {
+	if available == nil && anime.ProviderId != "" {
+		providerName, providerID := AnimeProviderID(anime)
+		list, err := EpisodesList(QualifyProviderID(providerName, providerID), config.SubOrDub)
+		if err != nil {
+			Log(fmt.Sprintf("Episode browser: failed to list episodes on %s: %v", providerName, err))
+		}
+		available = list
+	}
+
+	in := episodes.Input{
+		Available: available,
+		Total:     anime.TotalEpisodes,
+		Progress:  trackedProgress(config, anime),
+	}
+	if malID := episodeMalID(config, anime); malID != 0 {
+		list, err := FetchJikanEpisodes(malID)
+		if err != nil {
+			Log(fmt.Sprintf("Episode browser: failed to get Jikan episodes of %d: %v", malID, err))
+		}
+		for _, episode := range list {
+			in.Meta = append(in.Meta, episodes.Meta{
+				Number: episode.EpisodeID,
+				Title:  episode.Title,
+				Aired:  episode.Aired,
+				Filler: episode.IsFiller,
+				Recap:  episode.IsRecap,
+			})
+		}
+	}
+	if history := LocalFindAnime(LocalGetAllAnime(HistoryFilePath(config)), anime.AnilistId, ""); history != nil {
+		in.ResumeEpisode, in.ResumePosition = history.Ep.Number, history.Ep.Player.PlaybackTime
+	}
+	return episodes.Build(in)
+}
+
+func episodeOptions(rows []episodes.Row) []SelectionOption {
+	options := make([]SelectionOption, 0, len(rows))
+	for _, row := range rows {
+		options = append(options, SelectionOption{Key: strconv.Itoa(row.Number), Label: row.Label()})
+	}
+	return options
+}
+
+// selectedEpisodeNumbers returns the episode numbers among selected,
+// dropping the menu's own entries.
+func selectedEpisodeNumbers(selected []SelectionOption) []int {
+	var numbers []int
+	for _, option := range selected {
+		if n, err := strconv.Atoi(option.Key); err == nil && n > 0 {
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers
+}
+
+// BrowseEpisodes lets the user pick episodes of anime and what to do with
+// them. It returns "play" or "download" with the picked episodes in order,
+// "back" when the user backed out and "-1" when they quit.
+func BrowseEpisodes(config *CurdConfig, anime *Anime) (string, []int, error) {
+	rows := episodeRows(config, anime, nil)
+	if len(rows) == 0 {
+		return "", nil, fmt.Errorf("no episodes found for %s", GetAnimeName(*anime))
+	}
+
+	ClearScreen()
+	CurdOut(fmt.Sprintf("%s: pick episodes with Space, then press Enter", GetAnimeName(*anime)))
+	options := append([]SelectionOption{{Key: "back", Label: "<- Back"}}, episodeOptions(rows)...)
+	selected, err := DynamicMultiSelectOrdered(options)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, option := range selected {
+		if option.Key == "-1" {
+			return "-1", nil, nil
+		}
+	}
+	picked := selectedEpisodeNumbers(selected)
+	if len(picked) == 0 {
+		return "back", nil, nil
+	}
+
+	what := fmt.Sprintf("episode %d", picked[0])
+	if len(picked) > 1 {
+		what = fmt.Sprintf("%d episodes", len(picked))
+	}
+	action, err := DynamicSelectOrdered([]SelectionOption{
+		{Key: "play", Label: "Play " + what},
+		{Key: "download", Label: "Download " + what},
+		{Key: "back", Label: "<- Back"},
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return action.Key, picked, nil
+}
+
+// pickEpisodeNumber asks for a single episode of anime out of available,
+// the provider's episode list.
+func pickEpisodeNumber(config *CurdConfig, anime *Anime, available []string) (int, error) {
+	rows := episodeRows(config, anime, available)
+	if len(rows) == 0 {
+		return 0, fmt.Errorf("no episodes to pick from")
+	}
+	selected, err := DynamicSelectOrdered(episodeOptions(rows))
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(selected.Key)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("no episode picked")
+	}
+	return n, nil
+}
+
+// startPlaylist makes picked the episodes to play, in order, starting with
+// the first one and resuming it where the history left off.
+func startPlaylist(config *CurdConfig, anime *Anime, picked []int) {
+	progress := trackedProgress(config, anime)
+	anime.Ep.Playlist = picked
+	anime.Ep.Number = picked[0]
+	anime.Ep.Resume, anime.Ep.Player.PlaybackTime = false, 0
+	if history := LocalFindAnime(LocalGetAllAnime(HistoryFilePath(config)), anime.AnilistId, ""); history != nil &&
+		history.Ep.Number == picked[0] && history.Ep.Player.PlaybackTime > 0 {
+		anime.Ep.Resume, anime.Ep.Player.PlaybackTime = true, history.Ep.Player.PlaybackTime
+	}
+	// A show being rewatched leaves the tracker alone for the whole playlist.
+	if anime.Rewatching {
+		for _, n := range picked {
+			progress = max(progress, n)
+		}
+	}
+	anime.Ep.PlaylistBase = progress
+	trackPlaylistEpisode(anime)
+}
+
+// trackPlaylistEpisode decides as each playlist episode starts whether
+// finishing it updates the tracker, so going back over watched episodes
+// never lowers the tracked progress.
+func trackPlaylistEpisode(anime *Anime) {
+	if InPlaylist(anime) {
+		anime.Rewatching = episodes.Rewatched(anime.Ep.Playlist, anime.Ep.PlaylistBase, anime.Ep.Number)
+	}
+}
+
+// InPlaylist reports whether the current episode of anime was picked in the
+// episode browser. Picked episodes play even when they are filler or recaps.
+func InPlaylist(anime *Anime) bool {
+	_, in := episodes.Next(anime.Ep.Playlist, anime.Ep.Number)
+	return in
+}
+
+// NextEpisodeNumber returns the episode to play after the current one: the
+// next of the playlist while one is playing, else the following episode.
+// Zero means the playlist is finished.
+func NextEpisodeNumber(anime *Anime) int {
+	if next, in := episodes.Next(anime.Ep.Playlist, anime.Ep.Number); in {
+		return next
+	}
+	return anime.Ep.Number + 1
+}
+
+// NextEpisodeLabel describes what continuing after the current episode
+// does, for the prompts that offer it.
+func NextEpisodeLabel(anime *Anime) string {
+	if next := NextEpisodeNumber(anime); next > 0 {
+		return fmt.Sprintf("Continue to next episode (%d)", next)
+	}
+	return "Finish the playlist"
+}
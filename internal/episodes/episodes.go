@@ -0,0 +1,177 @@
+// Package episodes builds the rows of curd's episode browser by merging
+// what is known about a show's episodes: which ones the provider has, their
+// titles, air dates and filler or recap flags from Jikan, and how far the
+// user got from the tracker and the local history.
+package episodes
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Meta is what Jikan says about an episode.
+type Meta struct {
+	Number int
+	Title  string
+	// Aired is the air date as Jikan sends it, an RFC 3339 timestamp.
+	Aired  string
+	Filler bool
+	Recap  bool
+}
+
+// Input is everything Build merges.
+type Input struct {
+	// Available is the provider's episode list. When it is empty the rows
+	// run from 1 to the highest episode known from the other fields.
+	Available []string
+	Meta      []Meta
+	Total     int
+	// Progress is the number of episodes the tracker has as watched.
+	Progress int
+	// ResumeEpisode and ResumePosition are the episode of the local history
+	// and the second playback stopped at in it.
+	ResumeEpisode  int
+	ResumePosition int
+}
+
+// Row is one episode of the browser.
+type Row struct {
+	Number  int
+	Title   string
+	Aired   string
+	Filler  bool
+	Recap   bool
+	Watched bool
+	// Resume is the second to resume playback from, zero when there is
+	// nothing to resume.
+	Resume int
+}
+
+// Build merges in into rows ordered by episode number.
+func Build(in Input) []Row {
+	numbers := ParseNumbers(in.Available)
+	if len(numbers) == 0 {
+		count := in.Total
+		if in.Progress > count {
+			count = in.Progress
+		}
+		if count == 0 {
+			for _, meta := range in.Meta {
+				if meta.Number > count {
+					count = meta.Number
+				}
+			}
+		}
+		for n := 1; n <= count; n++ {
+			numbers = append(numbers, n)
+		}
+	}
+
+	meta := make(map[int]Meta, len(in.Meta))
+	for _, m := range in.Meta {
+		meta[m.Number] = m
+	}
+	rows := make([]Row, 0, len(numbers))
+	for _, n := range numbers {
+		m := meta[n]
+		row := Row{
+			Number:  n,
+			Title:   strings.TrimSpace(m.Title),
+			Aired:   airDate(m.Aired),
+			Filler:  m.Filler,
+			Recap:   m.Recap,
+			Watched: n <= in.Progress,
+		}
+		if n == in.ResumeEpisode && in.ResumePosition > 0 {
+			row.Resume = in.ResumePosition
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// ParseNumbers returns the whole episode numbers of a provider's episode
+// list, sorted and without duplicates. Specials such as "12.5" are left out
+// since curd plays episodes by whole number.
+func ParseNumbers(list []string) []int {
+	seen := make(map[int]bool, len(list))
+	var numbers []int
+	for _, item := range list {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || n <= 0 || seen[n] {
+			continue
+		}
+		seen[n] = true
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+func airDate(aired string) string {
+	if len(aired) >= len("2006-01-02") {
+		return aired[:len("2006-01-02")]
+	}
+	return aired
+}
+
+// Label renders r for a selection menu, as in
+// "Episode 12: Title · 2023-10-06 · filler · watched".
+func (r Row) Label() string {
+	parts := []string{fmt.Sprintf("Episode %d", r.Number)}
+	if r.Title != "" {
+		parts[0] += ": " + r.Title
+	}
+	if r.Aired != "" {
+		parts = append(parts, r.Aired)
+	}
+	if r.Filler {
+		parts = append(parts, "filler")
+	}
+	if r.Recap {
+		parts = append(parts, "recap")
+	}
+	if r.Watched {
+		parts = append(parts, "watched")
+	}
+	if r.Resume > 0 {
+		parts = append(parts, fmt.Sprintf("resume at %d:%02d", r.Resume/60, r.Resume%60))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// Next returns the episode to play after current in playlist, zero when
+// current is the last one. inPlaylist is false when current is not part of
+// the playlist, and the caller should carry on as without one.
+func Next(playlist []int, current int) (next int, inPlaylist bool) {
+	for i, n := range playlist {
+		if n != current {
+			continue
+		}
+		if i+1 < len(playlist) {
+			return playlist[i+1], true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// Rewatched reports whether finishing episode of playlist leaves the
+// tracker alone because it would not raise progress. progress is the
+// tracked progress when the playlist started; the episodes played before
+// this one raise it as they are finished. Deciding per episode keeps a
+// playlist that goes back over watched episodes from lowering the tracked
+// progress while the new episodes it reaches are still tracked.
+func Rewatched(playlist []int, progress, episode int) bool {
+	for _, n := range playlist {
+		if n == episode {
+			break
+		}
+		if n > progress {
+			progress = n
+		}
+	}
+	return episode <= progress
+}
@@ -0,0 +1,94 @@
+package episodes
+
+import "testing"
+
+func TestBuildMergesProviderJikanAndHistory(t *testing.T) {
+	rows := Build(Input{
+		Available: []string{"3", "1", "2", "2", "2.5", "4"},
+		Meta: []Meta{
+			{Number: 2, Title: " The Hero's Party ", Aired: "2023-10-06T00:00:00+00:00"},
+			{Number: 3, Filler: true},
+			{Number: 4, Recap: true},
+			{Number: 5, Title: "not on the provider yet"},
+		},
+		Total:          28,
+		Progress:       1,
+		ResumeEpisode:  2,
+		ResumePosition: 754,
+	})
+	if len(rows) != 4 {
+		t.Fatalf("expected the provider's four whole episodes, got %+v", rows)
+	}
+	for i, row := range rows {
+		if row.Number != i+1 {
+			t.Fatalf("rows are not in episode order: %+v", rows)
+		}
+	}
+	if !rows[0].Watched || rows[1].Watched {
+		t.Fatalf("watched does not follow the progress: %+v", rows)
+	}
+	want := "Episode 2: The Hero's Party · 2023-10-06 · resume at 12:34"
+	if got := rows[1].Label(); got != want {
+		t.Fatalf("Label() = %q, want %q", got, want)
+	}
+	if got := rows[2].Label(); got != "Episode 3 · filler" {
+		t.Fatalf("unexpected filler label %q", got)
+	}
+	if got := rows[0].Label(); got != "Episode 1 · watched" {
+		t.Fatalf("unexpected watched label %q", got)
+	}
+}
+
+func TestBuildWithoutProviderList(t *testing.T) {
+	if rows := Build(Input{Total: 12, Progress: 3}); len(rows) != 12 {
+		t.Fatalf("expected the total to be listed, got %d rows", len(rows))
+	}
+	if rows := Build(Input{Progress: 5}); len(rows) != 5 {
+		t.Fatalf("expected the watched episodes of an unknown total, got %d rows", len(rows))
+	}
+	if rows := Build(Input{Meta: []Meta{{Number: 7}}}); len(rows) != 7 {
+		t.Fatalf("expected the episodes Jikan knows, got %d rows", len(rows))
+	}
+}
+
+func TestNext(t *testing.T) {
+	playlist := []int{2, 5, 9}
+	cases := []struct {
+		current, next int
+		in            bool
+	}{
+		{2, 5, true},
+		{5, 9, true},
+		{9, 0, true},
+		{3, 0, false},
+	}
+	for _, c := range cases {
+		next, in := Next(playlist, c.current)
+		if next != c.next || in != c.in {
+			t.Errorf("Next(%d) = %d, %v; want %d, %v", c.current, next, in, c.next, c.in)
+		}
+	}
+	if _, in := Next(nil, 1); in {
+		t.Fatal("no playlist means no playlist episode")
+	}
+}
+
+func TestRewatchedMixedPlaylist(t *testing.T) {
+	cases := []struct {
+		playlist []int
+		progress int
+		want     []bool
+	}{
+		{[]int{3, 10}, 5, []bool{true, false}},
+		{[]int{6, 7}, 5, []bool{false, false}},
+		{[]int{8, 6, 9}, 5, []bool{false, true, false}},
+		{[]int{1, 2}, 5, []bool{true, true}},
+	}
+	for _, c := range cases {
+		for i, episode := range c.playlist {
+			if got := Rewatched(c.playlist, c.progress, episode); got != c.want[i] {
+				t.Errorf("Rewatched(%v, %d, %d) = %v; want %v", c.playlist, c.progress, episode, got, c.want[i])
+			}
+		}
+	}
+}
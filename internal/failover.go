@@ -0,0 +1,274 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wraient/curd/internal/providers"
+)
+
+const (
+	// failoverStallTimeout is how long time-pos may stay put while playing
+	// before the stream is considered dead.
+	failoverStallTimeout = 30 * time.Second
+	// failoverIdleTimeout is how long mpv may sit without a file.
+	failoverIdleTimeout = 10 * time.Second
+	// failoverGrace gives a freshly loaded stream time to start.
+	failoverGrace = 20 * time.Second
+)
+
+// endFileErrors holds the last end-file error mpv reported per socket.
+var (
+	endFileMu        sync.Mutex
+	endFileErrors    = map[string]string{}
+	endFileListeners = map[string]int{}
+)
+
+// watchEndFileErrors listens for end-file events on the mpv behind socket.
+// Listeners are keyed by mpv's pid so a restarted player is picked up.
+func watchEndFileErrors(socket string) {
+	pidValue, err := MPVSendCommand(socket, []interface{}{"get_property", "pid"})
+	if err != nil {
+		return
+	}
+	pid := 0
+	if value, ok := pidValue.(float64); ok {
+		pid = int(value)
+	}
+
+	endFileMu.Lock()
+	if endFileListeners[socket] == pid {
+		endFileMu.Unlock()
+		return
+	}
+	endFileListeners[socket] = pid
+	endFileMu.Unlock()
+
+	err = StartMPVEventListener(socket, func(event string, data interface{}) {
+		if event != "end-file" {
+			return
+		}
+		payload, ok := data.(map[string]interface{})
+		if !ok || payload["reason"] != "error" {
+			return
+		}
+		reason := "end-file error"
+		if fileError, ok := payload["file_error"].(string); ok && fileError != "" {
+			reason = "mpv: " + fileError
+		}
+		endFileMu.Lock()
+		endFileErrors[socket] = reason
+		endFileMu.Unlock()
+	})
+	if err != nil {
+		Log(fmt.Sprintf("Failover event listener failed: %v", err))
+	}
+}
+
+func takeEndFileError(socket string) string {
+	endFileMu.Lock()
+	defer endFileMu.Unlock()
+	reason := endFileErrors[socket]
+	delete(endFileErrors, socket)
+	return reason
+}
+
+// PlaybackFailover watches the running episode for dead streams and moves
+// playback to the next resolved link, then to the next provider.
+type PlaybackFailover struct {
+	config *CurdConfig
+	anime  *Anime
+
+	episode        int
+	failedLinks    map[string]bool
+	triedProviders map[string]bool
+
+	lastPos      float64
+	lastProgress time.Time
+	idleSince    time.Time
+	graceUntil   time.Time
+}
+
+// NewPlaybackFailover starts watching the episode playing in anime.
+func NewPlaybackFailover(config *CurdConfig, anime *Anime) *PlaybackFailover {
+	f := &PlaybackFailover{config: config, anime: anime}
+	f.reset()
+	if socket := anime.Ep.Player.SocketPath; socket != "" && socket != "android-intent" {
+		watchEndFileErrors(socket)
+		takeEndFileError(socket)
+	}
+	return f
+}
+
+func (f *PlaybackFailover) reset() {
+	f.episode = f.anime.Ep.Number
+	f.failedLinks = map[string]bool{}
+	f.triedProviders = map[string]bool{}
+	f.restartTimers()
+}
+
+func (f *PlaybackFailover) restartTimers() {
+	now := time.Now()
+	f.lastPos = -1
+	f.lastProgress = now
+	f.idleSince = time.Time{}
+	f.graceUntil = now.Add(failoverGrace)
+}
+
+func (f *PlaybackFailover) property(name string) (interface{}, error) {
+	return MPVSendCommand(f.anime.Ep.Player.SocketPath, []interface{}{"get_property", name})
+}
+
+// Check reports why the current stream failed, if it did.
+func (f *PlaybackFailover) Check() (string, bool) {
+	socket := f.anime.Ep.Player.SocketPath
+	if socket == "" || socket == "android-intent" {
+		return "", false
+	}
+	if f.anime.Ep.Number != f.episode {
+		f.reset()
+		return "", false
+	}
+	if reason := takeEndFileError(socket); reason != "" {
+		return reason, true
+	}
+	if time.Now().Before(f.graceUntil) {
+		f.lastProgress = time.Now()
+		return "", false
+	}
+
+	if idle, err := f.property("idle-active"); err == nil && idle == true {
+		if f.idleSince.IsZero() {
+			f.idleSince = time.Now()
+		} else if time.Since(f.idleSince) > failoverIdleTimeout {
+			return "mpv stopped playing without reaching the end", true
+		}
+		return "", false
+	}
+	f.idleSince = time.Time{}
+
+	if !f.anime.Ep.Started {
+		return "", false
+	}
+
+	timePos, err := f.property("time-pos")
+	pos, ok := timePos.(float64)
+	if err != nil || !ok {
+		return "", false
+	}
+
+	if eof, err := f.property("eof-reached"); err == nil && eof == true {
+		percentage := PercentageWatched(int(pos+0.5), f.anime.Ep.Duration)
+		if f.anime.Ep.Duration > 0 && int(percentage) < f.config.PercentageToMarkComplete {
+			return fmt.Sprintf("stream ended early at %.0f%%", percentage), true
+		}
+		return "", false
+	}
+
+	paused, _ := f.property("pause")
+	waitingForCache, _ := f.property("paused-for-cache")
+	if paused == true && waitingForCache != true {
+		f.lastProgress = time.Now()
+		return "", false
+	}
+	if pos != f.lastPos {
+		f.lastPos = pos
+		f.lastProgress = time.Now()
+		return "", false
+	}
+	if time.Since(f.lastProgress) > failoverStallTimeout {
+		return fmt.Sprintf("playback stalled at %ds", int(pos+0.5)), true
+	}
+	return "", false
+}
+
+// Recover moves playback to the next untried link of the episode, then to
+// the next provider, resuming at the last known position. It returns an
+// error once every source has failed.
+func (f *PlaybackFailover) Recover(reason string) error {
+	anime := f.anime
+	position := anime.Ep.Player.PlaybackTime
+	providerName := CurrentAnimeProviderName(anime)
+
+	failed := anime.Ep.StreamURL
+	if failed == "" {
+		failed = PrioritizeLink(anime.Ep.Links)
+	}
+	failedStream, _ := streamForLink(failed)
+	f.failedLinks[failed] = true
+	Log(fmt.Sprintf("Stream failover: %s stream %s failed at %ds: %s", providerName, streamLabel(failedStream, false), position, reason))
+
+	streams, linkProvider := linkStreams(anime.Ep.Links)
+	if linkProvider != "" {
+		providerName = linkProvider
+	}
+	if f.tryStreams(RankStreams(f.config, providerName, streams), position, providerName, &reason) {
+		return nil
+	}
+
+	// Every link failed. Links often die together when a token expires, so
+	// ask the current provider for fresh ones before moving on.
+	mode := normalizeTranslationType(f.config.SubOrDub)
+	candidates := []string{providerName}
+	for _, name := range configuredProviderNames(f.config) {
+		if name != providerName {
+			candidates = append(candidates, name)
+		}
+	}
+	for _, name := range candidates {
+		if f.triedProviders[name] {
+			continue
+		}
+		f.triedProviders[name] = true
+		result, err := episodeModeResultWithProviders(navigationContext(), *f.config, anime, anime.Ep.Number, mode, []string{name})
+		if err != nil {
+			Log(fmt.Sprintf("Stream failover: %s has no replacement: %v", name, err))
+			continue
+		}
+		anime.Ep.Links = result.Links
+		if f.tryStreams(result.Streams, position, result.ProviderName, &reason) {
+			return nil
+		}
+		Log(fmt.Sprintf("Stream failover: %s has no stream left that loads", name))
+	}
+
+	return fmt.Errorf("every stream for episode %d failed across %s (last: %s)", anime.Ep.Number, strings.Join(candidates, ", "), reason)
+}
+
+func (f *PlaybackFailover) nextStream(streams []providers.Stream) (providers.Stream, bool) {
+	for _, stream := range streams {
+		if !f.failedLinks[stream.URL] {
+			return stream, true
+		}
+	}
+	return providers.Stream{}, false
+}
+
+// tryStreams switches to the first of streams that has not failed yet and
+// loads, marking the ones that do not. reason is set to the last load error.
+func (f *PlaybackFailover) tryStreams(streams []providers.Stream, position int, providerName string, reason *string) bool {
+	for next, ok := f.nextStream(streams); ok; next, ok = f.nextStream(streams) {
+		err := f.switchTo(next, position, providerName)
+		if err == nil {
+			return true
+		}
+		Log(fmt.Sprintf("Stream failover: %v", err))
+		*reason = err.Error()
+	}
+	return false
+}
+
+func (f *PlaybackFailover) switchTo(stream providers.Stream, position int, providerName string) error {
+	label := streamLabel(stream, false)
+	CurdOut(fmt.Sprintf("Stream failed, switching to %s (%s)", label, providerName))
+	Log(fmt.Sprintf("Stream failover: trying %s stream %s from %ds", providerName, label, position))
+	if err := loadStream(f.anime, stream, position); err != nil {
+		f.failedLinks[stream.URL] = true
+		return err
+	}
+	takeEndFileError(f.anime.Ep.Player.SocketPath)
+	f.restartTimers()
+	return nil
+}
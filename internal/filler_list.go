@@ -4,12 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"time"
 )
 
 type AnimeFillerListEpisode struct {
-	EpisodeID int  `json:"mal_id"`
-	IsFiller  bool `json:"filler"`
+	EpisodeID int    `json:"mal_id"`
+	Title     string `json:"title"`
+	Aired     string `json:"aired"`
+	IsFiller  bool   `json:"filler"`
+	IsRecap   bool   `json:"recap"`
 }
 
 type EpisodesResponse struct {
@@ -20,26 +22,35 @@ type EpisodesResponse struct {
 }
 
 func FetchFillerEpisodes(malID int) ([]int, error) {
-	baseURL := fmt.Sprintf("https://api.jikan.moe/v4/anime/%d/episodes", malID)
+	episodes, err := FetchJikanEpisodes(malID)
+	if err != nil {
+		return nil, err
+	}
+
 	var fillerEpisodes []int
+	for _, episode := range episodes {
+		if episode.IsFiller {
+			fillerEpisodes = append(fillerEpisodes, episode.EpisodeID)
+		}
+	}
+	return fillerEpisodes, nil
+}
+
+// FetchJikanEpisodes lists every episode Jikan knows of an anime, with its
+// title and filler and recap flags.
+func FetchJikanEpisodes(malID int) ([]AnimeFillerListEpisode, error) {
+	baseURL := fmt.Sprintf("https://api.jikan.moe/v4/anime/%d/episodes", malID)
+	var episodes []AnimeFillerListEpisode
 	page := 1
-	rateLimiter := time.NewTicker(334 * time.Millisecond) // ~3 requests per second
-	defer rateLimiter.Stop()
 
+	// The shared client rate limits Jikan and retries 429 responses.
 	for {
-		<-rateLimiter.C // Wait for rate limit
 		url := fmt.Sprintf("%s?page=%d", baseURL, page)
-		resp, err := http.Get(url)
+		resp, err := sharedHTTPClient.Get(url)
 		if err != nil {
 			return nil, fmt.Errorf("error fetching episodes: %v", err)
 		}
 
-		if resp.StatusCode == http.StatusTooManyRequests {
-			// Wait for 2 seconds and retry
-			time.Sleep(2 * time.Second)
-			continue
-		}
-
 		if resp.StatusCode != http.StatusOK {
 			resp.Body.Close()
 			return nil, fmt.Errorf("received non-200 response: %d", resp.StatusCode)
@@ -52,11 +63,7 @@ func FetchFillerEpisodes(malID int) ([]int, error) {
 		}
 		resp.Body.Close()
 
-		for _, episode := range episodesResp.Data {
-			if episode.IsFiller {
-				fillerEpisodes = append(fillerEpisodes, episode.EpisodeID)
-			}
-		}
+		episodes = append(episodes, episodesResp.Data...)
 
 		if !episodesResp.Pagination.HasNextPage {
 			break
@@ -64,7 +71,7 @@ func FetchFillerEpisodes(malID int) ([]int, error) {
 		page++
 	}
 
-	return fillerEpisodes, nil
+	return episodes, nil
 }
 
 // IsEpisodeFiller checks if a given episode number is in the filler episodes list
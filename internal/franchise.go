@@ -0,0 +1,457 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxFranchiseHops bounds prequel/sequel walks so a malformed relation graph
+// can never loop forever.
+const maxFranchiseHops = 30
+
+// FranchiseEntry is one step of a franchise watch order. Main entries form the
+// prequel/sequel chain; side stories and spin-offs follow the entry they hang off.
+type FranchiseEntry struct {
+	AnimeRelation
+	Main bool
+}
+
+var franchiseSideRelations = map[string]bool{
+	"SIDE_STORY": true,
+	"SPIN_OFF":   true,
+}
+
+// pickFranchiseRelation returns the preferred relation of the given type,
+// favouring full series over movies, specials and music videos.
+func pickFranchiseRelation(relations []AnimeRelation, relationType string, visited map[int]bool) (AnimeRelation, bool) {
+	var fallback *AnimeRelation
+	for i := range relations {
+		rel := relations[i]
+		if rel.RelationType != relationType || rel.Format == "MUSIC" || visited[rel.ID] {
+			continue
+		}
+		switch rel.Format {
+		case "TV", "TV_SHORT", "ONA":
+			return rel, true
+		}
+		if fallback == nil {
+			fallback = &relations[i]
+		}
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return AnimeRelation{}, false
+}
+
+// FetchFranchiseWatchOrder walks AniList relations from anilistID back to the
+// first prequel and then forward along sequels, returning the franchise in
+// watch order with side stories and spin-offs placed after their parent.
+func FetchFranchiseWatchOrder(anilistID int) ([]FranchiseEntry, error) {
+	type relationResult struct {
+		self      AnimeRelation
+		relations []AnimeRelation
+	}
+	cache := make(map[int]relationResult)
+	fetch := func(id int) (relationResult, error) {
+		if cached, ok := cache[id]; ok {
+			return cached, nil
+		}
+		self, relations, err := FetchAnimeRelations(id)
+		if err != nil {
+			return relationResult{}, err
+		}
+		result := relationResult{self: self, relations: relations}
+		cache[id] = result
+		return result, nil
+	}
+
+	rootID := anilistID
+	seen := map[int]bool{anilistID: true}
+	for i := 0; i < maxFranchiseHops; i++ {
+		current, err := fetch(rootID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch relations for %d: %w", rootID, err)
+		}
+		prequel, ok := pickFranchiseRelation(current.relations, "PREQUEL", seen)
+		if !ok {
+			break
+		}
+		seen[prequel.ID] = true
+		rootID = prequel.ID
+	}
+
+	var order []FranchiseEntry
+	added := make(map[int]bool)
+	currentID := rootID
+	for i := 0; i < maxFranchiseHops && currentID != 0; i++ {
+		current, err := fetch(currentID)
+		if err != nil {
+			if len(order) > 0 {
+				Log(fmt.Sprintf("Stopping franchise walk at %d: %v", currentID, err))
+				break
+			}
+			return nil, fmt.Errorf("failed to fetch relations for %d: %w", currentID, err)
+		}
+		order = append(order, FranchiseEntry{AnimeRelation: current.self, Main: true})
+		added[current.self.ID] = true
+
+		for _, rel := range current.relations {
+			if franchiseSideRelations[rel.RelationType] && !added[rel.ID] {
+				order = append(order, FranchiseEntry{AnimeRelation: rel})
+				added[rel.ID] = true
+			}
+		}
+
+		currentID = 0
+		if sequel, ok := pickFranchiseRelation(current.relations, "SEQUEL", added); ok {
+			currentID = sequel.ID
+		}
+	}
+
+	return order, nil
+}
+
+func animeDisplayTitle(config *CurdConfig, title AnimeTitle) string {
+	if title.English == "" || (config != nil && config.AnimeNameLanguage == "romaji") {
+		return title.Romaji
+	}
+	return title.English
+}
+
+// relationTrackerID returns the ID used by the configured tracker for rel.
+func relationTrackerID(config *CurdConfig, rel AnimeRelation) int {
+	if GetTrackingService(config) == "mal" {
+		return rel.MalID
+	}
+	return rel.ID
+}
+
+// relationListStatus reports the list a related anime is on and its list entry.
+func relationListStatus(config *CurdConfig, user *User, rel AnimeRelation) (string, *Entry) {
+	id := relationTrackerID(config, rel)
+	if user == nil || id == 0 {
+		return "", nil
+	}
+	lists := []struct {
+		status  string
+		entries []Entry
+	}{
+		{"CURRENT", user.AnimeList.Watching},
+		{"REPEATING", user.AnimeList.Rewatching},
+		{"COMPLETED", user.AnimeList.Completed},
+		{"PAUSED", user.AnimeList.Paused},
+		{"DROPPED", user.AnimeList.Dropped},
+		{"PLANNING", user.AnimeList.Planning},
+	}
+	for _, list := range lists {
+		for i := range list.entries {
+			if list.entries[i].Media.ID == id {
+				entry := list.entries[i]
+				return list.status, &entry
+			}
+		}
+	}
+	return "", nil
+}
+
+func franchiseProgressLabel(config *CurdConfig, user *User, rel AnimeRelation) string {
+	if rel.Status == "NOT_YET_RELEASED" {
+		return "not yet aired"
+	}
+	total := "?"
+	if rel.Episodes > 0 {
+		total = strconv.Itoa(rel.Episodes)
+	}
+	status, entry := relationListStatus(config, user, rel)
+	if entry == nil {
+		return fmt.Sprintf("not on list, %s eps", total)
+	}
+	labels := map[string]string{
+		"CURRENT":   "watching",
+		"REPEATING": "rewatching",
+		"COMPLETED": "completed",
+		"PAUSED":    "paused",
+		"DROPPED":   "dropped",
+		"PLANNING":  "planning",
+	}
+	return fmt.Sprintf("%s %d/%s", labels[status], entry.Progress, total)
+}
+
+func franchiseEntryLabel(config *CurdConfig, user *User, index int, entry FranchiseEntry) string {
+	details := []string{}
+	if entry.Format != "" {
+		details = append(details, entry.Format)
+	}
+	if entry.Year > 0 {
+		details = append(details, strconv.Itoa(entry.Year))
+	}
+	prefix := fmt.Sprintf("%d.", index)
+	if !entry.Main {
+		relation := strings.ToLower(strings.ReplaceAll(entry.RelationType, "_", " "))
+		prefix = fmt.Sprintf("↳ %s%s:", strings.ToUpper(relation[:1]), relation[1:])
+	}
+	label := fmt.Sprintf("%s %s", prefix, animeDisplayTitle(config, entry.Title))
+	if len(details) > 0 {
+		label += fmt.Sprintf(" (%s)", strings.Join(details, ", "))
+	}
+	return fmt.Sprintf("%s - %s", label, franchiseProgressLabel(config, user, entry.AnimeRelation))
+}
+
+// selectFranchiseEntry shows the franchise watch order for anilistID with
+// per-entry progress and returns the entry the user picked.
+func selectFranchiseEntry(config *CurdConfig, user *User, anilistID int) (AnimeRelation, bool, error) {
+	CurdOut("Loading franchise watch order...")
+	order, err := FetchFranchiseWatchOrder(anilistID)
+	if err != nil {
+		return AnimeRelation{}, false, err
+	}
+	if len(order) == 0 {
+		return AnimeRelation{}, false, fmt.Errorf("no franchise entries found")
+	}
+
+	options := []SelectionOption{{Key: "back", Label: "<- Back"}}
+	byKey := make(map[string]AnimeRelation, len(order))
+	mainIndex := 0
+	for _, entry := range order {
+		if entry.Main {
+			mainIndex++
+		}
+		key := strconv.Itoa(entry.ID)
+		byKey[key] = entry.AnimeRelation
+		options = append(options, SelectionOption{
+			Key:   key,
+			Label: franchiseEntryLabel(config, user, mainIndex, entry),
+		})
+	}
+
+	selected, err := DynamicSelectOrdered(options)
+	if err != nil {
+		return AnimeRelation{}, false, err
+	}
+	rel, ok := byKey[selected.Key]
+	return rel, ok, nil
+}
+
+// refreshUserAnimeList reloads the tracker list after it was modified.
+func refreshUserAnimeList(config *CurdConfig, user *User) error {
+	withPreview := usePreviewMenu(config)
+	data, err := GetUserDataUnified(user.Token, user.Id, config, withPreview)
+	if err != nil {
+		return err
+	}
+	user.AnimeList = ParseAnimeList(data)
+	return nil
+}
+
+// ensureRelationWatching adds rel to the watching list unless it is
+// already being watched, completed or dropped, and returns its tracker ID.
+func ensureRelationWatching(config *CurdConfig, user *User, rel AnimeRelation) (int, error) {
+	mediaID := relationTrackerID(config, rel)
+	if mediaID == 0 {
+		return 0, fmt.Errorf("%s has no %s ID", animeDisplayTitle(config, rel.Title), GetServiceName(config))
+	}
+	status, _ := relationListStatus(config, user, rel)
+	if status != "" && status != "PLANNING" && status != "PAUSED" {
+		return mediaID, nil
+	}
+	if err := AddAnimeToWatchingListUnified(mediaID, user.Token, config); err != nil {
+		return 0, err
+	}
+	if err := refreshUserAnimeList(config, user); err != nil {
+		Log(fmt.Sprintf("Error refreshing anime list: %v", err))
+	}
+	return mediaID, nil
+}
+
+// FranchiseMenu lets the user pick a show from their list, view its franchise
+// watch order and choose an entry to watch. The returned option is keyed by
+// the tracker ID, or "back" when the user backed out.
+func FranchiseMenu(config *CurdConfig, user *User) (SelectionOption, error) {
+	DiscordMenuPresence(config, "franchise watch orders")
+	options := []SelectionOption{{Key: "back", Label: "<- Back"}}
+	for _, entry := range getEntriesByCategory(user.AnimeList, "ALL") {
+		options = append(options, SelectionOption{
+			Key:   strconv.Itoa(entry.Media.ID),
+			Label: animeDisplayTitle(config, entry.Media.Title),
+		})
+	}
+
+	CurdOut("Select an anime to view its franchise watch order")
+	selected, err := DynamicSelect(options)
+	if err != nil {
+		return SelectionOption{}, err
+	}
+	if selected.Key == "-1" || selected.Key == "back" || selected.Key == "" {
+		return SelectionOption{Key: "back"}, nil
+	}
+
+	anilistID, err := strconv.Atoi(selected.Key)
+	if err != nil {
+		return SelectionOption{}, fmt.Errorf("invalid anime ID %q", selected.Key)
+	}
+	if GetTrackingService(config) == "mal" {
+		anilistID, err = ConvertMALIDToAnilist(anilistID, "")
+		if err != nil {
+			return SelectionOption{}, fmt.Errorf("failed to convert MAL ID to AniList ID: %w", err)
+		}
+	}
+
+	rel, ok, err := selectFranchiseEntry(config, user, anilistID)
+	if err != nil {
+		return SelectionOption{}, err
+	}
+	if !ok {
+		return SelectionOption{Key: "back"}, nil
+	}
+
+	mediaID, err := ensureRelationWatching(config, user, rel)
+	if err != nil {
+		return SelectionOption{}, err
+	}
+	return SelectionOption{Key: strconv.Itoa(mediaID), Label: animeDisplayTitle(config, rel.Title)}, nil
+}
+
+// ContinueWithSequel offers to continue with the sequel of a finished anime.
+// When accepted, the sequel is added to the watching list, mapped to a
+// provider and anime is switched over to it so the playback loop can go on.
+func ContinueWithSequel(config *CurdConfig, anime *Anime, user *User, databaseFile string) bool {
+	_, relations, err := FetchAnimeRelations(anime.AnilistId)
+	if err != nil {
+		Log(fmt.Sprintf("Error fetching relations for %d: %v", anime.AnilistId, err))
+		return false
+	}
+	sequel, ok := pickFranchiseRelation(relations, "SEQUEL", nil)
+	if !ok {
+		return false
+	}
+	sequelTitle := animeDisplayTitle(config, sequel.Title)
+	if sequel.Status == "NOT_YET_RELEASED" {
+		CurdOut(fmt.Sprintf("Sequel %s has not aired yet.", sequelTitle))
+		return false
+	}
+
+	options := []SelectionOption{
+		{Key: "sequel", Label: fmt.Sprintf("Continue with sequel: %s", sequelTitle)},
+		{Key: "franchise", Label: "Show franchise watch order"},
+	}
+	selected, err := DynamicSelect(options)
+	if err != nil {
+		Log(fmt.Sprintf("Error in sequel prompt selection: %v", err))
+		return false
+	}
+
+	target := sequel
+	switch selected.Key {
+	case "sequel":
+	case "franchise":
+		rel, ok, err := selectFranchiseEntry(config, user, anime.AnilistId)
+		if err != nil {
+			Log(fmt.Sprintf("Error showing franchise watch order: %v", err))
+			CurdOut(fmt.Sprintf("Failed to load franchise watch order: %v", err))
+			return false
+		}
+		if !ok {
+			return false
+		}
+		target = rel
+	default:
+		return false
+	}
+
+	return startFranchiseEntry(config, anime, user, databaseFile, target)
+}
+
+func startFranchiseEntry(config *CurdConfig, anime *Anime, user *User, databaseFile string, rel AnimeRelation) bool {
+	if _, err := ensureRelationWatching(config, user, rel); err != nil {
+		Log(fmt.Sprintf("Error adding %d to watching list: %v", rel.ID, err))
+		CurdOut(fmt.Sprintf("Failed to add %s to your watching list: %v", animeDisplayTitle(config, rel.Title), err))
+		return false
+	}
+
+	next := Anime{
+		AnilistId:     rel.ID,
+		MalId:         rel.MalID,
+		Title:         rel.Title,
+		TotalEpisodes: rel.Episodes,
+		CoverImage:    rel.CoverImage,
+		IsAiring:      rel.Status == "RELEASING",
+		ProviderName:  anime.ProviderName,
+	}
+	next.Ep.Number = 1
+	next.Ep.Player.Speed = anime.Ep.Player.Speed
+	next.Ep.Player.SocketPath = anime.Ep.Player.SocketPath
+
+	status, listEntry := relationListStatus(config, user, rel)
+	if listEntry != nil && status != "COMPLETED" && listEntry.Progress > 0 {
+		next.Ep.Number = listEntry.Progress + 1
+	}
+	if status == "COMPLETED" {
+		next.Rewatching = true
+	}
+
+	if local := LocalFindAnime(LocalGetAllAnime(databaseFile), rel.ID, ""); local != nil && local.ProviderId != "" {
+		next.ProviderId = local.ProviderId
+		if local.ProviderName != "" {
+			next.ProviderName = local.ProviderName
+		}
+		if local.Ep.Number == next.Ep.Number {
+			next.Ep.Player.PlaybackTime = local.Ep.Player.PlaybackTime
+			next.Ep.Resume = next.Ep.Player.PlaybackTime > 0
+		}
+	} else {
+		mappingEntry := listEntry
+		if mappingEntry == nil {
+			mappingEntry = &Entry{Media: Media{
+				ID:       relationTrackerID(config, rel),
+				MalID:    rel.MalID,
+				Episodes: rel.Episodes,
+				Format:   rel.Format,
+				Status:   rel.Status,
+				Title:    rel.Title,
+			}}
+		}
+		outcome, err := ResolveAnimeProviderMapping(config, &next, rel.Title.Romaji, mappingEntry)
+		if err != nil {
+			Log(fmt.Sprintf("Failed to resolve provider mapping for %d: %v", rel.ID, err))
+			CurdOut(fmt.Sprintf("Failed to find %s on a provider: %v", animeDisplayTitle(config, rel.Title), err))
+			return false
+		}
+		if outcome != ProviderMappingOK {
+			return false
+		}
+	}
+
+	if err := LocalUpdateAnime(databaseFile, next.AnilistId, next.ProviderId, next.Ep.Number, next.Ep.Player.PlaybackTime, 0, GetAnimeName(next), next.ProviderName); err != nil {
+		Log(fmt.Sprintf("Warning: Failed to save %d to database: %v", next.AnilistId, err))
+	}
+
+	*anime = next
+	if anime.MalId != 0 {
+		go func(anilistID, malID int) {
+			fillerList, err := FetchFillerEpisodes(malID)
+			if err != nil {
+				Log("Error getting filler list: " + err.Error())
+				return
+			}
+			if anime.AnilistId == anilistID {
+				anime.FillerEpisodes = fillerList
+			}
+		}(anime.AnilistId, anime.MalId)
+	}
+
+	CurdOut(fmt.Sprintf("Continuing with %s - Episode %d", GetAnimeName(*anime), anime.Ep.Number))
+	return true
+}
+
+// OfferSequelAfterCompletion handles series completion for a finished anime
+// whose last episode was just watched and then offers its sequel. It reports
+// whether playback should continue with the new anime.
+func OfferSequelAfterCompletion(config *CurdConfig, anime *Anime, user *User, databaseFile string) bool {
+	if anime.TotalEpisodes <= 0 || anime.Ep.Number != anime.TotalEpisodes || anime.IsAiring {
+		return false
+	}
+	HandleLastEpisodeCompletion(config, anime, user)
+	return ContinueWithSequel(config, anime, user, databaseFile)
+}
@@ -0,0 +1,196 @@
+// Package fuzzy scores selection menu entries against what the user typed,
+// fzf style: the characters of the pattern must appear in order, and
+// matches that are contiguous or start words score higher than scattered
+// ones.
+package fuzzy
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	scoreMatch       = 16
+	bonusBoundary    = 10
+	bonusCamel       = 8
+	bonusConsecutive = 8
+	penaltyGapStart  = 3
+	penaltyGapExtra  = 1
+)
+
+// Result is how well a pattern matched a text.
+type Result struct {
+	Score int
+	// Positions are the indexes of the matched runes of the text, in order.
+	Positions []int
+}
+
+// Match matches pattern against text. Words of pattern separated by spaces
+// must all match, in any order. A word with an uppercase letter matches
+// case-sensitively, as in fzf's smart case. An empty pattern matches
+// everything with a zero score.
+func Match(pattern, text string) (Result, bool) {
+	runes := []rune(text)
+	var result Result
+	for _, term := range strings.Fields(pattern) {
+		score, positions, ok := matchTerm([]rune(term), runes)
+		if !ok {
+			return Result{}, false
+		}
+		result.Score += score
+		result.Positions = append(result.Positions, positions...)
+	}
+	result.Positions = dedupe(result.Positions)
+	return result, true
+}
+
+// Best matches pattern against each of texts, such as a show's titles in
+// several languages, and returns the best result and the index of the text
+// it came from. Ties go to the earlier text.
+func Best(pattern string, texts ...string) (Result, int, bool) {
+	best, index, found := Result{}, -1, false
+	for i, text := range texts {
+		if text == "" {
+			continue
+		}
+		result, ok := Match(pattern, text)
+		if ok && (!found || result.Score > best.Score) {
+			best, index, found = result, i, true
+		}
+	}
+	return best, index, found
+}
+
+// Highlight renders text with the runes at positions passed through match
+// and the rest through plain. Runs of either kind are rendered at once.
+func Highlight(text string, positions []int, match, plain func(string) string) string {
+	matched := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		matched[p] = true
+	}
+	var b strings.Builder
+	var run []rune
+	runMatched := false
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		if runMatched {
+			b.WriteString(match(string(run)))
+		} else {
+			b.WriteString(plain(string(run)))
+		}
+		run = run[:0]
+	}
+	for i, r := range []rune(text) {
+		if matched[i] != runMatched {
+			flush()
+			runMatched = matched[i]
+		}
+		run = append(run, r)
+	}
+	flush()
+	return b.String()
+}
+
+// matchTerm finds the shortest window of text holding term in order, as
+// fzf's first algorithm does, and scores the leftmost match inside it.
+func matchTerm(term, text []rune) (int, []int, bool) {
+	caseSensitive := false
+	for _, r := range term {
+		if unicode.IsUpper(r) {
+			caseSensitive = true
+			break
+		}
+	}
+	equal := func(a, b rune) bool {
+		if caseSensitive {
+			return a == b
+		}
+		return unicode.ToLower(a) == unicode.ToLower(b)
+	}
+
+	// Forward: where does the first complete match end?
+	t, end := 0, -1
+	for i := 0; i < len(text) && t < len(term); i++ {
+		if equal(text[i], term[t]) {
+			t++
+			if t == len(term) {
+				end = i
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	// Backward: the latest start that still holds the whole term.
+	start := end
+	for i, t := end, len(term)-1; i >= 0; i-- {
+		if equal(text[i], term[t]) {
+			start = i
+			t--
+			if t < 0 {
+				break
+			}
+		}
+	}
+
+	positions := make([]int, 0, len(term))
+	t = 0
+	for i := start; i <= end && t < len(term); i++ {
+		if equal(text[i], term[t]) {
+			positions = append(positions, i)
+			t++
+		}
+	}
+	return score(text, positions), positions, true
+}
+
+func score(text []rune, positions []int) int {
+	total := 0
+	for n, p := range positions {
+		bonus := boundaryBonus(text, p)
+		if n == 0 {
+			// Where the match starts matters most, as in fzf.
+			bonus *= 2
+		} else if gap := p - positions[n-1] - 1; gap == 0 {
+			bonus += bonusConsecutive
+		} else {
+			total -= penaltyGapStart + (gap-1)*penaltyGapExtra
+		}
+		total += scoreMatch + bonus
+	}
+	return total
+}
+
+func boundaryBonus(text []rune, i int) int {
+	if i == 0 {
+		return bonusBoundary
+	}
+	prev, cur := text[i-1], text[i]
+	switch {
+	case !unicode.IsLetter(prev) && !unicode.IsDigit(prev) && (unicode.IsLetter(cur) || unicode.IsDigit(cur)):
+		return bonusBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(cur):
+		return bonusCamel
+	case !unicode.IsDigit(prev) && unicode.IsDigit(cur):
+		return bonusCamel
+	}
+	return 0
+}
+
+func dedupe(positions []int) []int {
+	if len(positions) < 2 {
+		return positions
+	}
+	sort.Ints(positions)
+	out := positions[:1]
+	for _, p := range positions[1:] {
+		if p != out[len(out)-1] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,70 @@
+package fuzzy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	result, ok := Match("frn", "Sousou no Frieren")
+	if !ok {
+		t.Fatal("expected a match")
+	}
+	if want := []int{10, 11, 16}; !reflect.DeepEqual(result.Positions, want) {
+		t.Fatalf("positions = %v, want %v", result.Positions, want)
+	}
+
+	if _, ok := Match("nrf", "Sousou no Frieren"); ok {
+		t.Fatal("characters out of order must not match")
+	}
+	if _, ok := Match("Fri", "sousou no frieren"); ok {
+		t.Fatal("an uppercase pattern should match case-sensitively")
+	}
+	if result, ok := Match("", "anything"); !ok || result.Score != 0 {
+		t.Fatalf("an empty pattern should match with no score, got %+v %v", result, ok)
+	}
+	if _, ok := Match("葬送 フリ", "葬送のフリーレン"); !ok {
+		t.Fatal("native titles should match word by word")
+	}
+	if _, ok := Match("frieren zzz", "Frieren"); ok {
+		t.Fatal("every word of the pattern must match")
+	}
+}
+
+func TestScoreRanksTightMatchesFirst(t *testing.T) {
+	ranked := []string{"Frieren", "Fire Force", "Dr. Stone: New World Fourth Reunion"}
+	prev := 1 << 30
+	for _, text := range ranked {
+		result, ok := Match("fr", text)
+		if !ok {
+			t.Fatalf("%q should match", text)
+		}
+		if result.Score > prev {
+			t.Fatalf("%q scored %d, above the tighter match before it (%d)", text, result.Score, prev)
+		}
+		prev = result.Score
+	}
+
+	// The shortest window wins over the first occurrence.
+	result, _ := Match("ab", "a--------ab")
+	if want := []int{9, 10}; !reflect.DeepEqual(result.Positions, want) {
+		t.Fatalf("positions = %v, want %v", result.Positions, want)
+	}
+}
+
+func TestBest(t *testing.T) {
+	result, index, ok := Best("shingeki", "Attack on Titan", "Shingeki no Kyojin", "進撃の巨人")
+	if !ok || index != 1 || len(result.Positions) != 8 {
+		t.Fatalf("expected the romaji title to match, got %+v from %d", result, index)
+	}
+	if _, _, ok := Best("zzz", "Attack on Titan", ""); ok {
+		t.Fatal("nothing should match")
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	got := Highlight("Frieren", []int{0, 1, 6}, func(s string) string { return "[" + s + "]" }, func(s string) string { return s })
+	if want := "[Fr]iere[n]"; got != want {
+		t.Fatalf("Highlight = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,281 @@
+// Package hooks runs user-configured commands and webhooks when something
+// happens during playback, such as an episode starting or a tracker being
+// updated.
+//
+// Hooks are read from a JSON file:
+//
+//	[
+//	  {"events": ["episode_complete", "show_complete"], "command": "~/bin/scrobble"},
+//	  {"events": ["*"], "url": "https://example.org/curd", "headers": {"Authorization": "Bearer …"}}
+//	]
+//
+// A command runs through the shell with the event as JSON on its stdin and
+// CURD_EVENT set to the event type. A webhook receives the same JSON as a
+// POST body.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	EpisodeStart    = "episode_start"
+	Pause           = "pause"
+	Resume          = "resume"
+	EpisodeComplete = "episode_complete"
+	TrackerUpdate   = "tracker_update"
+	ShowComplete    = "show_complete"
+)
+
+// EventTypes lists every event a hook can subscribe to.
+var EventTypes = []string{EpisodeStart, Pause, Resume, EpisodeComplete, TrackerUpdate, ShowComplete}
+
+// DefaultTimeout bounds a single hook run.
+const DefaultTimeout = 10 * time.Second
+
+// Event is what hooks receive.
+type Event struct {
+	Type          string    `json:"event"`
+	Time          time.Time `json:"time"`
+	Account       string    `json:"account,omitempty"`
+	AnilistID     int       `json:"anilist_id,omitempty"`
+	MalID         int       `json:"mal_id,omitempty"`
+	Title         string    `json:"title,omitempty"`
+	Episode       int       `json:"episode,omitempty"`
+	TotalEpisodes int       `json:"total_episodes,omitempty"`
+	EpisodeTitle  string    `json:"episode_title,omitempty"`
+	Provider      string    `json:"provider,omitempty"`
+	Mode          string    `json:"mode,omitempty"`
+	Position      int       `json:"position,omitempty"`
+	Duration      int       `json:"duration,omitempty"`
+	Tracker       *Tracker  `json:"tracker,omitempty"`
+}
+
+// Tracker describes a tracker update.
+type Tracker struct {
+	// Services are the trackers written to, such as "anilist" and "mal".
+	Services []string `json:"services"`
+	// Action is "progress", "status", "score" or "add".
+	Action   string `json:"action"`
+	Progress int    `json:"progress,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Hook is one configured reaction. Exactly one of Command and URL is set.
+type Hook struct {
+	Events  []string          `json:"events"`
+	Command string            `json:"command,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Timeout is in seconds; DefaultTimeout when zero.
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// Wants reports whether h subscribes to eventType.
+func (h Hook) Wants(eventType string) bool {
+	for _, event := range h.Events {
+		if event == "*" || event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (h Hook) timeout() time.Duration {
+	if h.Timeout > 0 {
+		return time.Duration(h.Timeout) * time.Second
+	}
+	return DefaultTimeout
+}
+
+func (h Hook) target() string {
+	if h.URL != "" {
+		return h.URL
+	}
+	return h.Command
+}
+
+// Load reads the hooks in path. A missing file means no hooks.
+func Load(path string) ([]Hook, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hooks: %w", err)
+	}
+	var list []Hook
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for i, hook := range list {
+		if (hook.Command == "") == (hook.URL == "") {
+			return nil, fmt.Errorf("hook %d in %s needs either a command or a url", i+1, path)
+		}
+		if len(hook.Events) == 0 {
+			return nil, fmt.Errorf("hook %d in %s has no events", i+1, path)
+		}
+		for _, event := range hook.Events {
+			if event != "*" && !knownEvent(event) {
+				return nil, fmt.Errorf("hook %d in %s: unknown event %q (want one of %s or *)", i+1, path, event, strings.Join(EventTypes, ", "))
+			}
+		}
+	}
+	return list, nil
+}
+
+func knownEvent(event string) bool {
+	for _, known := range EventTypes {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Dispatcher runs hooks in the background so playback never waits on them.
+type Dispatcher struct {
+	hooks  []Hook
+	client *http.Client
+	log    func(string)
+
+	wg sync.WaitGroup
+}
+
+// NewDispatcher returns a Dispatcher for hooks. client sends the webhooks
+// and log receives failures; either may be nil.
+func NewDispatcher(hooks []Hook, client *http.Client, log func(string)) *Dispatcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if log == nil {
+		log = func(string) {}
+	}
+	return &Dispatcher{hooks: hooks, client: client, log: log}
+}
+
+// Wants reports whether any hook subscribes to one of eventTypes, so
+// callers can skip work, such as polling the player, nobody listens for.
+func (d *Dispatcher) Wants(eventTypes ...string) bool {
+	if d == nil {
+		return false
+	}
+	for _, hook := range d.hooks {
+		for _, eventType := range eventTypes {
+			if hook.Wants(eventType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Fire starts every hook subscribed to event.Type.
+func (d *Dispatcher) Fire(event Event) {
+	if d == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	var payload []byte
+	for _, hook := range d.hooks {
+		if !hook.Wants(event.Type) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			if payload, err = json.Marshal(event); err != nil {
+				d.log(fmt.Sprintf("Hook event %s could not be encoded: %v", event.Type, err))
+				return
+			}
+		}
+		d.wg.Add(1)
+		go func(hook Hook) {
+			defer d.wg.Done()
+			if err := d.run(hook, event.Type, payload); err != nil {
+				d.log(fmt.Sprintf("Hook %s for %s failed: %v", hook.target(), event.Type, err))
+			}
+		}(hook)
+	}
+}
+
+// Wait blocks until the running hooks finish or timeout passes, and reports
+// whether they finished.
+func (d *Dispatcher) Wait(timeout time.Duration) bool {
+	if d == nil {
+		return true
+	}
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (d *Dispatcher) run(hook Hook, eventType string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hook.timeout())
+	defer cancel()
+	if hook.URL != "" {
+		return d.post(ctx, hook, payload)
+	}
+	return runCommand(ctx, hook.Command, eventType, payload)
+}
+
+func (d *Dispatcher) post(ctx context.Context, hook Hook, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range hook.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+func runCommand(ctx context.Context, command, eventType string, payload []byte) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(), "CURD_EVENT="+eventType)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if text := strings.TrimSpace(string(output)); text != "" {
+			return fmt.Errorf("%w: %s", err, text)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package hooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeHooks(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hooks.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadValidatesHooks(t *testing.T) {
+	if hooks, err := Load(filepath.Join(t.TempDir(), "missing.json")); err != nil || hooks != nil {
+		t.Fatalf("a missing file should mean no hooks, got %v %v", hooks, err)
+	}
+
+	bad := map[string]string{
+		"no target":     `[{"events": ["pause"]}]`,
+		"two targets":   `[{"events": ["pause"], "command": "true", "url": "http://x"}]`,
+		"no events":     `[{"command": "true"}]`,
+		"unknown event": `[{"events": ["paused"], "command": "true"}]`,
+	}
+	for name, content := range bad {
+		if _, err := Load(writeHooks(t, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	hooks, err := Load(writeHooks(t, `[{"events": ["*"], "url": "http://x"}, {"events": ["pause", "resume"], "command": "true"}]`))
+	if err != nil || len(hooks) != 2 {
+		t.Fatalf("unexpected hooks %v %v", hooks, err)
+	}
+	d := NewDispatcher(hooks[1:], nil, nil)
+	if !d.Wants(EpisodeStart, Resume) || d.Wants(TrackerUpdate) {
+		t.Fatal("Wants does not follow the subscribed events")
+	}
+}
+
+func TestWebhookReceivesEvent(t *testing.T) {
+	received := make(chan Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("missing configured header")
+		}
+		var event Event
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("bad payload %s: %v", body, err)
+		}
+		received <- event
+	}))
+	defer server.Close()
+
+	d := NewDispatcher([]Hook{{Events: []string{EpisodeComplete}, URL: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}}}, server.Client(), nil)
+	d.Fire(Event{Type: Pause, Title: "ignored"})
+	d.Fire(Event{Type: EpisodeComplete, AnilistID: 154587, Title: "Frieren", Episode: 3})
+	if !d.Wait(5 * time.Second) {
+		t.Fatal("webhook did not finish")
+	}
+	select {
+	case event := <-received:
+		if event.Type != EpisodeComplete || event.AnilistID != 154587 || event.Episode != 3 || event.Time.IsZero() {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	default:
+		t.Fatal("webhook was not called")
+	}
+	if len(received) != 0 {
+		t.Fatal("an unsubscribed event reached the webhook")
+	}
+}
+
+func TestCommandGetsEventOnStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	out := filepath.Join(t.TempDir(), "event.json")
+	var failures []string
+	d := NewDispatcher([]Hook{
+		{Events: []string{TrackerUpdate}, Command: `cat > "` + out + `"; test "$CURD_EVENT" = tracker_update`},
+		{Events: []string{TrackerUpdate}, Command: "exit 3"},
+	}, nil, func(line string) { failures = append(failures, line) })
+	d.Fire(Event{Type: TrackerUpdate, Tracker: &Tracker{Services: []string{"anilist"}, Action: "progress", Progress: 4}})
+	if !d.Wait(5 * time.Second) {
+		t.Fatal("command did not finish")
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event Event
+	if err := json.Unmarshal(data, &event); err != nil || event.Tracker == nil || event.Tracker.Progress != 4 {
+		t.Fatalf("unexpected stdin %s %v", data, err)
+	}
+	if len(failures) != 1 || !strings.Contains(failures[0], "exit 3") {
+		t.Fatalf("expected the failing command to be logged, got %v", failures)
+	}
+}
@@ -4,19 +4,14 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/cookiejar"
-	"net/url"
 	"strings"
 	"time"
+
+	"github.com/wraient/curd/internal/httpclient"
 )
 
 var sharedHTTPClient *http.Client
 
-func SetCookiesForAnimepahe(u *url.URL, cookies []*http.Cookie) {
-	if sharedHTTPClient != nil && sharedHTTPClient.Jar != nil {
-		sharedHTTPClient.Jar.SetCookies(u, cookies)
-	}
-}
-
 func httpStatusOK(statusCode int) bool {
 	return statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices
 }
@@ -34,13 +29,46 @@ func httpStatusError(context string, statusCode int, body []byte) error {
 
 func init() {
 	jar, _ := cookiejar.New(nil)
+	transport, _ := httpclient.New(httpclient.Config{
+		Timeout:    15 * time.Second,
+		Retries:    3,
+		RateLimits: httpclient.DefaultRateLimits,
+	})
 	sharedHTTPClient = &http.Client{
-		Transport: &http.Transport{
-			MaxIdleConns:        10,
-			MaxIdleConnsPerHost: 5,
-			IdleConnTimeout:     30 * time.Second,
-		},
-		Timeout: 15 * time.Second,
-		Jar:     jar,
+		Transport: transport,
+		Jar:       jar,
+	}
+}
+
+// ConfigureHTTPClient rebuilds the shared transport from the network
+// settings in config. Every request curd and its providers make goes
+// through it, so it should run before the first request.
+func ConfigureHTTPClient(config *CurdConfig) error {
+	limits, err := httpclient.ParseRateLimits(parseStringArray(config.RateLimits))
+	if err != nil {
+		return err
+	}
+	transport, err := httpclient.New(httpclient.Config{
+		Proxy:        strings.TrimSpace(config.HTTPProxy),
+		DNSOverHTTPS: strings.TrimSpace(config.DNSOverHTTPS),
+		Timeout:      time.Duration(config.HTTPTimeout) * time.Second,
+		Retries:      config.HTTPRetries,
+		RateLimits:   limits,
+		Log:          func(line string) { Log(line) },
+	})
+	if err != nil {
+		return err
+	}
+	sharedHTTPClient.Transport = transport
+	return nil
+}
+
+// httpClientWithTimeout returns a client on the shared transport and cookie
+// jar whose requests, retries included, give up after timeout.
+func httpClientWithTimeout(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: sharedHTTPClient.Transport,
+		Jar:       sharedHTTPClient.Jar,
+		Timeout:   timeout,
 	}
 }
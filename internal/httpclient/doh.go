@@ -0,0 +1,232 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dnsTypeA    = 1
+	dnsTypeAAAA = 28
+	// minDNSTTL keeps answers with tiny TTLs from being re-queried for every
+	// connection.
+	minDNSTTL = 30 * time.Second
+)
+
+type dnsEntry struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+// dohResolver resolves host names with RFC 8484 DNS-over-HTTPS queries and
+// caches the answers for their TTL.
+type dohResolver struct {
+	endpoint string
+	host     string
+	client   *http.Client
+
+	mu    sync.Mutex
+	cache map[string]dnsEntry
+}
+
+func newDoHResolver(endpoint string, proxy func(*http.Request) (*url.URL, error)) (*dohResolver, error) {
+	parsed, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid DNS-over-HTTPS endpoint %q: want an https URL", endpoint)
+	}
+	return &dohResolver{
+		endpoint: parsed.String(),
+		host:     parsed.Hostname(),
+		// The endpoint itself is resolved by the system.
+		client: &http.Client{
+			Transport: &http.Transport{Proxy: proxy, ForceAttemptHTTP2: true},
+			Timeout:   10 * time.Second,
+		},
+		cache: map[string]dnsEntry{},
+	}, nil
+}
+
+// dialer returns a DialContext that resolves through r and falls back to
+// the system resolver when the DoH endpoint fails.
+func (r *dohResolver) dialer(d *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil || host == "localhost" || host == r.host {
+			return d.DialContext(ctx, network, addr)
+		}
+		ips, err := r.lookup(ctx, host)
+		if err != nil || len(ips) == 0 {
+			return d.DialContext(ctx, network, addr)
+		}
+		var lastErr error
+		for _, ip := range ips {
+			conn, err := d.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+}
+
+func (r *dohResolver) lookup(ctx context.Context, host string) ([]net.IP, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	r.mu.Lock()
+	entry, ok := r.cache[host]
+	r.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.ips, nil
+	}
+
+	var ips []net.IP
+	ttl := time.Hour
+	var firstErr error
+	for _, qtype := range []uint16{dnsTypeA, dnsTypeAAAA} {
+		answers, answerTTL, err := r.query(ctx, host, qtype)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		ips = append(ips, answers...)
+		if len(answers) > 0 && answerTTL < ttl {
+			ttl = answerTTL
+		}
+	}
+	if len(ips) == 0 {
+		if firstErr == nil {
+			firstErr = fmt.Errorf("no addresses for %s", host)
+		}
+		return nil, firstErr
+	}
+	if ttl < minDNSTTL {
+		ttl = minDNSTTL
+	}
+	r.mu.Lock()
+	r.cache[host] = dnsEntry{ips: ips, expires: time.Now().Add(ttl)}
+	r.mu.Unlock()
+	return ips, nil
+}
+
+func (r *dohResolver) query(ctx context.Context, host string, qtype uint16) ([]net.IP, time.Duration, error) {
+	msg, err := buildDNSQuery(host, qtype)
+	if err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(msg))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("DNS-over-HTTPS query for %s: %w", host, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("DNS-over-HTTPS query for %s failed with status %d", host, resp.StatusCode)
+	}
+	return parseDNSAnswers(body, qtype)
+}
+
+// buildDNSQuery encodes a recursive query for host. The ID is zero as RFC
+// 8484 recommends for cache friendliness.
+func buildDNSQuery(host string, qtype uint16) ([]byte, error) {
+	msg := []byte{0, 0, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 {
+			return nil, fmt.Errorf("invalid host name %q", host)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0, byte(qtype>>8), byte(qtype), 0, 1)
+	return msg, nil
+}
+
+// parseDNSAnswers returns the addresses of type qtype in a DNS response and
+// the lowest TTL among them.
+func parseDNSAnswers(msg []byte, qtype uint16) ([]net.IP, time.Duration, error) {
+	if len(msg) < 12 {
+		return nil, 0, fmt.Errorf("short DNS response")
+	}
+	if rcode := msg[3] & 0x0f; rcode != 0 {
+		return nil, 0, fmt.Errorf("DNS response code %d", rcode)
+	}
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	answers := int(binary.BigEndian.Uint16(msg[6:]))
+
+	offset := 12
+	var err error
+	for i := 0; i < questions; i++ {
+		if offset, err = skipDNSName(msg, offset); err != nil {
+			return nil, 0, err
+		}
+		offset += 4
+	}
+
+	var ips []net.IP
+	var ttl time.Duration
+	for i := 0; i < answers; i++ {
+		if offset, err = skipDNSName(msg, offset); err != nil {
+			return nil, 0, err
+		}
+		if offset+10 > len(msg) {
+			return nil, 0, fmt.Errorf("truncated DNS answer")
+		}
+		rtype := binary.BigEndian.Uint16(msg[offset:])
+		rttl := time.Duration(binary.BigEndian.Uint32(msg[offset+4:])) * time.Second
+		length := int(binary.BigEndian.Uint16(msg[offset+8:]))
+		offset += 10
+		if offset+length > len(msg) {
+			return nil, 0, fmt.Errorf("truncated DNS answer")
+		}
+		data := msg[offset : offset+length]
+		offset += length
+
+		if rtype != qtype || (length != net.IPv4len && length != net.IPv6len) {
+			continue
+		}
+		ips = append(ips, net.IP(append([]byte(nil), data...)))
+		if ttl == 0 || rttl < ttl {
+			ttl = rttl
+		}
+	}
+	return ips, ttl, nil
+}
+
+func skipDNSName(msg []byte, offset int) (int, error) {
+	for {
+		if offset >= len(msg) {
+			return 0, fmt.Errorf("truncated DNS name")
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			return offset + 1, nil
+		case length&0xC0 == 0xC0:
+			if offset+2 > len(msg) {
+				return 0, fmt.Errorf("truncated DNS name")
+			}
+			return offset + 2, nil
+		default:
+			offset += length + 1
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// limiter spaces requests to one host evenly at its per-minute rate while
+// allowing a burst of up to three seconds' worth of requests.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    time.Duration
+	// next is the theoretical arrival time of the next request.
+	next        time.Time
+	pausedUntil time.Time
+}
+
+func newLimiter(perMinute int) *limiter {
+	interval := time.Minute / time.Duration(perMinute)
+	burst := perMinute / 20
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{interval: interval, burst: time.Duration(burst-1) * interval}
+}
+
+// reserve books a slot for a request made at now and returns how long the
+// request has to wait for it.
+func (l *limiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	start := now
+	if l.pausedUntil.After(start) {
+		start = l.pausedUntil
+	}
+	if l.next.Before(start) {
+		l.next = start
+	}
+	at := l.next.Add(-l.burst)
+	if at.Before(start) {
+		at = start
+	}
+	l.next = l.next.Add(l.interval)
+	return at.Sub(now)
+}
+
+func (l *limiter) wait(ctx context.Context) error {
+	return sleep(ctx, l.reserve(time.Now()))
+}
+
+// pause holds every request to the host for d, used when the server
+// answers 429.
+func (l *limiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// ParseRateLimits reads "host=requests-per-minute" entries.
+func ParseRateLimits(entries []string) (map[string]int, error) {
+	limits := map[string]int{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, value, ok := strings.Cut(entry, "=")
+		perMinute, err := strconv.Atoi(strings.TrimSpace(value))
+		host = strings.ToLower(strings.TrimSpace(host))
+		if !ok || err != nil || host == "" || perMinute < 0 {
+			return nil, fmt.Errorf("invalid rate limit %q (want host=requests-per-minute)", entry)
+		}
+		limits[host] = perMinute
+	}
+	return limits, nil
+}
@@ -0,0 +1,343 @@
+// Package httpclient builds the HTTP transport curd uses for every request.
+//
+// The Transport layers, in order: a per-host rate limit, retries with
+// jittered exponential backoff on 429 and 5xx responses that honour
+// Retry-After, and a per-attempt timeout that covers the wait for response
+// headers and every pause between body reads, so long downloads are not cut
+// off while stalled requests are. Underneath, an http.Transport dials through
+// the configured HTTP or SOCKS proxy and can resolve host names with
+// DNS-over-HTTPS.
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRateLimits are requests per minute for APIs that enforce a limit.
+var DefaultRateLimits = map[string]int{
+	"api.jikan.moe":      60,
+	"graphql.anilist.co": 90,
+}
+
+const (
+	defaultBaseDelay = 500 * time.Millisecond
+	maxBackoff       = 10 * time.Second
+	// maxRetryAfter is the longest Retry-After worth waiting for; a server
+	// asking for more gets its response passed through.
+	maxRetryAfter = time.Minute
+)
+
+// Config describes the transport.
+type Config struct {
+	// Proxy is an http://, https://, socks5:// or socks5h:// proxy URL.
+	// When empty the HTTP_PROXY/HTTPS_PROXY environment is used.
+	Proxy string
+	// DNSOverHTTPS is an RFC 8484 endpoint such as
+	// https://cloudflare-dns.com/dns-query. When empty the system resolver
+	// is used.
+	DNSOverHTTPS string
+	// Timeout bounds each attempt's wait for headers and every pause
+	// between body reads. Zero disables it.
+	Timeout time.Duration
+	// Retries is how many times a failed request is retried.
+	Retries int
+	// RateLimits maps a host, which also matches its subdomains, to the
+	// requests per minute allowed to it.
+	RateLimits map[string]int
+	// Log receives a line for every retry. It may be nil.
+	Log func(string)
+}
+
+// Transport is an http.RoundTripper adding rate limits, retries and
+// timeouts to a base transport.
+type Transport struct {
+	base      http.RoundTripper
+	timeout   time.Duration
+	retries   int
+	baseDelay time.Duration
+	limiters  map[string]*limiter
+	log       func(string)
+}
+
+// New builds a Transport from config.
+func New(config Config) (*Transport, error) {
+	proxy := http.ProxyFromEnvironment
+	if config.Proxy != "" {
+		proxyURL, err := ParseProxy(config.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	dial := dialer.DialContext
+	if config.DNSOverHTTPS != "" {
+		resolver, err := newDoHResolver(config.DNSOverHTTPS, proxy)
+		if err != nil {
+			return nil, err
+		}
+		dial = resolver.dialer(dialer)
+	}
+
+	base := &http.Transport{
+		Proxy:               proxy,
+		DialContext:         dial,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        10,
+		MaxIdleConnsPerHost: 5,
+		IdleConnTimeout:     30 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	return Wrap(base, config), nil
+}
+
+// Wrap adds the rate limits, retries and timeouts of config to base.
+func Wrap(base http.RoundTripper, config Config) *Transport {
+	t := &Transport{
+		base:      base,
+		timeout:   config.Timeout,
+		retries:   config.Retries,
+		baseDelay: defaultBaseDelay,
+		limiters:  map[string]*limiter{},
+		log:       config.Log,
+	}
+	for host, perMinute := range config.RateLimits {
+		if perMinute > 0 {
+			t.limiters[strings.ToLower(host)] = newLimiter(perMinute)
+		}
+	}
+	return t
+}
+
+// ParseProxy validates a proxy URL.
+func ParseProxy(raw string) (*url.URL, error) {
+	proxyURL, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", raw, err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (use http, https, socks5 or socks5h)", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: missing host", raw)
+	}
+	return proxyURL, nil
+}
+
+func (t *Transport) limiter(host string) *limiter {
+	host = strings.ToLower(host)
+	for {
+		if l, ok := t.limiters[host]; ok {
+			return l
+		}
+		idx := strings.Index(host, ".")
+		if idx < 0 {
+			return nil
+		}
+		host = host[idx+1:]
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	limiter := t.limiter(req.URL.Hostname())
+	for attempt := 0; ; attempt++ {
+		if limiter != nil {
+			if err := limiter.wait(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+
+		attemptReq, err := attemptRequest(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithCancel(req.Context())
+		var timer *time.Timer
+		if t.timeout > 0 {
+			timer = time.AfterFunc(t.timeout, cancel)
+		}
+		resp, err := t.base.RoundTrip(attemptReq.WithContext(ctx))
+		if err != nil && ctx.Err() != nil && req.Context().Err() == nil {
+			err = fmt.Errorf("no response from %s within %s: %w", req.URL.Host, t.timeout, err)
+		}
+
+		delay, retry := t.retryDelay(req, resp, err, attempt)
+		if !retry {
+			if err != nil {
+				stopTimer(timer)
+				cancel()
+				return nil, err
+			}
+			resp.Body = &idleTimeoutBody{ReadCloser: resp.Body, timer: timer, timeout: t.timeout, cancel: cancel}
+			return resp, nil
+		}
+
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusTooManyRequests && limiter != nil {
+				limiter.pause(delay)
+			}
+		}
+		stopTimer(timer)
+		cancel()
+		if t.log != nil {
+			t.log(fmt.Sprintf("HTTP %s %s failed (%s), retry %d/%d in %s", req.Method, req.URL.Host, reason, attempt+1, t.retries, delay.Round(time.Millisecond)))
+		}
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// attemptRequest returns the request to send for attempt, with a fresh body
+// on retries.
+func attemptRequest(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	clone := req.Clone(req.Context())
+	clone.Body = body
+	return clone, nil
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// retryDelay decides whether an attempt is retried and after how long.
+// Rate limiting and 503 are retried for any method since the server did not
+// act on the request; other failures only for idempotent ones.
+func (t *Transport) retryDelay(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= t.retries || req.Context().Err() != nil {
+		return 0, false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return 0, false
+	}
+	if err != nil {
+		return t.backoff(attempt), isIdempotent(req.Method)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		if !isIdempotent(req.Method) {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	if wait, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		return wait, wait <= maxRetryAfter
+	}
+	return t.backoff(attempt), true
+}
+
+// backoff is a full-jitter exponential delay between half and all of
+// baseDelay*2^attempt.
+func (t *Transport) backoff(attempt int) time.Duration {
+	delay := t.baseDelay << uint(attempt)
+	if delay > maxBackoff || delay <= 0 {
+		delay = maxBackoff
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryAfter parses a Retry-After header given in seconds or as a date.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if wait := when.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func stopTimer(timer *time.Timer) {
+	if timer != nil {
+		timer.Stop()
+	}
+}
+
+// idleTimeoutBody restarts the attempt timeout on every read and releases
+// the attempt's context when closed.
+type idleTimeoutBody struct {
+	io.ReadCloser
+	timer   *time.Timer
+	timeout time.Duration
+	cancel  context.CancelFunc
+	once    sync.Once
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	if b.timer != nil {
+		b.timer.Reset(b.timeout)
+	}
+	n, err := b.ReadCloser.Read(p)
+	if b.timer != nil {
+		b.timer.Reset(b.timeout)
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		stopTimer(b.timer)
+		b.cancel()
+	})
+	return err
+}
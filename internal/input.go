@@ -22,7 +22,7 @@ func promptText(config *CurdConfig, prompt string, allowEmpty bool) (string, err
 	var input string
 	var err error
 	if config != nil && config.RofiSelection {
-		input, err = GetUserInputFromRofi(prompt)
+		input, err = GetUserInput(prompt)
 	} else {
 		CurdOut(prompt)
 		input, err = readTrimmedStdinLine()
@@ -59,14 +59,6 @@ func parsePositiveIntInput(input, label string) (int, error) {
 	return value, nil
 }
 
-func promptPositiveEpisodeNumber(config *CurdConfig, prompt string) (int, error) {
-	input, err := promptText(config, prompt, false)
-	if err != nil {
-		return 0, err
-	}
-	return parsePositiveIntInput(input, "episode number")
-}
-
 func isAffirmativeAnswer(answer string) bool {
 	switch strings.ToLower(strings.TrimSpace(answer)) {
 	case "y", "yes":
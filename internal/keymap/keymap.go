@@ -0,0 +1,146 @@
+// Package keymap holds the key bindings of curd's selection menu. Keys are
+// named as Bubble Tea names them, such as "j", "G", "pgdown" or "ctrl+n".
+//
+// The menu starts out filtering: printable keys type into the filter and
+// only the other bindings act. Navigate leaves the filter so that single
+// letter bindings such as j/k and the actions on the highlighted item work;
+// Filter goes back to typing.
+package keymap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Action is what a key does in the menu.
+type Action string
+
+const (
+	Up       Action = "up"
+	Down     Action = "down"
+	PageUp   Action = "pageup"
+	PageDown Action = "pagedown"
+	Top      Action = "top"
+	Bottom   Action = "bottom"
+	Navigate Action = "navigate"
+	Filter   Action = "filter"
+
+	// Actions on the highlighted item, for menus that offer them.
+	Download Action = "download"
+	Progress Action = "progress"
+	Provider Action = "provider"
+	Info     Action = "info"
+	Plan     Action = "plan"
+)
+
+var defaults = map[Action][]string{
+	Up:       {"up", "shift+tab", "ctrl+p", "k"},
+	Down:     {"down", "tab", "ctrl+n", "j"},
+	PageUp:   {"pgup", "ctrl+u"},
+	PageDown: {"pgdown", "ctrl+d"},
+	Top:      {"home", "g"},
+	Bottom:   {"end", "G"},
+	Navigate: {"esc"},
+	Filter:   {"/"},
+	Download: {"d"},
+	Progress: {"u"},
+	Provider: {"p"},
+	Info:     {"i"},
+	Plan:     {"a"},
+}
+
+// Map binds keys to actions.
+type Map struct {
+	actions map[string]Action
+}
+
+// Default returns the built-in bindings.
+func Default() Map {
+	m, _ := Parse(nil)
+	return m
+}
+
+// Parse returns the built-in bindings changed by entries of the form
+// "action=key key ...". The keys listed for an action replace its defaults,
+// and take over from any other action they were bound to.
+func Parse(entries []string) (Map, error) {
+	bindings := make(map[Action][]string, len(defaults))
+	for action, keys := range defaults {
+		bindings[action] = keys
+	}
+	overridden := map[Action]bool{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		action := Action(strings.ToLower(strings.TrimSpace(name)))
+		keys := strings.Fields(value)
+		if _, known := defaults[action]; !ok || !known || len(keys) == 0 {
+			return Map{}, fmt.Errorf("invalid key binding %q (want action=key, with action one of %s)", entry, strings.Join(actionNames(), ", "))
+		}
+		bindings[action] = keys
+		overridden[action] = true
+	}
+
+	m := Map{actions: map[string]Action{}}
+	// Defaults first, so that the user's bindings win any clash.
+	for _, pass := range []bool{false, true} {
+		for action, keys := range bindings {
+			if overridden[action] != pass {
+				continue
+			}
+			for _, key := range keys {
+				m.actions[key] = action
+			}
+		}
+	}
+	return m, nil
+}
+
+// Action returns what key does. While filtering, printable keys type into
+// the filter and do nothing else.
+func (m Map) Action(key string, filtering bool) (Action, bool) {
+	if filtering && Printable(key) {
+		return "", false
+	}
+	action, ok := m.actions[key]
+	return action, ok
+}
+
+// Keys returns the keys bound to action, sorted.
+func (m Map) Keys(action Action) []string {
+	var keys []string
+	for key, a := range m.actions {
+		if a == action {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Printable reports whether key types a character rather than being a
+// named or modified key.
+func Printable(key string) bool {
+	return utf8.RuneCountInString(key) == 1 && key >= " "
+}
+
+// Backspace removes the last character typed into filter. Printable keys
+// may be any rune, so it never cuts one in half.
+func Backspace(filter string) string {
+	_, size := utf8.DecodeLastRuneInString(filter)
+	return filter[:len(filter)-size]
+}
+
+func actionNames() []string {
+	names := make([]string, 0, len(defaults))
+	for action := range defaults {
+		names = append(names, string(action))
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,72 @@
+package keymap
+
+import (
+	"reflect"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestDefaults(t *testing.T) {
+	m := Default()
+	cases := []struct {
+		key       string
+		filtering bool
+		action    Action
+		ok        bool
+	}{
+		{"j", false, Down, true},
+		{"j", true, "", false},
+		{"down", true, Down, true},
+		{"G", false, Bottom, true},
+		{"ctrl+d", true, PageDown, true},
+		{"esc", true, Navigate, true},
+		{"d", false, Download, true},
+		{"a", false, Plan, true},
+		{"a", true, "", false},
+		{"x", false, "", false},
+	}
+	for _, c := range cases {
+		action, ok := m.Action(c.key, c.filtering)
+		if action != c.action || ok != c.ok {
+			t.Errorf("Action(%q, %v) = %q, %v; want %q, %v", c.key, c.filtering, action, ok, c.action, c.ok)
+		}
+	}
+}
+
+func TestParseOverrides(t *testing.T) {
+	m, err := Parse([]string{"download=D", " top = d home "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action, _ := m.Action("d", false); action != Top {
+		t.Fatalf("d should have moved to top, got %q", action)
+	}
+	if got := m.Keys(Download); !reflect.DeepEqual(got, []string{"D"}) {
+		t.Fatalf("download keys = %v", got)
+	}
+	if _, ok := m.Action("g", false); ok {
+		t.Fatal("g should no longer be bound once top is overridden")
+	}
+
+	for _, bad := range []string{"jump=j", "down", "down="} {
+		if _, err := Parse([]string{bad}); err == nil {
+			t.Errorf("Parse(%q) should fail", bad)
+		}
+	}
+}
+
+func TestBackspaceRemovesWholeRunes(t *testing.T) {
+	cases := map[string]string{
+		"naruto":    "narut",
+		"pokémon é": "pokémon ",
+		"進撃":        "進",
+		"x":         "",
+		"":          "",
+	}
+	for filter, want := range cases {
+		got := Backspace(filter)
+		if got != want || !utf8.ValidString(got) {
+			t.Errorf("Backspace(%q) = %q; want %q", filter, got, want)
+		}
+	}
+}
@@ -1,8 +1,14 @@
 package internal
 
-import "strings"
+import (
+	"sync"
 
-// LinkPriorities defines the order of priority for link domains
+	"github.com/wraient/curd/internal/providers"
+	"github.com/wraient/curd/internal/providers/streamrank"
+)
+
+// LinkPriorities defines the order of priority for link domains. They rank
+// after the user's PreferredServers.
 var LinkPriorities = []string{
 	"video.wixstatic.com",
 	"sharepoint.com",
@@ -13,52 +19,89 @@ var LinkPriorities = []string{
 	// Add more domains in order of priority
 }
 
-// PrioritizeLink takes an array of links and returns a single link based on priority
-func PrioritizeLink(links []string) string {
-	if len(links) == 0 {
-		return ""
-	}
+type knownStream struct {
+	stream   providers.Stream
+	provider string
+}
 
-	bestLink := links[0]
-	bestScore := linkSelectionScore(bestLink)
-	for _, link := range links[1:] {
-		score := linkSelectionScore(link)
-		if score > bestScore {
-			bestScore = score
-			bestLink = link
+// knownStreams remembers provider metadata for resolved URLs so that
+// PrioritizeLink can rank bare links the same way the provider lookup did.
+var (
+	knownStreamsMu sync.Mutex
+	knownStreams   = map[string]knownStream{}
+)
+
+const maxKnownStreams = 256
+
+func rememberStreams(providerName string, streams []providers.Stream) {
+	knownStreamsMu.Lock()
+	defer knownStreamsMu.Unlock()
+	if len(knownStreams)+len(streams) > maxKnownStreams {
+		knownStreams = map[string]knownStream{}
+	}
+	for _, stream := range streams {
+		if stream.URL != "" {
+			knownStreams[stream.URL] = knownStream{stream: stream, provider: providerName}
 		}
 	}
-	return bestLink
 }
 
-func linkSelectionScore(link string) int {
-	score := 0
-	for i, domain := range LinkPriorities {
-		if strings.Contains(link, domain) {
-			score += (len(LinkPriorities) - i) * 1000
-			break
-		}
+// streamForLink returns the remembered metadata and provider for link, or a
+// guess from the URL itself.
+func streamForLink(link string) (providers.Stream, string) {
+	knownStreamsMu.Lock()
+	known, ok := knownStreams[link]
+	knownStreamsMu.Unlock()
+	if ok {
+		return known.stream, known.provider
 	}
-	score += wixmpQualityScore(link)
-	return score
+	return providers.GuessStream(link), ""
 }
 
-func wixmpQualityScore(link string) int {
-	for _, quality := range []string{"1080p", "720p", "480p", "360p", "240p"} {
-		if strings.Contains(link, quality) {
-			switch quality {
-			case "1080p":
-				return 1080
-			case "720p":
-				return 720
-			case "480p":
-				return 480
-			case "360p":
-				return 360
-			case "240p":
-				return 240
-			}
+// streamPreferences builds the ranking preferences from the config. The
+// server last picked for providerName ranks ahead of everything else.
+func streamPreferences(config *CurdConfig, providerName string) streamrank.Preferences {
+	var servers []string
+	if remembered := RememberedStreamServer(providerName); remembered != "" {
+		servers = append(servers, remembered)
+	}
+	prefs := streamrank.Preferences{}
+	if config != nil {
+		prefs.PreferredQuality = config.PreferredQuality
+		prefs.MaxResolution = config.MaxResolution
+		servers = append(servers, parseStringArray(config.PreferredServers)...)
+	}
+	prefs.PreferredServers = append(servers, LinkPriorities...)
+	return prefs
+}
+
+// RankStreams orders streams from providerName best first according to the
+// config.
+func RankStreams(config *CurdConfig, providerName string, streams []providers.Stream) []providers.Stream {
+	return streamrank.Rank(streams, streamPreferences(config, providerName))
+}
+
+// linkStreams returns the metadata for links and the provider they came from.
+func linkStreams(links []string) ([]providers.Stream, string) {
+	streams := make([]providers.Stream, 0, len(links))
+	providerName := ""
+	for _, link := range links {
+		stream, provider := streamForLink(link)
+		if providerName == "" {
+			providerName = provider
 		}
+		streams = append(streams, stream)
 	}
-	return 0
+	return streams, providerName
+}
+
+// PrioritizeLink takes an array of links and returns a single link based on
+// the configured quality and server preferences
+func PrioritizeLink(links []string) string {
+	if len(links) == 0 {
+		return ""
+	}
+
+	streams, providerName := linkStreams(links)
+	return RankStreams(GetGlobalConfig(), providerName, streams)[0].URL
 }
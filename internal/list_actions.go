@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wraient/curd/internal/dashboard"
+	"github.com/wraient/curd/internal/keymap"
+)
+
+// animeListActions are the single-key actions the anime list offers on the
+// highlighted show.
+var animeListActions = []keymap.Action{keymap.Download, keymap.Progress, keymap.Provider, keymap.Info}
+
+// reopenCategory is the list category the main menu opens straight away
+// after an action on one of its shows, so the user lands back in the list.
+var reopenCategory SelectionOption
+
+// titleAliases lists a show's titles in every language, so the filter finds
+// it by whichever one the user types.
+func titleAliases(title AnimeTitle) []string {
+	return []string{title.Romaji, title.English, title.Japanese}
+}
+
+// runAnimeListAction performs action on the list entry with the given key.
+func runAnimeListAction(config *CurdConfig, user *User, databaseAnimes *[]Anime, databaseFile string, action keymap.Action, key string) {
+	entry, err := FindAnimeByAnilistID(user.AnimeList, key)
+	if err != nil {
+		Log(fmt.Sprintf("List action %s: %v", action, err))
+		CurdOut("Error: Could not find selected anime")
+		return
+	}
+
+	ClearScreen()
+	switch action {
+	case keymap.Download:
+		downloadAnime(config, entry, databaseAnimes)
+	case keymap.Progress:
+		changeAnimeProgress(config, user, entry)
+		CurdOut("Anime updated successfully!")
+	case keymap.Provider:
+		changeAnimeProvider(config, entry, databaseAnimes, databaseFile)
+	case keymap.Info:
+		showAnimeInfo(config, entry)
+	}
+}
+
+// listEntryAnime returns the show of a list entry with its AniList and MAL
+// ids set, whichever service the list came from.
+func listEntryAnime(config *CurdConfig, entry *Entry) *Anime {
+	anime := &Anime{
+		Title:         entry.Media.Title,
+		TotalEpisodes: entry.Media.Episodes,
+		CoverImage:    entry.CoverImage,
+		AnilistId:     entry.Media.ID,
+	}
+	if GetTrackingService(config) == "mal" {
+		anime.MalId = entry.Media.ID
+		if anilistID, err := ConvertMALIDToAnilist(entry.Media.ID, ""); err == nil {
+			anime.AnilistId = anilistID
+		} else {
+			Log(fmt.Sprintf("Failed to convert MAL ID %d to AniList ID: %v", entry.Media.ID, err))
+		}
+	}
+	return anime
+}
+
+// changeAnimeProvider drops the provider listing a show is mapped to and
+// lets the user pick another one from a fresh search.
+func changeAnimeProvider(config *CurdConfig, entry *Entry, databaseAnimes *[]Anime, databaseFile string) {
+	anime := listEntryAnime(config, entry)
+	anime.Ep.Number = entry.Progress + 1
+	if history := LocalFindAnime(*databaseAnimes, anime.AnilistId, ""); history != nil {
+		anime.ProviderName, anime.ProviderId = history.ProviderName, history.ProviderId
+		anime.Ep.Number = history.Ep.Number
+		anime.Ep.Player.PlaybackTime = history.Ep.Player.PlaybackTime
+		anime.Ep.Duration = history.Ep.Duration
+	}
+	if anime.ProviderId != "" {
+		providerName, providerID := providerIDForAnime(anime)
+		CurdOut(fmt.Sprintf("Currently mapped to %s on %s", providerID, providerName))
+		forgetProviderMapping(anime, providerName, providerID, config.SubOrDub)
+	}
+	anime.ProviderName, anime.ProviderId = "", ""
+
+	query := anime.Title.Romaji
+	if query == "" {
+		query = anime.Title.English
+	}
+	outcome, err := searchAnimeProviderMapping(config, anime, query, entry, true)
+	if err != nil {
+		CurdOut(fmt.Sprintf("Error mapping anime: %v", err))
+		return
+	}
+	if outcome != ProviderMappingOK {
+		return
+	}
+
+	err = LocalUpdateAnime(databaseFile, anime.AnilistId, anime.ProviderId, anime.Ep.Number, anime.Ep.Player.PlaybackTime, anime.Ep.Duration, GetAnimeName(*anime), anime.ProviderName)
+	if err != nil {
+		Log(fmt.Sprintf("Failed to save the new provider mapping: %v", err))
+		CurdOut(fmt.Sprintf("Failed to save the new provider: %v", err))
+		return
+	}
+	*databaseAnimes = LocalGetAllAnime(databaseFile)
+	CurdOut(fmt.Sprintf("%s now plays from %s", GetAnimeName(*anime), anime.ProviderName))
+}
+
+// showAnimeInfo prints what is known about a list entry and waits for the
+// user to go back.
+func showAnimeInfo(config *CurdConfig, entry *Entry) {
+	var lines []string
+	for _, title := range titleAliases(entry.Media.Title) {
+		if title != "" && !containsString(lines, title) {
+			lines = append(lines, title)
+		}
+	}
+
+	total := "?"
+	if entry.Media.Episodes > 0 {
+		total = strconv.Itoa(entry.Media.Episodes)
+	}
+	lines = append(lines, fmt.Sprintf("Progress: %d/%s", entry.Progress, total))
+	if entry.Status != "" {
+		lines = append(lines, "Status: "+entry.Status)
+	}
+	if entry.Score > 0 {
+		lines = append(lines, fmt.Sprintf("Score: %v", entry.Score))
+	}
+
+	overview, err := GetAnimeOverview(entry.Media.ID, GetTrackingService(config) == "mal")
+	if err != nil {
+		Log(fmt.Sprintf("Failed to get details of %d: %v", entry.Media.ID, err))
+		lines = append(lines, "", "Could not load more details.")
+	} else {
+		if overview.Format != "" {
+			lines = append(lines, "Format: "+overview.Format)
+		}
+		if len(overview.Genres) > 0 {
+			lines = append(lines, "Genres: "+strings.Join(overview.Genres, ", "))
+		}
+		if overview.NextEpisode > 0 {
+			lines = append(lines, fmt.Sprintf("Episode %d airs in %s", overview.NextEpisode, dashboard.Until(overview.NextAiring, time.Now())))
+		}
+		if synopsis := cleanSynopsis(overview.Description); synopsis != "" {
+			lines = append(lines, "", synopsis)
+		}
+	}
+
+	CurdOut(strings.Join(lines, "\n") + "\n")
+	if selected, _ := DynamicSelect([]SelectionOption{{Key: "back", Label: "<- Back"}}); selected.Key == "-1" {
+		ExitCurd(nil)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -9,6 +9,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/wraient/curd/internal/hooks"
 )
 
 // Function to add an anime entry
@@ -322,10 +324,10 @@ func WatchUntracked(userCurdConfig *CurdConfig) {
 	for {
 		// Get anime name from user
 		if userCurdConfig.RofiSelection {
-			userInput, err := GetUserInputFromRofi("Enter the anime name")
+			userInput, err := GetUserInput("Enter the anime name")
 			if err != nil {
 				Log("Error getting user input: " + err.Error())
-				ExitCurd(fmt.Errorf("Error getting user input: " + err.Error()))
+				ExitCurd(fmt.Errorf("Error getting user input: %w", err))
 			}
 			query = userInput
 		} else {
@@ -380,10 +382,10 @@ func WatchUntracked(userCurdConfig *CurdConfig) {
 	// Get episode number
 	var episodeNumber int
 	if userCurdConfig.RofiSelection {
-		userInput, err := GetUserInputFromRofi("Enter the episode number")
+		userInput, err := GetUserInput("Enter the episode number")
 		if err != nil {
 			Log("Error getting episode number: " + err.Error())
-			ExitCurd(fmt.Errorf("Error getting episode number: " + err.Error()))
+			ExitCurd(fmt.Errorf("Error getting episode number: %w", err))
 		}
 		episodeNumber, err = strconv.Atoi(userInput)
 		if err != nil {
@@ -467,6 +469,7 @@ func WatchUntracked(userCurdConfig *CurdConfig) {
 					Log(fmt.Sprint(anime.Ep.Duration))
 					Log(fmt.Sprint(userCurdConfig.PercentageToMarkComplete))
 					if int(percentageWatched) >= userCurdConfig.PercentageToMarkComplete {
+						FireAnimeHook(hooks.EpisodeComplete, &anime)
 						anime.Ep.Number++
 						anime.Ep.Started = false
 						Log("Completed episode, starting next.")
@@ -486,6 +489,7 @@ func WatchUntracked(userCurdConfig *CurdConfig) {
 			if timePos != nil {
 				if !anime.Ep.Started {
 					anime.Ep.Started = true
+					FireAnimeHook(hooks.EpisodeStart, &anime)
 				}
 
 				animePosition, ok := timePos.(float64)
@@ -0,0 +1,343 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/wraient/curd/internal/mappingdb"
+)
+
+const providerMappingsFile = "provider_mappings.json"
+
+var (
+	mappingStoreMu   sync.Mutex
+	mappingStore     *mappingdb.Store
+	mappingStorePath string
+)
+
+func providerMappingsPath(config *CurdConfig) string {
+	storagePath := ""
+	if config != nil {
+		storagePath = config.StoragePath
+	}
+	if storagePath == "" {
+		storagePath = os.ExpandEnv("${HOME}/.local/share/curd")
+	}
+	return filepath.Join(os.ExpandEnv(storagePath), providerMappingsFile)
+}
+
+// providerMappingStore returns the mapping store for the current storage
+// path, or nil when it cannot be read.
+func providerMappingStore() *mappingdb.Store {
+	path := providerMappingsPath(GetGlobalConfig())
+	mappingStoreMu.Lock()
+	defer mappingStoreMu.Unlock()
+	if mappingStore != nil && mappingStorePath == path {
+		return mappingStore
+	}
+	store, err := mappingdb.Open(path)
+	if err != nil {
+		Log(fmt.Sprintf("Provider mappings unavailable: %v", err))
+		return nil
+	}
+	mappingStore, mappingStorePath = store, path
+	return store
+}
+
+// storedProviderID returns anime's recorded id on providerName for mode.
+// Unverified guesses are ignored. confident is false for guesses.
+func storedProviderID(anime *Anime, providerName, mode string) (id string, confident bool) {
+	if anime == nil || anime.AnilistId <= 0 {
+		return "", false
+	}
+	store := providerMappingStore()
+	if store == nil {
+		return "", false
+	}
+	mapping, ok := store.Lookup(anime.AnilistId, providerName, normalizeTranslationType(mode))
+	if !ok {
+		return "", false
+	}
+	confident = mapping.Confidence.AtLeast(mappingdb.Match)
+	if !confident && mapping.VerifiedAt.IsZero() {
+		return "", false
+	}
+	return mapping.ID, confident
+}
+
+// recordProviderMapping remembers providerID as anime's id on providerName.
+func recordProviderMapping(anime *Anime, providerName, providerID, mode string, confidence mappingdb.Confidence, verified bool) {
+	if anime == nil || anime.AnilistId <= 0 || providerName == "" || providerID == "" {
+		return
+	}
+	store := providerMappingStore()
+	if store == nil {
+		return
+	}
+	title := strings.TrimSpace(GetAnimeName(*anime))
+	if err := store.Record(anime.AnilistId, title, normalizeProviderName(providerName), normalizeTranslationType(mode), providerID, confidence, verified); err != nil {
+		Log(fmt.Sprintf("Failed to record provider mapping: %v", err))
+	}
+}
+
+// forgetProviderMapping drops anime's recorded id on providerName for mode if
+// it is still providerID, so a broken mapping is not reused.
+func forgetProviderMapping(anime *Anime, providerName, providerID, mode string) {
+	if anime == nil || anime.AnilistId <= 0 || providerName == "" {
+		return
+	}
+	store := providerMappingStore()
+	if store == nil {
+		return
+	}
+	mode = normalizeTranslationType(mode)
+	if mapping, ok := store.Lookup(anime.AnilistId, providerName, mode); !ok || mapping.ID != providerID {
+		return
+	}
+	if _, err := store.Remove(anime.AnilistId, providerName, mode); err != nil {
+		Log(fmt.Sprintf("Failed to forget provider mapping: %v", err))
+	}
+}
+
+// applyStoredProviderMapping maps anime to the first configured provider with
+// a recorded id for the configured mode. With manualOnly, only mappings the
+// user made count.
+func applyStoredProviderMapping(config *CurdConfig, anime *Anime, manualOnly bool) bool {
+	if anime == nil || anime.AnilistId <= 0 {
+		return false
+	}
+	store := providerMappingStore()
+	if store == nil {
+		return false
+	}
+	mode := normalizeTranslationType(config.SubOrDub)
+	for _, providerName := range configuredProviderNames(config) {
+		mapping, ok := store.Lookup(anime.AnilistId, providerName, mode)
+		if !ok {
+			continue
+		}
+		if manualOnly && mapping.Confidence != mappingdb.Manual {
+			continue
+		}
+		if !mapping.Confidence.AtLeast(mappingdb.Match) && mapping.VerifiedAt.IsZero() {
+			continue
+		}
+		anime.ProviderName = providerName
+		anime.ProviderId = mapping.ID
+		Log(fmt.Sprintf("Using stored %s mapping for AniList id %d: %s %s (%s)", mode, anime.AnilistId, providerName, mapping.ID, mapping.Confidence))
+		return true
+	}
+	return false
+}
+
+const mappingCommandUsage = `Usage: curd mapping <command>
+
+  list [query]                               List stored mappings, optionally filtered by title or AniList id
+  show <anilist-id>                          Show every mapping of one AniList entry
+  set <anilist-id> <provider> <sub|dub> <id> Map an AniList entry to a provider show id
+  remove <anilist-id> [provider [sub|dub]]   Remove mappings
+  edit                                       Open the mapping file in $EDITOR
+  export [file]                              Write all mappings as JSON (default: stdout)
+  import [-replace] <file>                   Merge mappings from a JSON file, or replace them with -replace`
+
+// RunMappingCommand runs "curd mapping" with args.
+func RunMappingCommand(config *CurdConfig, args []string) error {
+	return runMappingCommand(config, args, os.Stdout)
+}
+
+func runMappingCommand(config *CurdConfig, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	path := providerMappingsPath(config)
+	store, err := mappingdb.Open(path)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		query := strings.ToLower(strings.Join(args[1:], " "))
+		var rows []mappingdb.Row
+		for _, row := range store.Rows() {
+			if query == "" || strings.Contains(strings.ToLower(row.Title), query) || strconv.Itoa(row.AnilistID) == query {
+				rows = append(rows, row)
+			}
+		}
+		if len(rows) == 0 {
+			fmt.Fprintf(out, "No provider mappings stored in %s\n", path)
+			return nil
+		}
+		return writeMappingRows(out, rows)
+
+	case "show":
+		if len(args) != 2 {
+			return fmt.Errorf("%s", mappingCommandUsage)
+		}
+		anilistID, err := parseAnilistIDArg(args[1])
+		if err != nil {
+			return err
+		}
+		var rows []mappingdb.Row
+		for _, row := range store.Rows() {
+			if row.AnilistID == anilistID {
+				rows = append(rows, row)
+			}
+		}
+		if len(rows) == 0 {
+			fmt.Fprintf(out, "No provider mappings for AniList id %d\n", anilistID)
+			return nil
+		}
+		return writeMappingRows(out, rows)
+
+	case "set":
+		if len(args) != 5 {
+			return fmt.Errorf("%s", mappingCommandUsage)
+		}
+		anilistID, err := parseAnilistIDArg(args[1])
+		if err != nil {
+			return err
+		}
+		providerName := normalizeProviderName(args[2])
+		if providerName == "" {
+			return fmt.Errorf("unknown provider %q", args[2])
+		}
+		mode, err := parseMappingMode(args[3])
+		if err != nil {
+			return err
+		}
+		mapping := mappingdb.Mapping{ID: args[4], Confidence: mappingdb.Manual}
+		if err := store.Set(anilistID, providerName, mode, mapping); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Mapped AniList id %d to %s %s %s\n", anilistID, providerName, mode, args[4])
+		return nil
+
+	case "remove", "rm":
+		if len(args) < 2 || len(args) > 4 {
+			return fmt.Errorf("%s", mappingCommandUsage)
+		}
+		anilistID, err := parseAnilistIDArg(args[1])
+		if err != nil {
+			return err
+		}
+		providerName, mode := "", ""
+		if len(args) > 2 {
+			// An unknown name must not fall through to removing every
+			// provider's mapping.
+			if providerName = normalizeProviderName(args[2]); providerName == "" {
+				return fmt.Errorf("unknown provider %q", args[2])
+			}
+		}
+		if len(args) > 3 {
+			if mode, err = parseMappingMode(args[3]); err != nil {
+				return err
+			}
+		}
+		removed, err := store.Remove(anilistID, providerName, mode)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			fmt.Fprintln(out, "Nothing to remove")
+			return nil
+		}
+		fmt.Fprintln(out, "Removed")
+		return nil
+
+	case "edit":
+		if err := store.Save(); err != nil {
+			return err
+		}
+		EditConfig(path)
+		if _, err := mappingdb.Open(path); err != nil {
+			return fmt.Errorf("the edited mapping file is invalid and will not be used until fixed: %w", err)
+		}
+		return nil
+
+	case "export":
+		if len(args) > 2 {
+			return fmt.Errorf("%s", mappingCommandUsage)
+		}
+		if len(args) == 1 || args[1] == "-" {
+			return store.Export(out)
+		}
+		file, err := os.Create(args[1])
+		if err != nil {
+			return err
+		}
+		if err := store.Export(file); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Exported %d mappings to %s\n", len(store.Rows()), args[1])
+		return nil
+
+	case "import":
+		replace := false
+		rest := args[1:]
+		if len(rest) > 0 && (rest[0] == "-replace" || rest[0] == "--replace") {
+			replace = true
+			rest = rest[1:]
+		}
+		if len(rest) != 1 {
+			return fmt.Errorf("%s", mappingCommandUsage)
+		}
+		file, err := os.Open(rest[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		count, err := store.Import(file, replace)
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %w", rest[0], err)
+		}
+		fmt.Fprintf(out, "Imported %d mappings\n", count)
+		return nil
+
+	case "help", "-h", "--help":
+		fmt.Fprintln(out, mappingCommandUsage)
+		return nil
+	}
+	return fmt.Errorf("unknown mapping command %q\n%s", args[0], mappingCommandUsage)
+}
+
+func writeMappingRows(out io.Writer, rows []mappingdb.Row) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ANILIST\tTITLE\tPROVIDER\tMODE\tID\tCONFIDENCE\tVERIFIED")
+	for _, row := range rows {
+		verified := "never"
+		if !row.VerifiedAt.IsZero() {
+			verified = row.VerifiedAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", row.AnilistID, row.Title, row.Provider, row.Mode, row.ID, row.Confidence, verified)
+	}
+	return w.Flush()
+}
+
+func parseAnilistIDArg(value string) (int, error) {
+	id, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid AniList id %q", value)
+	}
+	return id, nil
+}
+
+func parseMappingMode(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "sub":
+		return "sub", nil
+	case "dub":
+		return "dub", nil
+	}
+	return "", fmt.Errorf("invalid mode %q (want sub or dub)", value)
+}
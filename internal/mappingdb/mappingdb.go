@@ -0,0 +1,430 @@
+// Package mappingdb stores which provider show each AniList entry maps to.
+//
+// Mappings are kept per provider and per audio mode, because several
+// providers list the sub and dub of a show under different ids. Each mapping
+// records how it was found and when it last produced playable links, so a
+// later fuzzy match never overwrites one the user picked by hand.
+//
+// The store is a single JSON file that users can export, edit and import:
+//
+//	{
+//	  "21": {
+//	    "title": "One Piece",
+//	    "providers": {
+//	      "mkissa": {
+//	        "sub": {"id": "ReooPAxPMsHM4KPMY", "confidence": "manual", "verified_at": "2026-10-01T20:00:00Z"}
+//	      }
+//	    }
+//	  }
+//	}
+package mappingdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Confidence says how a mapping was found. Higher levels win over lower ones.
+type Confidence string
+
+const (
+	// Guess is the best fuzzy search result when nothing clearly matched.
+	Guess Confidence = "guess"
+	// Match is a search result identified by thumbnail, metadata or a
+	// clearly winning title.
+	Match Confidence = "match"
+	// Manual is a mapping the user picked or entered.
+	Manual Confidence = "manual"
+)
+
+func (c Confidence) rank() int {
+	switch c {
+	case Manual:
+		return 3
+	case Match:
+		return 2
+	case Guess:
+		return 1
+	}
+	return 0
+}
+
+// AtLeast reports whether c is as strong as other.
+func (c Confidence) AtLeast(other Confidence) bool {
+	return c.rank() >= other.rank()
+}
+
+// ParseConfidence accepts the names used in the JSON file.
+func ParseConfidence(value string) (Confidence, error) {
+	c := Confidence(strings.ToLower(strings.TrimSpace(value)))
+	if c.rank() == 0 {
+		return "", fmt.Errorf("unknown confidence %q (want guess, match or manual)", value)
+	}
+	return c, nil
+}
+
+// Mapping is one provider show id.
+type Mapping struct {
+	ID         string     `json:"id"`
+	Confidence Confidence `json:"confidence"`
+	// VerifiedAt is when the id last resolved playable links; zero if never.
+	VerifiedAt time.Time `json:"verified_at,omitzero"`
+}
+
+// Entry holds every known mapping of one AniList entry, by provider and then
+// by audio mode ("sub" or "dub").
+type Entry struct {
+	Title     string                        `json:"title,omitempty"`
+	Providers map[string]map[string]Mapping `json:"providers"`
+}
+
+// Row is one mapping flattened for listing.
+type Row struct {
+	AnilistID int
+	Title     string
+	Provider  string
+	Mode      string
+	Mapping
+}
+
+// Store is a mapping file loaded into memory. It is safe for concurrent use.
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[int]*Entry
+}
+
+// Open loads the store at path. A missing file is an empty store.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, entries: map[int]*Entry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provider mappings: %w", err)
+	}
+	entries, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse provider mappings %s: %w", path, err)
+	}
+	s.entries = entries
+	return s, nil
+}
+
+// Path returns the file the store is saved to.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Lookup returns the mapping of anilistID on provider for mode.
+func (s *Store) Lookup(anilistID int, provider, mode string) (Mapping, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[anilistID]
+	if !ok {
+		return Mapping{}, false
+	}
+	mapping, ok := entry.Providers[provider][mode]
+	return mapping, ok && mapping.ID != ""
+}
+
+// Entry returns a copy of everything known about anilistID.
+func (s *Store) Entry(anilistID int) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[anilistID]
+	if !ok {
+		return Entry{}, false
+	}
+	return copyEntry(entry), true
+}
+
+// Rows lists every mapping ordered by AniList id, provider and mode.
+func (s *Store) Rows() []Row {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rows []Row
+	for id, entry := range s.entries {
+		for provider, modes := range entry.Providers {
+			for mode, mapping := range modes {
+				rows = append(rows, Row{AnilistID: id, Title: entry.Title, Provider: provider, Mode: mode, Mapping: mapping})
+			}
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].AnilistID != rows[j].AnilistID {
+			return rows[i].AnilistID < rows[j].AnilistID
+		}
+		if rows[i].Provider != rows[j].Provider {
+			return rows[i].Provider < rows[j].Provider
+		}
+		return rows[i].Mode < rows[j].Mode
+	})
+	return rows
+}
+
+// Record stores id as the mapping of anilistID on provider for mode and
+// saves the store. A different id only replaces an existing mapping of
+// lower confidence; recording the same id keeps the higher confidence.
+// verified marks the id as having just produced playable links.
+func (s *Store) Record(anilistID int, title, provider, mode, id string, confidence Confidence, verified bool) error {
+	if anilistID <= 0 || provider == "" || mode == "" || id == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entries[anilistID]
+	if entry == nil {
+		entry = &Entry{Providers: map[string]map[string]Mapping{}}
+		s.entries[anilistID] = entry
+	}
+	if title != "" {
+		entry.Title = title
+	}
+	if entry.Providers[provider] == nil {
+		entry.Providers[provider] = map[string]Mapping{}
+	}
+
+	existing, exists := entry.Providers[provider][mode]
+	next := Mapping{ID: id, Confidence: confidence}
+	switch {
+	case exists && existing.ID == id:
+		next = existing
+		if !existing.Confidence.AtLeast(confidence) {
+			next.Confidence = confidence
+		}
+	case exists && !confidence.AtLeast(existing.Confidence):
+		return nil
+	}
+	if verified {
+		next.VerifiedAt = time.Now().UTC()
+	}
+	entry.Providers[provider][mode] = next
+	return s.saveLocked()
+}
+
+// Set stores a mapping as given, replacing whatever was there, and saves.
+func (s *Store) Set(anilistID int, provider, mode string, mapping Mapping) error {
+	if anilistID <= 0 || provider == "" || mode == "" || mapping.ID == "" {
+		return fmt.Errorf("an AniList id, provider, mode and provider id are required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.entries[anilistID]
+	if entry == nil {
+		entry = &Entry{Providers: map[string]map[string]Mapping{}}
+		s.entries[anilistID] = entry
+	}
+	if entry.Providers[provider] == nil {
+		entry.Providers[provider] = map[string]Mapping{}
+	}
+	entry.Providers[provider][mode] = mapping
+	return s.saveLocked()
+}
+
+// Remove deletes mappings of anilistID and saves. An empty provider removes
+// the whole entry; an empty mode removes every mode of provider. It reports
+// whether anything was removed.
+func (s *Store) Remove(anilistID int, provider, mode string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[anilistID]
+	if !ok {
+		return false, nil
+	}
+	switch {
+	case provider == "":
+		delete(s.entries, anilistID)
+	case mode == "":
+		if _, ok := entry.Providers[provider]; !ok {
+			return false, nil
+		}
+		delete(entry.Providers, provider)
+	default:
+		if _, ok := entry.Providers[provider][mode]; !ok {
+			return false, nil
+		}
+		delete(entry.Providers[provider], mode)
+		if len(entry.Providers[provider]) == 0 {
+			delete(entry.Providers, provider)
+		}
+	}
+	if len(entry.Providers) == 0 {
+		delete(s.entries, anilistID)
+	}
+	return true, s.saveLocked()
+}
+
+// Export writes the store as indented JSON.
+func (s *Store) Export(w io.Writer) error {
+	s.mu.Lock()
+	data, err := encode(s.entries)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Import merges mappings read from r and saves. Imported mappings follow the
+// same rules as Record unless replace is set, in which case the store becomes
+// exactly what was read. It returns how many mappings were taken.
+func (s *Store) Import(r io.Reader, replace bool) (int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	imported, err := decode(data)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if replace {
+		s.entries = imported
+		count := 0
+		for _, entry := range imported {
+			for _, modes := range entry.Providers {
+				count += len(modes)
+			}
+		}
+		return count, s.saveLocked()
+	}
+
+	count := 0
+	for id, incoming := range imported {
+		entry := s.entries[id]
+		if entry == nil {
+			entry = &Entry{Providers: map[string]map[string]Mapping{}}
+			s.entries[id] = entry
+		}
+		if entry.Title == "" {
+			entry.Title = incoming.Title
+		}
+		for provider, modes := range incoming.Providers {
+			if entry.Providers[provider] == nil {
+				entry.Providers[provider] = map[string]Mapping{}
+			}
+			for mode, mapping := range modes {
+				existing, exists := entry.Providers[provider][mode]
+				if exists && existing.ID != mapping.ID && !mapping.Confidence.AtLeast(existing.Confidence) {
+					continue
+				}
+				if exists && existing.ID == mapping.ID && existing.VerifiedAt.After(mapping.VerifiedAt) {
+					mapping.VerifiedAt = existing.VerifiedAt
+				}
+				entry.Providers[provider][mode] = mapping
+				count++
+			}
+		}
+	}
+	return count, s.saveLocked()
+}
+
+// Save writes the store to its file.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveLocked()
+}
+
+func (s *Store) saveLocked() error {
+	data, err := encode(s.entries)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create mapping directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".provider-mappings-*")
+	if err != nil {
+		return fmt.Errorf("failed to save provider mappings: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save provider mappings: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save provider mappings: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save provider mappings: %w", err)
+	}
+	return nil
+}
+
+func encode(entries map[int]*Entry) ([]byte, error) {
+	keyed := make(map[string]*Entry, len(entries))
+	for id, entry := range entries {
+		keyed[strconv.Itoa(id)] = entry
+	}
+	data, err := json.MarshalIndent(keyed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func decode(data []byte) (map[int]*Entry, error) {
+	var keyed map[string]*Entry
+	if err := json.Unmarshal(data, &keyed); err != nil {
+		return nil, err
+	}
+	entries := make(map[int]*Entry, len(keyed))
+	for key, entry := range keyed {
+		id, err := strconv.Atoi(key)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid AniList id %q", key)
+		}
+		if entry == nil {
+			continue
+		}
+		for provider, modes := range entry.Providers {
+			for mode, mapping := range modes {
+				if mapping.ID == "" {
+					return nil, fmt.Errorf("AniList id %d: %s %s mapping has no id", id, provider, mode)
+				}
+				if mapping.Confidence == "" {
+					mapping.Confidence = Manual
+					modes[mode] = mapping
+				} else if _, err := ParseConfidence(string(mapping.Confidence)); err != nil {
+					return nil, fmt.Errorf("AniList id %d: %s %s: %w", id, provider, mode, err)
+				}
+			}
+		}
+		if entry.Providers == nil {
+			entry.Providers = map[string]map[string]Mapping{}
+		}
+		entries[id] = entry
+	}
+	return entries, nil
+}
+
+func copyEntry(entry *Entry) Entry {
+	out := Entry{Title: entry.Title, Providers: make(map[string]map[string]Mapping, len(entry.Providers))}
+	for provider, modes := range entry.Providers {
+		out.Providers[provider] = make(map[string]Mapping, len(modes))
+		for mode, mapping := range modes {
+			out.Providers[provider][mode] = mapping
+		}
+	}
+	return out
+}
@@ -0,0 +1,151 @@
+package mappingdb
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openTemp(t *testing.T) *Store {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "mappings.json"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return store
+}
+
+func TestRecordKeepsStrongerMapping(t *testing.T) {
+	store := openTemp(t)
+
+	if err := store.Record(21, "One Piece", "allanime", "sub", "manual-id", Manual, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Record(21, "One Piece", "allanime", "sub", "fuzzy-id", Guess, true); err != nil {
+		t.Fatal(err)
+	}
+	mapping, ok := store.Lookup(21, "allanime", "sub")
+	if !ok || mapping.ID != "manual-id" || mapping.Confidence != Manual {
+		t.Fatalf("guess replaced a manual mapping: %+v", mapping)
+	}
+	if !mapping.VerifiedAt.IsZero() {
+		t.Fatalf("rejected guess should not verify the manual mapping: %+v", mapping)
+	}
+
+	if err := store.Record(21, "", "allanime", "sub", "manual-id", Guess, true); err != nil {
+		t.Fatal(err)
+	}
+	mapping, _ = store.Lookup(21, "allanime", "sub")
+	if mapping.Confidence != Manual || mapping.VerifiedAt.IsZero() {
+		t.Fatalf("re-recording the same id should verify it and keep its confidence: %+v", mapping)
+	}
+
+	if _, ok := store.Lookup(21, "allanime", "dub"); ok {
+		t.Fatal("sub mapping should not answer for dub")
+	}
+}
+
+func TestStorePersistsAndReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "mappings.json")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Record(5114, "Fullmetal Alchemist: Brotherhood", "senshi", "dub", "5114", Match, true); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	mapping, ok := reloaded.Lookup(5114, "senshi", "dub")
+	if !ok || mapping.ID != "5114" || mapping.Confidence != Match || mapping.VerifiedAt.IsZero() {
+		t.Fatalf("unexpected reloaded mapping %+v", mapping)
+	}
+	entry, ok := reloaded.Entry(5114)
+	if !ok || entry.Title != "Fullmetal Alchemist: Brotherhood" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	store := openTemp(t)
+	_ = store.Record(1, "A", "allanime", "sub", "a-sub", Manual, false)
+	_ = store.Record(1, "A", "allanime", "dub", "a-dub", Manual, false)
+	_ = store.Record(1, "A", "senshi", "sub", "1", Manual, false)
+
+	if removed, err := store.Remove(1, "allanime", "dub"); err != nil || !removed {
+		t.Fatalf("Remove mode: %v %v", removed, err)
+	}
+	if _, ok := store.Lookup(1, "allanime", "dub"); ok {
+		t.Fatal("dub mapping should be gone")
+	}
+	if removed, _ := store.Remove(1, "anipub", ""); removed {
+		t.Fatal("removing an unknown provider should report nothing removed")
+	}
+	if removed, err := store.Remove(1, "", ""); err != nil || !removed {
+		t.Fatalf("Remove entry: %v %v", removed, err)
+	}
+	if rows := store.Rows(); len(rows) != 0 {
+		t.Fatalf("expected empty store, got %+v", rows)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	source := openTemp(t)
+	_ = source.Record(2, "B", "allanime", "sub", "b-new", Manual, true)
+	_ = source.Record(3, "C", "senshi", "sub", "3", Guess, false)
+
+	var exported bytes.Buffer
+	if err := source.Export(&exported); err != nil {
+		t.Fatal(err)
+	}
+
+	target := openTemp(t)
+	_ = target.Record(2, "B", "allanime", "sub", "b-old", Match, false)
+	_ = target.Record(3, "C", "senshi", "sub", "3-better", Match, false)
+
+	count, err := target.Import(bytes.NewReader(exported.Bytes()), false)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected one mapping taken, got %d", count)
+	}
+	if mapping, _ := target.Lookup(2, "allanime", "sub"); mapping.ID != "b-new" {
+		t.Fatalf("manual import should replace a match: %+v", mapping)
+	}
+	if mapping, _ := target.Lookup(3, "senshi", "sub"); mapping.ID != "3-better" {
+		t.Fatalf("guess import should not replace a match: %+v", mapping)
+	}
+
+	count, err = target.Import(bytes.NewReader(exported.Bytes()), true)
+	if err != nil || count != 2 {
+		t.Fatalf("replace import: %d %v", count, err)
+	}
+	if mapping, _ := target.Lookup(3, "senshi", "sub"); mapping.ID != "3" {
+		t.Fatalf("replace import should take the file as is: %+v", mapping)
+	}
+}
+
+func TestImportRejectsInvalidFiles(t *testing.T) {
+	store := openTemp(t)
+	for _, input := range []string{
+		`{"abc": {"providers": {}}}`,
+		`{"1": {"providers": {"allanime": {"sub": {"confidence": "manual"}}}}}`,
+		`{"1": {"providers": {"allanime": {"sub": {"id": "x", "confidence": "sure"}}}}}`,
+	} {
+		if _, err := store.Import(strings.NewReader(input), false); err == nil {
+			t.Fatalf("expected error importing %s", input)
+		}
+	}
+
+	if _, err := store.Import(strings.NewReader(`{"1": {"providers": {"allanime": {"sub": {"id": "x"}}}}}`), false); err != nil {
+		t.Fatalf("mapping without confidence should import as manual: %v", err)
+	}
+	if mapping, _ := store.Lookup(1, "allanime", "sub"); mapping.Confidence != Manual {
+		t.Fatalf("unexpected confidence %+v", mapping)
+	}
+}
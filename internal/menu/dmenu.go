@@ -0,0 +1,115 @@
+package menu
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// launcher is how a dmenu-compatible launcher is called.
+type launcher struct {
+	// args are always passed, such as the flag turning on dmenu mode.
+	args []string
+	// list are passed when choosing from items, input when reading text.
+	list  []string
+	input []string
+	// prompt is the flag taking the prompt.
+	prompt string
+}
+
+var launchers = map[string]launcher{
+	"dmenu":  {list: []string{"-i", "-l", "15"}, prompt: "-p"},
+	"wmenu":  {list: []string{"-i", "-l", "15"}, prompt: "-p"},
+	"bemenu": {list: []string{"-i", "-l", "15"}, prompt: "-p"},
+	"fuzzel": {args: []string{"--dmenu"}, input: []string{"--lines", "0"}, prompt: "--prompt"},
+	"tofi":   {input: []string{"--require-match=false"}, prompt: "--prompt-text"},
+	"walker": {args: []string{"--dmenu"}, prompt: "--placeholder"},
+}
+
+// Launchers returns the names of the launchers Dmenu can run.
+func Launchers() []string {
+	names := make([]string, 0, len(launchers))
+	for name := range launchers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Dmenu runs menus with dmenu or a launcher in its dmenu mode. These show
+// neither images nor several choices, so Preview works as Select and
+// MultiSelect lets the user choose one item.
+type Dmenu struct {
+	// Launcher is the name of the launcher, one of Launchers.
+	Launcher string
+	// Run runs the launcher, Exec when nil.
+	Run Runner
+}
+
+// NewDmenu returns the Dmenu running the named launcher.
+func NewDmenu(name string) (Dmenu, error) {
+	if _, ok := launchers[name]; !ok {
+		return Dmenu{}, fmt.Errorf("unknown launcher %q (supported: %s)", name, strings.Join(Launchers(), ", "))
+	}
+	return Dmenu{Launcher: name}, nil
+}
+
+// run runs the launcher with the extra flags of the mode and the prompt.
+// Launchers exit with 1 when the user pressed escape.
+func (d Dmenu) run(mode []string, prompt, stdin string) (string, error) {
+	l, ok := launchers[d.Launcher]
+	if !ok {
+		return "", fmt.Errorf("unknown launcher %q", d.Launcher)
+	}
+	args := append(append(append([]string{}, l.args...), mode...), l.prompt, oneLine(prompt))
+	run := d.Run
+	if run == nil {
+		run = Exec
+	}
+	out, err := run(d.Launcher, args, stdin)
+	if code := exitCode(err); code == 1 {
+		return "", ErrCancelled
+	} else if err != nil {
+		return "", fmt.Errorf("failed to run %s: %w", d.Launcher, err)
+	}
+	return strings.TrimRight(out, "\r\n"), nil
+}
+
+// Select matches the chosen line back to the first item labelled with it.
+func (d Dmenu) Select(prompt string, items []Item) (Item, error) {
+	var input strings.Builder
+	for _, item := range items {
+		input.WriteString(oneLine(item.Label))
+		input.WriteByte('\n')
+	}
+	out, err := d.run(launchers[d.Launcher].list, prompt, input.String())
+	if err != nil {
+		return Item{}, err
+	}
+	if out == "" {
+		return Item{}, ErrCancelled
+	}
+	for _, item := range items {
+		if oneLine(item.Label) == out {
+			return item, nil
+		}
+	}
+	return Item{}, fmt.Errorf("%s returned %q, which is not one of the options", d.Launcher, out)
+}
+
+func (d Dmenu) MultiSelect(prompt string, items []Item) ([]Item, error) {
+	item, err := d.Select(prompt, items)
+	if err != nil {
+		return nil, err
+	}
+	return []Item{item}, nil
+}
+
+func (d Dmenu) Preview(prompt string, items []Item) (Item, error) {
+	return d.Select(prompt, items)
+}
+
+func (d Dmenu) Input(prompt string) (string, error) {
+	out, err := d.run(launchers[d.Launcher].input, prompt, "")
+	return strings.TrimSpace(out), err
+}
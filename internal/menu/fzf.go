@@ -0,0 +1,121 @@
+package menu
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultFzfPreview draws a cover image in fzf's preview window with chafa.
+const DefaultFzfPreview = `chafa -s "${FZF_PREVIEW_COLUMNS}x${FZF_PREVIEW_LINES}" {image}`
+
+// Fzf runs menus in the terminal with fzf.
+type Fzf struct {
+	// Path is the fzf binary, "fzf" when empty.
+	Path string
+	// PreviewCommand is the shell command fzf runs to preview the
+	// highlighted item, with {image} standing for its image file. Preview
+	// works as Select when it is empty.
+	PreviewCommand string
+	// Run runs fzf, Exec when nil.
+	Run Runner
+}
+
+// Each item is written as "index\tlabel\timage" and only the label is shown,
+// so that the chosen lines map back to the items whatever their labels and
+// the preview command can reach the image as field 3.
+func (f Fzf) choose(prompt string, items []Item, extra ...string) ([]Item, error) {
+	var input strings.Builder
+	for i, item := range items {
+		fmt.Fprintf(&input, "%d\t%s\t%s\n", i, oneLine(item.Label), oneLine(item.Image))
+	}
+	args := append([]string{
+		"--delimiter", "\t",
+		"--with-nth", "2",
+		"--layout", "reverse",
+		"--prompt", oneLine(prompt) + "> ",
+	}, extra...)
+
+	// fzf exits with 1 when nothing matched and 130 when the user pressed
+	// escape or ctrl-c.
+	out, err := f.run(args, input.String())
+	if code := exitCode(err); code == 1 || code == 130 {
+		return nil, ErrCancelled
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to run fzf: %w", err)
+	}
+	// fzf prints marked lines in the order they were marked
+	var indexes []int
+	for _, line := range strings.Split(out, "\n") {
+		index, _, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		i, err := strconv.Atoi(index)
+		if err != nil || i < 0 || i >= len(items) {
+			return nil, fmt.Errorf("unexpected fzf output %q", line)
+		}
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	chosen := make([]Item, 0, len(indexes))
+	for _, i := range indexes {
+		chosen = append(chosen, items[i])
+	}
+	if len(chosen) == 0 {
+		return nil, ErrCancelled
+	}
+	return chosen, nil
+}
+
+// run runs fzf with args, feeding it stdin.
+func (f Fzf) run(args []string, stdin string) (string, error) {
+	path := f.Path
+	if path == "" {
+		path = "fzf"
+	}
+	run := f.Run
+	if run == nil {
+		run = Exec
+	}
+	return run(path, args, stdin)
+}
+
+func (f Fzf) Select(prompt string, items []Item) (Item, error) {
+	chosen, err := f.choose(prompt, items, "--no-multi")
+	if err != nil {
+		return Item{}, err
+	}
+	return chosen[0], nil
+}
+
+func (f Fzf) MultiSelect(prompt string, items []Item) ([]Item, error) {
+	return f.choose(prompt, items, "--multi", "--header", "tab: mark · enter: done")
+}
+
+func (f Fzf) Preview(prompt string, items []Item) (Item, error) {
+	if f.PreviewCommand == "" {
+		return f.Select(prompt, items)
+	}
+	preview := strings.ReplaceAll(f.PreviewCommand, "{image}", "{3}")
+	chosen, err := f.choose(prompt, items, "--no-multi", "--preview", preview, "--preview-window", "right,50%")
+	if err != nil {
+		return Item{}, err
+	}
+	return chosen[0], nil
+}
+
+// Input shows prompt above fzf's query line and returns the query. With no
+// items to match, fzf exits with 1 even when the user pressed enter, so only
+// 130 means the user gave up.
+func (f Fzf) Input(prompt string) (string, error) {
+	out, err := f.run([]string{"--print-query", "--layout", "reverse", "--header", prompt, "--prompt", "> "}, "")
+	if code := exitCode(err); code == 130 {
+		return "", ErrCancelled
+	} else if err != nil && code != 1 {
+		return "", fmt.Errorf("failed to run fzf: %w", err)
+	}
+	query, _, _ := strings.Cut(out, "\n")
+	return strings.TrimSpace(query), nil
+}
@@ -0,0 +1,75 @@
+// Package menu runs curd's menus through external launchers: fzf in the
+// terminal, and dmenu or one of the launchers that speak its protocol on the
+// desktop. Items go to the launcher's standard input one per line and the
+// chosen ones come back on its standard output.
+//
+// curd's own menus, the Bubble Tea one in the terminal and rofi, implement
+// the same Menu interface outside this package.
+package menu
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ErrCancelled is returned when the user closes a menu without choosing.
+var ErrCancelled = errors.New("menu cancelled")
+
+// Item is an entry of a menu.
+type Item struct {
+	Key   string
+	Label string
+	// Image is a local image file previewed next to the item, by the
+	// backends that can show one.
+	Image string
+}
+
+// Menu is a way of asking the user to choose.
+type Menu interface {
+	// Select returns the item the user chose.
+	Select(prompt string, items []Item) (Item, error)
+	// MultiSelect returns the items the user chose, in the order they were
+	// given. Backends without multiple selection let the user choose one.
+	MultiSelect(prompt string, items []Item) ([]Item, error)
+	// Preview is Select with each item's image shown, where the backend can.
+	Preview(prompt string, items []Item) (Item, error)
+	// Input returns the text the user typed.
+	Input(prompt string) (string, error)
+}
+
+// Runner runs the command name with args, feeding it stdin, and returns what
+// it wrote to its standard output. A command that exits with a non-zero
+// status returns an error with an ExitCode method, as *exec.ExitError has.
+type Runner func(name string, args []string, stdin string) (string, error)
+
+// Exec is the Runner that runs real commands. Their standard error goes to
+// curd's, which is where fzf draws when it is not given a terminal.
+func Exec(name string, args []string, stdin string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	return stdout.String(), err
+}
+
+// exitCode returns the status err reports the command exited with, or -1
+// when the command did not run to an exit.
+func exitCode(err error) int {
+	var exit interface{ ExitCode() int }
+	if errors.As(err, &exit) {
+		return exit.ExitCode()
+	}
+	return -1
+}
+
+// oneLine makes s fit on a single line of a launcher's input.
+func oneLine(s string) string {
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == '\t'
+	}), " ")
+}
@@ -0,0 +1,143 @@
+package menu
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type exitError int
+
+func (e exitError) Error() string { return "exit status" }
+func (e exitError) ExitCode() int { return int(e) }
+
+// fake records the last command and answers with out and err.
+type fake struct {
+	name  string
+	args  []string
+	stdin string
+	out   string
+	err   error
+}
+
+func (f *fake) run(name string, args []string, stdin string) (string, error) {
+	f.name, f.args, f.stdin = name, args, stdin
+	return f.out, f.err
+}
+
+var items = []Item{
+	{Key: "1", Label: "Frieren", Image: "/cache/a.jpg"},
+	{Key: "2", Label: "Dandadan\tSeason 1", Image: "/cache/b.jpg"},
+	{Key: "3", Label: "Frieren"},
+}
+
+func TestFzfSelect(t *testing.T) {
+	f := &fake{out: "2\tFrieren\t\n"}
+	got, err := Fzf{Run: f.run}.Select("Select", items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != items[2] {
+		t.Fatalf("Select = %+v, want the second Frieren", got)
+	}
+	wantStdin := "0\tFrieren\t/cache/a.jpg\n1\tDandadan Season 1\t/cache/b.jpg\n2\tFrieren\t\n"
+	if f.name != "fzf" || f.stdin != wantStdin {
+		t.Fatalf("ran %s with stdin %q", f.name, f.stdin)
+	}
+}
+
+func TestFzfMultiSelectAndPreview(t *testing.T) {
+	f := &fake{out: "1\tDandadan Season 1\t/cache/b.jpg\n0\tFrieren\t/cache/a.jpg\n"}
+	got, err := Fzf{Run: f.run}.MultiSelect("Episodes", items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, items[:2]) {
+		t.Fatalf("MultiSelect = %+v", got)
+	}
+
+	f.out = "1\tDandadan Season 1\t/cache/b.jpg\n"
+	fzf := Fzf{Run: f.run, PreviewCommand: DefaultFzfPreview}
+	if _, err := fzf.Preview("Select Anime", items); err != nil {
+		t.Fatal(err)
+	}
+	args := strings.Join(f.args, " ")
+	if !strings.Contains(args, `--preview chafa -s "${FZF_PREVIEW_COLUMNS}x${FZF_PREVIEW_LINES}" {3}`) {
+		t.Fatalf("preview command not passed on: %s", args)
+	}
+}
+
+func TestFzfCancelled(t *testing.T) {
+	for _, code := range []int{1, 130} {
+		f := &fake{err: exitError(code)}
+		if _, err := (Fzf{Run: f.run}).Select("Select", items); !errors.Is(err, ErrCancelled) {
+			t.Errorf("exit %d: err = %v, want ErrCancelled", code, err)
+		}
+	}
+	f := &fake{err: exitError(2)}
+	if _, err := (Fzf{Run: f.run}).Select("Select", items); err == nil || errors.Is(err, ErrCancelled) {
+		t.Errorf("exit 2: err = %v, want a failure", err)
+	}
+}
+
+func TestFzfInput(t *testing.T) {
+	// Enter on a query that matches nothing exits with 1.
+	f := &fake{out: "one piece\n", err: exitError(1)}
+	got, err := Fzf{Run: f.run}.Input("Search")
+	if err != nil || got != "one piece" {
+		t.Fatalf("Input = %q, %v", got, err)
+	}
+	f = &fake{err: exitError(130)}
+	if _, err := (Fzf{Run: f.run}).Input("Search"); !errors.Is(err, ErrCancelled) {
+		t.Fatalf("err = %v, want ErrCancelled", err)
+	}
+}
+
+func TestDmenuSelect(t *testing.T) {
+	f := &fake{out: "Dandadan Season 1\n"}
+	d, err := NewDmenu("fuzzel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Run = f.run
+	got, err := d.Select("Select", items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != items[1] {
+		t.Fatalf("Select = %+v", got)
+	}
+	if want := []string{"--dmenu", "--prompt", "Select"}; f.name != "fuzzel" || !reflect.DeepEqual(f.args, want) {
+		t.Fatalf("ran %s %v, want fuzzel %v", f.name, f.args, want)
+	}
+
+	f.out = "Frieren\n"
+	if got, _ := d.MultiSelect("Select", items); !reflect.DeepEqual(got, items[:1]) {
+		t.Fatalf("MultiSelect = %+v, want the first Frieren", got)
+	}
+
+	f.out = "Made up\n"
+	if _, err := d.Select("Select", items); err == nil {
+		t.Fatal("typed text that is not an option should fail")
+	}
+	f.err = exitError(1)
+	if _, err := d.Select("Select", items); !errors.Is(err, ErrCancelled) {
+		t.Fatalf("err = %v, want ErrCancelled", err)
+	}
+}
+
+func TestDmenuInput(t *testing.T) {
+	f := &fake{out: "  86  \n"}
+	d := Dmenu{Launcher: "tofi", Run: f.run}
+	got, err := d.Input("Episode\nnumber")
+	if err != nil || got != "86" {
+		t.Fatalf("Input = %q, %v", got, err)
+	}
+	if want := []string{"--require-match=false", "--prompt-text", "Episode number"}; !reflect.DeepEqual(f.args, want) {
+		t.Fatalf("args = %v, want %v", f.args, want)
+	}
+	if _, err := NewDmenu("rofi"); err == nil {
+		t.Fatal("rofi is not one of the dmenu launchers")
+	}
+}
@@ -0,0 +1,208 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wraient/curd/internal/menu"
+)
+
+// Menu backends besides the dmenu-compatible launchers of the menu package.
+const (
+	menuBackendAuto = "auto"
+	menuBackendTUI  = "tui"
+	menuBackendRofi = "rofi"
+	menuBackendFzf  = "fzf"
+)
+
+// menuBackendNames lists the values MenuBackend takes.
+func menuBackendNames() []string {
+	return append([]string{menuBackendAuto, menuBackendTUI, menuBackendRofi, menuBackendFzf}, menu.Launchers()...)
+}
+
+// menuBackend returns the backend config's menus run in, resolving auto to
+// rofi in rofi mode and to the terminal menu otherwise.
+func menuBackend(config *CurdConfig) string {
+	if config == nil {
+		return menuBackendTUI
+	}
+	name := strings.ToLower(strings.TrimSpace(config.MenuBackend))
+	if name == "" || name == menuBackendAuto {
+		if config.RofiSelection {
+			return menuBackendRofi
+		}
+		return menuBackendTUI
+	}
+	return name
+}
+
+// ApplyMenuBackend checks the configured menu backend and sets rofi mode to
+// match it: the desktop launchers run curd like rofi does, with
+// notifications instead of terminal output, while fzf runs in the terminal.
+func ApplyMenuBackend(config *CurdConfig) error {
+	name := menuBackend(config)
+	switch name {
+	case menuBackendTUI, menuBackendFzf:
+		config.RofiSelection = false
+	case menuBackendRofi:
+		config.RofiSelection = true
+	default:
+		if _, err := menu.NewDmenu(name); err != nil {
+			config.MenuBackend = menuBackendAuto
+			return fmt.Errorf("unknown menu backend %q (supported: %s)", name, strings.Join(menuBackendNames(), ", "))
+		}
+		config.RofiSelection = true
+	}
+	config.MenuBackend = name
+	return nil
+}
+
+// activeMenu returns the backend the current config's menus run in.
+func activeMenu() menu.Menu {
+	config := GetGlobalConfig()
+	switch name := menuBackend(config); name {
+	case menuBackendRofi:
+		return rofiMenu{}
+	case menuBackendFzf:
+		preview := strings.TrimSpace(config.FzfPreviewCommand)
+		if preview == "" {
+			preview = menu.DefaultFzfPreview
+		}
+		return menu.Fzf{PreviewCommand: preview}
+	case menuBackendTUI:
+		return tuiMenu{}
+	default:
+		if d, err := menu.NewDmenu(name); err == nil {
+			return d
+		}
+		return tuiMenu{}
+	}
+}
+
+// isTUIMenu reports whether m is the terminal selection prompt, which
+// offers more than the Menu interface: key actions and its own sorting.
+func isTUIMenu(m menu.Menu) bool {
+	_, ok := m.(tuiMenu)
+	return ok
+}
+
+// usePreviewMenu reports whether shows are picked from cover image previews,
+// which only rofi and fzf can show.
+func usePreviewMenu(config *CurdConfig) bool {
+	if !config.ImagePreview {
+		return false
+	}
+	backend := menuBackend(config)
+	return backend == menuBackendRofi || backend == menuBackendFzf
+}
+
+// GetUserInput asks the user for a line of text in the active menu backend.
+func GetUserInput(message string) (string, error) {
+	return activeMenu().Input(message)
+}
+
+// selectFromMenu lets the user pick one of options in m, with a Quit entry
+// appended. Closing the menu picks Quit.
+func selectFromMenu(m menu.Menu, prompt string, options []SelectionOption) (SelectionOption, error) {
+	items := make([]menu.Item, 0, len(options)+1)
+	for i, opt := range options {
+		items = append(items, menu.Item{Key: strconv.Itoa(i), Label: opt.Label})
+	}
+	items = append(items, menu.Item{Key: "-1", Label: "Quit"})
+
+	item, err := m.Select(prompt, items)
+	if errors.Is(err, menu.ErrCancelled) || (err == nil && item.Key == "-1") {
+		return SelectionOption{Key: "-1", Label: "Quit"}, nil
+	}
+	if err != nil {
+		return SelectionOption{}, err
+	}
+	i, _ := strconv.Atoi(item.Key)
+	return options[i], nil
+}
+
+// multiSelectFromMenu is selectFromMenu for several options, in the order
+// they were given. Closing the menu picks Quit alone.
+func multiSelectFromMenu(m menu.Menu, prompt string, options []SelectionOption) ([]SelectionOption, error) {
+	items := make([]menu.Item, 0, len(options)+1)
+	for i, opt := range options {
+		items = append(items, menu.Item{Key: strconv.Itoa(i), Label: opt.Label})
+	}
+	items = append(items, menu.Item{Key: "-1", Label: "Quit"})
+
+	chosen, err := m.MultiSelect(prompt, items)
+	if errors.Is(err, menu.ErrCancelled) {
+		return []SelectionOption{{Key: "-1", Label: "Quit"}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]SelectionOption, 0, len(chosen))
+	for _, item := range chosen {
+		if item.Key == "-1" {
+			return []SelectionOption{{Key: "-1", Label: "Quit"}}, nil
+		}
+		i, _ := strconv.Atoi(item.Key)
+		selected = append(selected, options[i])
+	}
+	return selected, nil
+}
+
+// tuiMenu is the Bubble Tea selection prompt as a menu backend.
+type tuiMenu struct{}
+
+func (tuiMenu) options(items []menu.Item) []SelectionOption {
+	options := make([]SelectionOption, len(items))
+	for i, item := range items {
+		options[i] = SelectionOption{Key: strconv.Itoa(i), Label: item.Label}
+	}
+	return options
+}
+
+func (t tuiMenu) Select(prompt string, items []menu.Item) (menu.Item, error) {
+	model, err := runSelectionModel(newSelectionModel(t.options(items), true))
+	if err != nil {
+		return menu.Item{}, err
+	}
+	if model.selected >= len(model.filteredKeys) {
+		return menu.Item{}, menu.ErrCancelled
+	}
+	i, err := strconv.Atoi(model.filteredKeys[model.selected].Key)
+	if err != nil || i < 0 {
+		// The prompt's own Quit entry
+		return menu.Item{}, menu.ErrCancelled
+	}
+	return items[i], nil
+}
+
+func (t tuiMenu) MultiSelect(prompt string, items []menu.Item) ([]menu.Item, error) {
+	model := newSelectionModel(t.options(items), true)
+	model.multiSelect = true
+	model.selectedItems = make(map[string]bool)
+	model, err := runSelectionModel(model)
+	if err != nil {
+		return nil, err
+	}
+	var chosen []menu.Item
+	for i, item := range items {
+		if model.selectedItems[strconv.Itoa(i)] {
+			chosen = append(chosen, item)
+		}
+	}
+	if len(chosen) == 0 {
+		return nil, menu.ErrCancelled
+	}
+	return chosen, nil
+}
+
+// Preview is Select: the terminal prompt shows no images.
+func (t tuiMenu) Preview(prompt string, items []menu.Item) (menu.Item, error) {
+	return t.Select(prompt, items)
+}
+
+func (tuiMenu) Input(prompt string) (string, error) {
+	CurdOut(prompt)
+	return readTrimmedStdinLine()
+}
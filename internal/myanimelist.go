@@ -10,8 +10,6 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -27,6 +25,8 @@ const (
 	malClientSecret = "73e137cc11ed13aa4da434c0642a2dfd44a98a4b9450b76caf9ea64af2effe31"
 	malRedirectURI  = "http://localhost:8888/oauth/callback"
 	malServerPort   = 8888
+	// malRefreshWindow is how long before expiry a MAL token is refreshed.
+	malRefreshWindow = 7 * 24 * time.Hour
 )
 
 // MALToken represents the OAuth token response from MyAnimeList
@@ -108,13 +108,13 @@ func generateCodeChallenge(verifier string) string {
 }
 
 // authenticateWithBrowserMAL performs OAuth authentication using browser
-func authenticateWithBrowserMAL(tokenPath string, forceReauth bool) (string, error) {
+func authenticateWithBrowserMAL(config *CurdConfig, forceReauth bool) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
 	// Try to load existing token first (skip if forcing re-authentication)
 	if !forceReauth {
-		if token, err := loadMALToken(tokenPath); err == nil && isMALTokenValid(token) {
+		if token, err := loadMALToken(config); err == nil && isMALTokenValid(token) {
 			return token.AccessToken, nil
 		}
 	}
@@ -123,12 +123,29 @@ func authenticateWithBrowserMAL(tokenPath string, forceReauth bool) (string, err
 	codeVerifier := generateCodeVerifier()
 	codeChallenge := generateCodeChallenge(codeVerifier)
 
+	authURL := fmt.Sprintf("%s/authorize?response_type=code&client_id=%s&redirect_uri=%s&code_challenge=%s&code_challenge_method=plain",
+		malOAuthURL,
+		malClientID,
+		url.QueryEscape(malRedirectURI),
+		codeChallenge)
+	if oauthHeadless(config) {
+		code, err := readAuthorizationCode(config, "MyAnimeList", authURL)
+		if err != nil {
+			return "", err
+		}
+		token, err := exchangeMALCode(code, codeVerifier)
+		if err != nil {
+			return "", fmt.Errorf("authentication failed: %w", err)
+		}
+		return saveMALLogin(config, token)
+	}
+
 	// Start local server to handle OAuth callback
-	callbackCh := make(chan string, 1)
+	callbackCh := make(chan *MALToken, 1)
 	errCh := make(chan error, 1)
 	mux := http.NewServeMux()
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", malServerPort),
+		Addr:    oauthListenAddr(config, malServerPort),
 		Handler: mux,
 	}
 
@@ -183,41 +200,12 @@ func authenticateWithBrowserMAL(tokenPath string, forceReauth bool) (string, err
 
 		// Exchange authorization code for access token
 		go func() {
-			tokenURL := fmt.Sprintf("%s/token", malOAuthURL)
-			data := url.Values{
-				"client_id":     {malClientID},
-				"client_secret": {malClientSecret},
-				"grant_type":    {"authorization_code"},
-				"code":          {code},
-				"redirect_uri":  {malRedirectURI},
-				"code_verifier": {codeVerifier},
-			}
-
-			resp, err := http.PostForm(tokenURL, data)
+			token, err := exchangeMALCode(code, codeVerifier)
 			if err != nil {
-				errCh <- fmt.Errorf("failed to exchange code for token: %w", err)
+				errCh <- err
 				return
 			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode != http.StatusOK {
-				body, _ := io.ReadAll(resp.Body)
-				errCh <- fmt.Errorf("token exchange failed with status %d: %s", resp.StatusCode, string(body))
-				return
-			}
-
-			var tokenResponse MALToken
-			if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
-				errCh <- fmt.Errorf("failed to parse token response: %w", err)
-				return
-			}
-
-			if tokenResponse.AccessToken == "" {
-				errCh <- fmt.Errorf("no access token in response")
-				return
-			}
-
-			callbackCh <- tokenResponse.AccessToken
+			callbackCh <- token
 		}()
 
 		// Show success page immediately
@@ -250,12 +238,6 @@ func authenticateWithBrowserMAL(tokenPath string, forceReauth bool) (string, err
 	time.Sleep(100 * time.Millisecond)
 
 	// Open browser for authentication
-	authURL := fmt.Sprintf("%s/authorize?response_type=code&client_id=%s&redirect_uri=%s&code_challenge=%s&code_challenge_method=plain",
-		malOAuthURL,
-		malClientID,
-		url.QueryEscape(malRedirectURI),
-		codeChallenge)
-
 	fmt.Println("Opening browser for MyAnimeList authentication...")
 	fmt.Printf("If the browser doesn't open automatically, visit: %s\n", authURL)
 
@@ -265,25 +247,59 @@ func authenticateWithBrowserMAL(tokenPath string, forceReauth bool) (string, err
 	}
 
 	// Wait for token
-	var accessToken string
+	var token *MALToken
 	select {
-	case accessToken = <-callbackCh:
+	case token = <-callbackCh:
 	case err := <-errCh:
 		return "", fmt.Errorf("authentication failed: %w", err)
 	case <-ctx.Done():
 		return "", fmt.Errorf("authentication timeout after 5 minutes")
 	}
 
-	// Create token object and save
-	token := &MALToken{
-		AccessToken: accessToken,
-		TokenType:   "Bearer",
-		ExpiresIn:   2592000, // MAL tokens are valid for 30 days
-		ExpiresAt:   time.Now().Add(30 * 24 * time.Hour),
+	return saveMALLogin(config, token)
+}
+
+// exchangeMALCode exchanges an authorization code and its PKCE verifier for a token pair
+func exchangeMALCode(code, codeVerifier string) (*MALToken, error) {
+	tokenURL := fmt.Sprintf("%s/token", malOAuthURL)
+	data := url.Values{
+		"client_id":     {malClientID},
+		"client_secret": {malClientSecret},
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {malRedirectURI},
+		"code_verifier": {codeVerifier},
+	}
+
+	resp, err := sharedHTTPClient.PostForm(tokenURL, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("token exchange failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var token MALToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("failed to parse token response: %w", err)
 	}
 
-	// Save token to file
-	if err := saveMALToken(tokenPath, token); err != nil {
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("no access token in response")
+	}
+
+	return &token, nil
+}
+
+// saveMALLogin stores a freshly obtained token pair and returns its access token
+func saveMALLogin(config *CurdConfig, token *MALToken) (string, error) {
+	setMALTokenExpiry(token)
+
+	// Save token to the credential store
+	if err := saveMALToken(config, token); err != nil {
 		return "", fmt.Errorf("failed to save token: %w", err)
 	}
 
@@ -291,11 +307,59 @@ func authenticateWithBrowserMAL(tokenPath string, forceReauth bool) (string, err
 	return token.AccessToken, nil
 }
 
-// loadMALToken loads the token from the token file
-func loadMALToken(tokenPath string) (*MALToken, error) {
-	data, err := os.ReadFile(tokenPath)
+// setMALTokenExpiry fills in ExpiresAt from ExpiresIn
+func setMALTokenExpiry(token *MALToken) {
+	if token.TokenType == "" {
+		token.TokenType = "Bearer"
+	}
+	if token.ExpiresIn <= 0 {
+		token.ExpiresIn = 2592000 // MAL tokens are valid for 30 days
+	}
+	token.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+}
+
+// refreshMALToken exchanges the refresh token for a new token pair. MAL
+// rotates refresh tokens, so the old one stops working once this succeeds.
+func refreshMALToken(token *MALToken) (*MALToken, error) {
+	data := url.Values{
+		"client_id":     {malClientID},
+		"client_secret": {malClientSecret},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {token.RefreshToken},
+	}
+	resp, err := sharedHTTPClient.PostForm(fmt.Sprintf("%s/token", malOAuthURL), data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read refresh response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token refresh failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var refreshed MALToken
+	if err := json.Unmarshal(body, &refreshed); err != nil {
+		return nil, fmt.Errorf("failed to parse refresh response: %w", err)
+	}
+	if refreshed.AccessToken == "" {
+		return nil, fmt.Errorf("no access token in refresh response")
+	}
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = token.RefreshToken
+	}
+	setMALTokenExpiry(&refreshed)
+	return &refreshed, nil
+}
+
+// loadMALToken loads the token from the credential store
+func loadMALToken(config *CurdConfig) (*MALToken, error) {
+	data, err := readCredential(config, malTokenKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read token file: %w", err)
+		return nil, err
 	}
 
 	var token MALToken
@@ -306,19 +370,14 @@ func loadMALToken(tokenPath string) (*MALToken, error) {
 	return &token, nil
 }
 
-// saveMALToken saves the token to the token file
-func saveMALToken(tokenPath string, token *MALToken) error {
-	// Ensure directory exists
-	if err := os.MkdirAll(filepath.Dir(tokenPath), 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
-
+// saveMALToken saves the token to the credential store
+func saveMALToken(config *CurdConfig, token *MALToken) error {
 	data, err := json.Marshal(token)
 	if err != nil {
 		return fmt.Errorf("failed to marshal token: %w", err)
 	}
 
-	return os.WriteFile(tokenPath, data, 0600)
+	return writeCredential(config, malTokenKey, data)
 }
 
 // isMALTokenValid checks if the token is still valid
@@ -326,19 +385,30 @@ func isMALTokenValid(token *MALToken) bool {
 	return token != nil && token.AccessToken != "" && time.Now().Before(token.ExpiresAt)
 }
 
-// GetMALTokenFromFile loads the token from the token file
-func GetMALTokenFromFile(tokenPath string) (string, error) {
-	data, err := os.ReadFile(tokenPath)
+// GetMALToken returns the stored MAL access token, refreshing it first when
+// it is close to expiry or already expired
+func GetMALToken(config *CurdConfig) (string, error) {
+	token, err := loadMALToken(config)
 	if err != nil {
-		return "", fmt.Errorf("failed to read token from file: %w", err)
+		return "", fmt.Errorf("failed to read token: %w", err)
 	}
 
-	var token MALToken
-	if err := json.Unmarshal(data, &token); err != nil {
-		return "", fmt.Errorf("failed to parse token file: %w", err)
+	if token.RefreshToken != "" && time.Until(token.ExpiresAt) < malRefreshWindow {
+		refreshed, err := refreshMALToken(token)
+		if err != nil {
+			Log(fmt.Sprintf("MAL token refresh failed: %v", err))
+		} else if err := saveMALToken(config, refreshed); err != nil {
+			// The old refresh token is spent, so keep using the new pair
+			// for this session even though it could not be stored.
+			Log(fmt.Sprintf("Failed to save refreshed MAL token: %v", err))
+			token = refreshed
+		} else {
+			Log(fmt.Sprintf("Refreshed MAL token, now valid until %s", refreshed.ExpiresAt.Format(time.RFC3339)))
+			token = refreshed
+		}
 	}
 
-	if !isMALTokenValid(&token) {
+	if !isMALTokenValid(token) {
 		return "", fmt.Errorf("token has expired")
 	}
 
@@ -348,11 +418,10 @@ func GetMALTokenFromFile(tokenPath string) (string, error) {
 // ChangeMALToken handles MAL OAuth token creation/change
 func ChangeMALToken(config *CurdConfig, user *User) {
 	var err error
-	tokenPath := filepath.Join(os.ExpandEnv(config.StoragePath), "mal_token.json")
 
 	// Try browser-based OAuth (force re-authentication since user explicitly wants to change token)
 	fmt.Println("Starting MyAnimeList browser-based authentication...")
-	user.Token, err = authenticateWithBrowserMAL(tokenPath, true)
+	user.Token, err = authenticateWithBrowserMAL(config, true)
 
 	if err != nil {
 		Log("MAL browser authentication failed: " + err.Error())
@@ -378,7 +447,7 @@ func GetMALUserInfo(token string) (int, string, error) {
 
 	req.Header.Set("Authorization", "Bearer "+token)
 
-	client := &http.Client{}
+	client := sharedHTTPClient
 	resp, err := client.Do(req)
 	if err != nil {
 		return 0, "", fmt.Errorf("failed to make request: %w", err)
@@ -417,7 +486,7 @@ func GetMALUserAnimeList(token string) (map[string]interface{}, error) {
 
 		req.Header.Set("Authorization", "Bearer "+token)
 
-		client := &http.Client{}
+		client := sharedHTTPClient
 		resp, err := client.Do(req)
 		if err != nil {
 			return nil, fmt.Errorf("failed to make request: %w", err)
@@ -520,7 +589,7 @@ func SearchAnimeMAL(query, token string) ([]SelectionOption, error) {
 
 	req.Header.Set("Authorization", "Bearer "+token)
 
-	client := &http.Client{}
+	client := sharedHTTPClient
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
@@ -591,7 +660,7 @@ func SearchAnimeMALPreview(query, token string) (map[string]RofiSelectPreview, e
 
 	req.Header.Set("Authorization", "Bearer "+token)
 
-	client := &http.Client{}
+	client := sharedHTTPClient
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
@@ -676,7 +745,7 @@ func UpdateMALAnimeProgress(token string, mediaID, progress int) error {
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{}
+	client := sharedHTTPClient
 	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to make request: %w", err)
@@ -742,7 +811,7 @@ func UpdateMALAnimeStatus(token string, mediaID int, status string) error {
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{}
+	client := sharedHTTPClient
 	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to make request: %w", err)
@@ -778,7 +847,7 @@ func RateAnimeMAL(token string, mediaID int) error {
 	}
 
 	if userCurdConfig.RofiSelection {
-		userInput, err := GetUserInputFromRofi("Enter a score for the anime (0-10)")
+		userInput, err := GetUserInput("Enter a score for the anime (0-10)")
 		if err != nil {
 			return err
 		}
@@ -809,7 +878,7 @@ func RateAnimeMAL(token string, mediaID int) error {
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{}
+	client := sharedHTTPClient
 	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to make request: %w", err)
@@ -845,7 +914,7 @@ func AddAnimeToMALWatchingList(animeID int, token string) error {
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{}
+	client := sharedHTTPClient
 	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to make request: %w", err)
@@ -861,6 +930,38 @@ func AddAnimeToMALWatchingList(animeID int, token string) error {
 	return nil
 }
 
+// AddAnimeToMALPlanningList adds an anime to the MAL plan to watch list
+func AddAnimeToMALPlanningList(animeID int, token string) error {
+	apiURL := fmt.Sprintf("%s/anime/%d/my_list_status", malAPIURL, animeID)
+
+	data := url.Values{
+		"status": {"plan_to_watch"},
+	}
+
+	req, err := http.NewRequest("PATCH", apiURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := sharedHTTPClient
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to add anime. Status Code: %d, Response: %s", resp.StatusCode, string(body))
+	}
+
+	CurdOut(fmt.Sprintf("Anime with ID %d has been added to your MyAnimeList plan to watch list.", animeID))
+	return nil
+}
+
 // GetMALAnimeDetails gets detailed information about an anime from MAL
 func GetMALAnimeDetails(malID int, token string) (Anime, error) {
 	apiURL := fmt.Sprintf("%s/anime/%d?fields=num_episodes,status,my_list_status", malAPIURL, malID)
@@ -872,7 +973,7 @@ func GetMALAnimeDetails(malID int, token string) (Anime, error) {
 
 	req.Header.Set("Authorization", "Bearer "+token)
 
-	client := &http.Client{}
+	client := sharedHTTPClient
 	resp, err := client.Do(req)
 	if err != nil {
 		return Anime{}, fmt.Errorf("failed to make request: %w", err)
@@ -944,7 +1045,7 @@ func ConvertMALIDToAnilist(malID int, token string) (int, error) {
 		req.Header.Set("Authorization", "Bearer "+token)
 	}
 
-	client := &http.Client{}
+	client := sharedHTTPClient
 	resp, err := client.Do(req)
 	if err != nil {
 		return 0, fmt.Errorf("failed to send request: %w", err)
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+var (
+	navigationMu     sync.Mutex
+	navigationCtx    context.Context
+	navigationCancel context.CancelFunc
+
+	playbackMu     sync.Mutex
+	playbackCancel context.CancelFunc
+)
+
+// navigationContext returns the context provider lookups run under. It is
+// cancelled when the user backs out of or quits a menu, so background work
+// such as next-episode prefetching stops once its result is no longer wanted.
+func navigationContext() context.Context {
+	navigationMu.Lock()
+	defer navigationMu.Unlock()
+	if navigationCtx == nil {
+		navigationCtx, navigationCancel = context.WithCancel(context.Background())
+	}
+	return navigationCtx
+}
+
+// cancelNavigation cancels every lookup started under the current navigation
+// context. Later calls to navigationContext get a fresh one.
+func cancelNavigation() {
+	navigationMu.Lock()
+	defer navigationMu.Unlock()
+	if navigationCancel != nil {
+		navigationCancel()
+	}
+	navigationCtx, navigationCancel = nil, nil
+}
+
+// beginPlayback returns the context of the episode about to play, ending
+// the previous episode's.
+func beginPlayback() context.Context {
+	playbackMu.Lock()
+	defer playbackMu.Unlock()
+	if playbackCancel != nil {
+		playbackCancel()
+	}
+	var ctx context.Context
+	ctx, playbackCancel = context.WithCancel(context.Background())
+	return ctx
+}
+
+// EndPlayback cancels the context of the episode that was playing, stopping
+// background work tied to it.
+func EndPlayback() {
+	playbackMu.Lock()
+	defer playbackMu.Unlock()
+	if playbackCancel != nil {
+		playbackCancel()
+	}
+	playbackCancel = nil
+}
+
+// cancelNavigationOnLeave cancels navigation when selected is the back or
+// quit entry of a menu.
+func cancelNavigationOnLeave(selected SelectionOption) {
+	if selected.Key == "back" || selected.Key == "-1" {
+		cancelNavigation()
+	}
+}
+
+// interruptible runs fn under the navigation context. Ctrl+C while fn runs
+// cancels it and exits curd cleanly instead of killing the process in the
+// middle of a provider request.
+func interruptible[T any](fn func(ctx context.Context) (T, error)) (T, error) {
+	ctx, cancel := context.WithCancel(navigationContext())
+	defer cancel()
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	done := make(chan struct{})
+	interrupted := make(chan struct{})
+	go func() {
+		select {
+		case <-interrupts:
+			close(interrupted)
+			cancel()
+		case <-done:
+		}
+	}()
+
+	value, err := fn(ctx)
+	signal.Stop(interrupts)
+	close(done)
+
+	select {
+	case <-interrupted:
+		ExitCurd(nil)
+	default:
+	}
+	return value, err
+}
@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/browser"
+)
+
+// oauthHeadless reports whether logins should skip the local callback
+// server and have the user paste the redirect URL instead. OAuthLogin is
+// "browser", "headless" or "auto", which goes headless over SSH and on Linux
+// without a display.
+func oauthHeadless(config *CurdConfig) bool {
+	mode := ""
+	if config != nil {
+		mode = strings.ToLower(strings.TrimSpace(config.OAuthLogin))
+	}
+	switch mode {
+	case "headless":
+		return true
+	case "browser":
+		return false
+	}
+	if os.Getenv("SSH_CONNECTION") != "" || os.Getenv("SSH_TTY") != "" {
+		return true
+	}
+	return runtime.GOOS == "linux" && os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == ""
+}
+
+// oauthListenAddr returns where the callback server listens. The redirect
+// URI registered with the tracker always names registeredPort on localhost,
+// so a different OAuthCallbackPort only helps when that port is forwarded
+// to it, as with ssh -L.
+func oauthListenAddr(config *CurdConfig, registeredPort int) string {
+	host, port := "", registeredPort
+	if config != nil {
+		host = strings.TrimSpace(config.OAuthBindAddress)
+		if config.OAuthCallbackPort > 0 {
+			port = config.OAuthCallbackPort
+		}
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+const authorizationCodeInstructions = "After approving, your browser is sent to a localhost page that may fail to load. Copy that page's full URL from the address bar."
+
+// readAuthorizationCode shows the login link for service and reads back
+// the URL the browser was redirected to, or just its code. The redirect
+// page itself fails to load on a headless machine, which is expected: its
+// address still carries the code.
+func readAuthorizationCode(config *CurdConfig, service, authURL string) (string, error) {
+	if config != nil && config.RofiSelection {
+		return readAuthorizationCodeRofi(service, authURL)
+	}
+
+	fmt.Printf("Open this link on any device to log in to %s:\n\n  %s\n\n", service, authURL)
+	fmt.Println(authorizationCodeInstructions)
+	fmt.Print("Paste the URL (or just the code) here: ")
+	input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && strings.TrimSpace(input) == "" {
+		return "", fmt.Errorf("failed to read the redirect URL: %w", err)
+	}
+	return parseAuthorizationResponse(input)
+}
+
+// readAuthorizationCodeRofi is readAuthorizationCode for rofi mode, where
+// the link is also opened in the default browser.
+func readAuthorizationCodeRofi(service, authURL string) (string, error) {
+	if err := browser.OpenURL(authURL); err != nil {
+		Log(fmt.Sprintf("Failed to open browser for %s login: %v", service, err))
+	}
+	input, err := GetUserInput(fmt.Sprintf("Log in to %s at %s\n%s\nPaste it here", service, authURL, authorizationCodeInstructions))
+	if err != nil {
+		return "", err
+	}
+	return parseAuthorizationResponse(input)
+}
+
+// parseAuthorizationResponse extracts the authorization code from a pasted
+// redirect URL, its query string, or a bare code.
+func parseAuthorizationResponse(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", fmt.Errorf("no authorization code provided")
+	}
+
+	query := ""
+	if u, err := url.Parse(input); err == nil && u.Scheme != "" {
+		query = u.RawQuery
+	} else if strings.Contains(input, "code=") || strings.Contains(input, "error=") {
+		query = strings.TrimPrefix(input, "?")
+	} else {
+		return input, nil
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the redirect URL: %w", err)
+	}
+	if errorParam := values.Get("error"); errorParam != "" {
+		if description := values.Get("error_description"); description != "" {
+			errorParam += ": " + description
+		}
+		return "", fmt.Errorf("oauth error: %s", errorParam)
+	}
+	code := values.Get("code")
+	if code == "" {
+		return "", fmt.Errorf("the pasted URL has no authorization code")
+	}
+	return code, nil
+}
@@ -0,0 +1,346 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wraient/curd/internal/party"
+)
+
+// partyHeartbeat is how often the host rebroadcasts its state and how often
+// joiners check their player for drift.
+const partyHeartbeat = time.Second
+
+var (
+	partyHostMu sync.Mutex
+	partyHost   *party.Host
+)
+
+// StartPartyHost starts a watch party on addr and broadcasts the playback of
+// anime to everyone who joins until ExitCurd closes it.
+func StartPartyHost(addr string, anime *Anime) (*party.Host, error) {
+	host, err := party.Listen(addr)
+	if err != nil {
+		return nil, err
+	}
+	partyHostMu.Lock()
+	partyHost = host
+	partyHostMu.Unlock()
+	host.OnJoin = func(name string) {
+		CurdOut(fmt.Sprintf("%s joined the watch party", name))
+	}
+	host.OnLeave = func(name string) {
+		CurdOut(fmt.Sprintf("%s left the watch party", name))
+	}
+
+	CurdOut("Watch party started. Others on your network can join with:")
+	for _, joinAddr := range partyJoinAddrs(host.Addr()) {
+		CurdOut("curd party join " + joinAddr)
+	}
+	Log(fmt.Sprintf("Watch party listening on %s", host.Addr()))
+
+	go broadcastPartyState(host, anime)
+	return host, nil
+}
+
+// closePartyHost says goodbye to every joiner of the hosted watch party.
+func closePartyHost() {
+	partyHostMu.Lock()
+	host := partyHost
+	partyHost = nil
+	partyHostMu.Unlock()
+	if host != nil {
+		if err := host.Close(); err != nil {
+			Log(fmt.Sprintf("Error closing watch party: %v", err))
+		}
+	}
+}
+
+// partyJoinAddrs lists the LAN addresses a joiner can use to reach addr.
+func partyJoinAddrs(addr net.Addr) []string {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return []string{addr.String()}
+	}
+	port := fmt.Sprint(tcpAddr.Port)
+	if !tcpAddr.IP.IsUnspecified() {
+		return []string{net.JoinHostPort(tcpAddr.IP.String(), port)}
+	}
+
+	var addrs []string
+	ifaceAddrs, err := net.InterfaceAddrs()
+	if err == nil {
+		for _, ifaceAddr := range ifaceAddrs {
+			ipNet, ok := ifaceAddr.(*net.IPNet)
+			if !ok || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+				continue
+			}
+			addrs = append(addrs, net.JoinHostPort(ipNet.IP.String(), port))
+		}
+	}
+	if len(addrs) == 0 {
+		addrs = append(addrs, net.JoinHostPort("127.0.0.1", port))
+	}
+	return addrs
+}
+
+// broadcastPartyState sends the host player state on every heartbeat and
+// immediately after pause, unpause, seek and file changes reported by the
+// mpv event listener.
+func broadcastPartyState(host *party.Host, anime *Anime) {
+	changed := make(chan struct{}, 1)
+	onEvent := func(event string, _ interface{}) {
+		switch event {
+		case "playback-restart", "pause-event", "unpause-event", "pause", "seek", "file-loaded":
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}
+
+	ticker := time.NewTicker(partyHeartbeat)
+	defer ticker.Stop()
+
+	listening := ""
+	lastEpisode := 0
+	lastAnime := 0
+	for {
+		socket := anime.Ep.Player.SocketPath
+		if socket != "" && socket != "android-intent" {
+			position, paused, speed, err := partyPlayerState(socket)
+			if err != nil {
+				// mpv may have been restarted on the same socket; reattach once it is back.
+				listening = ""
+			} else {
+				if socket != listening {
+					if err := StartMPVEventListener(socket, onEvent); err != nil {
+						Log(fmt.Sprintf("Watch party event listener failed: %v", err))
+					}
+					listening = socket
+				}
+				// The old file keeps reporting its final position until the
+				// next episode loads, so announce new episodes from the start.
+				if lastEpisode != 0 && (anime.Ep.Number != lastEpisode || anime.AnilistId != lastAnime) {
+					position = 0
+				}
+				host.Broadcast(party.State{
+					AnilistID:    anime.AnilistId,
+					MalID:        anime.MalId,
+					Title:        anime.Title.Romaji,
+					EnglishTitle: anime.Title.English,
+					Episode:      anime.Ep.Number,
+					Position:     position,
+					Paused:       paused,
+					Speed:        speed,
+				})
+				lastEpisode = anime.Ep.Number
+				lastAnime = anime.AnilistId
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-changed:
+		}
+	}
+}
+
+// partyPlayerState reads the position, pause state and speed from mpv.
+func partyPlayerState(socket string) (float64, bool, float64, error) {
+	timePos, err := MPVSendCommand(socket, []interface{}{"get_property", "time-pos"})
+	if err != nil {
+		return 0, false, 0, err
+	}
+	position, ok := timePos.(float64)
+	if !ok {
+		return 0, false, 0, fmt.Errorf("unexpected time-pos value %v", timePos)
+	}
+	paused, err := GetMPVPausedStatus(socket)
+	if err != nil {
+		return 0, false, 0, err
+	}
+	speed, err := GetMPVPlaybackSpeed(socket)
+	if err != nil || speed <= 0 {
+		speed = 1
+	}
+	return position, paused, speed, nil
+}
+
+// partyMemberName identifies this machine to the host.
+func partyMemberName() string {
+	name := os.Getenv("USER")
+	if name == "" {
+		name = os.Getenv("USERNAME")
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		if name == "" {
+			return hostname
+		}
+		return name + "@" + hostname
+	}
+	return name
+}
+
+// RunPartyJoin joins the watch party at addr and mirrors the host playback.
+// Episodes are resolved through the local provider stack; progress is kept
+// in local history only and never pushed to the tracker.
+func RunPartyJoin(config *CurdConfig, anime *Anime, addr string) error {
+	client, err := party.Join(addr, partyMemberName())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	databaseFile := HistoryFilePath(config)
+	CurdOut(fmt.Sprintf("Joined watch party at %s, waiting for the host to start playback...", party.NormalizeAddr(addr)))
+
+	ticker := time.NewTicker(partyHeartbeat)
+	defer ticker.Stop()
+
+	var latest party.Update
+	haveUpdate := false
+	for {
+		select {
+		case update, ok := <-client.Updates():
+			if !ok {
+				savePartyProgress(databaseFile, anime)
+				if err := client.Err(); err != nil {
+					return fmt.Errorf("lost connection to watch party host: %w", err)
+				}
+				CurdOut("The host ended the watch party")
+				return nil
+			}
+			latest = update
+			haveUpdate = true
+			if update.AnilistID != anime.AnilistId || update.Episode != anime.Ep.Number {
+				savePartyProgress(databaseFile, anime)
+				if err := loadPartyEpisode(config, anime, databaseFile, update.State); err != nil {
+					return err
+				}
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		if !haveUpdate || anime.Ep.Player.SocketPath == "" {
+			continue
+		}
+		closed, err := syncPartyPlayer(anime, latest)
+		if closed {
+			savePartyProgress(databaseFile, anime)
+			CurdOut("Player closed, leaving the watch party")
+			return nil
+		}
+		if err != nil {
+			Log(fmt.Sprintf("Watch party sync failed: %v", err))
+		}
+	}
+}
+
+// loadPartyEpisode resolves the host's episode through the local providers
+// and starts it at the host position.
+func loadPartyEpisode(config *CurdConfig, anime *Anime, databaseFile string, state party.State) error {
+	if state.AnilistID != anime.AnilistId {
+		next := Anime{
+			AnilistId: state.AnilistID,
+			MalId:     state.MalID,
+			Title:     AnimeTitle{Romaji: state.Title, English: state.EnglishTitle},
+		}
+		next.Ep.Player.Speed = 1
+		next.Ep.Player.SocketPath = anime.Ep.Player.SocketPath
+
+		if local := LocalFindAnime(LocalGetAllAnime(databaseFile), state.AnilistID, ""); local != nil && local.ProviderId != "" {
+			next.ProviderId = local.ProviderId
+			next.ProviderName = local.ProviderName
+		} else {
+			query := state.Title
+			if query == "" {
+				query = state.EnglishTitle
+			}
+			outcome, err := ResolveAnimeProviderMapping(config, &next, query, nil)
+			if err != nil {
+				return fmt.Errorf("failed to find %s on a provider: %w", query, err)
+			}
+			if outcome != ProviderMappingOK {
+				return fmt.Errorf("no provider selected for %s", query)
+			}
+		}
+		*anime = next
+	}
+
+	anime.Ep.Number = state.Episode
+	anime.Ep.Links = nil
+	anime.Ep.Player.PlaybackTime = int(state.Position)
+	result, err := ResolveEpisodeURLForPlayback(*config, anime, state.Episode)
+	if err != nil {
+		return fmt.Errorf("failed to get links for episode %d: %w", state.Episode, err)
+	}
+	if len(result.Links) == 0 {
+		return fmt.Errorf("no links found for episode %d", state.Episode)
+	}
+	anime.Ep.Links = result.Links
+	applyStreamPlaybackHints(anime, anime.Ep.Links, result.LinkHints)
+
+	title := fmt.Sprintf("%s - Episode %d", GetAnimeName(*anime), anime.Ep.Number)
+	CurdOut(title)
+	var args []string
+	if state.Position > 0 {
+		args = append(args, fmt.Sprintf("--start=%.1f", state.Position))
+	}
+	socket, err := StartVideo(PrioritizeLink(anime.Ep.Links), args, title, anime)
+	if err != nil {
+		return fmt.Errorf("failed to start player: %w", err)
+	}
+	anime.Ep.Player.SocketPath = socket
+	return nil
+}
+
+// syncPartyPlayer applies drift correction to the local player. It reports
+// closed when the player is no longer running.
+func syncPartyPlayer(anime *Anime, update party.Update) (bool, error) {
+	socket := anime.Ep.Player.SocketPath
+	position, paused, speed, err := partyPlayerState(socket)
+	if err != nil {
+		if strings.Contains(err.Error(), "property unavailable") || IsMPVRunning(socket) {
+			// Still loading the episode.
+			return false, nil
+		}
+		return true, nil
+	}
+	anime.Ep.Player.PlaybackTime = int(position)
+
+	correction := party.Correct(update, position, time.Now())
+	if correction.Paused != paused {
+		if _, err := MPVSendCommand(socket, []interface{}{"set_property", "pause", correction.Paused}); err != nil {
+			return false, err
+		}
+	}
+	if correction.Seek {
+		Log(fmt.Sprintf("Watch party drift %.2fs, seeking to %.2f", correction.Drift, correction.Position))
+		if _, err := MPVSendCommand(socket, []interface{}{"seek", correction.Position, "absolute"}); err != nil {
+			return false, err
+		}
+	}
+	if math.Abs(correction.Speed-speed) > 0.001 {
+		if _, err := MPVSendCommand(socket, []interface{}{"set_property", "speed", correction.Speed}); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// savePartyProgress records the joiner's position in local history.
+func savePartyProgress(databaseFile string, anime *Anime) {
+	if anime.AnilistId == 0 || anime.ProviderId == "" {
+		return
+	}
+	if err := LocalUpdateAnime(databaseFile, anime.AnilistId, anime.ProviderId, anime.Ep.Number, anime.Ep.Player.PlaybackTime, 0, GetAnimeName(*anime), anime.ProviderName); err != nil {
+		Log(fmt.Sprintf("Failed to save watch party progress: %v", err))
+	}
+}
@@ -0,0 +1,413 @@
+// Package party implements the LAN watch party protocol.
+//
+// A host accepts TCP connections and broadcasts the playback state of its
+// player as newline-delimited JSON messages. Joiners resolve the episode
+// through their own providers and use Correct to keep their player in sync.
+package party
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProtocolVersion is bumped whenever the wire format changes incompatibly.
+const ProtocolVersion = 1
+
+// DefaultPort is used when a listen or join address does not include a port.
+const DefaultPort = "7717"
+
+const (
+	MessageHello = "hello"
+	MessageState = "state"
+	MessageBye   = "bye"
+)
+
+const (
+	handshakeTimeout = 5 * time.Second
+	writeTimeout     = 2 * time.Second
+)
+
+// Drift thresholds used by Correct, in seconds.
+const (
+	SeekThreshold  = 2.0
+	NudgeThreshold = 0.3
+	NudgeFactor    = 0.05
+)
+
+// State is the playback state broadcast by the host.
+type State struct {
+	AnilistID int    `json:"anilist_id"`
+	MalID     int    `json:"mal_id,omitempty"`
+	Title     string `json:"title"`
+	// EnglishTitle helps joiners search providers when the romaji title misses.
+	EnglishTitle string  `json:"english_title,omitempty"`
+	Episode      int     `json:"episode"`
+	Position     float64 `json:"position"`
+	Paused       bool    `json:"paused"`
+	Speed        float64 `json:"speed,omitempty"`
+}
+
+// Message is a single line on the wire.
+type Message struct {
+	Type    string `json:"type"`
+	Version int    `json:"version,omitempty"`
+	Name    string `json:"name,omitempty"`
+	State   *State `json:"state,omitempty"`
+}
+
+// Update is a state received by a joiner, stamped with the local receive time
+// so clock differences between machines do not matter.
+type Update struct {
+	State
+	ReceivedAt time.Time
+}
+
+// NormalizeAddr appends DefaultPort when addr has no port.
+func NormalizeAddr(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return ":" + DefaultPort
+	}
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(strings.Trim(addr, "[]"), DefaultPort)
+}
+
+type peer struct {
+	name string
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+func (p *peer) send(msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_ = p.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err = p.conn.Write(append(payload, '\n'))
+	return err
+}
+
+// Host accepts joiners and broadcasts playback state to them.
+type Host struct {
+	listener net.Listener
+	// OnJoin and OnLeave are called with the joiner name when set.
+	OnJoin  func(name string)
+	OnLeave func(name string)
+
+	mu     sync.Mutex
+	peers  map[*peer]struct{}
+	last   *State
+	closed bool
+}
+
+// Listen starts a host on addr.
+func Listen(addr string) (*Host, error) {
+	listener, err := net.Listen("tcp", NormalizeAddr(addr))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start watch party host: %w", err)
+	}
+	host := &Host{listener: listener, peers: map[*peer]struct{}{}}
+	go host.acceptLoop()
+	return host, nil
+}
+
+// Addr returns the address the host is listening on.
+func (h *Host) Addr() net.Addr {
+	return h.listener.Addr()
+}
+
+// Peers returns the names of the connected joiners.
+func (h *Host) Peers() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	names := make([]string, 0, len(h.peers))
+	for p := range h.peers {
+		names = append(names, p.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Broadcast sends state to every joiner and remembers it for late joiners.
+func (h *Host) Broadcast(state State) {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return
+	}
+	stored := state
+	h.last = &stored
+	peers := make([]*peer, 0, len(h.peers))
+	for p := range h.peers {
+		peers = append(peers, p)
+	}
+	h.mu.Unlock()
+
+	msg := Message{Type: MessageState, State: &state}
+	for _, p := range peers {
+		if err := p.send(msg); err != nil {
+			h.drop(p)
+		}
+	}
+}
+
+// Close disconnects all joiners and stops listening.
+func (h *Host) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	peers := h.peers
+	h.peers = map[*peer]struct{}{}
+	h.mu.Unlock()
+
+	for p := range peers {
+		_ = p.send(Message{Type: MessageBye})
+		_ = p.conn.Close()
+	}
+	return h.listener.Close()
+}
+
+func (h *Host) acceptLoop() {
+	for {
+		conn, err := h.listener.Accept()
+		if err != nil {
+			return
+		}
+		go h.handle(conn)
+	}
+}
+
+func (h *Host) handle(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	_ = conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	hello, err := readMessage(reader)
+	if err != nil || hello.Type != MessageHello {
+		_ = conn.Close()
+		return
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+
+	p := &peer{name: strings.TrimSpace(hello.Name), conn: conn}
+	if p.name == "" {
+		p.name = conn.RemoteAddr().String()
+	}
+	if hello.Version != ProtocolVersion {
+		_ = p.send(Message{Type: MessageBye, Name: fmt.Sprintf("unsupported protocol version %d", hello.Version)})
+		_ = conn.Close()
+		return
+	}
+	if err := p.send(Message{Type: MessageHello, Version: ProtocolVersion}); err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		_ = conn.Close()
+		return
+	}
+	h.peers[p] = struct{}{}
+	var last *State
+	if h.last != nil {
+		copied := *h.last
+		last = &copied
+	}
+	h.mu.Unlock()
+
+	if h.OnJoin != nil {
+		h.OnJoin(p.name)
+	}
+	if last != nil {
+		if err := p.send(Message{Type: MessageState, State: last}); err != nil {
+			h.drop(p)
+			return
+		}
+	}
+
+	// Joiners only ever send a bye; reading until EOF detects disconnects.
+	for {
+		msg, err := readMessage(reader)
+		if err != nil || msg.Type == MessageBye {
+			h.drop(p)
+			return
+		}
+	}
+}
+
+func (h *Host) drop(p *peer) {
+	h.mu.Lock()
+	_, ok := h.peers[p]
+	delete(h.peers, p)
+	h.mu.Unlock()
+	_ = p.conn.Close()
+	if ok && h.OnLeave != nil {
+		h.OnLeave(p.name)
+	}
+}
+
+// Client is a joiner connected to a host.
+type Client struct {
+	conn    net.Conn
+	updates chan Update
+
+	mu  sync.Mutex
+	err error
+}
+
+// Join connects to the host at addr and identifies as name.
+func Join(addr, name string) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", NormalizeAddr(addr), handshakeTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to watch party: %w", err)
+	}
+	p := &peer{name: name, conn: conn}
+	if err := p.send(Message{Type: MessageHello, Version: ProtocolVersion, Name: name}); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to greet watch party host: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	_ = conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	reply, err := readMessage(reader)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("no reply from watch party host: %w", err)
+	}
+	if reply.Type != MessageHello {
+		conn.Close()
+		if reply.Name != "" {
+			return nil, fmt.Errorf("watch party host refused connection: %s", reply.Name)
+		}
+		return nil, errors.New("watch party host refused connection")
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+
+	client := &Client{conn: conn, updates: make(chan Update, 1)}
+	go client.readLoop(reader)
+	return client, nil
+}
+
+// Updates delivers host states. Only the latest pending state is kept, so a
+// slow consumer never acts on stale positions. The channel is closed when the
+// host disconnects.
+func (c *Client) Updates() <-chan Update {
+	return c.updates
+}
+
+// Err reports why the connection ended, or nil if the host said goodbye.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close leaves the party.
+func (c *Client) Close() error {
+	p := &peer{conn: c.conn}
+	_ = p.send(Message{Type: MessageBye})
+	return c.conn.Close()
+}
+
+func (c *Client) readLoop(reader *bufio.Reader) {
+	defer close(c.updates)
+	for {
+		msg, err := readMessage(reader)
+		if err != nil {
+			c.mu.Lock()
+			c.err = err
+			c.mu.Unlock()
+			return
+		}
+		switch msg.Type {
+		case MessageBye:
+			return
+		case MessageState:
+			if msg.State == nil {
+				continue
+			}
+			update := Update{State: *msg.State, ReceivedAt: time.Now()}
+			select {
+			case <-c.updates:
+			default:
+			}
+			c.updates <- update
+		}
+	}
+}
+
+func readMessage(reader *bufio.Reader) (Message, error) {
+	var msg Message
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return msg, err
+	}
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return msg, fmt.Errorf("invalid watch party message: %w", err)
+	}
+	return msg, nil
+}
+
+// ExpectedPosition extrapolates the host position at now.
+func ExpectedPosition(update Update, now time.Time) float64 {
+	if update.Paused {
+		return update.Position
+	}
+	speed := update.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	return update.Position + now.Sub(update.ReceivedAt).Seconds()*speed
+}
+
+// Correction describes how a joiner should adjust its player.
+type Correction struct {
+	Seek     bool
+	Position float64
+	Paused   bool
+	Speed    float64
+	Drift    float64
+}
+
+// Correct compares the local player against the host and returns the
+// adjustments needed. Large drift is fixed with a seek; small drift is
+// absorbed by nudging the playback speed.
+func Correct(update Update, localPosition float64, now time.Time) Correction {
+	speed := update.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	expected := ExpectedPosition(update, now)
+	drift := localPosition - expected
+	correction := Correction{Position: expected, Paused: update.Paused, Speed: speed, Drift: drift}
+
+	if update.Paused {
+		correction.Seek = math.Abs(drift) > NudgeThreshold
+		return correction
+	}
+	switch {
+	case math.Abs(drift) > SeekThreshold:
+		correction.Seek = true
+	case drift > NudgeThreshold:
+		correction.Speed = speed * (1 - NudgeFactor)
+	case drift < -NudgeThreshold:
+		correction.Speed = speed * (1 + NudgeFactor)
+	}
+	return correction
+}
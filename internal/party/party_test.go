@@ -0,0 +1,249 @@
+package party_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wraient/curd/internal/party"
+)
+
+func waitForUpdate(t *testing.T, client *party.Client) party.Update {
+	t.Helper()
+	select {
+	case update, ok := <-client.Updates():
+		if !ok {
+			t.Fatalf("connection closed before update: %v", client.Err())
+		}
+		return update
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for update")
+	}
+	return party.Update{}
+}
+
+func waitForPeers(t *testing.T, host *party.Host, count int) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for len(host.Peers()) != count {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d peers, got %v", count, host.Peers())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHostBroadcastsToJoiners(t *testing.T) {
+	host, err := party.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer host.Close()
+
+	client, err := party.Join(host.Addr().String(), "alice")
+	if err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	defer client.Close()
+	waitForPeers(t, host, 1)
+
+	host.Broadcast(party.State{AnilistID: 21, Title: "One Piece", Episode: 3, Position: 42, Speed: 1})
+	update := waitForUpdate(t, client)
+	if update.AnilistID != 21 || update.Episode != 3 || update.Position != 42 {
+		t.Fatalf("unexpected update: %+v", update)
+	}
+	if peers := host.Peers(); len(peers) != 1 || peers[0] != "alice" {
+		t.Fatalf("unexpected peers: %v", peers)
+	}
+}
+
+func TestLateJoinerReceivesLastState(t *testing.T) {
+	host, err := party.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer host.Close()
+
+	host.Broadcast(party.State{AnilistID: 1, Episode: 5, Position: 100, Paused: true})
+
+	client, err := party.Join(host.Addr().String(), "bob")
+	if err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	defer client.Close()
+
+	update := waitForUpdate(t, client)
+	if update.Episode != 5 || !update.Paused {
+		t.Fatalf("unexpected update: %+v", update)
+	}
+}
+
+func TestClientUpdatesCloseWhenHostLeaves(t *testing.T) {
+	host, err := party.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	client, err := party.Join(host.Addr().String(), "carol")
+	if err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	defer client.Close()
+	waitForPeers(t, host, 1)
+	host.Close()
+
+	select {
+	case _, ok := <-client.Updates():
+		if ok {
+			t.Fatal("expected closed updates channel")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for disconnect")
+	}
+	if client.Err() != nil {
+		t.Fatalf("expected clean goodbye, got %v", client.Err())
+	}
+}
+
+func TestCorrect(t *testing.T) {
+	received := time.Unix(1000, 0)
+	now := received.Add(2 * time.Second)
+	update := party.Update{State: party.State{Position: 60, Speed: 1}, ReceivedAt: received}
+
+	if c := party.Correct(update, 62, now); c.Seek || c.Speed != 1 {
+		t.Fatalf("in sync should not adjust: %+v", c)
+	}
+	if c := party.Correct(update, 70, now); !c.Seek || c.Position != 62 {
+		t.Fatalf("large drift should seek to 62: %+v", c)
+	}
+	if c := party.Correct(update, 63, now); c.Seek || c.Speed >= 1 {
+		t.Fatalf("ahead should slow down: %+v", c)
+	}
+	if c := party.Correct(update, 61, now); c.Seek || c.Speed <= 1 {
+		t.Fatalf("behind should speed up: %+v", c)
+	}
+
+	paused := party.Update{State: party.State{Position: 60, Paused: true}, ReceivedAt: received}
+	if c := party.Correct(paused, 61, now); !c.Seek || c.Position != 60 || !c.Paused {
+		t.Fatalf("paused host should pin position: %+v", c)
+	}
+}
+
+func TestNormalizeAddr(t *testing.T) {
+	cases := map[string]string{
+		"":              ":" + party.DefaultPort,
+		"192.168.1.5":   "192.168.1.5:" + party.DefaultPort,
+		"host.lan:9000": "host.lan:9000",
+		"::1":           "[::1]:" + party.DefaultPort,
+		"[::1]:9000":    "[::1]:9000",
+	}
+	for in, want := range cases {
+		if got := party.NormalizeAddr(in); got != want {
+			t.Fatalf("NormalizeAddr(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestPartyHelperProcess is not a real test: TestPartyAcrossProcesses runs
+// the test binary again with CURD_PARTY_ROLE set to play host or joiner.
+func TestPartyHelperProcess(t *testing.T) {
+	switch os.Getenv("CURD_PARTY_ROLE") {
+	case "host":
+		host, err := party.Listen("127.0.0.1:0")
+		if err != nil {
+			fmt.Println("error", err)
+			os.Exit(1)
+		}
+		fmt.Println(host.Addr())
+		host.Broadcast(party.State{AnilistID: 21, Title: "One Piece", Episode: 7, Position: 90, Speed: 1})
+		// The host leaves once it is told to on stdin.
+		bufio.NewReader(os.Stdin).ReadString('\n')
+		host.Close()
+		os.Exit(0)
+	case "join":
+		client, err := party.Join(os.Getenv("CURD_PARTY_ADDR"), "dave")
+		if err != nil {
+			fmt.Println("error", err)
+			os.Exit(1)
+		}
+		update, ok := <-client.Updates()
+		if !ok {
+			fmt.Println("error", client.Err())
+			os.Exit(1)
+		}
+		fmt.Println("update", update.AnilistID, update.Episode)
+		for range client.Updates() {
+		}
+		fmt.Println("left", client.Err())
+		os.Exit(0)
+	}
+}
+
+func helperProcess(t *testing.T, role string, env ...string) (*exec.Cmd, io.WriteCloser, *bufio.Reader) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestPartyHelperProcess$")
+	cmd.Env = append(append(os.Environ(), "CURD_PARTY_ROLE="+role), env...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start %s: %v", role, err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	return cmd, stdin, bufio.NewReader(stdout)
+}
+
+func readLine(t *testing.T, role string, reader *bufio.Reader) string {
+	t.Helper()
+	lines := make(chan string, 1)
+	go func() {
+		line, _ := reader.ReadString('\n')
+		lines <- strings.TrimSpace(line)
+	}()
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", role)
+	}
+	return ""
+}
+
+func TestPartyAcrossProcesses(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts helper processes")
+	}
+	host, hostIn, hostOut := helperProcess(t, "host")
+	addr := readLine(t, "host", hostOut)
+	if strings.HasPrefix(addr, "error") || addr == "" {
+		t.Fatalf("host failed: %q", addr)
+	}
+
+	joiner, _, joinOut := helperProcess(t, "join", "CURD_PARTY_ADDR="+addr)
+	if line := readLine(t, "joiner", joinOut); line != "update 21 7" {
+		t.Fatalf("joiner got %q", line)
+	}
+
+	fmt.Fprintln(hostIn, "leave")
+	if line := readLine(t, "joiner", joinOut); line != "left <nil>" {
+		t.Fatalf("joiner expected a clean goodbye, got %q", line)
+	}
+	if err := joiner.Wait(); err != nil {
+		t.Fatalf("joiner: %v", err)
+	}
+	if err := host.Wait(); err != nil {
+		t.Fatalf("host: %v", err)
+	}
+}
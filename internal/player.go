@@ -299,6 +299,18 @@ func StartVideo(link string, args []string, title string, anime *Anime) (string,
 	}
 	args = normalizeReferrerArgs(args)
 
+	// The warm cache proxy fetches the stream itself, so it needs the
+	// referrer mpv would have sent.
+	if isHTTPStreamLink(link) {
+		cacheReferrer := referrer
+		if cacheReferrer == "" {
+			cacheReferrer = streamReferrerForLink(link, CurrentAnimeProviderName(anime))
+		}
+		played := warmCachedLink(link, cacheReferrer)
+		replacePlayingStream(anime, link, played)
+		link = played
+	}
+
 	// Check if we have an existing socket and if MPV is still running
 	if anime.Ep.Player.SocketPath != "" && IsMPVRunning(anime.Ep.Player.SocketPath) {
 		// Reuse existing socket
@@ -0,0 +1,148 @@
+// Package presence renders the text of curd's Discord activity and decides
+// when it may be sent.
+//
+// Templates name fields in braces. A part wrapped in square brackets is
+// dropped when any field inside it is empty, so optional details do not
+// leave dangling separators:
+//
+//	Episode {episode}[/{total}][ · {episode_title}]
+package presence
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Discord refuses activity strings shorter than 2 or longer than 128
+// characters.
+const (
+	minTextLength = 2
+	maxTextLength = 128
+)
+
+// Render fills template with fields. Unknown fields render empty.
+func Render(template string, fields map[string]string) string {
+	var out strings.Builder
+	for len(template) > 0 {
+		open := strings.IndexByte(template, '[')
+		if open < 0 {
+			out.WriteString(expand(template, fields, nil))
+			break
+		}
+		out.WriteString(expand(template[:open], fields, nil))
+		closing := strings.IndexByte(template[open:], ']')
+		if closing < 0 {
+			out.WriteString(expand(template[open:], fields, nil))
+			break
+		}
+		missing := false
+		section := expand(template[open+1:open+closing], fields, &missing)
+		if !missing {
+			out.WriteString(section)
+		}
+		template = template[open+closing+1:]
+	}
+	return clamp(strings.Join(strings.Fields(out.String()), " "))
+}
+
+// expand replaces the {field} references in text, setting *missing when one
+// of them is empty.
+func expand(text string, fields map[string]string, missing *bool) string {
+	var out strings.Builder
+	for {
+		open := strings.IndexByte(text, '{')
+		if open < 0 {
+			out.WriteString(text)
+			return out.String()
+		}
+		closing := strings.IndexByte(text[open:], '}')
+		if closing < 0 {
+			out.WriteString(text)
+			return out.String()
+		}
+		out.WriteString(text[:open])
+		value := fields[text[open+1:open+closing]]
+		if value == "" && missing != nil {
+			*missing = true
+		}
+		out.WriteString(value)
+		text = text[open+closing+1:]
+	}
+}
+
+func clamp(text string) string {
+	if utf8.RuneCountInString(text) < minTextLength {
+		return ""
+	}
+	if utf8.RuneCountInString(text) <= maxTextLength {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:maxTextLength-1])) + "…"
+}
+
+// Limiter keeps activity updates within Discord's rate limit. Every caller
+// asks it before sending, so the policy lives in one place: a changed
+// activity goes out as soon as the minimum interval allows, an unchanged one
+// only to keep the presence alive.
+type Limiter struct {
+	// MinInterval is the least time between two updates. Discord accepts
+	// about five updates per 20 seconds.
+	MinInterval time.Duration
+	// KeepAlive resends an unchanged activity after this long.
+	KeepAlive time.Duration
+
+	mu      sync.Mutex
+	last    time.Time
+	lastKey string
+	pending bool
+}
+
+// NewLimiter returns a Limiter with Discord's limits.
+func NewLimiter() *Limiter {
+	return &Limiter{MinInterval: 4 * time.Second, KeepAlive: 2 * time.Minute}
+}
+
+// Allow reports whether an activity identified by key may be sent at now.
+// force asks for an update even though key is unchanged, as after a seek. A
+// changed or forced update that comes too early is held: the next call
+// sends whatever activity it brings, and Pending says when that may be.
+func (l *Limiter) Allow(key string, force bool, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	changed := l.last.IsZero() || key != l.lastKey || force
+	wanted := changed || l.pending || now.Sub(l.last) >= l.KeepAlive
+	if !wanted {
+		return false
+	}
+	if !l.last.IsZero() && now.Sub(l.last) < l.MinInterval {
+		l.pending = l.pending || changed
+		return false
+	}
+	l.last, l.lastKey, l.pending = now, key, false
+	return true
+}
+
+// Pending reports whether an update is being held and how long after now
+// it may be sent.
+func (l *Limiter) Pending(now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.pending {
+		return 0, false
+	}
+	wait := l.MinInterval - now.Sub(l.last)
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
+
+// Reset forgets the last update, so the next activity is sent right away.
+func (l *Limiter) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.last, l.lastKey, l.pending = time.Time{}, "", false
+}
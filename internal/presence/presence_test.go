@@ -0,0 +1,86 @@
+package presence
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderDropsSectionsWithMissingFields(t *testing.T) {
+	fields := map[string]string{"title": "Frieren", "episode": "3", "total": "28"}
+	cases := map[string]string{
+		"{title}":                                 "Frieren",
+		"Episode {episode}[/{total}]":             "Episode 3/28",
+		"Episode {episode}[ · {episode_title}]":   "Episode 3",
+		"[{season} · ]{title}":                    "Frieren",
+		"{title}  {unknown} [{speed}x]":           "Frieren",
+		"Episode {episode} [of {total}] · {mode}": "Episode 3 of 28 ·",
+	}
+	for template, want := range cases {
+		if got := Render(template, fields); got != want {
+			t.Errorf("Render(%q) = %q, want %q", template, got, want)
+		}
+	}
+}
+
+func TestRenderKeepsDiscordLimits(t *testing.T) {
+	if got := Render("{title}", map[string]string{"title": "A"}); got != "" {
+		t.Fatalf("expected a one-character text to be dropped, got %q", got)
+	}
+	long := strings.Repeat("x", 200)
+	got := Render("{title}", map[string]string{"title": long})
+	if n := len([]rune(got)); n != maxTextLength {
+		t.Fatalf("expected %d characters, got %d", maxTextLength, n)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := &Limiter{MinInterval: 4 * time.Second, KeepAlive: time.Minute}
+	start := time.Unix(1000, 0)
+
+	if !l.Allow("ep1", false, start) {
+		t.Fatal("the first update must go out")
+	}
+	if l.Allow("ep1", false, start.Add(10*time.Second)) {
+		t.Fatal("an unchanged activity must wait for the keep-alive")
+	}
+	if l.Allow("ep1-paused", false, start.Add(2*time.Second)) {
+		t.Fatal("a change inside the minimum interval must wait")
+	}
+	if wait, held := l.Pending(start.Add(2 * time.Second)); !held || wait != 2*time.Second {
+		t.Fatalf("Pending = %s, %v; want the change held for 2s", wait, held)
+	}
+	if !l.Allow("ep1-paused", false, start.Add(5*time.Second)) {
+		t.Fatal("a change must go out once the interval has passed")
+	}
+	if l.Allow("ep1-paused", true, start.Add(6*time.Second)) {
+		t.Fatal("a forced update inside the interval must wait")
+	}
+	if !l.Allow("ep1-paused", false, start.Add(10*time.Second)) {
+		t.Fatal("a deferred forced update must go out on the next call")
+	}
+	if !l.Allow("ep1-paused", false, start.Add(80*time.Second)) {
+		t.Fatal("an unchanged activity must be resent after the keep-alive")
+	}
+	if _, held := l.Pending(start.Add(80 * time.Second)); held {
+		t.Fatal("nothing is held after an update went out")
+	}
+}
+
+func TestLimiterHoldsChangesForLaterCalls(t *testing.T) {
+	l := &Limiter{MinInterval: 4 * time.Second, KeepAlive: time.Minute}
+	start := time.Unix(1000, 0)
+
+	l.Allow("menu|Episode 3", false, start)
+	if l.Allow("menu|Main menu", false, start.Add(time.Second)) {
+		t.Fatal("a change inside the minimum interval must wait")
+	}
+	// The held change is flushed with the same activity once the interval
+	// has passed, although its key is now the one last asked about.
+	if !l.Allow("menu|Main menu", false, start.Add(4*time.Second)) {
+		t.Fatal("a held change must go out when the interval has passed")
+	}
+	if l.Allow("menu|Main menu", false, start.Add(9*time.Second)) {
+		t.Fatal("the flushed change must not be sent twice")
+	}
+}
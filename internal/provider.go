@@ -1,10 +1,12 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
 
+	"github.com/wraient/curd/internal/mappingdb"
 	"github.com/wraient/curd/internal/providers"
 	"github.com/wraient/curd/internal/providers/animepahe"
 )
@@ -21,12 +23,20 @@ type ProviderModeResolver interface {
 	GetEpisodeURLForMode(config CurdConfig, id string, epNo int, mode string) ([]string, error)
 }
 
+// ProviderContext is implemented by providers whose lookups can be cancelled.
+type ProviderContext interface {
+	SearchAnimeContext(ctx context.Context, query, mode string) ([]SelectionOption, error)
+	EpisodesListContext(ctx context.Context, showID, mode string) ([]string, error)
+}
+
 type ProviderEpisodeResult struct {
 	Links        []string
 	LinkHints    map[string]StreamPlaybackHint
 	ProviderName string
 	ProviderID   string
 	Mode         string
+	// Streams holds the typed metadata for Links, in the same ranked order.
+	Streams []providers.Stream
 }
 
 const providerIDSeparator = "::"
@@ -327,7 +337,15 @@ func providerNamesForAnime(config *CurdConfig, anime *Anime) []string {
 		// might resolve to a completely different anime (e.g. a previous season)
 		// and silently play the wrong episodes if the requested episode number
 		// is out of bounds for the mapped provider but valid for the fallback provider.
-		return []string{providerName}
+		// Alternates from a merged search are the same listing on other
+		// providers, so they are safe to fall back to.
+		result := []string{providerName}
+		for _, alternate := range providerAlternates(anime) {
+			if name, _, ok := ParseProviderQualifiedID(alternate); ok && ProviderEnabled(name) {
+				result = appendProviderName(result, name)
+			}
+		}
+		return result
 	}
 
 	result := make([]string, 0, len(configuredNames)+1)
@@ -379,19 +397,46 @@ func qualifySelectionOption(providerName string, option SelectionOption, stacked
 
 // Wrap functions so they can be easily called
 func SearchAnime(query, mode string) ([]SelectionOption, error) {
+	return interruptible(func(ctx context.Context) ([]SelectionOption, error) {
+		return SearchAnimeContext(ctx, query, mode)
+	})
+}
+
+// SearchAnimeContext searches the configured providers until ctx is done.
+func SearchAnimeContext(ctx context.Context, query, mode string) ([]SelectionOption, error) {
 	config := GetGlobalConfig()
 	providerNames := configuredProviderNames(config)
-	return searchAnimeWithProviders(providerNames, query, mode)
+	return searchAnimeWithProviders(ctx, providerNames, query, mode)
+}
+
+func searchProviderAnime(ctx context.Context, provider Provider, query, mode string) ([]SelectionOption, error) {
+	if cp, ok := provider.(ProviderContext); ok {
+		return cp.SearchAnimeContext(ctx, query, mode)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return provider.SearchAnime(query, mode)
+}
+
+func providerEpisodesList(ctx context.Context, provider Provider, showID, mode string) ([]string, error) {
+	if cp, ok := provider.(ProviderContext); ok {
+		return cp.EpisodesListContext(ctx, showID, mode)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return provider.EpisodesList(showID, mode)
 }
 
-func searchAnimeWithProviders(providerNames []string, query, mode string) ([]SelectionOption, error) {
+func searchAnimeWithProviders(ctx context.Context, providerNames []string, query, mode string) ([]SelectionOption, error) {
 	if len(providerNames) == 1 {
 		providerName := providerNames[0]
 		provider, err := ProviderByName(providerName)
 		if err != nil {
 			return nil, err
 		}
-		options, err := provider.SearchAnime(query, mode)
+		options, err := searchProviderAnime(ctx, provider, query, mode)
 		if err != nil {
 			return nil, err
 		}
@@ -401,35 +446,7 @@ func searchAnimeWithProviders(providerNames []string, query, mode string) ([]Sel
 		return options, nil
 	}
 
-	results := make([]SelectionOption, 0)
-	seen := make(map[string]struct{})
-	var searchErrors []string
-	for _, providerName := range providerNames {
-		provider, err := ProviderByName(providerName)
-		if err != nil {
-			searchErrors = append(searchErrors, err.Error())
-			continue
-		}
-		options, err := provider.SearchAnime(query, mode)
-		if err != nil {
-			Log(fmt.Sprintf("Provider %s search failed for %q: %v", providerName, query, err))
-			searchErrors = append(searchErrors, fmt.Sprintf("%s: %v", providerName, err))
-			continue
-		}
-		for _, option := range options {
-			option = qualifySelectionOption(providerName, option, true)
-			if _, exists := seen[option.Key]; exists {
-				continue
-			}
-			seen[option.Key] = struct{}{}
-			results = append(results, option)
-		}
-	}
-
-	if len(results) == 0 && len(searchErrors) > 0 {
-		return nil, fmt.Errorf("all provider searches failed: %s", strings.Join(searchErrors, "; "))
-	}
-	return results, nil
+	return searchProvidersMerged(ctx, providerNames, query, mode)
 }
 
 
@@ -463,15 +480,23 @@ func appendProviderName(providerNames []string, providerName string) []string {
 
 
 func EpisodesList(showID, mode string) ([]string, error) {
+	return interruptible(func(ctx context.Context) ([]string, error) {
+		return EpisodesListContext(ctx, showID, mode)
+	})
+}
+
+// EpisodesListContext lists the episodes of a provider-qualified show id
+// until ctx is done.
+func EpisodesListContext(ctx context.Context, showID, mode string) ([]string, error) {
 	providerName, providerID, ok := ParseProviderQualifiedID(showID)
 	if !ok {
-		return GetProvider().EpisodesList(showID, mode)
+		return providerEpisodesList(ctx, GetProvider(), showID, mode)
 	}
 	provider, err := ProviderByName(providerName)
 	if err != nil {
 		return nil, err
 	}
-	return provider.EpisodesList(providerID, mode)
+	return providerEpisodesList(ctx, provider, providerID, mode)
 }
 
 func GetProviderTotalEpisodes(showID, mode string) (int, error) {
@@ -624,6 +649,25 @@ func getProviderEpisodeURLForModeWithHints(provider Provider, config CurdConfig,
 	return links, nil, err
 }
 
+func getProviderEpisodeStreams(ctx context.Context, provider Provider, config CurdConfig, id string, epNo int, mode string) ([]providers.Stream, error) {
+	mode = normalizeTranslationType(mode)
+	if resolver, ok := provider.(interface {
+		GetEpisodeStreamsContext(context.Context, CurdConfig, string, int, string) ([]providers.Stream, error)
+	}); ok {
+		return resolver.GetEpisodeStreamsContext(ctx, config, id, epNo, mode)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if resolver, ok := provider.(interface {
+		GetEpisodeStreams(CurdConfig, string, int, string) ([]providers.Stream, error)
+	}); ok {
+		return resolver.GetEpisodeStreams(config, id, epNo, mode)
+	}
+	links, hints, err := getProviderEpisodeURLForModeWithHints(provider, config, id, epNo, mode)
+	return providers.StreamsFromLinks(links, toProviderStreamHints(hints)), err
+}
+
 func getProviderEpisodeURLForMode(provider Provider, config CurdConfig, id string, epNo int, mode string) ([]string, error) {
 	mode = normalizeTranslationType(mode)
 	if resolver, ok := provider.(ProviderModeResolver); ok {
@@ -634,7 +678,7 @@ func getProviderEpisodeURLForMode(provider Provider, config CurdConfig, id strin
 	return provider.GetEpisodeURL(modeConfig, id, epNo)
 }
 
-func episodeModeResult(config CurdConfig, anime *Anime, epNo int, mode string) (ProviderEpisodeResult, error) {
+func episodeModeResult(ctx context.Context, config CurdConfig, anime *Anime, epNo int, mode string) (ProviderEpisodeResult, error) {
 	if anime == nil {
 		modeConfig := config
 		modeConfig.SubOrDub = mode
@@ -644,64 +688,182 @@ func episodeModeResult(config CurdConfig, anime *Anime, epNo int, mode string) (
 
 	mode = normalizeTranslationType(mode)
 	providerNames := providerNamesForAnime(&config, anime)
-	return episodeModeResultWithProviders(config, anime, epNo, mode, providerNames)
+	return episodeModeResultWithProviders(ctx, config, anime, epNo, mode, providerNames)
 }
 
-func episodeModeResultWithProviders(config CurdConfig, anime *Anime, epNo int, mode string, providerNames []string) (ProviderEpisodeResult, error) {
+// providerLookup is the outcome of finding an anime on one provider.
+type providerLookup struct {
+	provider  Provider
+	id        string
+	confident bool
+	titles    AnimeTitle
+	err       string
+}
+
+func lookupProviderForEpisode(ctx context.Context, providerName string, anime *Anime, mode string) providerLookup {
+	provider, err := ProviderByName(providerName)
+	if err != nil {
+		return providerLookup{err: err.Error()}
+	}
+	providerID, confident, err := findProviderIDForAnime(ctx, provider, anime, mode)
+	if err != nil {
+		return providerLookup{provider: provider, err: fmt.Sprintf("%s lookup: %v", providerName, err)}
+	}
+	lookup := providerLookup{provider: provider, id: providerID, confident: confident}
+	if anime != nil {
+		lookup.titles = anime.Title
+	}
+	return lookup
+}
+
+// episodeModeResultWithProviders looks the anime up on every provider at
+// once and plays the first one, in stack order, that returns links. A
+// provider further down the stack does not wait for the ones above it when
+// its match is confident; the first success cancels the remaining lookups.
+func episodeModeResultWithProviders(ctx context.Context, config CurdConfig, anime *Anime, epNo int, mode string, providerNames []string) (ProviderEpisodeResult, error) {
 	mode = normalizeTranslationType(mode)
 	if len(providerNames) == 0 {
 		providerNames = []string{firstEnabledProviderName()}
 	}
 
-	var errors []string
-	for _, providerName := range providerNames {
-		CurdOut(fmt.Sprintf("\033[1;30mSearching %s for episode...\033[0m", providerName))
-		provider, err := ProviderByName(providerName)
-		if err != nil {
-			errors = append(errors, err.Error())
+	CurdOut(fmt.Sprintf("\033[1;30mSearching %s for episode...\033[0m", strings.Join(providerNames, ", ")))
+	lookupCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type arrival struct {
+		index  int
+		lookup providerLookup
+	}
+	arrivals := make(chan arrival, len(providerNames))
+	for i, providerName := range providerNames {
+		// Each lookup gets its own copy because findProviderIDForAnime may
+		// replace the titles with fresh ones from AniList.
+		var animeCopy *Anime
+		if anime != nil {
+			copied := *anime
+			animeCopy = &copied
+		}
+		go func(index int, providerName string, anime *Anime) {
+			arrivals <- arrival{index, lookupProviderForEpisode(lookupCtx, providerName, anime, mode)}
+		}(i, providerName, animeCopy)
+	}
+
+	lookups := make([]*providerLookup, len(providerNames))
+	tried := make([]bool, len(providerNames))
+	failures := make([]string, len(providerNames))
+	remaining := len(providerNames)
+
+	// next returns the provider to try now: one with a confident match, or
+	// the first untried one once every provider above it has been tried.
+	next := func() int {
+		higherDone := true
+		for i, lookup := range lookups {
+			if tried[i] {
+				continue
+			}
+			if lookup != nil && (higherDone || lookup.confident) {
+				return i
+			}
+			higherDone = false
+		}
+		return -1
+	}
+
+	for remaining > 0 {
+		i := next()
+		if i < 0 {
+			select {
+			case got := <-arrivals:
+				lookups[got.index] = &got.lookup
+			case <-ctx.Done():
+				return ProviderEpisodeResult{}, ctx.Err()
+			}
 			continue
 		}
+		tried[i] = true
+		remaining--
 
-		providerID, err := findProviderIDForAnime(provider, anime, mode)
-		if err != nil {
-			errors = append(errors, fmt.Sprintf("%s lookup: %v", providerName, err))
+		providerName := providerNames[i]
+		lookup := lookups[i]
+		if lookup.err != "" {
+			failures[i] = lookup.err
 			continue
 		}
 
-		links, linkHints, err := getProviderEpisodeURLForModeWithHints(provider, config, providerID, epNo, mode)
+		streams, err := getProviderEpisodeStreams(lookupCtx, lookup.provider, config, lookup.id, epNo, mode)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ProviderEpisodeResult{}, ctxErr
+		}
+		streams = RankStreams(&config, providerName, streams)
+		links, hints := providers.LinksFromStreams(streams)
 		if err != nil || len(links) == 0 {
 			if err != nil {
-				errors = append(errors, fmt.Sprintf("%s episode: %v", providerName, err))
+				failures[i] = fmt.Sprintf("%s episode: %v", providerName, err)
 			} else {
-				errors = append(errors, fmt.Sprintf("%s episode: no links", providerName))
+				failures[i] = fmt.Sprintf("%s episode: no links", providerName)
 			}
 			continue
 		}
 
-		anime.ProviderName = providerName
-		anime.ProviderId = providerID
+		cancel()
+		rememberStreams(providerName, streams)
+		confidence := mappingdb.Guess
+		if lookup.confident {
+			confidence = mappingdb.Match
+		}
+		recordProviderMapping(anime, providerName, lookup.id, mode, confidence, true)
+		if anime != nil {
+			anime.ProviderName = providerName
+			anime.ProviderId = lookup.id
+			anime.Title = lookup.titles
+		}
 		return ProviderEpisodeResult{
 			Links:        links,
-			LinkHints:    linkHints,
+			LinkHints:    fromStreamHints(hints),
+			Streams:      streams,
 			ProviderName: providerName,
-			ProviderID:   providerID,
+			ProviderID:   lookup.id,
 			Mode:         mode,
 		}, nil
 	}
 
-	return ProviderEpisodeResult{}, fmt.Errorf("no %s episode links found across providers %s for %q episode %d: %s", mode, strings.Join(providerNames, ","), animeSearchTitle(anime), epNo, strings.Join(errors, "; "))
+	var messages []string
+	for _, failure := range failures {
+		if failure != "" {
+			messages = append(messages, failure)
+		}
+	}
+	return ProviderEpisodeResult{}, fmt.Errorf("no %s episode links found across providers %s for %q episode %d: %s", mode, strings.Join(providerNames, ","), animeSearchTitle(anime), epNo, strings.Join(messages, "; "))
 }
 
 func ResolveEpisodeURL(config CurdConfig, anime *Anime, epNo int) (ProviderEpisodeResult, error) {
-	return episodeModeResult(config, anime, epNo, config.SubOrDub)
+	return interruptible(func(ctx context.Context) (ProviderEpisodeResult, error) {
+		return ResolveEpisodeURLContext(ctx, config, anime, epNo)
+	})
+}
+
+// ResolveEpisodeURLContext is ResolveEpisodeURL bounded by ctx.
+func ResolveEpisodeURLContext(ctx context.Context, config CurdConfig, anime *Anime, epNo int) (ProviderEpisodeResult, error) {
+	return episodeModeResult(ctx, config, anime, epNo, config.SubOrDub)
 }
 
 func ResolveEpisodeURLForPlayback(config CurdConfig, anime *Anime, epNo int) (ProviderEpisodeResult, error) {
+	return interruptible(func(ctx context.Context) (ProviderEpisodeResult, error) {
+		return ResolveEpisodeURLForPlaybackContext(ctx, config, anime, epNo)
+	})
+}
+
+// ResolveEpisodeURLForPlaybackContext is ResolveEpisodeURLForPlayback bounded
+// by ctx.
+func ResolveEpisodeURLForPlaybackContext(ctx context.Context, config CurdConfig, anime *Anime, epNo int) (ProviderEpisodeResult, error) {
 	preferredMode := normalizeTranslationType(config.SubOrDub)
-	result, err := episodeModeResult(config, anime, epNo, preferredMode)
+	result, err := episodeModeResult(ctx, config, anime, epNo, preferredMode)
 	if err == nil && len(result.Links) > 0 {
 		return result, nil
 	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ProviderEpisodeResult{}, ctxErr
+	}
 
 	preferredErr := err
 
@@ -711,7 +873,10 @@ func ResolveEpisodeURLForPlayback(config CurdConfig, anime *Anime, epNo int) (Pr
 		animeCopy := *anime
 		fallbackAnime = &animeCopy
 	}
-	fallbackResult, fallbackErr := episodeModeResult(config, fallbackAnime, epNo, fallbackMode)
+	fallbackResult, fallbackErr := episodeModeResult(ctx, config, fallbackAnime, epNo, fallbackMode)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ProviderEpisodeResult{}, ctxErr
+	}
 	if fallbackErr != nil || len(fallbackResult.Links) == 0 {
 		if preferredErr != nil {
 			return ProviderEpisodeResult{}, preferredErr
@@ -850,15 +1015,23 @@ func confidentProviderSearchMatch(options []SelectionOption, anime *Anime, query
 	return SelectionOption{}, false
 }
 
-func findProviderIDForAnime(provider Provider, anime *Anime, mode string) (string, error) {
+// findProviderIDForAnime returns the provider id of anime and whether the
+// match is confident: a stored id, or a search result that clearly wins.
+func findProviderIDForAnime(ctx context.Context, provider Provider, anime *Anime, mode string) (string, bool, error) {
 	currentProviderName, currentProviderID := providerIDForAnime(anime)
 	if currentProviderName == provider.Name() && currentProviderID != "" {
-		return currentProviderID, nil
+		return currentProviderID, true, nil
+	}
+	if alternateID := alternateProviderID(anime, provider.Name()); alternateID != "" {
+		return alternateID, true, nil
+	}
+	if storedID, confident := storedProviderID(anime, provider.Name(), mode); storedID != "" {
+		return storedID, confident, nil
 	}
 
 	query := animeSearchTitle(anime)
 	if query == "" {
-		return "", fmt.Errorf("cannot search %s without an anime title", provider.Name())
+		return "", false, fmt.Errorf("cannot search %s without an anime title", provider.Name())
 	}
 
 	// Build a list of title candidates to try in order. Many providers (e.g.
@@ -883,9 +1056,13 @@ func findProviderIDForAnime(provider Provider, anime *Anime, mode string) (strin
 		return qs
 	}
 
+	confident := false
 	tryQueries := func(qs []string) (string, bool) {
 		for _, q := range qs {
-			options, err := provider.SearchAnime(q, mode)
+			if ctx.Err() != nil {
+				return "", false
+			}
+			options, err := searchProviderAnime(ctx, provider, q, mode)
 			if err != nil || len(options) == 0 {
 				if err != nil {
 					Log(fmt.Sprintf("Provider %s search failed for %q: %v", provider.Name(), q, err))
@@ -896,6 +1073,10 @@ func findProviderIDForAnime(provider Provider, anime *Anime, mode string) (strin
 			if !ok {
 				continue
 			}
+			if anime != nil {
+				match, ok := confidentProviderSearchMatch(options, anime, q)
+				confident = ok && match.Key == option.Key
+			}
 			Log(fmt.Sprintf("Mapped %q (via query %q) to provider %s id %s for %s", animeSearchTitle(anime), q, provider.Name(), option.Key, mode))
 			return option.Key, true
 		}
@@ -905,13 +1086,16 @@ func findProviderIDForAnime(provider Provider, anime *Anime, mode string) (strin
 	// First pass: use cached titles from the anime struct.
 	if anime != nil {
 		if id, ok := tryQueries(buildQueries(anime.Title)); ok {
-			return id, nil
+			return id, confident, nil
 		}
 	} else {
 		if id, ok := tryQueries([]string{query}); ok {
-			return id, nil
+			return id, confident, nil
 		}
 	}
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
 
 	// Second pass: all cached titles failed. Try to get the real romaji/native
 	// titles from AniList and retry. This handles the common case where only the
@@ -931,13 +1115,16 @@ func findProviderIDForAnime(provider Provider, anime *Anime, mode string) (strin
 			if anime != nil {
 				anime.Title = freshTitles
 			}
-			return id, nil
+			return id, confident, nil
 		}
 	} else {
 		Log(fmt.Sprintf("AniList title lookup failed for %q: %v", query, fetchErr))
 	}
 
-	return "", fmt.Errorf("no results for %q", query)
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+	return "", false, fmt.Errorf("no results for %q", query)
 }
 
 func audioFallbackPrompt(preferredMode, fallbackMode string) string {
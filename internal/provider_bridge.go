@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"net/http"
 
 	_ "github.com/wraient/curd/internal/loadproviders"
@@ -57,7 +58,9 @@ func init() {
 		}
 		return "english"
 	}
-	curdhost.SetCookiesForAnimepahe = SetCookiesForAnimepahe
+	curdhost.SolveChallenge = solveSiteChallenge
+	curdhost.CachedChallenge = cachedSiteChallenge
+	curdhost.ForgetChallenge = forgetSiteChallenge
 }
 
 func normalizeTranslationType(mode string) string {
@@ -79,6 +82,8 @@ func toInternalSelectionOptions(options []providers.SelectionOption) []Selection
 			Label:     option.Label,
 			Title:     option.Title,
 			Thumbnail: option.Thumbnail,
+			Year:      option.Year,
+			Episodes:  option.Episodes,
 			ExtraData: option.ExtraData,
 		})
 	}
@@ -96,6 +101,8 @@ func toProviderSelectionOptions(options []SelectionOption) []providers.Selection
 			Label:     option.Label,
 			Title:     option.Title,
 			Thumbnail: option.Thumbnail,
+			Year:      option.Year,
+			Episodes:  option.Episodes,
 			ExtraData: option.ExtraData,
 		})
 	}
@@ -123,6 +130,20 @@ func fromStreamHints(hints map[string]providers.StreamPlaybackHint) map[string]S
 	return result
 }
 
+func toProviderStreamHints(hints map[string]StreamPlaybackHint) map[string]providers.StreamPlaybackHint {
+	if len(hints) == 0 {
+		return nil
+	}
+	result := make(map[string]providers.StreamPlaybackHint, len(hints))
+	for key, hint := range hints {
+		result[key] = providers.StreamPlaybackHint{
+			Referrer: hint.Referrer,
+			Subtitle: hint.Subtitle,
+		}
+	}
+	return result
+}
+
 type providerAdapter struct {
 	inner providers.Provider
 }
@@ -132,43 +153,62 @@ func (a *providerAdapter) Name() string {
 }
 
 func (a *providerAdapter) SearchAnime(query, mode string) ([]SelectionOption, error) {
-	options, err := a.inner.SearchAnime(query, mode)
+	return a.SearchAnimeContext(context.Background(), query, mode)
+}
+
+func (a *providerAdapter) SearchAnimeContext(ctx context.Context, query, mode string) ([]SelectionOption, error) {
+	options, err := providers.SearchAnime(ctx, a.inner, query, mode)
 	return toInternalSelectionOptions(options), err
 }
 
 func (a *providerAdapter) EpisodesList(showID, mode string) ([]string, error) {
-	return a.inner.EpisodesList(showID, mode)
+	return a.EpisodesListContext(context.Background(), showID, mode)
+}
+
+func (a *providerAdapter) EpisodesListContext(ctx context.Context, showID, mode string) ([]string, error) {
+	return providers.EpisodesList(ctx, a.inner, showID, mode)
 }
 
 func (a *providerAdapter) GetEpisodeURL(config CurdConfig, id string, epNo int) ([]string, error) {
-	return a.inner.GetEpisodeURL(toPlaybackConfig(config), id, epNo)
+	return providers.GetEpisodeURL(context.Background(), a.inner, toPlaybackConfig(config), id, epNo)
 }
 
 func (a *providerAdapter) GetEpisodeURLForMode(config CurdConfig, id string, epNo int, mode string) ([]string, error) {
-	if resolver, ok := a.inner.(providers.ModeResolver); ok {
-		return resolver.GetEpisodeURLForMode(toPlaybackConfig(config), id, epNo, mode)
-	}
-	return a.inner.GetEpisodeURL(toPlaybackConfig(config), id, epNo)
+	return providers.GetEpisodeURLForMode(context.Background(), a.inner, toPlaybackConfig(config), id, epNo, mode)
 }
 
 func (a *providerAdapter) GetEpisodeURLForModeWithHints(config CurdConfig, id string, epNo int, mode string) ([]string, map[string]StreamPlaybackHint, error) {
-	if resolver, ok := a.inner.(providers.HintResolver); ok {
-		links, hints, err := resolver.GetEpisodeURLForModeWithHints(toPlaybackConfig(config), id, epNo, mode)
-		return links, fromStreamHints(hints), err
-	}
-	links, err := a.GetEpisodeURLForMode(config, id, epNo, mode)
-	return links, nil, err
+	links, hints, err := providers.GetEpisodeURLForModeWithHints(context.Background(), a.inner, toPlaybackConfig(config), id, epNo, mode)
+	return links, fromStreamHints(hints), err
+}
+
+// GetEpisodeStreams returns typed streams, converting the links and hints of
+// providers that only implement the older interfaces.
+func (a *providerAdapter) GetEpisodeStreams(config CurdConfig, id string, epNo int, mode string) ([]providers.Stream, error) {
+	return a.GetEpisodeStreamsContext(context.Background(), config, id, epNo, mode)
 }
 
-func resolveProviderID(provider Provider, providerID, query string) (string, error) {
+func (a *providerAdapter) GetEpisodeStreamsContext(ctx context.Context, config CurdConfig, id string, epNo int, mode string) ([]providers.Stream, error) {
+	return providers.GetEpisodeStreams(ctx, a.inner, toPlaybackConfig(config), id, epNo, mode)
+}
+
+func resolveProviderID(ctx context.Context, provider Provider, providerID, query string) (string, error) {
 	inner := unwrapProvider(provider)
 	if inner == nil {
 		return providerID, nil
 	}
-	if resolver, ok := inner.(providers.IDResolver); ok {
-		return resolver.ResolveProviderID(providerID, query)
+	return providers.ResolveProviderID(ctx, inner, providerID, query)
+}
+
+// searchListsModes reports whether provider's search results depend on the
+// sub/dub mode. Providers outside the providers package are assumed to.
+func searchListsModes(provider Provider) bool {
+	inner := unwrapProvider(provider)
+	if inner == nil {
+		return true
 	}
-	return providerID, nil
+	searcher, ok := inner.(providers.ModeSearcher)
+	return ok && searcher.SearchListsModes()
 }
 
 func wrapProvider(provider providers.Provider) Provider {
@@ -187,6 +227,7 @@ func applyStreamPlaybackHints(anime *Anime, links []string, hints map[string]Str
 		return
 	}
 	selected := PrioritizeLink(links)
+	anime.Ep.StreamURL = selected
 	if hint, ok := hints[selected]; ok {
 		anime.Ep.StreamReferrer = hint.Referrer
 		anime.Ep.SubtitleURL = hint.Subtitle
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -8,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/wraient/curd/internal/mappingdb"
 	"github.com/wraient/curd/internal/providers/animepahe"
 )
 
@@ -83,7 +85,9 @@ func searchAnimeForMapping(config *CurdConfig, state *providerMappingSearchState
 	if !state.sequential && len(providers) == len(state.allProviders) {
 		return SearchAnime(state.query, mode)
 	}
-	return searchAnimeWithProviders(providers, state.query, mode)
+	return interruptible(func(ctx context.Context) ([]SelectionOption, error) {
+		return searchAnimeWithProviders(ctx, providers, state.query, mode)
+	})
 }
 
 func confirmProviderMatch(option SelectionOption, reason string) bool {
@@ -379,6 +383,7 @@ func applySelectedProviderMapping(config *CurdConfig, anime *Anime, selected Sel
 	} else {
 		anime.ProviderName = configuredProviderNames(config)[0]
 	}
+	recordProviderMapping(anime, anime.ProviderName, anime.ProviderId, config.SubOrDub, mappingdb.Manual, false)
 }
 
 func promptCustomProviderSearchQuery(config *CurdConfig, currentQuery, hint string) (string, bool, bool, error) {
@@ -397,6 +402,15 @@ func ResolveAnimeProviderMapping(config *CurdConfig, anime *Anime, query string,
 }
 
 func resolveAnimeProviderMapping(config *CurdConfig, anime *Anime, query string, anilistEntry *Entry, manualOnly bool) (ProviderMappingOutcome, error) {
+	if applyStoredProviderMapping(config, anime, manualOnly) {
+		return ProviderMappingOK, nil
+	}
+	return searchAnimeProviderMapping(config, anime, query, anilistEntry, manualOnly)
+}
+
+// searchAnimeProviderMapping maps anime by searching the providers, without
+// looking at the mappings recorded before.
+func searchAnimeProviderMapping(config *CurdConfig, anime *Anime, query string, anilistEntry *Entry, manualOnly bool) (ProviderMappingOutcome, error) {
 	state := &providerMappingSearchState{
 		query:        query,
 		allProviders: configuredProviderNames(config),
@@ -445,6 +459,7 @@ func resolveAnimeProviderMapping(config *CurdConfig, anime *Anime, query string,
 			} else {
 				anime.ProviderName = configuredProviderNames(config)[0]
 			}
+			recordProviderMapping(anime, anime.ProviderName, anime.ProviderId, config.SubOrDub, mappingdb.Match, false)
 			return ProviderMappingOK, nil
 		}
 
@@ -586,6 +601,8 @@ func RemapAnimeProviderOnEpisodeFailure(config *CurdConfig, anime *Anime, anilis
 	}
 
 	CurdOut("Could not get an episode link with the current provider mapping.")
+	failedProviderName, failedProviderID := providerIDForAnime(anime)
+	forgetProviderMapping(anime, failedProviderName, failedProviderID, config.SubOrDub)
 	anime.ProviderId = ""
 	anime.ProviderName = ""
 	anime.Ep.NextEpisode = NextEpisode{}
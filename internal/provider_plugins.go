@@ -0,0 +1,31 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/wraient/curd/internal/providers/plugins"
+)
+
+// ProviderPluginDir is where external provider plugins and their manifests live.
+func ProviderPluginDir(config *CurdConfig) string {
+	return filepath.Join(os.ExpandEnv(config.StoragePath), "providers")
+}
+
+// LoadProviderPlugins registers the external provider plugins found in the
+// storage directory. Broken manifests are logged and skipped.
+func LoadProviderPlugins(config *CurdConfig) {
+	names, err := plugins.LoadDir(ProviderPluginDir(config), func(msg string) { Log(msg) })
+	if err != nil {
+		Log(fmt.Sprintf("Provider plugins: %v", err))
+	}
+	if len(names) > 0 {
+		Log(fmt.Sprintf("Loaded provider plugins: %v", names))
+	}
+}
+
+// CloseProviderPlugins stops any running plugin processes.
+func CloseProviderPlugins() {
+	plugins.CloseAll()
+}
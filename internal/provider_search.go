@@ -0,0 +1,270 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// providerSearchHit is one provider's listing of a title in a stacked search.
+type providerSearchHit struct {
+	providerName string
+	option       SelectionOption
+	sub          bool
+	dub          bool
+}
+
+// providerSearchGroup is one title as carried by one or more providers. The
+// first hit, in provider stack order, is the one the user selects; the others
+// are kept as fallbacks.
+type providerSearchGroup struct {
+	title    string
+	year     int
+	episodes int
+	hits     []providerSearchHit
+}
+
+type providerSearchResult struct {
+	hits []providerSearchHit
+	err  error
+}
+
+var (
+	searchEpisodesRegex = regexp.MustCompile(`(?i)\b(\d+)\s*(?:episodes|eps)\b`)
+	searchYearRegex     = regexp.MustCompile(`\b((?:19|20)\d{2})\b`)
+)
+
+// searchProvidersMerged searches every provider at once and merges listings
+// of the same title into one option.
+func searchProvidersMerged(ctx context.Context, providerNames []string, query, mode string) ([]SelectionOption, error) {
+	results := make([]providerSearchResult, len(providerNames))
+	var wg sync.WaitGroup
+	for i, providerName := range providerNames {
+		wg.Add(1)
+		go func(i int, providerName string) {
+			defer wg.Done()
+			results[i] = searchProviderModes(ctx, providerName, query, mode)
+		}(i, providerName)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var searchErrors []string
+	var groups []*providerSearchGroup
+	for i, result := range results {
+		if result.err != nil {
+			Log(fmt.Sprintf("Provider %s search failed for %q: %v", providerNames[i], query, result.err))
+			searchErrors = append(searchErrors, fmt.Sprintf("%s: %v", providerNames[i], result.err))
+			continue
+		}
+		groups = groupProviderSearchHits(groups, result.hits)
+	}
+
+	options := make([]SelectionOption, 0, len(groups))
+	for _, group := range groups {
+		rememberSearchGroup(group)
+		options = append(options, group.selectionOption())
+	}
+	if len(options) == 0 && len(searchErrors) > 0 {
+		return nil, fmt.Errorf("all provider searches failed: %s", strings.Join(searchErrors, "; "))
+	}
+	return options, nil
+}
+
+// searchProviderModes searches one provider in mode and, for providers that
+// list modes separately and only when that finds anything, in the other
+// audio mode to learn which listings carry both.
+func searchProviderModes(ctx context.Context, providerName, query, mode string) providerSearchResult {
+	provider, err := ProviderByName(providerName)
+	if err != nil {
+		return providerSearchResult{err: err}
+	}
+	mode = normalizeTranslationType(mode)
+	options, err := searchProviderAnime(ctx, provider, query, mode)
+	if err != nil || len(options) == 0 {
+		return providerSearchResult{err: err}
+	}
+
+	inAlternate := make(map[string]bool)
+	if searchListsModes(provider) {
+		alternateMode := alternateTranslationType(mode)
+		alternateOptions, err := searchProviderAnime(ctx, provider, query, alternateMode)
+		if err != nil {
+			Log(fmt.Sprintf("Provider %s %s search failed for %q: %v", providerName, alternateMode, query, err))
+		}
+		for _, option := range alternateOptions {
+			inAlternate[option.Key] = true
+		}
+	} else {
+		for _, option := range options {
+			inAlternate[option.Key] = true
+		}
+	}
+
+	hits := make([]providerSearchHit, 0, len(options))
+	seen := make(map[string]struct{})
+	for _, option := range options {
+		if _, exists := seen[option.Key]; exists {
+			continue
+		}
+		seen[option.Key] = struct{}{}
+		hit := providerSearchHit{providerName: providerName, option: option}
+		hit.sub = mode == "sub" || inAlternate[option.Key]
+		hit.dub = mode == "dub" || inAlternate[option.Key]
+		hits = append(hits, hit)
+	}
+	return providerSearchResult{hits: hits}
+}
+
+// groupProviderSearchHits adds hits to groups, joining a group when the
+// normalized title matches and the year and episode count do not disagree.
+// A provider joins each group at most once.
+func groupProviderSearchHits(groups []*providerSearchGroup, hits []providerSearchHit) []*providerSearchGroup {
+	for _, hit := range hits {
+		title, year, episodes := searchOptionFacts(hit.option)
+		var match *providerSearchGroup
+		for _, group := range groups {
+			if group.title == title && factsAgree(group.year, year) && factsAgree(group.episodes, episodes) && !group.hasProvider(hit.providerName) {
+				match = group
+				break
+			}
+		}
+		if match == nil {
+			match = &providerSearchGroup{title: title}
+			groups = append(groups, match)
+		}
+		if match.year == 0 {
+			match.year = year
+		}
+		if match.episodes == 0 {
+			match.episodes = episodes
+		}
+		match.hits = append(match.hits, hit)
+	}
+	return groups
+}
+
+func factsAgree(a, b int) bool {
+	return a == 0 || b == 0 || a == b
+}
+
+// searchOptionFacts returns the normalized title, year and episode count of
+// a provider listing, or zero where the provider does not report them.
+func searchOptionFacts(option SelectionOption) (title string, year, episodes int) {
+	title = normalizeSearchTitle(option.Title)
+	details := option.Label
+	if option.Title != "" {
+		details = strings.Replace(details, option.Title, "", 1)
+	} else {
+		title = normalizeSearchTitle(searchEpisodesRegex.ReplaceAllString(option.Label, ""))
+	}
+	if option.Year > 0 || option.Episodes > 0 {
+		return title, option.Year, option.Episodes
+	}
+	if matches := searchEpisodesRegex.FindStringSubmatch(details); len(matches) > 1 {
+		episodes, _ = strconv.Atoi(matches[1])
+		details = searchEpisodesRegex.ReplaceAllString(details, "")
+	}
+	if matches := searchYearRegex.FindStringSubmatch(details); len(matches) > 1 {
+		year, _ = strconv.Atoi(matches[1])
+	}
+	return title, year, episodes
+}
+
+func (g *providerSearchGroup) hasProvider(providerName string) bool {
+	for _, hit := range g.hits {
+		if hit.providerName == providerName {
+			return true
+		}
+	}
+	return false
+}
+
+// selectionOption presents the group as its first hit, labelled with every
+// provider that carries it and the audio each one has.
+func (g *providerSearchGroup) selectionOption() SelectionOption {
+	primary := g.hits[0]
+	option := primary.option
+	option.Key = QualifyProviderID(primary.providerName, option.Key)
+
+	tags := make([]string, 0, len(g.hits))
+	for _, hit := range g.hits {
+		if option.Thumbnail == "" {
+			option.Thumbnail = hit.option.Thumbnail
+		}
+		tags = append(tags, hit.providerName+" "+hit.audioLabel())
+	}
+	label := strings.TrimSpace(strings.TrimSuffix(option.Label, "["+primary.providerName+"]"))
+	option.Label = fmt.Sprintf("%s [%s]", label, strings.Join(tags, ", "))
+	return option
+}
+
+func (h providerSearchHit) audioLabel() string {
+	switch {
+	case h.sub && h.dub:
+		return "sub/dub"
+	case h.dub:
+		return "dub"
+	default:
+		return "sub"
+	}
+}
+
+// searchAlternates remembers, for every qualified provider id shown in a
+// merged search, the ids of the same title on other providers.
+var (
+	searchAlternatesMu sync.Mutex
+	searchAlternates   = map[string][]string{}
+)
+
+const maxSearchAlternates = 512
+
+func rememberSearchGroup(group *providerSearchGroup) {
+	if len(group.hits) < 2 {
+		return
+	}
+	keys := make([]string, 0, len(group.hits))
+	for _, hit := range group.hits {
+		keys = append(keys, QualifyProviderID(hit.providerName, hit.option.Key))
+	}
+
+	searchAlternatesMu.Lock()
+	defer searchAlternatesMu.Unlock()
+	if len(searchAlternates)+len(keys) > maxSearchAlternates {
+		searchAlternates = map[string][]string{}
+	}
+	for i, key := range keys {
+		others := make([]string, 0, len(keys)-1)
+		others = append(others, keys[:i]...)
+		others = append(others, keys[i+1:]...)
+		searchAlternates[key] = others
+	}
+}
+
+// providerAlternates returns the provider ids remembered as the same title
+// as anime's current mapping, in stack order.
+func providerAlternates(anime *Anime) []string {
+	providerName, providerID := providerIDForAnime(anime)
+	if providerID == "" {
+		return nil
+	}
+	searchAlternatesMu.Lock()
+	defer searchAlternatesMu.Unlock()
+	return searchAlternates[QualifyProviderID(providerName, providerID)]
+}
+
+// alternateProviderID returns anime's id on providerName from a merged
+// search, or "" when none was seen.
+func alternateProviderID(anime *Anime, providerName string) string {
+	for _, alternate := range providerAlternates(anime) {
+		if name, rawID, ok := ParseProviderQualifiedID(alternate); ok && name == providerName {
+			return rawID
+		}
+	}
+	return ""
+}
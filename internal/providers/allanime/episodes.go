@@ -2,6 +2,7 @@ package allanime
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -35,7 +36,7 @@ type episodesResponse struct {
 // }
 
 // episodesList performs the API call and fetches the episodes list
-func getAllAnimeEpisodesList(showID, mode string) ([]string, error) {
+func getAllAnimeEpisodesList(ctx context.Context, showID, mode string) ([]string, error) {
 	preferredMode := providers.NormalizeTranslationType(mode)
 
 	episodesListGql := `query ($showId: String!) { show( _id: $showId ) { _id availableEpisodesDetail }}`
@@ -50,7 +51,7 @@ func getAllAnimeEpisodesList(showID, mode string) ([]string, error) {
 	}
 
 	// Make the HTTP POST request
-	req, err := http.NewRequest("POST", "https://api.mkissa.net/api", bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.mkissa.net/api", bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
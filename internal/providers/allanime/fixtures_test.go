@@ -0,0 +1,101 @@
+package allanime
+
+import (
+	"context"
+	"testing"
+
+	"github.com/wraient/curd/internal/curdhost"
+	"github.com/wraient/curd/internal/providers"
+	"github.com/wraient/curd/internal/providers/cassette"
+)
+
+func TestFixtureSearchAnime(t *testing.T) {
+	previousLanguage := curdhost.AnimeNameLanguage
+	t.Cleanup(func() { curdhost.AnimeNameLanguage = previousLanguage })
+	curdhost.AnimeNameLanguage = func() string { return "english" }
+	cassette.Use(t, "search")
+
+	options, err := searchAllAnime(context.Background(), "Frieren", "sub")
+	if err != nil {
+		t.Fatalf("searchAllAnime: %v", err)
+	}
+	if len(options) != 2 {
+		t.Fatalf("unexpected options %+v", options)
+	}
+	if first := options[0]; first.Key != "ReooPAxPMsHM4KPMY" || first.Label != "Frieren: Beyond Journey's End (28 episodes)" {
+		t.Fatalf("unexpected first option %+v", first)
+	}
+	if second := options[1]; second.Label != "Sousou no Frieren: ●● no Mahou (Unknown episodes)" {
+		t.Fatalf("expected romaji fallback and unknown count, got %+v", second)
+	}
+}
+
+func TestFixtureEpisodesList(t *testing.T) {
+	cassette.Use(t, "episodes")
+
+	episodes, err := getAllAnimeEpisodesList(context.Background(), "ReooPAxPMsHM4KPMY", "sub")
+	if err != nil {
+		t.Fatalf("getAllAnimeEpisodesList: %v", err)
+	}
+	want := []string{"1", "1.5", "2", "3", "9", "10"}
+	if len(episodes) != len(want) {
+		t.Fatalf("unexpected episodes %v", episodes)
+	}
+	for i := range want {
+		if episodes[i] != want[i] {
+			t.Fatalf("unexpected episodes %v", episodes)
+		}
+	}
+}
+
+func TestFixtureEpisodeStreams(t *testing.T) {
+	invalidateAllanimeKeys()
+	t.Cleanup(invalidateAllanimeKeys)
+	// The persisted query carries a time-based aaReq token in extensions.
+	cassette.Use(t, "streams", cassette.IgnoreQuery("extensions"))
+
+	links, hints, err := getAllanimeEpisodeStreamsForMode(context.Background(), "ReooPAxPMsHM4KPMY", "sub", 1)
+	if err != nil {
+		t.Fatalf("getAllanimeEpisodeStreamsForMode: %v", err)
+	}
+	want := []string{
+		"https://vd.mkissa.example/hls/frieren/01/1080/index.m3u8",
+		"https://vd.mkissa.example/hls/frieren/01/720/index.m3u8",
+		"https://cdn.mkissa.example/frieren/sub/1/frieren-01.mp4",
+	}
+	if len(links) != len(want) {
+		t.Fatalf("unexpected links %v", links)
+	}
+	for i := range want {
+		if links[i] != want[i] {
+			t.Fatalf("unexpected links %v", links)
+		}
+	}
+	hint := hints[want[0]]
+	if hint.Referrer != "https://allmanga.to/" || hint.Subtitle != "https://vd.mkissa.example/subs/frieren/01/en.vtt" {
+		t.Fatalf("unexpected hint %+v", hint)
+	}
+}
+
+func TestFixtureEpisodeStreamMetadata(t *testing.T) {
+	invalidateAllanimeKeys()
+	t.Cleanup(invalidateAllanimeKeys)
+	cassette.Use(t, "streams", cassette.IgnoreQuery("extensions"))
+
+	streams, err := (&Provider{}).GetEpisodeStreams(providers.PlaybackConfig{SubOrDub: "sub"}, "ReooPAxPMsHM4KPMY", 1, "sub")
+	if err != nil {
+		t.Fatalf("GetEpisodeStreams: %v", err)
+	}
+	if len(streams) != 3 {
+		t.Fatalf("unexpected streams %+v", streams)
+	}
+	if streams[0].Resolution != 1080 || streams[1].Resolution != 720 {
+		t.Fatalf("expected variant resolutions, got %d and %d", streams[0].Resolution, streams[1].Resolution)
+	}
+	if streams[0].Container != providers.ContainerHLS || streams[2].Container != providers.ContainerMP4 {
+		t.Fatalf("unexpected containers %q and %q", streams[0].Container, streams[2].Container)
+	}
+	if streams[0].Server == "" || streams[0].SubtitleMode != providers.SubtitlesSoft || streams[0].Referrer() != "https://allmanga.to/" {
+		t.Fatalf("unexpected stream metadata %+v", streams[0])
+	}
+}
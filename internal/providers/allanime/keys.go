@@ -1,6 +1,7 @@
 package allanime
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/sha256"
@@ -37,7 +38,7 @@ var (
 	cachedKeysTime time.Time
 )
 
-func getAllanimeKeys() (*allanimeKeys, error) {
+func getAllanimeKeys(ctx context.Context) (*allanimeKeys, error) {
 	keysLock.Lock()
 	defer keysLock.Unlock()
 
@@ -45,7 +46,7 @@ func getAllanimeKeys() (*allanimeKeys, error) {
 		return cachedKeys, nil
 	}
 
-	keys, err := fetchAllanimeKeys()
+	keys, err := fetchAllanimeKeys(ctx)
 	if err != nil {
 		if cachedKeys != nil {
 			return cachedKeys, nil
@@ -73,10 +74,10 @@ func httpClient() *http.Client {
 	return &http.Client{Timeout: 15 * time.Second}
 }
 
-func fetchAllanimeKeys() (*allanimeKeys, error) {
+func fetchAllanimeKeys(ctx context.Context) (*allanimeKeys, error) {
 	client := httpClient()
 
-	req, err := http.NewRequest("GET", allanimeRefr, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", allanimeRefr, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request for mkissa home: %w", err)
 	}
@@ -120,7 +121,7 @@ func fetchAllanimeKeys() (*allanimeKeys, error) {
 		return nil, fmt.Errorf("app js URL not found in mkissa home page")
 	}
 
-	reqApp, err := http.NewRequest("GET", string(appURLMatch), nil)
+	reqApp, err := http.NewRequestWithContext(ctx, "GET", string(appURLMatch), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request for app js: %w", err)
 	}
@@ -156,7 +157,7 @@ func fetchAllanimeKeys() (*allanimeKeys, error) {
 		wg.Add(1)
 		go func(urlStr string) {
 			defer wg.Done()
-			reqC, err := http.NewRequest("GET", urlStr, nil)
+			reqC, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
 			if err != nil {
 				return
 			}
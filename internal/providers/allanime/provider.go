@@ -1,6 +1,8 @@
 package allanime
 
 import (
+	"context"
+
 	"github.com/wraient/curd/internal/providers"
 )
 
@@ -12,22 +14,56 @@ func (p *Provider) Name() string {
 }
 
 func (p *Provider) SearchAnime(query, mode string) ([]providers.SelectionOption, error) {
-	return searchAllAnime(query, mode)
+	return p.SearchAnimeContext(context.Background(), query, mode)
+}
+
+func (p *Provider) SearchAnimeContext(ctx context.Context, query, mode string) ([]providers.SelectionOption, error) {
+	return searchAllAnime(ctx, query, mode)
+}
+
+// SearchListsModes reports that allanime searches sub and dub listings
+// separately.
+func (p *Provider) SearchListsModes() bool {
+	return true
 }
 
 func (p *Provider) EpisodesList(showID, mode string) ([]string, error) {
-	return getAllAnimeEpisodesList(showID, mode)
+	return p.EpisodesListContext(context.Background(), showID, mode)
+}
+
+func (p *Provider) EpisodesListContext(ctx context.Context, showID, mode string) ([]string, error) {
+	return getAllAnimeEpisodesList(ctx, showID, mode)
 }
 
 func (p *Provider) GetEpisodeURL(config providers.PlaybackConfig, id string, epNo int) ([]string, error) {
-	return p.GetEpisodeURLForMode(config, id, epNo, config.SubOrDub)
+	return p.GetEpisodeURLContext(context.Background(), config, id, epNo)
+}
+
+func (p *Provider) GetEpisodeURLContext(ctx context.Context, config providers.PlaybackConfig, id string, epNo int) ([]string, error) {
+	return p.GetEpisodeURLForModeContext(ctx, config, id, epNo, config.SubOrDub)
 }
 
 func (p *Provider) GetEpisodeURLForMode(config providers.PlaybackConfig, id string, epNo int, mode string) ([]string, error) {
-	links, _, err := p.GetEpisodeURLForModeWithHints(config, id, epNo, mode)
+	return p.GetEpisodeURLForModeContext(context.Background(), config, id, epNo, mode)
+}
+
+func (p *Provider) GetEpisodeURLForModeContext(ctx context.Context, config providers.PlaybackConfig, id string, epNo int, mode string) ([]string, error) {
+	links, _, err := p.GetEpisodeURLForModeWithHintsContext(ctx, config, id, epNo, mode)
 	return links, err
 }
 
 func (p *Provider) GetEpisodeURLForModeWithHints(config providers.PlaybackConfig, id string, epNo int, mode string) ([]string, map[string]providers.StreamPlaybackHint, error) {
-	return getAllanimeEpisodeStreamsForMode(id, mode, epNo)
+	return p.GetEpisodeURLForModeWithHintsContext(context.Background(), config, id, epNo, mode)
+}
+
+func (p *Provider) GetEpisodeURLForModeWithHintsContext(ctx context.Context, config providers.PlaybackConfig, id string, epNo int, mode string) ([]string, map[string]providers.StreamPlaybackHint, error) {
+	return getAllanimeEpisodeStreamsForMode(ctx, id, mode, epNo)
+}
+
+func (p *Provider) GetEpisodeStreams(config providers.PlaybackConfig, id string, epNo int, mode string) ([]providers.Stream, error) {
+	return p.GetEpisodeStreamsContext(context.Background(), config, id, epNo, mode)
+}
+
+func (p *Provider) GetEpisodeStreamsContext(ctx context.Context, config providers.PlaybackConfig, id string, epNo int, mode string) ([]providers.Stream, error) {
+	return getAllanimeEpisodeStreams(ctx, id, mode, epNo)
 }
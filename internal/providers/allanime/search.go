@@ -2,6 +2,7 @@ package allanime
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -42,12 +43,12 @@ type response struct {
 // 	fmt.Println(animeList)
 // }
 
-func searchAllAnime(query, mode string) ([]providers.SelectionOption, error) {
+func searchAllAnime(ctx context.Context, query, mode string) ([]providers.SelectionOption, error) {
 	preferredMode := providers.NormalizeTranslationType(mode)
-	return searchAnimeByMode(query, preferredMode, preferredMode)
+	return searchAnimeByMode(ctx, query, preferredMode, preferredMode)
 }
 
-func searchAnimeByMode(query, mode, preferredMode string) ([]providers.SelectionOption, error) {
+func searchAnimeByMode(ctx context.Context, query, mode, preferredMode string) ([]providers.SelectionOption, error) {
 	const (
 		agent        = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/121.0"
 		allanimeRef  = "https://mkissa.to"
@@ -97,7 +98,7 @@ func searchAnimeByMode(query, mode, preferredMode string) ([]providers.Selection
 	}
 
 	// Make the HTTP POST request
-	req, err := http.NewRequest("POST", allanimeAPI, bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", allanimeAPI, bytes.NewBuffer(requestBody))
 	if err != nil {
 		curdhost.Log(fmt.Sprintf("Error creating HTTP request: %v", err))
 		return animeList, err
@@ -140,9 +141,11 @@ func searchAnimeByMode(query, mode, preferredMode string) ([]providers.Selection
 
 	for _, anime := range response.Data.Shows.Edges {
 		var episodesStr string
+		episodeCount := 0
 		if episodes, ok := anime.AvailableEpisodes.(map[string]interface{}); ok {
 			if modeEpisodes, ok := episodes[mode].(float64); ok {
-				episodesStr = fmt.Sprintf("%d", int(modeEpisodes))
+				episodeCount = int(modeEpisodes)
+				episodesStr = fmt.Sprintf("%d", episodeCount)
 			} else {
 				episodesStr = "Unknown"
 			}
@@ -166,6 +169,7 @@ func searchAnimeByMode(query, mode, preferredMode string) ([]providers.Selection
 			Key:       anime.ID,
 			Label:     label,
 			Thumbnail: anime.Thumbnail,
+			Episodes:  episodeCount,
 		})
 	}
 	return animeList, nil
@@ -1,6 +1,7 @@
 package allanime
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -24,6 +25,11 @@ type allanimeResolvedStream struct {
 	Referrer     string
 	SubtitleURL  string
 	QualityScore int
+	// Resolution is the stream height when known; QualityScore may instead
+	// be a server preference.
+	Resolution int
+	Bitrate    int
+	Server     string
 }
 
 var (
@@ -33,15 +39,33 @@ var (
 	allanimeStreamBandwidthPattern  = regexp.MustCompile(`BANDWIDTH=(\d+)`)
 )
 
-func getAllanimeEpisodeStreamsForMode(id, mode string, epNo int) ([]string, map[string]providers.StreamPlaybackHint, error) {
-	sourceUrls, err := fetchEpisodeSourcesForMode(id, mode, epNo)
+func getAllanimeEpisodeStreamsForMode(ctx context.Context, id, mode string, epNo int) ([]string, map[string]providers.StreamPlaybackHint, error) {
+	streams, err := getAllanimeEpisodeStreams(ctx, id, mode, epNo)
 	if err != nil {
 		return nil, nil, err
 	}
-	return getLinksFromEncodedSourceUrls(sourceUrls)
+	links, hints := providers.LinksFromStreams(streams)
+	return links, hints, nil
+}
+
+func getAllanimeEpisodeStreams(ctx context.Context, id, mode string, epNo int) ([]providers.Stream, error) {
+	sourceUrls, err := fetchEpisodeSourcesForMode(ctx, id, mode, epNo)
+	if err != nil {
+		return nil, err
+	}
+	return getStreamsFromEncodedSourceUrls(ctx, sourceUrls)
+}
+
+func getLinksFromEncodedSourceUrls(ctx context.Context, sourceUrls []allanimeSource) ([]string, map[string]providers.StreamPlaybackHint, error) {
+	streams, err := getStreamsFromEncodedSourceUrls(ctx, sourceUrls)
+	if err != nil {
+		return nil, nil, err
+	}
+	links, hints := providers.LinksFromStreams(streams)
+	return links, hints, nil
 }
 
-func getLinksFromEncodedSourceUrls(sourceUrls []allanimeSource) ([]string, map[string]providers.StreamPlaybackHint, error) {
+func getStreamsFromEncodedSourceUrls(ctx context.Context, sourceUrls []allanimeSource) ([]providers.Stream, error) {
 	type providerJob struct {
 		index int
 		name  string
@@ -63,7 +87,7 @@ func getLinksFromEncodedSourceUrls(sourceUrls []allanimeSource) ([]string, map[s
 		})
 	}
 	if len(jobs) == 0 {
-		return nil, nil, fmt.Errorf("no usable Allanime provider sources found")
+		return nil, fmt.Errorf("no usable Allanime provider sources found")
 	}
 
 	type streamResult struct {
@@ -72,6 +96,10 @@ func getLinksFromEncodedSourceUrls(sourceUrls []allanimeSource) ([]string, map[s
 		err     error
 	}
 
+	// Sources still resolving when the collector returns are cancelled.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	results := make(chan streamResult, len(jobs))
 	var wg sync.WaitGroup
 	for _, job := range jobs {
@@ -85,7 +113,10 @@ func getLinksFromEncodedSourceUrls(sourceUrls []allanimeSource) ([]string, map[s
 				decodedProviderID = encodedURL
 			}
 			logAllanime(fmt.Sprintf("Fetching Allanime provider %s via %s", providerName, decodedProviderID))
-			streams, err := resolveAllanimeClockProvider(providerName, decodedProviderID)
+			streams, err := resolveAllanimeClockProvider(ctx, providerName, decodedProviderID)
+			for i := range streams {
+				streams[i].Server = providerName
+			}
 			results <- streamResult{index: idx, streams: streams, err: err}
 		}(job.index, job.name, job.url)
 	}
@@ -106,6 +137,9 @@ func getLinksFromEncodedSourceUrls(sourceUrls []allanimeSource) ([]string, map[s
 				completedCount = len(jobs)
 				break
 			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			completedCount++
 			if res.err != nil {
 				logAllanime(fmt.Sprintf("Allanime provider %s failed: %v", jobs[res.index].name, res.err))
@@ -119,16 +153,18 @@ func getLinksFromEncodedSourceUrls(sourceUrls []allanimeSource) ([]string, map[s
 			}
 		case <-timeout:
 			if successCount > 0 {
-				return buildAllanimeLinkResult(orderedStreams)
+				return buildAllanimeStreams(orderedStreams)
 			}
-			return nil, nil, fmt.Errorf("timeout waiting for Allanime provider links")
+			return nil, fmt.Errorf("timeout waiting for Allanime provider links")
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		}
 	}
 
 	if successCount == 0 {
-		return nil, nil, fmt.Errorf("no valid links found from Allanime providers: %v", collectedErrors)
+		return nil, fmt.Errorf("no valid links found from Allanime providers: %v", collectedErrors)
 	}
-	return buildAllanimeLinkResult(orderedStreams)
+	return buildAllanimeStreams(orderedStreams)
 }
 
 // usableAllanimeSourceURL accepts both legacy encoded sources and direct
@@ -179,7 +215,7 @@ func allanimeSourceURLShape(sourceURL string) string {
 	}
 }
 
-func buildAllanimeLinkResult(orderedStreams [][]allanimeResolvedStream) ([]string, map[string]providers.StreamPlaybackHint, error) {
+func buildAllanimeStreams(orderedStreams [][]allanimeResolvedStream) ([]providers.Stream, error) {
 	allStreams := make([]allanimeResolvedStream, 0)
 	for _, streams := range orderedStreams {
 		allStreams = append(allStreams, streams...)
@@ -188,8 +224,7 @@ func buildAllanimeLinkResult(orderedStreams [][]allanimeResolvedStream) ([]strin
 		return allStreams[i].QualityScore > allStreams[j].QualityScore
 	})
 
-	links := make([]string, 0, len(allStreams))
-	hints := make(map[string]providers.StreamPlaybackHint)
+	result := make([]providers.Stream, 0, len(allStreams))
 	seen := make(map[string]struct{})
 	for _, stream := range allStreams {
 		if stream.URL == "" || isUnreliableAllanimeDirectURL(stream.URL) {
@@ -199,23 +234,32 @@ func buildAllanimeLinkResult(orderedStreams [][]allanimeResolvedStream) ([]strin
 			continue
 		}
 		seen[stream.URL] = struct{}{}
-		links = append(links, stream.URL)
 		referrer := stream.Referrer
 		if referrer == "" {
 			referrer = allanimeGraphQLReferer
 		}
-		hints[stream.URL] = providers.StreamPlaybackHint{
-			Referrer: referrer,
-			Subtitle: stream.SubtitleURL,
+		typed := providers.GuessStream(stream.URL)
+		if stream.Resolution > 0 {
+			typed.Resolution = stream.Resolution
 		}
+		typed.Bitrate = stream.Bitrate
+		if stream.Server != "" {
+			typed.Server = stream.Server
+		}
+		typed.Headers = map[string]string{"Referer": referrer}
+		if stream.SubtitleURL != "" {
+			typed.SubtitleMode = providers.SubtitlesSoft
+			typed.Subtitles = []providers.SubtitleTrack{{URL: stream.SubtitleURL, Lang: "en", Label: "English", Default: true}}
+		}
+		result = append(result, typed)
 	}
-	if len(links) == 0 {
-		return nil, nil, fmt.Errorf("no reliable Allanime streams found")
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no reliable Allanime streams found")
 	}
-	return links, hints, nil
+	return result, nil
 }
 
-func resolveAllanimeClockProvider(providerName, providerPath string) ([]allanimeResolvedStream, error) {
+func resolveAllanimeClockProvider(ctx context.Context, providerName, providerPath string) ([]allanimeResolvedStream, error) {
 	providerPath = normalizeAllanimeProviderPath(providerPath)
 	if strings.HasPrefix(providerPath, "http://") || strings.HasPrefix(providerPath, "https://") {
 		if isUnreliableAllanimeDirectURL(providerPath) {
@@ -231,7 +275,7 @@ func resolveAllanimeClockProvider(providerName, providerPath string) ([]allanime
 			score = 100 // Prefer Ok.ru as it works reliably out of the box in yt-dlp
 		} else if providerName == "Mp4" {
 			score = 80
-			if link := extractMp4UploadLink(providerPath); link != "" {
+			if link := extractMp4UploadLink(ctx, providerPath); link != "" {
 				providerPath = link
 			}
 		} else if providerName == "Fm-Hls" {
@@ -247,7 +291,7 @@ func resolveAllanimeClockProvider(providerName, providerPath string) ([]allanime
 		}}, nil
 	}
 
-	rawBody, videoData, err := fetchAllanimeClockResponse(providerPath)
+	rawBody, videoData, err := fetchAllanimeClockResponse(ctx, providerPath)
 	if err != nil {
 		return nil, err
 	}
@@ -280,13 +324,13 @@ func resolveAllanimeClockProvider(providerName, providerPath string) ([]allanime
 
 		resolutionScore := allanimeResolutionScore(linkMap["resolutionStr"])
 		if linkURL, ok := linkMap["link"].(string); ok && strings.TrimSpace(linkURL) != "" {
-			streams = append(streams, resolveAllanimeLinkURL(linkURL, referrer, subtitleURL, resolutionScore)...)
+			streams = append(streams, resolveAllanimeLinkURL(ctx, linkURL, referrer, subtitleURL, resolutionScore)...)
 			continue
 		}
 
 		if hlsMap, ok := linkMap["hls"].(map[string]interface{}); ok {
 			if hlsURL, ok := hlsMap["url"].(string); ok && strings.TrimSpace(hlsURL) != "" {
-				streams = append(streams, resolveAllanimeLinkURL(hlsURL, referrer, subtitleURL, resolutionScore)...)
+				streams = append(streams, resolveAllanimeLinkURL(ctx, hlsURL, referrer, subtitleURL, resolutionScore)...)
 			}
 		}
 	}
@@ -300,12 +344,12 @@ func resolveAllanimeClockProvider(providerName, providerPath string) ([]allanime
 	return streams, nil
 }
 
-func fetchAllanimeClockResponse(providerPath string) ([]byte, map[string]interface{}, error) {
+func fetchAllanimeClockResponse(ctx context.Context, providerPath string) ([]byte, map[string]interface{}, error) {
 	requestURL, err := allanimeClockURL(providerPath)
 	if err != nil {
 		return nil, nil, err
 	}
-	req, err := http.NewRequest("GET", requestURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -362,7 +406,7 @@ func allanimeClockURL(providerPath string) (string, error) {
 	return "https://allanime.day" + parsed.String(), nil
 }
 
-func resolveAllanimeLinkURL(linkURL, referrer, subtitleURL string, resolutionScore int) []allanimeResolvedStream {
+func resolveAllanimeLinkURL(ctx context.Context, linkURL, referrer, subtitleURL string, resolutionScore int) []allanimeResolvedStream {
 	linkURL = strings.TrimSpace(linkURL)
 	if linkURL == "" {
 		return nil
@@ -374,7 +418,7 @@ func resolveAllanimeLinkURL(linkURL, referrer, subtitleURL string, resolutionSco
 	}
 
 	if strings.Contains(linkURL, "master.m3u8") {
-		if streams, err := fetchAllanimeM3U8VariantStreams(linkURL, referrer, subtitleURL); err == nil && len(streams) > 0 {
+		if streams, err := fetchAllanimeM3U8VariantStreams(ctx, linkURL, referrer, subtitleURL); err == nil && len(streams) > 0 {
 			return streams
 		}
 	}
@@ -388,11 +432,12 @@ func resolveAllanimeLinkURL(linkURL, referrer, subtitleURL string, resolutionSco
 		Referrer:     referrer,
 		SubtitleURL:  subtitleURL,
 		QualityScore: qualityScore,
+		Resolution:   qualityScore,
 	}}
 }
 
-func fetchAllanimeM3U8VariantStreams(masterURL, referrer, subtitleURL string) ([]allanimeResolvedStream, error) {
-	req, err := http.NewRequest("GET", masterURL, nil)
+func fetchAllanimeM3U8VariantStreams(ctx context.Context, masterURL, referrer, subtitleURL string) ([]allanimeResolvedStream, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", masterURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -433,6 +478,7 @@ func fetchAllanimeM3U8VariantStreams(masterURL, referrer, subtitleURL string) ([
 			continue
 		}
 		qualityScore := parseAllanimeStreamInfScore(line)
+		height, bitrate := parseAllanimeStreamInf(line)
 		i++
 		for i < len(lines) {
 			nextLine := strings.TrimSpace(lines[i])
@@ -453,6 +499,8 @@ func fetchAllanimeM3U8VariantStreams(masterURL, referrer, subtitleURL string) ([
 				Referrer:     referrer,
 				SubtitleURL:  subtitleURL,
 				QualityScore: qualityScore,
+				Resolution:   height,
+				Bitrate:      bitrate,
 			})
 			break
 		}
@@ -481,6 +529,17 @@ func parseAllanimeStreamInfScore(line string) int {
 	return 0
 }
 
+// parseAllanimeStreamInf reads the height and bandwidth of a variant.
+func parseAllanimeStreamInf(line string) (height, bitrate int) {
+	if match := allanimeStreamResolutionPattern.FindStringSubmatch(line); len(match) == 3 {
+		height, _ = strconv.Atoi(match[2])
+	}
+	if match := allanimeStreamBandwidthPattern.FindStringSubmatch(line); len(match) == 2 {
+		bitrate, _ = strconv.Atoi(match[1])
+	}
+	return height, bitrate
+}
+
 func resolveAllanimeRelativeURL(baseURL, uri string) string {
 	uri = strings.TrimSpace(uri)
 	if strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://") {
@@ -509,14 +568,14 @@ func extractAllanimeClockSubtitle(rawBody []byte) string {
 	return ""
 }
 
-func extractMp4UploadLink(embedURL string) string {
-	req, err := http.NewRequest("GET", embedURL, nil)
+func extractMp4UploadLink(ctx context.Context, embedURL string) string {
+	req, err := http.NewRequestWithContext(ctx, "GET", embedURL, nil)
 	if err != nil {
 		return ""
 	}
 	req.Header.Set("User-Agent", allanimeUserAgent)
 	req.Header.Set("Referer", allanimeGraphQLReferer)
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := httpClient()
 	resp, err := client.Do(req)
 	if err != nil {
 		return ""
@@ -570,6 +629,7 @@ func streamsFromPlainURLs(urls []string, referrer, subtitleURL string, qualitySc
 			Referrer:     referrer,
 			SubtitleURL:  subtitleURL,
 			QualityScore: score,
+			Resolution:   score,
 		})
 	}
 	return streams
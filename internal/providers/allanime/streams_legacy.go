@@ -2,6 +2,7 @@ package allanime
 
 import (
 	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/base64"
@@ -336,21 +337,21 @@ func extractFilemoonLinks(videoData map[string]interface{}) []string {
 // Returns:
 // - []string: a list of links for specified episode.
 // - error: an error if the episode is not found or if there is an issue during the search.
-func getAllanimeEpisodeURL(config providers.PlaybackConfig, id string, epNo int) ([]string, error) {
+func getAllanimeEpisodeURL(ctx context.Context, config providers.PlaybackConfig, id string, epNo int) ([]string, error) {
 	preferredMode := providers.NormalizeTranslationType(config.SubOrDub)
-	return getAllanimeEpisodeURLForMode(id, preferredMode, epNo)
+	return getAllanimeEpisodeURLForMode(ctx, id, preferredMode, epNo)
 }
 
-func getAllanimeEpisodeURLForMode(id, mode string, epNo int) ([]string, error) {
-	return getEpisodeURLForMode(id, providers.NormalizeTranslationType(mode), epNo)
+func getAllanimeEpisodeURLForMode(ctx context.Context, id, mode string, epNo int) ([]string, error) {
+	return getEpisodeURLForMode(ctx, id, providers.NormalizeTranslationType(mode), epNo)
 }
 
-func fetchAllanimeEpisodeSources(id, mode string, epNo int) ([]allanimeSource, error) {
-	return fetchEpisodeSourcesForMode(id, providers.NormalizeTranslationType(mode), epNo)
+func fetchAllanimeEpisodeSources(ctx context.Context, id, mode string, epNo int) ([]allanimeSource, error) {
+	return fetchEpisodeSourcesForMode(ctx, id, providers.NormalizeTranslationType(mode), epNo)
 }
 
-func fetchEpisodeSourcesForMode(id, mode string, epNo int) ([]allanimeSource, error) {
-	keys, err := getAllanimeKeys()
+func fetchEpisodeSourcesForMode(ctx context.Context, id, mode string, epNo int) ([]allanimeSource, error) {
+	keys, err := getAllanimeKeys(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch allanime keys: %w", err)
 	}
@@ -388,7 +389,7 @@ func fetchEpisodeSourcesForMode(id, mode string, epNo int) ([]allanimeSource, er
 
 	persistedURL := allanimeAPI + "?variables=" + url.QueryEscape(string(variablesJSON)) + "&extensions=" + url.QueryEscape(string(extensionsJSON))
 
-	req, err := http.NewRequest("GET", persistedURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", persistedURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create persisted query request: %w", err)
 	}
@@ -431,7 +432,7 @@ func fetchEpisodeSourcesForMode(id, mode string, epNo int) ([]allanimeSource, er
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
 
-		req, err := http.NewRequest("POST", allanimeAPI, bytes.NewBuffer(requestBody))
+		req, err := http.NewRequestWithContext(ctx, "POST", allanimeAPI, bytes.NewBuffer(requestBody))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %w", err)
 		}
@@ -489,8 +490,8 @@ func logAllanimeEpisodeResponse(stage, showID, mode string, epNo int, body []byt
 	logAllanime(fmt.Sprintf("Allanime %s returned no sources for show=%q mode=%q episode=%d; response=%s", stage, showID, providers.NormalizeTranslationType(mode), epNo, snippet))
 }
 
-func getEpisodeURLForMode(id, mode string, epNo int) ([]string, error) {
-	links, _, err := getAllanimeEpisodeStreamsForMode(id, mode, epNo)
+func getEpisodeURLForMode(ctx context.Context, id, mode string, epNo int) ([]string, error) {
+	links, _, err := getAllanimeEpisodeStreamsForMode(ctx, id, mode, epNo)
 	return links, err
 }
 
@@ -1,8 +1,14 @@
 package allanime
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestExpandWixmpLinksMatchesAniCLI(t *testing.T) {
@@ -29,7 +35,7 @@ func TestGetLinksFromEncodedSourceUrlsSkipsUnreliableFast4speed(t *testing.T) {
 		},
 	}
 
-	_, _, err := getLinksFromEncodedSourceUrls(sourceUrls)
+	_, _, err := getLinksFromEncodedSourceUrls(context.Background(), sourceUrls)
 	if err == nil {
 		t.Fatal("expected error when only direct fast4speed source is available")
 	}
@@ -46,7 +52,7 @@ func TestGetLinksFromEncodedSourceUrlsUsesDirectSource(t *testing.T) {
 		},
 	}
 
-	links, _, err := getLinksFromEncodedSourceUrls(sourceUrls)
+	links, _, err := getLinksFromEncodedSourceUrls(context.Background(), sourceUrls)
 	if err != nil {
 		t.Fatalf("getLinksFromEncodedSourceUrls() error = %v", err)
 	}
@@ -55,6 +61,31 @@ func TestGetLinksFromEncodedSourceUrlsUsesDirectSource(t *testing.T) {
 	}
 }
 
+func TestGetStreamsFromEncodedSourceUrlsStopsWhenCancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	sourceUrls := []allanimeSource{{SourceUrl: server.URL + "/embed.mp4", SourceName: "Mp4", Priority: 7}}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := getStreamsFromEncodedSourceUrls(ctx, sourceUrls)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("cancelled resolution took %s", elapsed)
+	}
+}
+
 func TestAllanimeClockURLKeepsCurrentDefaultEndpoint(t *testing.T) {
 	t.Parallel()
 
@@ -117,7 +148,10 @@ func TestGetAAReqAndDecryptTobeparsed(t *testing.T) {
 }
 
 func TestLiveFetchMkissaSources(t *testing.T) {
-	sources, err := fetchAllanimeEpisodeSources("ReooPAxPMsHM4KPMY", "sub", 1)
+	if os.Getenv("CURD_LIVE_ALLANIME_TEST") != "1" {
+		t.Skip("set CURD_LIVE_ALLANIME_TEST=1 to run live allanime stream verification")
+	}
+	sources, err := fetchAllanimeEpisodeSources(context.Background(), "ReooPAxPMsHM4KPMY", "sub", 1)
 	if err != nil {
 		t.Fatalf("fetchAllanimeEpisodeSources() failed: %v", err)
 	}
@@ -128,7 +162,10 @@ func TestLiveFetchMkissaSources(t *testing.T) {
 }
 
 func TestLiveFetchMkissaStreams(t *testing.T) {
-	links, hints, err := getAllanimeEpisodeStreamsForMode("ReooPAxPMsHM4KPMY", "sub", 1)
+	if os.Getenv("CURD_LIVE_ALLANIME_TEST") != "1" {
+		t.Skip("set CURD_LIVE_ALLANIME_TEST=1 to run live allanime stream verification")
+	}
+	links, hints, err := getAllanimeEpisodeStreamsForMode(context.Background(), "ReooPAxPMsHM4KPMY", "sub", 1)
 	if err != nil {
 		t.Fatalf("getAllanimeEpisodeStreamsForMode() failed: %v", err)
 	}
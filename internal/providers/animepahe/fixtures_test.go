@@ -0,0 +1,67 @@
+package animepahe
+
+import (
+	"testing"
+
+	"github.com/wraient/curd/internal/providers"
+	"github.com/wraient/curd/internal/providers/cassette"
+)
+
+const frierenProviderID = "5498:a4b1e9d2-6c1f-4f7e-b0a3-2d5c8e7f9a10"
+
+// useAnimepaheCassette replays name with the DDoS-Guard bypass marked as done,
+// since cassettes never contain the browser challenge.
+func useAnimepaheCassette(t *testing.T, name string) {
+	t.Helper()
+	previousBypassed := animepaheCookiesBypassed
+	t.Cleanup(func() { animepaheCookiesBypassed = previousBypassed })
+	animepaheCookiesBypassed = true
+	cassette.Use(t, name)
+}
+
+func TestFixtureSearchAnime(t *testing.T) {
+	useAnimepaheCassette(t, "search")
+
+	options, err := (&Provider{}).SearchAnime("Frieren", "sub")
+	if err != nil {
+		t.Fatalf("SearchAnime: %v", err)
+	}
+	if len(options) != 2 {
+		t.Fatalf("unexpected options %+v", options)
+	}
+	first := options[0]
+	if first.Key != frierenProviderID || first.Label != "Sousou no Frieren (28 episodes) [animepahe]" {
+		t.Fatalf("unexpected first option %+v", first)
+	}
+	if item, ok := first.ExtraData.(SearchItem); !ok || item.Year != 2023 {
+		t.Fatalf("unexpected extra data %+v", first.ExtraData)
+	}
+}
+
+func TestFixtureEpisodesListFollowsPages(t *testing.T) {
+	useAnimepaheCassette(t, "episodes")
+
+	episodes, err := (&Provider{}).EpisodesList(frierenProviderID, "sub")
+	if err != nil {
+		t.Fatalf("EpisodesList: %v", err)
+	}
+	if len(episodes) != 3 || episodes[0] != "1" || episodes[2] != "3" {
+		t.Fatalf("unexpected episodes %v", episodes)
+	}
+}
+
+func TestFixtureEpisodeStreams(t *testing.T) {
+	useAnimepaheCassette(t, "streams")
+
+	links, err := (&Provider{}).GetEpisodeURLForMode(providers.PlaybackConfig{}, frierenProviderID, 2, "sub")
+	if err != nil {
+		t.Fatalf("GetEpisodeURLForMode: %v", err)
+	}
+	want := []string{
+		"https://eu-11.files.nextcdn.org/stream/11/9f3c1a1080/uwu.m3u8",
+		"https://eu-11.files.nextcdn.org/stream/11/9f3c1a0720/uwu.m3u8",
+	}
+	if len(links) != len(want) || links[0] != want[0] || links[1] != want[1] {
+		t.Fatalf("expected sub streams by resolution, got %v", links)
+	}
+}
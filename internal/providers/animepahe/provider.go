@@ -1,73 +1,29 @@
 package animepahe
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
-	"time"
-
-	"github.com/go-rod/rod"
-	"github.com/go-rod/rod/lib/launcher"
-	"github.com/go-rod/stealth"
 
 	"github.com/wraient/curd/internal/curdhost"
 	"github.com/wraient/curd/internal/providers"
 )
 
+const animepaheSiteURL = "https://animepahe.pw/"
+
 var animepaheCookiesBypassed bool
 var animepaheUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
-var solveAnimepaheBrowserChallenge = solveAnimepaheBrowserChallengeWithRod
-
-func getUserAgentFilePath() string {
-	return filepath.Join(curdhost.StoragePath(), "animepahe_ua.txt")
-}
-
-func saveUserAgent(ua string) {
-	os.WriteFile(getUserAgentFilePath(), []byte(ua), 0644)
-	animepaheUserAgent = ua
-}
-
-func loadUserAgent() {
-	b, err := os.ReadFile(getUserAgentFilePath())
-	if err == nil && len(b) > 0 {
-		animepaheUserAgent = string(b)
-	}
-}
-
-func getCookieFilePath() string {
-	return filepath.Join(curdhost.StoragePath(), "animepahe_cookies.json")
-}
-
-func saveCookies(cookies []*http.Cookie) {
-	cookieFilePath := getCookieFilePath()
-	os.MkdirAll(filepath.Dir(cookieFilePath), 0755)
-	b, _ := json.Marshal(cookies)
-	os.WriteFile(cookieFilePath, b, 0644)
-}
+var solveAnimepaheBrowserChallenge = solveAnimepaheChallengeWithHost
 
-func loadCookies() []*http.Cookie {
-	cookieFilePath := getCookieFilePath()
-	b, err := os.ReadFile(cookieFilePath)
-	if err != nil {
-		return nil
-	}
-	var cookies []*http.Cookie
-	if err := json.Unmarshal(b, &cookies); err != nil {
-		return nil
-	}
-	return cookies
-}
-
-func newAnimepaheAPIRequest(rawURL string) (*http.Request, error) {
-	req, err := http.NewRequest("GET", rawURL, nil)
+func newAnimepaheAPIRequest(ctx context.Context, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -80,11 +36,11 @@ func newAnimepaheAPIRequest(rawURL string) (*http.Request, error) {
 
 // NewPageRequest builds an HTTP request for animepahe HTML pages.
 func NewPageRequest(rawURL string) (*http.Request, error) {
-	return newAnimepahePageRequest(rawURL)
+	return newAnimepahePageRequest(context.Background(), rawURL)
 }
 
-func newAnimepahePageRequest(rawURL string) (*http.Request, error) {
-	req, err := http.NewRequest("GET", rawURL, nil)
+func newAnimepahePageRequest(ctx context.Context, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -94,8 +50,8 @@ func newAnimepahePageRequest(rawURL string) (*http.Request, error) {
 	return req, nil
 }
 
-func checkCookiesValid() bool {
-	req, err := newAnimepaheAPIRequest("https://animepahe.pw/api?m=search&q=test")
+func checkCookiesValid(ctx context.Context) bool {
+	req, err := newAnimepaheAPIRequest(ctx, "https://animepahe.pw/api?m=search&q=test")
 	if err != nil {
 		return false
 	}
@@ -138,24 +94,14 @@ func isAnimepaheChallengeBody(body []byte) bool {
 		strings.Contains(normalized, "checking your browser")
 }
 
-func (p *Provider) ensureBypass() (err error) {
-	defer func() {
-		if recovered := recover(); recovered != nil {
-			err = fmt.Errorf("failed to solve Animepahe DDoS-Guard challenge: %v", recovered)
-		}
-	}()
-
+func (p *Provider) ensureBypass(ctx context.Context) error {
 	if animepaheCookiesBypassed {
 		return nil
 	}
 
-	loadUserAgent()
-	cookies := loadCookies()
-	if len(cookies) > 0 {
-		u, _ := url.Parse("https://animepahe.pw")
-		curdhost.SetCookiesForAnimepahe(u, cookies)
-
-		if checkCookiesValid() {
+	if clearance, ok := curdhost.CachedChallenge(animepaheSiteURL); ok {
+		useAnimepaheUserAgent(clearance.UserAgent)
+		if checkCookiesValid(ctx) {
 			animepaheCookiesBypassed = true
 			curdhost.Log("Successfully restored Animepahe session from cache.")
 			return nil
@@ -163,18 +109,15 @@ func (p *Provider) ensureBypass() (err error) {
 		curdhost.Log("Cached Animepahe session expired. Requesting new session...")
 	}
 
-	return p.refreshBypass()
+	return p.refreshBypass(ctx)
 }
 
-func (p *Provider) refreshBypass() error {
-	u, _ := url.Parse("https://animepahe.pw")
+func (p *Provider) refreshBypass(ctx context.Context) error {
 	animepaheCookiesBypassed = false
-	if err := os.Remove(getCookieFilePath()); err != nil && !os.IsNotExist(err) {
-		curdhost.Log(fmt.Sprintf("Failed to remove cached Animepahe cookies: %v", err))
-	}
+	curdhost.ForgetChallenge(animepaheSiteURL)
 
-	curdhost.Out("Animepahe needs a browser challenge before Curd can search or play. Starting a temporary headless browser...")
-	httpCookies, ua, err := solveAnimepaheBrowserChallenge()
+	curdhost.Out("Animepahe needs a browser challenge before Curd can search or play. Solving it in the challenge browser...")
+	httpCookies, ua, err := solveAnimepaheBrowserChallenge(ctx)
 	if err != nil {
 		curdhost.Out("Animepahe browser challenge failed. Try again later or switch provider.")
 		return fmt.Errorf("animepahe browser challenge failed: %w", err)
@@ -182,54 +125,34 @@ func (p *Provider) refreshBypass() error {
 	if len(httpCookies) == 0 {
 		return fmt.Errorf("animepahe browser challenge returned no cookies")
 	}
-	curdhost.SetCookiesForAnimepahe(u, httpCookies)
-	saveUserAgent(ua)
+	useAnimepaheUserAgent(ua)
 
-	if !checkCookiesValid() {
+	if !checkCookiesValid(ctx) {
+		curdhost.ForgetChallenge(animepaheSiteURL)
 		return fmt.Errorf("bypassed cookies are still invalid")
 	}
 
-	saveCookies(httpCookies)
 	animepaheCookiesBypassed = true
 	curdhost.Out("Successfully bypassed DDoS-Guard.")
 	return nil
 }
 
-func solveAnimepaheBrowserChallengeWithRod() (httpCookies []*http.Cookie, userAgent string, err error) {
-	if err := rod.Try(func() {
-		l := launcher.New().Headless(false)
-		defer l.Cleanup()
-		browser := rod.New().ControlURL(l.MustLaunch()).MustConnect()
-		defer browser.MustClose()
-
-		page := stealth.MustPage(browser)
-		page.MustNavigate("https://animepahe.pw/")
-		page.MustWaitLoad()
-
-		userAgent = page.MustEval("() => navigator.userAgent").Str()
-
-		for i := 0; i < 30; i++ {
-			info, err := page.Info()
-			if err == nil && info.Title != "DDoS-Guard" && info.Title != "Just a moment..." && info.Title != "" {
-				break
-			}
-			time.Sleep(500 * time.Millisecond)
-		}
-
-		rodCookies, err := page.Cookies(nil)
-		if err != nil {
-			panic(err)
-		}
-		for _, cookie := range rodCookies {
-			httpCookies = append(httpCookies, &http.Cookie{
-				Name:  cookie.Name,
-				Value: cookie.Value,
-			})
-		}
-	}); err != nil {
+// solveAnimepaheChallengeWithHost clears DDoS-Guard through the challenge
+// service the host shares between providers.
+func solveAnimepaheChallengeWithHost(ctx context.Context) ([]*http.Cookie, string, error) {
+	clearance, err := curdhost.SolveChallenge(ctx, animepaheSiteURL)
+	if err != nil {
 		return nil, "", err
 	}
-	return httpCookies, userAgent, nil
+	return clearance.Cookies, clearance.UserAgent, nil
+}
+
+// useAnimepaheUserAgent switches requests to the user agent the clearance
+// cookies were issued to.
+func useAnimepaheUserAgent(ua string) {
+	if ua != "" {
+		animepaheUserAgent = ua
+	}
 }
 
 type Provider struct{}
@@ -308,6 +231,10 @@ func StableProviderID(providerID string) string {
 }
 
 func (p *Provider) ResolveProviderID(providerID, query string) (string, error) {
+	return p.ResolveProviderIDContext(context.Background(), providerID, query)
+}
+
+func (p *Provider) ResolveProviderIDContext(ctx context.Context, providerID, query string) (string, error) {
 	animeRef := ParseProviderID(providerID)
 	query = strings.TrimSpace(query)
 	if query == "" {
@@ -317,9 +244,9 @@ func (p *Provider) ResolveProviderID(providerID, query string) (string, error) {
 		return "", fmt.Errorf("cannot resolve animepahe provider id %q without an anime title", providerID)
 	}
 
-	options, err := p.SearchAnime(query, "")
+	options, err := p.SearchAnimeContext(ctx, query, "")
 	if err != nil {
-		if animeRef.Session != "" {
+		if ctx.Err() == nil && animeRef.Session != "" {
 			curdhost.Log(fmt.Sprintf("Animepahe provider id refresh failed for %q, using existing session: %v", providerID, err))
 			return providerID, nil
 		}
@@ -357,13 +284,17 @@ type animepaheSearchResponse struct {
 }
 
 func (p *Provider) SearchAnime(query, mode string) ([]providers.SelectionOption, error) {
-	if err := p.ensureBypass(); err != nil {
+	return p.SearchAnimeContext(context.Background(), query, mode)
+}
+
+func (p *Provider) SearchAnimeContext(ctx context.Context, query, mode string) ([]providers.SelectionOption, error) {
+	if err := p.ensureBypass(ctx); err != nil {
 		return nil, err
 	}
 	// animepahe doesn't distinguish sub/dub at the search level
 	searchUrl := fmt.Sprintf("https://animepahe.pw/api?m=search&q=%s", url.QueryEscape(query))
 
-	req, err := newAnimepaheAPIRequest(searchUrl)
+	req, err := newAnimepaheAPIRequest(ctx, searchUrl)
 	if err != nil {
 		return nil, err
 	}
@@ -396,6 +327,8 @@ func (p *Provider) SearchAnime(query, mode string) ([]providers.SelectionOption,
 			Label:     label,
 			Key:       formatAnimepaheProviderID(item),
 			Thumbnail: item.Poster,
+			Year:      item.Year,
+			Episodes:  item.Episodes,
 			ExtraData: item,
 		})
 	}
@@ -417,7 +350,11 @@ type animepaheEpisodesResponse struct {
 }
 
 func (p *Provider) EpisodesList(showID, mode string) ([]string, error) {
-	if err := p.ensureBypass(); err != nil {
+	return p.EpisodesListContext(context.Background(), showID, mode)
+}
+
+func (p *Provider) EpisodesListContext(ctx context.Context, showID, mode string) ([]string, error) {
+	if err := p.ensureBypass(ctx); err != nil {
 		return nil, err
 	}
 	animeRef := ParseProviderID(showID)
@@ -432,7 +369,7 @@ func (p *Provider) EpisodesList(showID, mode string) ([]string, error) {
 
 	for {
 		epUrl := fmt.Sprintf("https://animepahe.pw/api?m=release&id=%s&sort=episode_asc&page=%d", apiID, page)
-		req, err := newAnimepaheAPIRequest(epUrl)
+		req, err := newAnimepaheAPIRequest(ctx, epUrl)
 		if err != nil {
 			return nil, err
 		}
@@ -452,7 +389,7 @@ func (p *Provider) EpisodesList(showID, mode string) ([]string, error) {
 				return nil, fmt.Errorf("animepahe episode list is still blocked by DDoS-Guard after refreshing browser session")
 			}
 			curdhost.Log("Animepahe episode list returned DDoS-Guard challenge. Refreshing browser session...")
-			if err := p.refreshBypass(); err != nil {
+			if err := p.refreshBypass(ctx); err != nil {
 				return nil, err
 			}
 			challengeRetried = true
@@ -488,11 +425,19 @@ func (p *Provider) EpisodesList(showID, mode string) ([]string, error) {
 }
 
 func (p *Provider) GetEpisodeURL(config providers.PlaybackConfig, id string, epNo int) ([]string, error) {
-	return p.GetEpisodeURLForMode(config, id, epNo, config.SubOrDub)
+	return p.GetEpisodeURLForModeContext(context.Background(), config, id, epNo, config.SubOrDub)
+}
+
+func (p *Provider) GetEpisodeURLContext(ctx context.Context, config providers.PlaybackConfig, id string, epNo int) ([]string, error) {
+	return p.GetEpisodeURLForModeContext(ctx, config, id, epNo, config.SubOrDub)
 }
 
 func (p *Provider) GetEpisodeURLForMode(config providers.PlaybackConfig, id string, epNo int, mode string) ([]string, error) {
-	if err := p.ensureBypass(); err != nil {
+	return p.GetEpisodeURLForModeContext(context.Background(), config, id, epNo, mode)
+}
+
+func (p *Provider) GetEpisodeURLForModeContext(ctx context.Context, config providers.PlaybackConfig, id string, epNo int, mode string) ([]string, error) {
+	if err := p.ensureBypass(ctx); err != nil {
 		return nil, err
 	}
 	animeRef := ParseProviderID(id)
@@ -503,7 +448,7 @@ func (p *Provider) GetEpisodeURLForMode(config providers.PlaybackConfig, id stri
 
 	// 1. Get episode session ID
 	// Map the 1-based Anilist episode number to Animepahe's actual episode number
-	eps, err := p.EpisodesList(id, "")
+	eps, err := p.EpisodesListContext(ctx, id, "")
 	if err != nil {
 		return nil, err
 	}
@@ -521,7 +466,7 @@ func (p *Provider) GetEpisodeURLForMode(config providers.PlaybackConfig, id stri
 	page := 1
 	for {
 		reqUrl := fmt.Sprintf("https://animepahe.pw/api?m=release&id=%s&sort=episode_asc&page=%d", apiID, page)
-		req, err := newAnimepaheAPIRequest(reqUrl)
+		req, err := newAnimepaheAPIRequest(ctx, reqUrl)
 		if err != nil {
 			return nil, err
 		}
@@ -566,7 +511,7 @@ func (p *Provider) GetEpisodeURLForMode(config providers.PlaybackConfig, id stri
 	// player page: https://animepahe.pw/play/<anime_session>/<episode_session>
 	// stream links in player page: <button type=\"button\" data-src=\"https://kwik.cx/e/Jwd0hMNswksj\"
 	playerUrl := fmt.Sprintf("https://animepahe.pw/play/%s/%s", animeRef.Session, episodeSession)
-	req, err := newAnimepahePageRequest(playerUrl)
+	req, err := newAnimepahePageRequest(ctx, playerUrl)
 	if err != nil {
 		return nil, err
 	}
@@ -656,7 +601,7 @@ func (p *Provider) GetEpisodeURLForMode(config providers.PlaybackConfig, id stri
 
 	var finalLinks []string
 	for _, kwikLink := range links {
-		m3u8, err := p.extractKwikM3u8(kwikLink)
+		m3u8, err := p.extractKwikM3u8(ctx, kwikLink)
 		if err == nil && m3u8 != "" {
 			finalLinks = append(finalLinks, m3u8)
 		}
@@ -669,8 +614,8 @@ func (p *Provider) GetEpisodeURLForMode(config providers.PlaybackConfig, id stri
 	return finalLinks, nil
 }
 
-func (p *Provider) extractKwikM3u8(kwikUrl string) (string, error) {
-	req, err := newAnimepahePageRequest(kwikUrl)
+func (p *Provider) extractKwikM3u8(ctx context.Context, kwikUrl string) (string, error) {
+	req, err := newAnimepahePageRequest(ctx, kwikUrl)
 	if err != nil {
 		return "", err
 	}
@@ -1,13 +1,15 @@
 package animepahe
 
 import (
+	"context"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/cookiejar"
-	"net/url"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/wraient/curd/internal/curdhost"
 )
@@ -39,7 +41,8 @@ func withAnimepaheTestHooks(t *testing.T, client *http.Client, solver func() ([]
 	previousClient := curdhost.HTTPClient
 	previousLog := curdhost.Log
 	previousOut := curdhost.Out
-	previousCookies := curdhost.SetCookiesForAnimepahe
+	previousCached := curdhost.CachedChallenge
+	previousForget := curdhost.ForgetChallenge
 	previousSolver := solveAnimepaheBrowserChallenge
 	previousBypassed := animepaheCookiesBypassed
 	previousStoragePath := curdhost.StoragePath
@@ -48,7 +51,8 @@ func withAnimepaheTestHooks(t *testing.T, client *http.Client, solver func() ([]
 		curdhost.HTTPClient = previousClient
 		curdhost.Log = previousLog
 		curdhost.Out = previousOut
-		curdhost.SetCookiesForAnimepahe = previousCookies
+		curdhost.CachedChallenge = previousCached
+		curdhost.ForgetChallenge = previousForget
 		solveAnimepaheBrowserChallenge = previousSolver
 		animepaheCookiesBypassed = previousBypassed
 		curdhost.StoragePath = previousStoragePath
@@ -57,8 +61,12 @@ func withAnimepaheTestHooks(t *testing.T, client *http.Client, solver func() ([]
 	curdhost.HTTPClient = func() *http.Client { return client }
 	curdhost.Log = func(string) {}
 	curdhost.Out = func(string) {}
-	curdhost.SetCookiesForAnimepahe = func(*url.URL, []*http.Cookie) {}
-	solveAnimepaheBrowserChallenge = solver
+	curdhost.CachedChallenge = func(string) (curdhost.Clearance, bool) { return curdhost.Clearance{}, false }
+	curdhost.ForgetChallenge = func(string) {}
+	solveAnimepaheBrowserChallenge = func(context.Context) ([]*http.Cookie, string, error) {
+		cookies, err := solver()
+		return cookies, "", err
+	}
 	curdhost.StoragePath = func() string { return t.TempDir() }
 }
 
@@ -182,3 +190,23 @@ func TestAnimepaheEpisodesListLiveOnePiece(t *testing.T) {
 		t.Fatalf("expected One Piece to return a large live episode list, got %d episodes", len(episodes))
 	}
 }
+
+func TestAnimepaheCancelStopsBrowserChallenge(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		t.Fatalf("unexpected request to %s", req.URL)
+		return nil, nil
+	})}
+	withAnimepaheTestHooks(t, client, nil)
+	animepaheCookiesBypassed = false
+	solveAnimepaheBrowserChallenge = func(ctx context.Context) ([]*http.Cookie, string, error) {
+		<-ctx.Done()
+		return nil, "", ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := (&Provider{}).EpisodesListContext(ctx, "4:session-id", "sub")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the challenge to be abandoned, got %v", err)
+	}
+}
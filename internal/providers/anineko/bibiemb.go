@@ -1,6 +1,7 @@
 package anineko
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"regexp"
@@ -20,13 +21,13 @@ type resolvedStream struct {
 	Subtitle string
 }
 
-func resolveBibiemb(embedURL string) (resolvedStream, error) {
+func resolveBibiemb(ctx context.Context, embedURL string) (resolvedStream, error) {
 	embedURL = strings.TrimSpace(embedURL)
 	if embedURL == "" {
 		return resolvedStream{}, fmt.Errorf("empty bibiemb embed url")
 	}
 
-	html, err := fetchString(embedURL, baseURL+"/")
+	html, err := fetchString(ctx, embedURL, baseURL+"/")
 	if err != nil {
 		return resolvedStream{}, err
 	}
@@ -37,7 +38,7 @@ func resolveBibiemb(embedURL string) (resolvedStream, error) {
 	}
 
 	masterURL := match[1]
-	variantURL, err := pickBibiembVariant(masterURL, embedURL)
+	variantURL, err := pickBibiembVariant(ctx, masterURL, embedURL)
 	if err != nil {
 		return resolvedStream{}, err
 	}
@@ -49,8 +50,8 @@ func resolveBibiemb(embedURL string) (resolvedStream, error) {
 	}, nil
 }
 
-func pickBibiembVariant(masterURL, referer string) (string, error) {
-	playlist, err := fetchString(masterURL, referer)
+func pickBibiembVariant(ctx context.Context, masterURL, referer string) (string, error) {
+	playlist, err := fetchString(ctx, masterURL, referer)
 	if err != nil {
 		return "", err
 	}
@@ -1,6 +1,7 @@
 package anineko
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,8 +14,8 @@ const userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36
 
 var baseURL = "https://anineko.to"
 
-func newRequest(method, rawURL, referer string) (*http.Request, error) {
-	req, err := http.NewRequest(method, rawURL, nil)
+func newRequest(ctx context.Context, method, rawURL, referer string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -25,8 +26,8 @@ func newRequest(method, rawURL, referer string) (*http.Request, error) {
 	return req, nil
 }
 
-func fetchString(rawURL, referer string) (string, error) {
-	req, err := newRequest(http.MethodGet, rawURL, referer)
+func fetchString(ctx context.Context, rawURL, referer string) (string, error) {
+	req, err := newRequest(ctx, http.MethodGet, rawURL, referer)
 	if err != nil {
 		return "", err
 	}
@@ -46,27 +47,6 @@ func fetchString(rawURL, referer string) (string, error) {
 	return string(body), nil
 }
 
-func fetchBytes(rawURL, referer string) ([]byte, error) {
-	req, err := newRequest(http.MethodGet, rawURL, referer)
-	if err != nil {
-		return nil, err
-	}
-	resp, err := curdhost.HTTPClient().Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	if !curdhost.HTTPStatusOK(resp.StatusCode) {
-		return nil, curdhost.HTTPStatusError("anineko request", resp.StatusCode, body)
-	}
-	return body, nil
-}
-
 func absoluteURL(path string) string {
 	path = strings.TrimSpace(path)
 	if path == "" {
@@ -1,6 +1,7 @@
 package anineko
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"sort"
@@ -10,13 +11,13 @@ import (
 
 var episodePathRE = regexp.MustCompile(`/ep-(\d+)`)
 
-func episodesList(showID, mode string) ([]string, error) {
+func episodesList(ctx context.Context, showID, mode string) ([]string, error) {
 	slug := strings.TrimSpace(showID)
 	if slug == "" {
 		return nil, fmt.Errorf("empty show id")
 	}
 
-	html, err := fetchString(watchURL(slug, 0), baseURL+"/")
+	html, err := fetchString(ctx, watchURL(slug, 0), baseURL+"/")
 	if err != nil {
 		return nil, err
 	}
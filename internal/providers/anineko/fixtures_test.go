@@ -0,0 +1,65 @@
+package anineko
+
+import (
+	"context"
+	"testing"
+
+	"github.com/wraient/curd/internal/providers"
+	"github.com/wraient/curd/internal/providers/cassette"
+)
+
+func TestFixtureSearchAnime(t *testing.T) {
+	cassette.Use(t, "search")
+
+	options, err := searchAnime(context.Background(), "Frieren", "sub")
+	if err != nil {
+		t.Fatalf("searchAnime: %v", err)
+	}
+	if len(options) != 2 {
+		t.Fatalf("expected results without a watch path to be skipped, got %+v", options)
+	}
+	first := options[0]
+	if first.Key != "frieren-beyond-journeys-end" || first.Label != "Frieren: Beyond Journey's End — TV · 2023" {
+		t.Fatalf("unexpected first option %+v", first)
+	}
+	if first.Thumbnail != "https://anineko.to/images/anime/frieren.jpg" {
+		t.Fatalf("expected relative thumbnail to be made absolute, got %q", first.Thumbnail)
+	}
+}
+
+func TestFixtureEpisodesList(t *testing.T) {
+	cassette.Use(t, "episodes")
+
+	episodes, err := episodesList(context.Background(), "frieren-beyond-journeys-end", "sub")
+	if err != nil {
+		t.Fatalf("episodesList: %v", err)
+	}
+	want := []string{"1", "2", "3", "10"}
+	if len(episodes) != len(want) {
+		t.Fatalf("unexpected episodes %v", episodes)
+	}
+	for i := range want {
+		if episodes[i] != want[i] {
+			t.Fatalf("unexpected episodes %v", episodes)
+		}
+	}
+}
+
+func TestFixtureEpisodeStreams(t *testing.T) {
+	resetSubStyleForTest()
+	t.Cleanup(resetSubStyleForTest)
+	cassette.Use(t, "streams")
+
+	links, hints, err := getEpisodeStreamsForMode(context.Background(), "frieren-beyond-journeys-end", providers.PlaybackConfig{SubOrDub: "dub"}, 1)
+	if err != nil {
+		t.Fatalf("getEpisodeStreamsForMode: %v", err)
+	}
+	want := "https://cdn.bibiemb.com/v/frieren-1-dub/1080/index.m3u8"
+	if len(links) != 1 || links[0] != want {
+		t.Fatalf("expected the 1080p variant, got %v", links)
+	}
+	hint := hints[want]
+	if hint.Referrer != "https://bibiemb.com/e/frieren-1-dub" || hint.Subtitle != "https://subs.bibiemb.com/frieren-1-en.vtt" {
+		t.Fatalf("unexpected hint %+v", hint)
+	}
+}
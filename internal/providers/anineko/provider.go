@@ -1,6 +1,10 @@
 package anineko
 
-import "github.com/wraient/curd/internal/providers"
+import (
+	"context"
+
+	"github.com/wraient/curd/internal/providers"
+)
 
 // Provider implements anineko.to catalog search and stream resolution.
 type Provider struct{}
@@ -10,25 +14,45 @@ func (p *Provider) Name() string {
 }
 
 func (p *Provider) SearchAnime(query, mode string) ([]providers.SelectionOption, error) {
-	return searchAnime(query, mode)
+	return p.SearchAnimeContext(context.Background(), query, mode)
+}
+
+func (p *Provider) SearchAnimeContext(ctx context.Context, query, mode string) ([]providers.SelectionOption, error) {
+	return searchAnime(ctx, query, mode)
 }
 
 func (p *Provider) EpisodesList(showID, mode string) ([]string, error) {
-	return episodesList(showID, mode)
+	return p.EpisodesListContext(context.Background(), showID, mode)
+}
+
+func (p *Provider) EpisodesListContext(ctx context.Context, showID, mode string) ([]string, error) {
+	return episodesList(ctx, showID, mode)
 }
 
 func (p *Provider) GetEpisodeURL(config providers.PlaybackConfig, id string, epNo int) ([]string, error) {
-	links, _, err := p.GetEpisodeURLForModeWithHints(config, id, epNo, config.SubOrDub)
+	return p.GetEpisodeURLContext(context.Background(), config, id, epNo)
+}
+
+func (p *Provider) GetEpisodeURLContext(ctx context.Context, config providers.PlaybackConfig, id string, epNo int) ([]string, error) {
+	links, _, err := p.GetEpisodeURLForModeWithHintsContext(ctx, config, id, epNo, config.SubOrDub)
 	return links, err
 }
 
 func (p *Provider) GetEpisodeURLForMode(config providers.PlaybackConfig, id string, epNo int, mode string) ([]string, error) {
-	links, _, err := p.GetEpisodeURLForModeWithHints(config, id, epNo, mode)
+	return p.GetEpisodeURLForModeContext(context.Background(), config, id, epNo, mode)
+}
+
+func (p *Provider) GetEpisodeURLForModeContext(ctx context.Context, config providers.PlaybackConfig, id string, epNo int, mode string) ([]string, error) {
+	links, _, err := p.GetEpisodeURLForModeWithHintsContext(ctx, config, id, epNo, mode)
 	return links, err
 }
 
 func (p *Provider) GetEpisodeURLForModeWithHints(config providers.PlaybackConfig, id string, epNo int, mode string) ([]string, map[string]providers.StreamPlaybackHint, error) {
+	return p.GetEpisodeURLForModeWithHintsContext(context.Background(), config, id, epNo, mode)
+}
+
+func (p *Provider) GetEpisodeURLForModeWithHintsContext(ctx context.Context, config providers.PlaybackConfig, id string, epNo int, mode string) ([]string, map[string]providers.StreamPlaybackHint, error) {
 	playback := config
 	playback.SubOrDub = providers.NormalizeTranslationType(mode)
-	return getEpisodeStreamsForMode(id, playback, epNo)
+	return getEpisodeStreamsForMode(ctx, id, playback, epNo)
 }
@@ -1,6 +1,7 @@
 package anineko
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -8,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/wraient/curd/internal/curdhost"
+	"github.com/wraient/curd/internal/providers/hlsproxy"
 )
 
 type roundTripFunc func(*http.Request) (*http.Response, error)
@@ -34,7 +36,7 @@ func withAninekoTestClient(t *testing.T, client *http.Client) {
 	previous := curdhost.HTTPClient
 	t.Cleanup(func() {
 		curdhost.HTTPClient = previous
-		resetVibeProxyForTest()
+		hlsproxy.ResetForTest()
 		resetSubStyleForTest()
 	})
 	curdhost.HTTPClient = func() *http.Client { return client }
@@ -56,7 +58,7 @@ func TestSearchAnimeParsesResults(t *testing.T) {
 	t.Cleanup(func() { baseURL = originalBase })
 	baseURL = server.URL
 
-	options, err := searchAnime("one piece", "sub")
+	options, err := searchAnime(context.Background(), "one piece", "sub")
 	if err != nil {
 		t.Fatalf("searchAnime: %v", err)
 	}
@@ -82,7 +84,7 @@ func TestEpisodesListParsesEpisodeLinks(t *testing.T) {
 	t.Cleanup(func() { baseURL = originalBase })
 	baseURL = server.URL
 
-	episodes, err := episodesList("frieren", "sub")
+	episodes, err := episodesList(context.Background(), "frieren", "sub")
 	if err != nil {
 		t.Fatalf("episodesList: %v", err)
 	}
@@ -243,7 +245,7 @@ func TestResolveBibiembPicks1080pVariant(t *testing.T) {
 
 	withAninekoTestClient(t, server.Client())
 
-	stream, err := resolveBibiemb(server.URL + "/" + hash)
+	stream, err := resolveBibiemb(context.Background(), server.URL+"/"+hash)
 	if err != nil {
 		t.Fatalf("resolveBibiemb: %v", err)
 	}
@@ -252,25 +254,6 @@ func TestResolveBibiembPicks1080pVariant(t *testing.T) {
 	}
 }
 
-func TestStripPNGWrapper(t *testing.T) {
-	png := []byte{
-		0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a,
-		0x00, 0x00, 0x00, 0x0d, 0x49, 0x48, 0x44, 0x52,
-		0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
-		0x08, 0x06, 0x00, 0x00, 0x00, 0x1f, 0x15, 0xc4,
-		0x89, 0x00, 0x00, 0x00, 0x0d, 0x49, 0x44, 0x41,
-		0x54, 0x78, 0xda, 0x63, 0x64, 0xf8, 0xcf, 0x50,
-		0x0f, 0x00, 0x03, 0x86, 0x01, 0x80, 0x5a, 0x34,
-		0x7d, 0x6b, 0x00, 0x00, 0x00, 0x00, 0x49, 0x45,
-		0x4e, 0x44, 0xae, 0x42, 0x60, 0x82,
-	}
-	payload := []byte{0x47, 0x01, 0x02, 0x03}
-	data := append(append([]byte{}, png...), payload...)
-	if got := stripPNGWrapper(data); string(got) != string(payload) {
-		t.Fatalf("unexpected stripped payload %v", got)
-	}
-}
-
 func TestVibeProxyRewritesAndStripsSegments(t *testing.T) {
 	const (
 		id      = "abc123"
@@ -304,7 +287,7 @@ func TestVibeProxyRewritesAndStripsSegments(t *testing.T) {
 	defer remote.Close()
 
 	withAninekoTestClient(t, remote.Client())
-	resetVibeProxyForTest()
+	hlsproxy.ResetForTest()
 
 	masterURL := remote.URL + "/public/stream/" + id + "/master.m3u8"
 	proxyURL, err := registerVibeStream(masterURL, remote.URL+"/"+id)
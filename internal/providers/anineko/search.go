@@ -1,6 +1,7 @@
 package anineko
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -22,7 +23,7 @@ type searchResponse struct {
 
 var slugFromPathRE = regexp.MustCompile(`/watch/([^/?#]+)`)
 
-func searchAnime(query, mode string) ([]providers.SelectionOption, error) {
+func searchAnime(ctx context.Context, query, mode string) ([]providers.SelectionOption, error) {
 	query = strings.TrimSpace(query)
 	// anineko's search API fails to find results if the query contains an apostrophe
 	query = strings.ReplaceAll(query, "'", "")
@@ -31,7 +32,7 @@ func searchAnime(query, mode string) ([]providers.SelectionOption, error) {
 	}
 
 	rawURL := fmt.Sprintf("%s/ajax/search?q=%s", baseURL, url.QueryEscape(query))
-	body, err := fetchString(rawURL, baseURL+"/")
+	body, err := fetchString(ctx, rawURL, baseURL+"/")
 	if err != nil {
 		return nil, err
 	}
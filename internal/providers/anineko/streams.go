@@ -1,13 +1,14 @@
 package anineko
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/wraient/curd/internal/providers"
 )
 
-func getEpisodeStreamsForMode(slug string, config providers.PlaybackConfig, epNo int) ([]string, map[string]providers.StreamPlaybackHint, error) {
+func getEpisodeStreamsForMode(ctx context.Context, slug string, config providers.PlaybackConfig, epNo int) ([]string, map[string]providers.StreamPlaybackHint, error) {
 	slug = strings.TrimSpace(slug)
 	if slug == "" {
 		return nil, nil, fmt.Errorf("empty show id")
@@ -17,7 +18,7 @@ func getEpisodeStreamsForMode(slug string, config providers.PlaybackConfig, epNo
 	}
 
 	mode := providers.NormalizeTranslationType(config.SubOrDub)
-	html, err := fetchString(watchURL(slug, epNo), baseURL+"/")
+	html, err := fetchString(ctx, watchURL(slug, epNo), baseURL+"/")
 	if err != nil {
 		return nil, nil, err
 	}
@@ -38,9 +39,9 @@ func getEpisodeStreamsForMode(slug string, config providers.PlaybackConfig, epNo
 		)
 		switch resolveEmbedHost(embedURL) {
 		case "bibiemb":
-			stream, err = resolveBibiemb(embedURL)
+			stream, err = resolveBibiemb(ctx, embedURL)
 		case "vibeplayer":
-			stream, err = resolveVibeplayer(embedURL)
+			stream, err = resolveVibeplayer(ctx, embedURL)
 		default:
 			continue
 		}
@@ -1,20 +1,23 @@
 package anineko
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
+
+	"github.com/wraient/curd/internal/providers/hlsproxy"
 )
 
 var vibeplayerMasterRE = regexp.MustCompile(`const src = "(https?://[^"]+/public/stream/[^"]+/master\.m3u8)"`)
 
-func resolveVibeplayer(embedURL string) (resolvedStream, error) {
+func resolveVibeplayer(ctx context.Context, embedURL string) (resolvedStream, error) {
 	embedURL = strings.TrimSpace(embedURL)
 	if embedURL == "" {
 		return resolvedStream{}, fmt.Errorf("empty vibeplayer embed url")
 	}
 
-	html, err := fetchString(embedURL, baseURL+"/")
+	html, err := fetchString(ctx, embedURL, baseURL+"/")
 	if err != nil {
 		return resolvedStream{}, err
 	}
@@ -35,3 +38,15 @@ func resolveVibeplayer(embedURL string) (resolvedStream, error) {
 		Subtitle: resolveSubtitle(embedURL, html),
 	}, nil
 }
+
+// registerVibeStream proxies the vibeplayer master playlist, whose segments
+// are MPEG-TS hidden behind a PNG header that mpv refuses to demux.
+func registerVibeStream(masterURL, referer string) (string, error) {
+	return hlsproxy.Register(masterURL, hlsproxy.Options{
+		Headers: map[string]string{
+			"User-Agent": userAgent,
+			"Referer":    referer,
+		},
+		Prefetch: 2,
+	})
+}
@@ -1,6 +1,7 @@
 package anipub
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -18,8 +19,8 @@ var (
 	megaplayBaseURL = "https://megaplay.buzz"
 )
 
-func newRequest(method, rawURL, referer string) (*http.Request, error) {
-	req, err := http.NewRequest(method, rawURL, nil)
+func newRequest(ctx context.Context, method, rawURL, referer string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -30,8 +31,8 @@ func newRequest(method, rawURL, referer string) (*http.Request, error) {
 	return req, nil
 }
 
-func fetchJSON(rawURL, referer string, dest any) error {
-	req, err := newRequest(http.MethodGet, rawURL, referer)
+func fetchJSON(ctx context.Context, rawURL, referer string, dest any) error {
+	req, err := newRequest(ctx, http.MethodGet, rawURL, referer)
 	if err != nil {
 		return err
 	}
@@ -61,8 +62,8 @@ func fetchJSON(rawURL, referer string, dest any) error {
 	return nil
 }
 
-func fetchString(rawURL, referer string) (string, error) {
-	req, err := newRequest(http.MethodGet, rawURL, referer)
+func fetchString(ctx context.Context, rawURL, referer string) (string, error) {
+	req, err := newRequest(ctx, http.MethodGet, rawURL, referer)
 	if err != nil {
 		return "", err
 	}
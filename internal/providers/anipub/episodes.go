@@ -1,12 +1,13 @@
 package anipub
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
 )
 
-func episodesList(showID, mode string) ([]string, error) {
+func episodesList(ctx context.Context, showID, mode string) ([]string, error) {
 	showID, err := parseShowID(showID)
 	if err != nil {
 		return nil, err
@@ -14,7 +15,7 @@ func episodesList(showID, mode string) ([]string, error) {
 	_ = mode
 
 	var details detailsResponse
-	if err := fetchJSON(detailsURL(showID), baseURL+"/", &details); err != nil {
+	if err := fetchJSON(ctx, detailsURL(showID), baseURL+"/", &details); err != nil {
 		return nil, err
 	}
 
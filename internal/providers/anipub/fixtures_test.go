@@ -0,0 +1,61 @@
+package anipub
+
+import (
+	"context"
+	"testing"
+
+	"github.com/wraient/curd/internal/providers"
+	"github.com/wraient/curd/internal/providers/cassette"
+)
+
+func TestFixtureSearchAnimeFallsBackToShorterQuery(t *testing.T) {
+	cassette.Use(t, "search")
+
+	options, err := searchAnime(context.Background(), "Sousou no Frieren Season 2", "sub")
+	if err != nil {
+		t.Fatalf("searchAnime: %v", err)
+	}
+	if len(options) != 2 {
+		t.Fatalf("unexpected options %+v", options)
+	}
+	first := options[0]
+	if first.Key != "412" || first.Label != "Sousou no Frieren · 28 eps" || first.Thumbnail != "https://anipub.xyz/posters/412.webp" {
+		t.Fatalf("unexpected first option %+v", first)
+	}
+	item, ok := first.ExtraData.(SearchItem)
+	if !ok || item.MalID != 52991 || item.Finder != "sousou-no-frieren" {
+		t.Fatalf("unexpected extra data %+v", first.ExtraData)
+	}
+	if second := options[1]; second.Label != "Sousou no Frieren 2nd Season" || second.Thumbnail != "https://anipub.xyz/images/1093.jpg" {
+		t.Fatalf("unexpected second option %+v", second)
+	}
+}
+
+func TestFixtureEpisodesList(t *testing.T) {
+	cassette.Use(t, "episodes")
+
+	episodes, err := episodesList(context.Background(), "412", "sub")
+	if err != nil {
+		t.Fatalf("episodesList: %v", err)
+	}
+	if len(episodes) != 3 || episodes[0] != "1" || episodes[2] != "3" {
+		t.Fatalf("unexpected episodes %v", episodes)
+	}
+}
+
+func TestFixtureEpisodeStreams(t *testing.T) {
+	cassette.Use(t, "streams")
+
+	links, hints, err := getEpisodeStreamsForMode(context.Background(), "412", providers.PlaybackConfig{SubOrDub: "sub"}, 2)
+	if err != nil {
+		t.Fatalf("getEpisodeStreamsForMode: %v", err)
+	}
+	want := "https://cdn.dotstream.buzz/anime/c71d20/master.m3u8"
+	if len(links) != 1 || links[0] != want {
+		t.Fatalf("unexpected links %v", links)
+	}
+	hint := hints[want]
+	if hint.Subtitle != "https://cdn.dotstream.buzz/subs/c71d20/eng-2.vtt" || hint.Referrer != "https://megaplay.buzz/" {
+		t.Fatalf("unexpected hint %+v", hint)
+	}
+}
@@ -2,6 +2,7 @@ package anipub
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"regexp"
@@ -77,7 +78,7 @@ func queryFallbacks(query string) []string {
 	return out
 }
 
-func searchAnime(query, mode string) ([]providers.SelectionOption, error) {
+func searchAnime(ctx context.Context, query, mode string) ([]providers.SelectionOption, error) {
 	query = normalizeAnipubQuery(query)
 	if query == "" {
 		return nil, fmt.Errorf("empty search query")
@@ -89,7 +90,7 @@ func searchAnime(query, mode string) ([]providers.SelectionOption, error) {
 
 	var lastErr error
 	for _, q := range queries {
-		results, err := fetchSearchResults(q)
+		results, err := fetchSearchResults(ctx, q)
 		if err != nil {
 			lastErr = err
 			continue
@@ -99,7 +100,7 @@ func searchAnime(query, mode string) ([]providers.SelectionOption, error) {
 			continue
 		}
 
-		infos := fetchSearchInfos(results)
+		infos := fetchSearchInfos(ctx, results)
 		options := make([]providers.SelectionOption, 0, len(results))
 		for i, item := range results {
 			if item.ID <= 0 {
@@ -113,6 +114,7 @@ func searchAnime(query, mode string) ([]providers.SelectionOption, error) {
 				Label:     label,
 				Title:     strings.TrimSpace(item.Name),
 				Thumbnail: thumbnailFromInfo(info, item.Image),
+				Episodes:  extra.Episodes,
 				ExtraData: extra,
 			})
 		}
@@ -128,8 +130,8 @@ func searchAnime(query, mode string) ([]providers.SelectionOption, error) {
 	return nil, fmt.Errorf("anipub lookup: no results for %q", query)
 }
 
-func fetchSearchResults(query string) ([]searchResult, error) {
-	raw, err := fetchString(searchURL(query), baseURL+"/")
+func fetchSearchResults(ctx context.Context, query string) ([]searchResult, error) {
+	raw, err := fetchString(ctx, searchURL(query), baseURL+"/")
 	if err != nil {
 		return nil, err
 	}
@@ -162,7 +164,7 @@ func decodeSearchResults(raw []byte) ([]searchResult, error) {
 	return nil, fmt.Errorf("parse anipub search response")
 }
 
-func fetchSearchInfos(results []searchResult) []infoResponse {
+func fetchSearchInfos(ctx context.Context, results []searchResult) []infoResponse {
 	infos := make([]infoResponse, len(results))
 	var wg sync.WaitGroup
 	wg.Add(len(results))
@@ -174,7 +176,7 @@ func fetchSearchInfos(results []searchResult) []infoResponse {
 				return
 			}
 			var info infoResponse
-			if err := fetchJSON(infoURL(strconv.Itoa(item.ID)), baseURL+"/", &info); err == nil {
+			if err := fetchJSON(ctx, infoURL(strconv.Itoa(item.ID)), baseURL+"/", &info); err == nil {
 				infos[i] = info
 			}
 		}()
@@ -1,12 +1,13 @@
 package anipub
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/wraient/curd/internal/providers"
 )
 
-func getEpisodeStreamsForMode(showID string, config providers.PlaybackConfig, epNo int) ([]string, map[string]providers.StreamPlaybackHint, error) {
+func getEpisodeStreamsForMode(ctx context.Context, showID string, config providers.PlaybackConfig, epNo int) ([]string, map[string]providers.StreamPlaybackHint, error) {
 	showID, err := parseShowID(showID)
 	if err != nil {
 		return nil, nil, err
@@ -16,7 +17,7 @@ func getEpisodeStreamsForMode(showID string, config providers.PlaybackConfig, ep
 	}
 
 	var details detailsResponse
-	if err := fetchJSON(detailsURL(showID), baseURL+"/", &details); err != nil {
+	if err := fetchJSON(ctx, detailsURL(showID), baseURL+"/", &details); err != nil {
 		return nil, nil, err
 	}
 
@@ -26,7 +27,7 @@ func getEpisodeStreamsForMode(showID string, config providers.PlaybackConfig, ep
 	}
 
 	mode := providers.NormalizeTranslationType(config.SubOrDub)
-	streamURL, subtitle, err := resolveMegaplayStream(videoLink, mode)
+	streamURL, subtitle, err := resolveMegaplayStream(ctx, videoLink, mode)
 	if err != nil {
 		return nil, nil, err
 	}
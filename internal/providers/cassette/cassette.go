@@ -0,0 +1,365 @@
+// Package cassette records and replays provider HTTP traffic so provider
+// tests can run offline.
+//
+// A cassette is a JSON file under the test package's testdata directory.
+// Tests call Use, which swaps curdhost.HTTPClient for a client backed by a
+// Recorder. By default the recorder replays the cassette and fails any
+// request it has no recording for. Set CURD_RECORD_FIXTURES=1 to send
+// requests upstream instead and rewrite the cassette from the responses.
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wraient/curd/internal/curdhost"
+)
+
+// RecordEnv switches Use from replaying to recording when set to "1".
+const RecordEnv = "CURD_RECORD_FIXTURES"
+
+// Mode selects whether a Recorder replays or records.
+type Mode int
+
+const (
+	Replay Mode = iota
+	Record
+)
+
+// recordedHeaders are the response headers worth keeping. Cookies and
+// per-request noise are dropped so cassettes stay stable and safe to commit.
+var recordedHeaders = []string{"Content-Type", "Location", "Server", "Retry-After"}
+
+// Request is the part of an HTTP request used for matching.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+// Response is a recorded HTTP response. Binary bodies are stored base64
+// encoded in BodyBase64.
+type Response struct {
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 string      `json:"body_base64,omitempty"`
+}
+
+// Interaction is one recorded request/response pair.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette is the on-disk fixture format.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Load reads a cassette from path.
+func Load(path string) (*Cassette, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, fmt.Errorf("parse cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to path, creating parent directories.
+func (c *Cassette) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(c); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// Option configures a Recorder.
+type Option func(*Recorder)
+
+// IgnoreQuery drops the named query parameters before matching, for values
+// such as signed tokens or timestamps that change between runs.
+func IgnoreQuery(params ...string) Option {
+	return func(r *Recorder) {
+		for _, param := range params {
+			r.ignoreQuery[param] = struct{}{}
+		}
+	}
+}
+
+// Upstream sets the transport used in Record mode. It defaults to
+// http.DefaultTransport.
+func Upstream(rt http.RoundTripper) Option {
+	return func(r *Recorder) {
+		r.upstream = rt
+	}
+}
+
+// Recorder is an http.RoundTripper that replays or records a cassette.
+type Recorder struct {
+	mode        Mode
+	upstream    http.RoundTripper
+	ignoreQuery map[string]struct{}
+
+	mu       sync.Mutex
+	cassette *Cassette
+	played   map[string]int
+}
+
+// New returns a Recorder for c.
+func New(c *Cassette, mode Mode, opts ...Option) *Recorder {
+	if c == nil {
+		c = &Cassette{}
+	}
+	r := &Recorder{
+		mode:        mode,
+		upstream:    http.DefaultTransport,
+		ignoreQuery: map[string]struct{}{},
+		cassette:    c,
+		played:      map[string]int{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if mode == Record {
+		r.cassette.Interactions = nil
+	}
+	return r
+}
+
+// Cassette returns the cassette being replayed or recorded.
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cassette
+}
+
+// Client returns an HTTP client using the recorder with its own cookie jar.
+func (r *Recorder) Client() *http.Client {
+	jar, _ := cookiejar.New(nil)
+	return &http.Client{Transport: r, Jar: jar, Timeout: 15 * time.Second}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode == Record {
+		return r.record(req, body)
+	}
+	return r.replay(req, body)
+}
+
+func (r *Recorder) replay(req *http.Request, body string) (*http.Response, error) {
+	key := r.matchKey(req.Method, req.URL.String(), body)
+
+	r.mu.Lock()
+	var matches []Interaction
+	for _, interaction := range r.cassette.Interactions {
+		if r.matchKey(interaction.Request.Method, interaction.Request.URL, interaction.Request.Body) == key {
+			matches = append(matches, interaction)
+		}
+	}
+	index := r.played[key]
+	if len(matches) > 0 {
+		r.played[key]++
+	}
+	r.mu.Unlock()
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("cassette: no recorded response for %s %s", req.Method, req.URL)
+	}
+	// Repeated requests walk through the recordings in order and then keep
+	// returning the last one.
+	if index >= len(matches) {
+		index = len(matches) - 1
+	}
+	return matches[index].Response.toHTTP(req)
+}
+
+func (r *Recorder) record(req *http.Request, body string) (*http.Response, error) {
+	resp, err := r.upstream.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := Response{Status: resp.StatusCode}
+	for _, name := range recordedHeaders {
+		if values := resp.Header.Values(name); len(values) > 0 {
+			if recorded.Header == nil {
+				recorded.Header = http.Header{}
+			}
+			recorded.Header[name] = values
+		}
+	}
+	if utf8.Valid(raw) {
+		recorded.Body = string(raw)
+	} else {
+		recorded.BodyBase64 = base64.StdEncoding.EncodeToString(raw)
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request:  Request{Method: req.Method, URL: req.URL.String(), Body: body},
+		Response: recorded,
+	})
+	r.mu.Unlock()
+
+	return recorded.toHTTP(req)
+}
+
+// matchKey normalises a request so that query parameter order, ignored
+// parameters and JSON formatting do not affect matching.
+func (r *Recorder) matchKey(method, rawURL, body string) string {
+	if parsed, err := url.Parse(rawURL); err == nil {
+		query := parsed.Query()
+		for param := range r.ignoreQuery {
+			query.Del(param)
+		}
+		parsed.RawQuery = query.Encode()
+		rawURL = parsed.String()
+	}
+	return strings.ToUpper(method) + " " + rawURL + "\n" + normalizeBody(body)
+}
+
+func normalizeBody(body string) string {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return ""
+	}
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(body), &decoded); err == nil {
+		if canonical, err := json.Marshal(decoded); err == nil {
+			return string(canonical)
+		}
+	}
+	return body
+}
+
+func readRequestBody(req *http.Request) (string, error) {
+	if req.Body == nil {
+		return "", nil
+	}
+	raw, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(raw))
+	return string(raw), nil
+}
+
+func (resp Response) toHTTP(req *http.Request) (*http.Response, error) {
+	body := []byte(resp.Body)
+	if resp.BodyBase64 != "" {
+		decoded, err := base64.StdEncoding.DecodeString(resp.BodyBase64)
+		if err != nil {
+			return nil, fmt.Errorf("cassette: invalid base64 body for %s: %w", req.URL, err)
+		}
+		body = decoded
+	}
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := http.Header{}
+	for name, values := range resp.Header {
+		header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// Use loads testdata/<name>.json and routes curdhost.HTTPClient through it
+// for the rest of the test. With CURD_RECORD_FIXTURES=1 the cassette is
+// recorded from upstream and written back when the test finishes.
+func Use(t testing.TB, name string, opts ...Option) *Recorder {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".json")
+	mode := Replay
+	if os.Getenv(RecordEnv) == "1" {
+		mode = Record
+	}
+
+	c := &Cassette{}
+	if mode == Replay {
+		loaded, err := Load(path)
+		if err != nil {
+			t.Fatalf("load cassette: %v (record it with %s=1)", err, RecordEnv)
+		}
+		c = loaded
+	}
+
+	recorder := New(c, mode, opts...)
+	client := recorder.Client()
+
+	previousClient := curdhost.HTTPClient
+	previousLog := curdhost.Log
+	previousOut := curdhost.Out
+	curdhost.HTTPClient = func() *http.Client { return client }
+	if curdhost.Log == nil {
+		curdhost.Log = func(string) {}
+	}
+	if curdhost.Out == nil {
+		curdhost.Out = func(string) {}
+	}
+
+	t.Cleanup(func() {
+		curdhost.HTTPClient = previousClient
+		curdhost.Log = previousLog
+		curdhost.Out = previousOut
+		if mode != Record || t.Failed() {
+			return
+		}
+		recorded := recorder.Cassette()
+		sort.SliceStable(recorded.Interactions, func(i, j int) bool {
+			return recorded.Interactions[i].Request.URL < recorded.Interactions[j].Request.URL
+		})
+		if err := recorded.Save(path); err != nil {
+			t.Errorf("save cassette: %v", err)
+		}
+	})
+	return recorder
+}
@@ -0,0 +1,111 @@
+package cassette_test
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wraient/curd/internal/providers/cassette"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func get(t *testing.T, client *http.Client, method, rawURL, body string) (int, string) {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, rawURL, reader)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, rawURL, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	return resp.StatusCode, string(raw)
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	calls := 0
+	upstream := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		body := "hello " + req.URL.Query().Get("q")
+		if req.Method == http.MethodPost {
+			raw, _ := io.ReadAll(req.Body)
+			body = "posted " + string(raw)
+		}
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/plain"}, "Set-Cookie": {"secret=1"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}
+		return resp, nil
+	})
+
+	recorder := cassette.New(nil, cassette.Record, cassette.Upstream(upstream))
+	client := recorder.Client()
+	get(t, client, http.MethodGet, "https://example.test/search?q=one&ts=1", "")
+	get(t, client, http.MethodPost, "https://example.test/api", `{"b":2,"a":1}`)
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if err := recorder.Cassette().Save(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	loaded, err := cassette.Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := loaded.Interactions[0].Response.Header.Get("Set-Cookie"); got != "" {
+		t.Fatalf("cookies should not be recorded, got %q", got)
+	}
+
+	replay := cassette.New(loaded, cassette.Replay, cassette.IgnoreQuery("ts")).Client()
+	if status, body := get(t, replay, http.MethodGet, "https://example.test/search?ts=99&q=one", ""); status != 200 || body != "hello one" {
+		t.Fatalf("unexpected replay %d %q", status, body)
+	}
+	if _, body := get(t, replay, http.MethodPost, "https://example.test/api", `{"a": 1, "b": 2}`); body != `posted {"b":2,"a":1}` {
+		t.Fatalf("json body should match regardless of formatting, got %q", body)
+	}
+	if calls != 2 {
+		t.Fatalf("replay must not reach upstream, got %d calls", calls)
+	}
+}
+
+func TestReplayRejectsUnknownRequest(t *testing.T) {
+	client := cassette.New(&cassette.Cassette{}, cassette.Replay).Client()
+	if _, err := client.Get("https://example.test/missing"); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Fatalf("expected missing recording error, got %v", err)
+	}
+}
+
+func TestReplayWalksRepeatedRequestsInOrder(t *testing.T) {
+	c := &cassette.Cassette{Interactions: []cassette.Interaction{
+		{Request: cassette.Request{Method: "GET", URL: "https://example.test/poll"}, Response: cassette.Response{Status: 503, Body: "busy"}},
+		{Request: cassette.Request{Method: "GET", URL: "https://example.test/poll"}, Response: cassette.Response{Body: "ready"}},
+		{Request: cassette.Request{Method: "GET", URL: "https://example.test/bin"}, Response: cassette.Response{BodyBase64: "iVBORw=="}},
+	}}
+	client := cassette.New(c, cassette.Replay).Client()
+
+	for i, want := range []string{"busy", "ready", "ready"} {
+		if _, body := get(t, client, http.MethodGet, "https://example.test/poll", ""); body != want {
+			t.Fatalf("call %d: got %q want %q", i, body, want)
+		}
+	}
+	if _, body := get(t, client, http.MethodGet, "https://example.test/bin", ""); body != "\x89PNG" {
+		t.Fatalf("unexpected binary body %q", body)
+	}
+}
@@ -0,0 +1,151 @@
+package providers
+
+import "context"
+
+// ContextProvider is a Provider whose lookups can be cancelled. The host
+// prefers these methods over the Provider ones when a provider has them.
+type ContextProvider interface {
+	SearchAnimeContext(ctx context.Context, query, mode string) ([]SelectionOption, error)
+	EpisodesListContext(ctx context.Context, showID, mode string) ([]string, error)
+	GetEpisodeURLContext(ctx context.Context, config PlaybackConfig, id string, epNo int) ([]string, error)
+}
+
+// ContextModeResolver is the cancellable form of ModeResolver.
+type ContextModeResolver interface {
+	GetEpisodeURLForModeContext(ctx context.Context, config PlaybackConfig, id string, epNo int, mode string) ([]string, error)
+}
+
+// ContextHintResolver is the cancellable form of HintResolver.
+type ContextHintResolver interface {
+	GetEpisodeURLForModeWithHintsContext(ctx context.Context, config PlaybackConfig, id string, epNo int, mode string) ([]string, map[string]StreamPlaybackHint, error)
+}
+
+// ContextStreamResolver is the cancellable form of StreamResolver.
+type ContextStreamResolver interface {
+	GetEpisodeStreamsContext(ctx context.Context, config PlaybackConfig, id string, epNo int, mode string) ([]Stream, error)
+}
+
+// ContextIDResolver is the cancellable form of IDResolver.
+type ContextIDResolver interface {
+	ResolveProviderIDContext(ctx context.Context, providerID, query string) (string, error)
+}
+
+// SearchAnime searches p, returning ctx.Err() as soon as ctx is done.
+// Providers without ContextProvider keep running in the background after
+// cancellation and their result is dropped.
+func SearchAnime(ctx context.Context, p Provider, query, mode string) ([]SelectionOption, error) {
+	if cp, ok := p.(ContextProvider); ok {
+		return cp.SearchAnimeContext(ctx, query, mode)
+	}
+	return await(ctx, func() ([]SelectionOption, error) {
+		return p.SearchAnime(query, mode)
+	})
+}
+
+// EpisodesList lists the episodes of showID on p, honouring ctx.
+func EpisodesList(ctx context.Context, p Provider, showID, mode string) ([]string, error) {
+	if cp, ok := p.(ContextProvider); ok {
+		return cp.EpisodesListContext(ctx, showID, mode)
+	}
+	return await(ctx, func() ([]string, error) {
+		return p.EpisodesList(showID, mode)
+	})
+}
+
+// GetEpisodeURL resolves the links of an episode on p, honouring ctx.
+func GetEpisodeURL(ctx context.Context, p Provider, config PlaybackConfig, id string, epNo int) ([]string, error) {
+	if cp, ok := p.(ContextProvider); ok {
+		return cp.GetEpisodeURLContext(ctx, config, id, epNo)
+	}
+	return await(ctx, func() ([]string, error) {
+		return p.GetEpisodeURL(config, id, epNo)
+	})
+}
+
+// GetEpisodeURLForMode resolves the links of an episode in mode, using the
+// richest interface p implements.
+func GetEpisodeURLForMode(ctx context.Context, p Provider, config PlaybackConfig, id string, epNo int, mode string) ([]string, error) {
+	switch resolver := p.(type) {
+	case ContextModeResolver:
+		return resolver.GetEpisodeURLForModeContext(ctx, config, id, epNo, mode)
+	case ModeResolver:
+		return await(ctx, func() ([]string, error) {
+			return resolver.GetEpisodeURLForMode(config, id, epNo, mode)
+		})
+	}
+	config.SubOrDub = mode
+	return GetEpisodeURL(ctx, p, config, id, epNo)
+}
+
+// GetEpisodeURLForModeWithHints resolves links and their playback hints.
+func GetEpisodeURLForModeWithHints(ctx context.Context, p Provider, config PlaybackConfig, id string, epNo int, mode string) ([]string, map[string]StreamPlaybackHint, error) {
+	type result struct {
+		links []string
+		hints map[string]StreamPlaybackHint
+	}
+	switch resolver := p.(type) {
+	case ContextHintResolver:
+		return resolver.GetEpisodeURLForModeWithHintsContext(ctx, config, id, epNo, mode)
+	case HintResolver:
+		res, err := await(ctx, func() (result, error) {
+			links, hints, err := resolver.GetEpisodeURLForModeWithHints(config, id, epNo, mode)
+			return result{links, hints}, err
+		})
+		return res.links, res.hints, err
+	}
+	links, err := GetEpisodeURLForMode(ctx, p, config, id, epNo, mode)
+	return links, nil, err
+}
+
+// GetEpisodeStreams resolves typed streams, converting the links and hints
+// of providers that only implement the older interfaces.
+func GetEpisodeStreams(ctx context.Context, p Provider, config PlaybackConfig, id string, epNo int, mode string) ([]Stream, error) {
+	switch resolver := p.(type) {
+	case ContextStreamResolver:
+		return resolver.GetEpisodeStreamsContext(ctx, config, id, epNo, mode)
+	case StreamResolver:
+		return await(ctx, func() ([]Stream, error) {
+			return resolver.GetEpisodeStreams(config, id, epNo, mode)
+		})
+	}
+	links, hints, err := GetEpisodeURLForModeWithHints(ctx, p, config, id, epNo, mode)
+	return StreamsFromLinks(links, hints), err
+}
+
+// ResolveProviderID refreshes providerID when p supports it and returns it
+// unchanged otherwise.
+func ResolveProviderID(ctx context.Context, p Provider, providerID, query string) (string, error) {
+	switch resolver := p.(type) {
+	case ContextIDResolver:
+		return resolver.ResolveProviderIDContext(ctx, providerID, query)
+	case IDResolver:
+		return await(ctx, func() (string, error) {
+			return resolver.ResolveProviderID(providerID, query)
+		})
+	}
+	return providerID, ctx.Err()
+}
+
+// await runs call and returns its result, or ctx.Err() once ctx is done
+// without waiting for call to finish.
+func await[T any](ctx context.Context, call func() (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := call()
+		done <- result{value, err}
+	}()
+	select {
+	case res := <-done:
+		return res.value, res.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,75 @@
+package hlsproxy
+
+import "sync"
+
+type cachedSegment struct {
+	data        []byte
+	contentType string
+}
+
+type cacheEntry struct {
+	done    chan struct{}
+	segment cachedSegment
+	err     error
+}
+
+// segmentCache keeps the most recently fetched segments of a session and
+// makes concurrent requests for the same segment share one download.
+type segmentCache struct {
+	mu      sync.Mutex
+	limit   int
+	entries map[int]*cacheEntry
+	order   []int
+}
+
+func newSegmentCache(limit int) *segmentCache {
+	return &segmentCache{limit: limit, entries: map[int]*cacheEntry{}}
+}
+
+func (c *segmentCache) has(index int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[index]
+	return ok
+}
+
+// get returns the cached segment at index, calling fetch to fill it when it
+// is missing. Failed fetches are not cached.
+func (c *segmentCache) get(index int, fetch func() (cachedSegment, error)) (cachedSegment, error) {
+	c.mu.Lock()
+	if entry, ok := c.entries[index]; ok {
+		c.mu.Unlock()
+		<-entry.done
+		return entry.segment, entry.err
+	}
+	entry := &cacheEntry{done: make(chan struct{})}
+	c.entries[index] = entry
+	c.order = append(c.order, index)
+	c.evictLocked()
+	c.mu.Unlock()
+
+	entry.segment, entry.err = fetch()
+	close(entry.done)
+	if entry.err != nil {
+		c.mu.Lock()
+		if c.entries[index] == entry {
+			delete(c.entries, index)
+			for i, queued := range c.order {
+				if queued == index {
+					c.order = append(c.order[:i], c.order[i+1:]...)
+					break
+				}
+			}
+		}
+		c.mu.Unlock()
+	}
+	return entry.segment, entry.err
+}
+
+func (c *segmentCache) evictLocked() {
+	for len(c.order) > c.limit {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		delete(c.entries, oldest)
+	}
+}
@@ -0,0 +1,492 @@
+// Package hlsproxy serves HLS streams through a local HTTP server so mpv can
+// play streams it cannot play directly.
+//
+// Providers call Register with the upstream playlist and get back a
+// 127.0.0.1 URL to hand to the player, which Release drops once the player
+// has moved on. While serving, the proxy:
+//
+//   - sends the provider's headers (Referer, User-Agent, ...) upstream on
+//     every playlist, key and segment request;
+//   - drops segments hosted on ad CDNs along with the discontinuity tags
+//     that bracket them;
+//   - unwraps segments disguised as images so the demuxer sees MPEG-TS;
+//   - prefetches the next few segments into a small in-memory cache;
+//   - reads segments through the disk Store set with SetStore, when there
+//     is one, and keeps the next stretch of the stream downloaded into it.
+//
+// One server is started lazily per process and shared by every provider.
+package hlsproxy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wraient/curd/internal/curdhost"
+	"github.com/wraient/curd/internal/readahead"
+)
+
+// DefaultAdHosts are URL fragments of CDNs known to inject ad segments into
+// HLS playlists. The segments are images, not video, and break playback.
+var DefaultAdHosts = []string{
+	"ibyteimg.com",
+	"p16-ad-sg",
+	"p16-ad.",
+}
+
+// Options control how a registered stream is proxied.
+type Options struct {
+	// Headers are sent with every upstream request.
+	Headers map[string]string
+	// AdHosts replaces DefaultAdHosts when set. Segments whose URL contains
+	// one of the entries are removed from media playlists.
+	AdHosts []string
+	// Prefetch is how many segments after the one being played are fetched
+	// ahead of time. Zero disables prefetching.
+	Prefetch int
+}
+
+func (o Options) adHosts() []string {
+	if o.AdHosts != nil {
+		return o.AdHosts
+	}
+	return DefaultAdHosts
+}
+
+const (
+	kindPlaylist = iota
+	kindSegment
+	kindRaw
+)
+
+// resource is an upstream URL the proxy hands out a local path for.
+type resource struct {
+	url  string
+	kind int
+	// byteRange is the Range header selecting the resource from url for
+	// EXT-X-BYTERANGE playlists, empty for the whole file.
+	byteRange string
+	// duration is the EXTINF duration of a segment in seconds.
+	duration float64
+	// next is the index of the segment that follows this one, or -1.
+	next int
+}
+
+type session struct {
+	id   string
+	opts Options
+
+	mu        sync.Mutex
+	resources []resource
+	byURL     map[string]int
+	cache     *segmentCache
+
+	ahead readahead.Worker
+}
+
+type proxy struct {
+	baseURL  string
+	server   *http.Server
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+var (
+	proxyMu     sync.Mutex
+	proxyServer *proxy
+)
+
+// Register starts proxying the playlist at playlistURL, which may be a
+// master or a media playlist, and returns the local URL to play.
+func Register(playlistURL string, opts Options) (string, error) {
+	p, err := getProxy()
+	if err != nil {
+		return "", err
+	}
+	id, err := randomSessionID()
+	if err != nil {
+		return "", err
+	}
+	s := &session{
+		id:    id,
+		opts:  opts,
+		byURL: map[string]int{},
+		cache: newSegmentCache(opts.Prefetch*2 + 4),
+	}
+	s.add(playlistURL, kindPlaylist)
+
+	p.mu.Lock()
+	p.sessions[id] = s
+	p.mu.Unlock()
+	return p.baseURL + "/stream/" + id + "/master.m3u8", nil
+}
+
+// Release stops serving the stream behind localURL, a URL returned by
+// Register, once the player has moved on from it. Other URLs are ignored.
+func Release(localURL string) {
+	proxyMu.Lock()
+	p := proxyServer
+	proxyMu.Unlock()
+	if p == nil {
+		return
+	}
+	rest, ok := strings.CutPrefix(localURL, p.baseURL+"/stream/")
+	if !ok {
+		return
+	}
+	id, _, _ := strings.Cut(rest, "/")
+	p.mu.Lock()
+	delete(p.sessions, id)
+	p.mu.Unlock()
+}
+
+// ResetForTest stops the shared server so tests start from a clean state.
+func ResetForTest() {
+	proxyMu.Lock()
+	defer proxyMu.Unlock()
+	if proxyServer != nil && proxyServer.server != nil {
+		_ = proxyServer.server.Close()
+	}
+	proxyServer = nil
+}
+
+func getProxy() (*proxy, error) {
+	proxyMu.Lock()
+	defer proxyMu.Unlock()
+
+	if proxyServer != nil {
+		return proxyServer, nil
+	}
+
+	p := &proxy{sessions: map[string]*session{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream/", p.handle)
+	server := &http.Server{Handler: mux}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("start hls proxy listener: %w", err)
+	}
+	p.server = server
+	p.baseURL = "http://" + listener.Addr().String()
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	proxyServer = p
+	return p, nil
+}
+
+// handle serves /stream/{session}/master.m3u8, /stream/{session}/p/{n}/{name},
+// /stream/{session}/s/{n} and /stream/{session}/r/{n}.
+func (p *proxy) handle(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/stream/"), "/")
+	if len(parts) < 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	p.mu.Lock()
+	s, ok := p.sessions[parts[0]]
+	p.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "master.m3u8" {
+		p.servePlaylist(w, s, 0)
+		return
+	}
+	if len(parts) < 3 {
+		http.NotFound(w, r)
+		return
+	}
+	index, err := strconv.Atoi(parts[2])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	res, ok := s.get(index)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case parts[1] == "p" && res.kind == kindPlaylist:
+		p.servePlaylist(w, s, index)
+	case parts[1] == "s" && res.kind == kindSegment:
+		s.serveSegment(w, index)
+	case parts[1] == "r" && res.kind == kindRaw:
+		s.serveRaw(w, res)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (p *proxy) servePlaylist(w http.ResponseWriter, s *session, index int) {
+	res, ok := s.get(index)
+	if !ok {
+		http.Error(w, "unknown playlist", http.StatusNotFound)
+		return
+	}
+	body, err := fetchPlaylist(res.url, s.opts.Headers)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	if isMediaPlaylist(body) {
+		body = p.rewriteMedia(s, res.url, body)
+	} else {
+		body = p.rewriteMaster(s, res.url, body)
+	}
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	_, _ = io.WriteString(w, body)
+}
+
+func (s *session) serveSegment(w http.ResponseWriter, index int) {
+	seg, err := s.cache.get(index, func() (cachedSegment, error) {
+		return s.loadSegment(index)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	s.prefetch(index)
+	s.readAheadFrom(index)
+
+	w.Header().Set("Content-Type", seg.contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(seg.data)))
+	_, _ = w.Write(seg.data)
+}
+
+func (s *session) serveRaw(w http.ResponseWriter, res resource) {
+	data, contentType, err := fetch(res.url, withRange(s.opts.Headers, res.byteRange))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	_, _ = w.Write(data)
+}
+
+func (s *session) fetchSegment(index int) (cachedSegment, error) {
+	res, ok := s.get(index)
+	if !ok {
+		return cachedSegment{}, fmt.Errorf("unknown segment %d", index)
+	}
+	return fetchSegmentURL(res.url, withRange(s.opts.Headers, res.byteRange))
+}
+
+// fetchSegmentURL downloads the segment at rawURL and unwraps it.
+func fetchSegmentURL(rawURL string, headers map[string]string) (cachedSegment, error) {
+	data, contentType, err := fetch(rawURL, headers)
+	if err != nil {
+		return cachedSegment{}, err
+	}
+	unwrapped := Unwrap(data)
+	if len(unwrapped) != len(data) || !isMediaContentType(contentType) {
+		contentType = "video/mp2t"
+	}
+	return cachedSegment{data: unwrapped, contentType: contentType}, nil
+}
+
+// prefetch warms the cache with the segments that follow index.
+func (s *session) prefetch(index int) {
+	next := index
+	for i := 0; i < s.opts.Prefetch; i++ {
+		res, ok := s.get(next)
+		if !ok || res.next < 0 {
+			return
+		}
+		next = res.next
+		if s.cache.has(next) {
+			continue
+		}
+		go func(index int) {
+			_, _ = s.cache.get(index, func() (cachedSegment, error) {
+				return s.loadSegment(index)
+			})
+		}(next)
+	}
+}
+
+func (s *session) add(rawURL string, kind int) int {
+	return s.addRange(rawURL, "", kind)
+}
+
+// addRange adds the byteRange of rawURL. Byte-range playlists keep every
+// segment in one file, so resources are told apart by URL and range.
+func (s *session) addRange(rawURL, byteRange string, kind int) int {
+	key := segmentKey(rawURL, byteRange)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index, ok := s.byURL[key]; ok {
+		return index
+	}
+	index := len(s.resources)
+	s.resources = append(s.resources, resource{url: rawURL, kind: kind, byteRange: byteRange, next: -1})
+	s.byURL[key] = index
+	return index
+}
+
+// segmentKey identifies the byteRange of rawURL, in the session and in the
+// store.
+func segmentKey(rawURL, byteRange string) string {
+	if byteRange == "" {
+		return rawURL
+	}
+	return rawURL + "#" + byteRange
+}
+
+// withRange returns headers with byteRange as the Range header.
+func withRange(headers map[string]string, byteRange string) map[string]string {
+	if byteRange == "" {
+		return headers
+	}
+	ranged := make(map[string]string, len(headers)+1)
+	for key, value := range headers {
+		ranged[key] = value
+	}
+	ranged["Range"] = byteRange
+	return ranged
+}
+
+func (s *session) get(index int) (resource, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index < 0 || index >= len(s.resources) {
+		return resource{}, false
+	}
+	return s.resources[index], true
+}
+
+func (s *session) link(from, to int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources[from].next = to
+}
+
+func (s *session) setDuration(index int, duration float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources[index].duration = duration
+}
+
+// IsLocal reports whether rawURL was returned by Register, so callers don't
+// put another proxy in front of it.
+func IsLocal(rawURL string) bool {
+	_, ok := localSession(rawURL)
+	return ok
+}
+
+// localSession returns the session a local URL belongs to.
+func localSession(rawURL string) (*session, bool) {
+	proxyMu.Lock()
+	p := proxyServer
+	proxyMu.Unlock()
+	if p == nil {
+		return nil, false
+	}
+	rest, ok := strings.CutPrefix(rawURL, p.baseURL+"/stream/")
+	if !ok {
+		return nil, false
+	}
+	id, _, _ := strings.Cut(rest, "/")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.sessions[id]
+	return s, ok
+}
+
+func (p *proxy) localURL(s *session, rawURL string, kind int) string {
+	return p.indexURL(s, s.add(rawURL, kind), rawURL, kind)
+}
+
+func (p *proxy) indexURL(s *session, index int, rawURL string, kind int) string {
+	prefix := fmt.Sprintf("%s/stream/%s", p.baseURL, s.id)
+	switch kind {
+	case kindPlaylist:
+		name := path.Base(stripQuery(rawURL))
+		if !strings.HasSuffix(name, ".m3u8") {
+			name = "index.m3u8"
+		}
+		return fmt.Sprintf("%s/p/%d/%s", prefix, index, name)
+	case kindSegment:
+		return fmt.Sprintf("%s/s/%d", prefix, index)
+	default:
+		return fmt.Sprintf("%s/r/%d", prefix, index)
+	}
+}
+
+// fetch downloads rawURL with headers through the host HTTP client.
+func fetch(rawURL string, headers map[string]string) ([]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := curdhost.HTTPClient().Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if !curdhost.HTTPStatusOK(resp.StatusCode) {
+		return nil, "", curdhost.HTTPStatusError("hls proxy request", resp.StatusCode, body)
+	}
+	return body, resp.Header.Get("Content-Type"), nil
+}
+
+func isMediaContentType(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	return strings.HasPrefix(contentType, "video/") || strings.HasPrefix(contentType, "audio/")
+}
+
+// ResolveURL resolves a playlist entry against the playlist it appeared in.
+func ResolveURL(baseURL, entry string) string {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return entry
+	}
+	ref, err := url.Parse(entry)
+	if err != nil {
+		return entry
+	}
+	return base.ResolveReference(ref).String()
+}
+
+func stripQuery(rawURL string) string {
+	if idx := strings.IndexAny(rawURL, "?#"); idx >= 0 {
+		return rawURL[:idx]
+	}
+	return rawURL
+}
+
+func randomSessionID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
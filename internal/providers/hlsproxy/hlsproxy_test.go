@@ -0,0 +1,394 @@
+package hlsproxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wraient/curd/internal/curdhost"
+)
+
+var pngWrapper = []byte{
+	0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a,
+	0x00, 0x00, 0x00, 0x0d, 0x49, 0x48, 0x44, 0x52,
+	0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
+	0x08, 0x06, 0x00, 0x00, 0x00, 0x1f, 0x15, 0xc4,
+	0x89, 0x00, 0x00, 0x00, 0x0d, 0x49, 0x44, 0x41,
+	0x54, 0x78, 0xda, 0x63, 0x64, 0xf8, 0xcf, 0x50,
+	0x0f, 0x00, 0x03, 0x86, 0x01, 0x80, 0x5a, 0x34,
+	0x7d, 0x6b, 0x00, 0x00, 0x00, 0x00, 0x49, 0x45,
+	0x4e, 0x44, 0xae, 0x42, 0x60, 0x82,
+}
+
+func withTestClient(t *testing.T, client *http.Client) {
+	t.Helper()
+	previous := curdhost.HTTPClient
+	t.Cleanup(func() {
+		curdhost.HTTPClient = previous
+		ResetForTest()
+	})
+	curdhost.HTTPClient = func() *http.Client { return client }
+	ResetForTest()
+}
+
+func get(t *testing.T, rawURL string) string {
+	t.Helper()
+	resp, err := http.Get(rawURL)
+	if err != nil {
+		t.Fatalf("get %s: %v", rawURL, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("get %s: status %d: %s", rawURL, resp.StatusCode, body)
+	}
+	return string(body)
+}
+
+func uris(playlist string) []string {
+	var result []string
+	for _, line := range strings.Split(playlist, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+func tsPackets(n int) []byte {
+	data := make([]byte, n*tsPacketSize)
+	for i := 0; i < n; i++ {
+		data[i*tsPacketSize] = tsSyncByte
+	}
+	return data
+}
+
+func TestUnwrap(t *testing.T) {
+	payload := []byte{0x47, 0x01, 0x02, 0x03}
+	if got := Unwrap(append(append([]byte{}, pngWrapper...), payload...)); !bytes.Equal(got, payload) {
+		t.Fatalf("png wrapper not stripped: %v", got)
+	}
+
+	ts := tsPackets(4)
+	if got := Unwrap(ts); !bytes.Equal(got, ts) {
+		t.Fatalf("plain ts changed")
+	}
+	jpeg := append([]byte{0xff, 0xd8, 0xff, 0xe0, 0x47, 0x00, 0xff, 0xd9}, ts...)
+	if got := Unwrap(jpeg); !bytes.Equal(got, ts) {
+		t.Fatalf("expected ts after jpeg prefix, got %d bytes", len(got))
+	}
+
+	mp4 := []byte{0x00, 0x00, 0x00, 0x18, 'f', 't', 'y', 'p', 'i', 's', 'o', 'm'}
+	if got := Unwrap(mp4); !bytes.Equal(got, mp4) {
+		t.Fatalf("mp4 changed")
+	}
+}
+
+func TestProxyStripsAdsAndForwardsHeaders(t *testing.T) {
+	var mu sync.Mutex
+	referers := map[string]string{}
+
+	var upstream *httptest.Server
+	upstream = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		referers[r.URL.Path] = r.Header.Get("Referer")
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/hls/master.m3u8":
+			_, _ = io.WriteString(w, "#EXTM3U\n"+
+				"#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"aud\",NAME=\"en\",URI=\"audio/en.m3u8\"\n"+
+				"#EXT-X-STREAM-INF:BANDWIDTH=1,AUDIO=\"aud\"\n"+
+				"video/index.m3u8?token=abc\n")
+		case "/hls/video/index.m3u8":
+			_, _ = io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:10\n"+
+				"#EXT-X-KEY:METHOD=AES-128,URI=\"/keys/1\"\n"+
+				"#EXTINF:10.0,\nseg0.ts\n"+
+				"#EXT-X-DISCONTINUITY\n"+
+				"#EXTINF:5.0,\nhttps://p16-ad-sg.ibyteimg.com/ad1.png\n"+
+				"#EXTINF:5.0,\nhttps://p16-ad-sg.ibyteimg.com/ad2.png\n"+
+				"#EXT-X-DISCONTINUITY\n"+
+				"#EXTINF:10.0,\nseg1.ts\n"+
+				"#EXT-X-DISCONTINUITY\n"+
+				"#EXTINF:10.0,\n"+upstream.URL+"/other/seg2.ts\n"+
+				"#EXT-X-ENDLIST\n")
+		case "/hls/video/seg0.ts":
+			_, _ = w.Write(append(append([]byte{}, pngWrapper...), tsPackets(3)...))
+		case "/keys/1":
+			_, _ = w.Write([]byte("0123456789abcdef"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+	withTestClient(t, upstream.Client())
+
+	proxied, err := Register(upstream.URL+"/hls/master.m3u8", Options{
+		Headers: map[string]string{"Referer": "https://example.org/"},
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if !strings.HasPrefix(proxied, "http://127.0.0.1:") {
+		t.Fatalf("expected local url, got %s", proxied)
+	}
+
+	master := get(t, proxied)
+	if strings.Contains(master, upstream.URL) {
+		t.Fatalf("master still points upstream:\n%s", master)
+	}
+	if !strings.Contains(master, `URI="http://127.0.0.1:`) || !strings.Contains(master, "/en.m3u8") {
+		t.Fatalf("rendition not rewritten:\n%s", master)
+	}
+	variants := uris(master)
+	if len(variants) != 1 || !strings.HasSuffix(variants[0], "/index.m3u8") {
+		t.Fatalf("unexpected variants %v", variants)
+	}
+
+	media := get(t, variants[0])
+	if strings.Contains(media, "ibyteimg") {
+		t.Fatalf("ad segments kept:\n%s", media)
+	}
+	if got := strings.Count(media, "#EXT-X-DISCONTINUITY"); got != 1 {
+		t.Fatalf("expected only the real discontinuity to remain, got %d:\n%s", got, media)
+	}
+	if got := strings.Count(media, "#EXTINF"); got != 3 {
+		t.Fatalf("expected 3 segments, got %d:\n%s", got, media)
+	}
+	if !strings.Contains(media, "#EXT-X-ENDLIST") || strings.Contains(media, upstream.URL) {
+		t.Fatalf("media playlist not rewritten:\n%s", media)
+	}
+	segments := uris(media)
+	if len(segments) != 3 {
+		t.Fatalf("unexpected segments %v", segments)
+	}
+
+	if got := get(t, segments[0]); !bytes.Equal([]byte(got), tsPackets(3)) {
+		t.Fatalf("segment not unwrapped: %d bytes", len(got))
+	}
+
+	keyURL := uriAttrRE.FindStringSubmatch(media)
+	if len(keyURL) < 2 || !strings.HasPrefix(keyURL[1], "http://127.0.0.1:") {
+		t.Fatalf("key uri not rewritten:\n%s", media)
+	}
+	if got := get(t, keyURL[1]); got != "0123456789abcdef" {
+		t.Fatalf("unexpected key %q", got)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for path, referer := range referers {
+		if referer != "https://example.org/" {
+			t.Fatalf("%s fetched with referer %q", path, referer)
+		}
+	}
+}
+
+func TestProxyPrefetchesSegments(t *testing.T) {
+	var mu sync.Mutex
+	hits := map[string]int{}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/index.m3u8":
+			_, _ = io.WriteString(w, "#EXTM3U\n#EXTINF:4,\n0.ts\n#EXTINF:4,\n1.ts\n#EXTINF:4,\n2.ts\n#EXT-X-ENDLIST\n")
+		default:
+			_, _ = w.Write(tsPackets(3))
+		}
+	}))
+	defer upstream.Close()
+	withTestClient(t, upstream.Client())
+
+	proxied, err := Register(upstream.URL+"/index.m3u8", Options{Prefetch: 1})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	segments := uris(get(t, proxied))
+	if len(segments) != 3 {
+		t.Fatalf("unexpected segments %v", segments)
+	}
+
+	get(t, segments[0])
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		prefetched := hits["/1.ts"]
+		mu.Unlock()
+		if prefetched == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("segment 1 was not prefetched")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	get(t, segments[1])
+	mu.Lock()
+	defer mu.Unlock()
+	if hits["/1.ts"] != 1 {
+		t.Fatalf("prefetched segment fetched again: %d hits", hits["/1.ts"])
+	}
+}
+
+func TestInspectCountsAdSegments(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/master.m3u8":
+			_, _ = io.WriteString(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\nindex.m3u8\n")
+		case "/index.m3u8":
+			_, _ = io.WriteString(w, "#EXTM3U\n#EXTINF:5,\nhttps://p16-ad.example/ad.png\n#EXTINF:10,\nseg.ts\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+	withTestClient(t, upstream.Client())
+
+	report, err := Inspect(upstream.URL+"/master.m3u8", Options{})
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	if report.Segments != 2 || report.AdSegments != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	report, err = Inspect(upstream.URL+"/index.m3u8", Options{AdHosts: []string{}})
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	if report.AdSegments != 0 {
+		t.Fatalf("empty AdHosts should disable ad detection, got %+v", report)
+	}
+}
+
+func TestProxyServesByteRangesAsSegments(t *testing.T) {
+	file := []byte("0123456789abcdefghij")
+	var mu sync.Mutex
+	var ranges []string
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.m3u8":
+			_, _ = io.WriteString(w, "#EXTM3U\n"+
+				"#EXT-X-MAP:URI=\"all.mp4\",BYTERANGE=\"4@0\"\n"+
+				"#EXTINF:4,\n#EXT-X-BYTERANGE:6@4\nall.mp4\n"+
+				"#EXTINF:4,\n#EXT-X-BYTERANGE:10\nall.mp4\n"+
+				"#EXT-X-ENDLIST\n")
+		case "/all.mp4":
+			mu.Lock()
+			ranges = append(ranges, r.Header.Get("Range"))
+			mu.Unlock()
+			http.ServeContent(w, r, "all.mp4", time.Time{}, bytes.NewReader(file))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+	withTestClient(t, upstream.Client())
+
+	proxied, err := Register(upstream.URL+"/index.m3u8", Options{})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	media := get(t, proxied)
+	if strings.Contains(media, "BYTERANGE") {
+		t.Fatalf("byte ranges passed on to the player:\n%s", media)
+	}
+	segments := uris(media)
+	if len(segments) != 2 || segments[0] == segments[1] {
+		t.Fatalf("byte-range segments collapsed: %v", segments)
+	}
+	if got := get(t, segments[0]); got != "456789" {
+		t.Fatalf("first segment = %q", got)
+	}
+	if got := get(t, segments[1]); got != "abcdefghij" {
+		t.Fatalf("second segment = %q", got)
+	}
+	initURL := uriAttrRE.FindStringSubmatch(media)
+	if len(initURL) < 2 {
+		t.Fatalf("init section missing:\n%s", media)
+	}
+	if got := get(t, initURL[1]); got != "0123" {
+		t.Fatalf("init section = %q", got)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, want := range []string{"bytes=4-9", "bytes=10-19", "bytes=0-3"} {
+		found := false
+		for _, got := range ranges {
+			found = found || got == want
+		}
+		if !found {
+			t.Fatalf("range %s never requested, got %v", want, ranges)
+		}
+	}
+}
+
+func TestReleaseStopsServingStream(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "#EXTM3U\n#EXTINF:4,\n0.ts\n#EXT-X-ENDLIST\n")
+	}))
+	defer upstream.Close()
+	withTestClient(t, upstream.Client())
+
+	kept, err := Register(upstream.URL+"/a.m3u8", Options{})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	released, err := Register(upstream.URL+"/b.m3u8", Options{})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	Release(released)
+	Release("https://example.org/stream/unknown/master.m3u8")
+
+	if IsLocal(released) {
+		t.Fatal("released stream is still served")
+	}
+	resp, err := http.Get(released)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("released stream answered %d", resp.StatusCode)
+	}
+	get(t, kept)
+}
+
+func TestPlayableURLProxiesOnlyStreamsWithAds(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/clean.m3u8":
+			_, _ = io.WriteString(w, "#EXTM3U\n#EXTINF:10,\nseg.ts\n")
+		case "/mixed.m3u8":
+			_, _ = io.WriteString(w, "#EXTM3U\n#EXTINF:5,\nhttps://p16-ad.example/ad.png\n#EXTINF:10,\nseg.ts\n")
+		case "/ads.m3u8":
+			_, _ = io.WriteString(w, "#EXTM3U\n#EXTINF:5,\nhttps://p16-ad.example/ad.png\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+	withTestClient(t, upstream.Client())
+
+	if got, err := PlayableURL(upstream.URL+"/clean.m3u8", Options{}); err != nil || got != upstream.URL+"/clean.m3u8" {
+		t.Fatalf("clean stream = %q, %v", got, err)
+	}
+	if got, err := PlayableURL(upstream.URL+"/mixed.m3u8", Options{}); err != nil || !IsLocal(got) {
+		t.Fatalf("stream with ads = %q, %v", got, err)
+	}
+	if _, err := PlayableURL(upstream.URL+"/ads.m3u8", Options{}); err == nil {
+		t.Fatal("a stream of nothing but ads should be unplayable")
+	}
+}
@@ -0,0 +1,268 @@
+package hlsproxy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	uriAttrRE       = regexp.MustCompile(`URI="([^"]*)"`)
+	bandwidthRE     = regexp.MustCompile(`(?:^|[:,])BANDWIDTH=(\d+)`)
+	byteRangeAttrRE = regexp.MustCompile(`BYTERANGE="([^"]*)"`)
+)
+
+// IsAdSegment reports whether segURL is served from one of adHosts.
+func IsAdSegment(segURL string, adHosts []string) bool {
+	for _, host := range adHosts {
+		if host != "" && strings.Contains(segURL, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// Report summarises the first media playlist of a stream.
+type Report struct {
+	Segments   int
+	AdSegments int
+}
+
+// Inspect fetches the playlist at playlistURL, following the first variant
+// of a master playlist, and counts its segments and ad segments.
+func Inspect(playlistURL string, opts Options) (Report, error) {
+	data, _, err := fetch(playlistURL, opts.Headers)
+	if err != nil {
+		return Report{}, err
+	}
+	body := string(data)
+	mediaURL := playlistURL
+	if !isMediaPlaylist(body) {
+		variant := ""
+		for _, line := range splitLines(body) {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				variant = line
+				break
+			}
+		}
+		if variant == "" {
+			return Report{}, fmt.Errorf("master playlist has no variants")
+		}
+		mediaURL = ResolveURL(playlistURL, variant)
+		data, _, err = fetch(mediaURL, opts.Headers)
+		if err != nil {
+			return Report{}, err
+		}
+		body = string(data)
+	}
+
+	var report Report
+	for _, line := range splitLines(body) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		report.Segments++
+		if IsAdSegment(ResolveURL(mediaURL, line), opts.adHosts()) {
+			report.AdSegments++
+		}
+	}
+	return report, nil
+}
+
+// PlayableURL returns the URL the player should open for the HLS stream at
+// streamURL. Some CDNs inject image ad segments into sub-playlists, which
+// leaves the player without video; such streams are played through the
+// proxy, which drops the ads. A stream made of nothing but ads is an error.
+func PlayableURL(streamURL string, opts Options) (string, error) {
+	report, err := Inspect(streamURL, opts)
+	if err != nil || report.AdSegments == 0 {
+		// Nothing to strip, or the playlist can't be read — let the player try anyway.
+		return streamURL, nil
+	}
+	if report.AdSegments == report.Segments {
+		return "", fmt.Errorf(
+			"the CDN is injecting ads into the HLS stream (%d/%d segments are ads). "+
+				"The stream is unplayable. Please use a different provider",
+			report.AdSegments, report.Segments,
+		)
+	}
+	proxied, err := Register(streamURL, opts)
+	if err != nil {
+		return "", fmt.Errorf("proxy stream: %w", err)
+	}
+	return proxied, nil
+}
+
+func isMediaPlaylist(body string) bool {
+	return strings.Contains(body, "#EXTINF") && !strings.Contains(body, "#EXT-X-STREAM-INF")
+}
+
+func splitLines(body string) []string {
+	return strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+}
+
+// rewriteURIAttr points the URI="..." attribute of a tag at the proxy.
+func (p *proxy) rewriteURIAttr(s *session, baseURL, line string, kind int) string {
+	return uriAttrRE.ReplaceAllStringFunc(line, func(attr string) string {
+		value := uriAttrRE.FindStringSubmatch(attr)[1]
+		resolved := ResolveURL(baseURL, value)
+		if !strings.HasPrefix(resolved, "http://") && !strings.HasPrefix(resolved, "https://") {
+			return attr
+		}
+		return `URI="` + p.localURL(s, resolved, kind) + `"`
+	})
+}
+
+// rewriteMap points an EXT-X-MAP tag at the proxy. The proxy fetches the
+// BYTERANGE of the init section itself, so the attribute is dropped.
+func (p *proxy) rewriteMap(s *session, mediaURL, line string) string {
+	byteRange := byteRangeAttrRE.FindStringSubmatch(line)
+	uri := uriAttrRE.FindStringSubmatch(line)
+	if byteRange == nil || uri == nil {
+		return p.rewriteURIAttr(s, mediaURL, line, kindRaw)
+	}
+	resolved := ResolveURL(mediaURL, uri[1])
+	index := s.addRange(resolved, byteRanges{}.resolve(resolved, byteRange[1]), kindRaw)
+	line = uriAttrRE.ReplaceAllLiteralString(line, `URI="`+p.indexURL(s, index, resolved, kindRaw)+`"`)
+	line = byteRangeAttrRE.ReplaceAllLiteralString(line, "")
+	line = strings.ReplaceAll(strings.ReplaceAll(line, ":,", ":"), ",,", ",")
+	return strings.TrimSuffix(line, ",")
+}
+
+// rewriteMaster points variant and rendition playlists at the proxy.
+func (p *proxy) rewriteMaster(s *session, masterURL, body string) string {
+	lines := splitLines(body)
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-MEDIA:"), strings.HasPrefix(line, "#EXT-X-I-FRAME-STREAM-INF:"):
+			line = p.rewriteURIAttr(s, masterURL, line, kindPlaylist)
+		case strings.HasPrefix(line, "#EXT-X-SESSION-KEY:"):
+			line = p.rewriteURIAttr(s, masterURL, line, kindRaw)
+		case strings.HasPrefix(line, "#"):
+		default:
+			line = p.localURL(s, ResolveURL(masterURL, line), kindPlaylist)
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rewriteMedia points segments, keys and init sections at the proxy and
+// drops ad segments. The discontinuities that switch into and out of an ad
+// break are dropped with it, since the content on either side is continuous.
+func (p *proxy) rewriteMedia(s *session, mediaURL, body string) string {
+	adHosts := s.opts.adHosts()
+	out := make([]string, 0, 64)
+	pending := make([]string, 0, 4)
+	afterAd := false
+	previous := -1
+	ranges := byteRanges{}
+
+	for _, line := range splitLines(body) {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXT-X-KEY:"):
+			pending = append(pending, p.rewriteURIAttr(s, mediaURL, line, kindRaw))
+			continue
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			pending = append(pending, p.rewriteMap(s, mediaURL, line))
+			continue
+		case strings.HasPrefix(line, "#EXT-X-ENDLIST"):
+			out = append(out, pending...)
+			pending = pending[:0]
+			out = append(out, line)
+			continue
+		case strings.HasPrefix(line, "#"):
+			pending = append(pending, line)
+			continue
+		}
+
+		// The proxy fetches the byte range itself and serves it as a whole
+		// segment, so the tag is not passed on.
+		byteRange := ""
+		for i, tag := range pending {
+			if value, ok := strings.CutPrefix(tag, "#EXT-X-BYTERANGE:"); ok {
+				byteRange = value
+				pending = append(pending[:i], pending[i+1:]...)
+				break
+			}
+		}
+		segmentURL := ResolveURL(mediaURL, line)
+		segmentRange := ranges.resolve(segmentURL, byteRange)
+		if IsAdSegment(segmentURL, adHosts) {
+			pending = keepPersistentTags(pending)
+			afterAd = true
+			continue
+		}
+		if afterAd {
+			pending = dropTag(pending, "#EXT-X-DISCONTINUITY")
+			afterAd = false
+		}
+
+		duration := 0.0
+		for _, tag := range pending {
+			if strings.HasPrefix(tag, "#EXTINF:") {
+				duration = parseExtInf(tag)
+			}
+		}
+		out = append(out, pending...)
+		pending = pending[:0]
+		index := s.addRange(segmentURL, segmentRange, kindSegment)
+		local := p.indexURL(s, index, segmentURL, kindSegment)
+		s.setDuration(index, duration)
+		if previous >= 0 && previous != index {
+			s.link(previous, index)
+		}
+		previous = index
+		out = append(out, local)
+	}
+	out = append(out, pending...)
+	return strings.Join(out, "\n") + "\n"
+}
+
+// segmentTags apply only to the segment that follows them.
+var segmentTags = []string{
+	"#EXTINF",
+	"#EXT-X-BYTERANGE",
+	"#EXT-X-DISCONTINUITY",
+	"#EXT-X-PROGRAM-DATE-TIME",
+	"#EXT-X-GAP",
+	"#EXT-X-BITRATE",
+}
+
+// keepPersistentTags drops the tags that belonged to a removed segment and
+// keeps playlist-level and state tags for the next one.
+func keepPersistentTags(tags []string) []string {
+	kept := tags[:0]
+	for _, tag := range tags {
+		if !isSegmentTag(tag) {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
+
+func isSegmentTag(tag string) bool {
+	for _, name := range segmentTags {
+		if tag == name || strings.HasPrefix(tag, name+":") {
+			return true
+		}
+	}
+	return false
+}
+
+func dropTag(tags []string, name string) []string {
+	kept := tags[:0]
+	for _, tag := range tags {
+		if tag != name {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
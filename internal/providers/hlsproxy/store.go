@@ -0,0 +1,259 @@
+package hlsproxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wraient/curd/internal/curdhost"
+)
+
+// Store is a persistent cache shared by every stream. Segments are kept
+// under their upstream URL and playlists that can no longer change under
+// playlistKey, so a stream warmed or played once starts from the store the
+// next time whichever session plays it.
+type Store interface {
+	Has(key string) bool
+	Get(key string) ([]byte, bool)
+	Put(key string, data []byte) error
+	// Fetch returns the data under key, calling load to fill it when it is
+	// missing.
+	Fetch(key string, load func() ([]byte, error)) ([]byte, error)
+}
+
+var (
+	storeMu   sync.RWMutex
+	diskStore Store
+	readAhead time.Duration
+)
+
+// SetStore makes every stream read through store and keep the next ahead
+// of playback downloaded into it. A nil store turns both off.
+func SetStore(store Store, ahead time.Duration) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	diskStore, readAhead = store, ahead
+}
+
+func currentStore() (Store, time.Duration) {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	return diskStore, readAhead
+}
+
+func playlistKey(rawURL string) string {
+	return rawURL + "#playlist"
+}
+
+// fetchPlaylist downloads the playlist at rawURL. Masters and ended media
+// playlists are read from and written to the store.
+func fetchPlaylist(rawURL string, headers map[string]string) (string, error) {
+	store, _ := currentStore()
+	if store != nil {
+		if data, ok := store.Get(playlistKey(rawURL)); ok {
+			return string(data), nil
+		}
+	}
+	data, _, err := fetch(rawURL, headers)
+	if err != nil {
+		return "", err
+	}
+	body := string(data)
+	if store != nil && (!isMediaPlaylist(body) || strings.Contains(body, "#EXT-X-ENDLIST")) {
+		if err := store.Put(playlistKey(rawURL), data); err != nil {
+			logf("HLS proxy cache write failed: %v", err)
+		}
+	}
+	return body, nil
+}
+
+// loadSegment returns the segment at index from the store, downloading it
+// when it is missing. Without a store it is downloaded every time.
+func (s *session) loadSegment(index int) (cachedSegment, error) {
+	store, _ := currentStore()
+	if store == nil {
+		return s.fetchSegment(index)
+	}
+	res, ok := s.get(index)
+	if !ok {
+		return cachedSegment{}, fmt.Errorf("unknown segment %d", index)
+	}
+	data, err := store.Fetch(segmentKey(res.url, res.byteRange), func() ([]byte, error) {
+		seg, err := fetchSegmentURL(res.url, withRange(s.opts.Headers, res.byteRange))
+		return seg.data, err
+	})
+	if err != nil {
+		return cachedSegment{}, err
+	}
+	return cachedSegment{data: data, contentType: sniffContentType(data)}, nil
+}
+
+// sniffContentType tells fMP4 from MPEG-TS for segments read back from the
+// store, which keeps no headers.
+func sniffContentType(data []byte) string {
+	if isMP4(data) {
+		return "video/mp4"
+	}
+	return "video/mp2t"
+}
+
+// readAheadFrom keeps the store filled with the segments covering ahead of
+// playback after index, restarting from wherever the player last read.
+func (s *session) readAheadFrom(index int) {
+	store, ahead := currentStore()
+	if store == nil || ahead <= 0 {
+		return
+	}
+	s.ahead.Kick(index, func(from int, moved func() bool) {
+		buffered := 0.0
+		res, ok := s.get(from)
+		for ok && res.next >= 0 && buffered < ahead.Seconds() {
+			if moved() {
+				return
+			}
+			next := res.next
+			if res, ok = s.get(next); !ok {
+				return
+			}
+			buffered += res.duration
+			if store.Has(segmentKey(res.url, res.byteRange)) {
+				continue
+			}
+			if _, err := s.loadSegment(next); err != nil {
+				logf("HLS proxy read-ahead failed: %v", err)
+				return
+			}
+		}
+	})
+}
+
+// Warm downloads the first length of the stream at playlistURL into the
+// store set with SetStore, so playing it later starts from disk. A master
+// playlist is warmed on its highest bandwidth variant, which is the one mpv
+// picks. playlistURL may also be a local URL returned by Register.
+func Warm(playlistURL string, opts Options, length time.Duration) error {
+	store, _ := currentStore()
+	if store == nil {
+		return fmt.Errorf("hls proxy has no store to warm")
+	}
+	if s, ok := localSession(playlistURL); ok {
+		res, _ := s.get(0)
+		playlistURL, opts = res.url, s.opts
+	}
+
+	mediaURL := playlistURL
+	body, err := fetchPlaylist(playlistURL, opts.Headers)
+	if err != nil {
+		return err
+	}
+	if !isMediaPlaylist(body) {
+		variant := bestVariant(body)
+		if variant == "" {
+			return fmt.Errorf("master playlist has no variants")
+		}
+		mediaURL = ResolveURL(playlistURL, variant)
+		if body, err = fetchPlaylist(mediaURL, opts.Headers); err != nil {
+			return err
+		}
+	}
+
+	buffered := 0.0
+	duration := 0.0
+	byteRange := ""
+	ranges := byteRanges{}
+	for _, line := range splitLines(body) {
+		if buffered >= length.Seconds() {
+			break
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "#EXTINF:"):
+			duration = parseExtInf(line)
+		case strings.HasPrefix(line, "#EXT-X-BYTERANGE:"):
+			byteRange = strings.TrimPrefix(line, "#EXT-X-BYTERANGE:")
+		case line == "", strings.HasPrefix(line, "#"):
+		default:
+			segmentURL := ResolveURL(mediaURL, line)
+			segmentRange := ranges.resolve(segmentURL, byteRange)
+			byteRange = ""
+			if IsAdSegment(segmentURL, opts.adHosts()) {
+				continue
+			}
+			_, err := store.Fetch(segmentKey(segmentURL, segmentRange), func() ([]byte, error) {
+				seg, err := fetchSegmentURL(segmentURL, withRange(opts.Headers, segmentRange))
+				return seg.data, err
+			})
+			if err != nil {
+				return err
+			}
+			buffered += duration
+		}
+	}
+	return nil
+}
+
+// bestVariant returns the URI of the highest bandwidth variant of a master
+// playlist.
+func bestVariant(body string) string {
+	best, bandwidth, pending := "", -1, -1
+	for _, line := range splitLines(body) {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			pending = 0
+			if match := bandwidthRE.FindStringSubmatch(line); len(match) == 2 {
+				pending, _ = strconv.Atoi(match[1])
+			}
+		case line == "", strings.HasPrefix(line, "#"):
+		case pending >= 0:
+			if pending > bandwidth {
+				best, bandwidth = line, pending
+			}
+			pending = -1
+		}
+	}
+	return best
+}
+
+// byteRanges resolves EXT-X-BYTERANGE values, whose offset defaults to the
+// end of the previous range of the same URL.
+type byteRanges map[string]int64
+
+// resolve returns the Range header for the segment at segmentURL described
+// by value, the part after "#EXT-X-BYTERANGE:" or the BYTERANGE attribute.
+// It is empty when value is.
+func (r byteRanges) resolve(segmentURL, value string) string {
+	lengthText, offsetText, hasOffset := strings.Cut(strings.TrimSpace(value), "@")
+	length, err := strconv.ParseInt(lengthText, 10, 64)
+	if err != nil || length <= 0 {
+		return ""
+	}
+	offset := r[segmentURL]
+	if hasOffset {
+		if offset, err = strconv.ParseInt(offsetText, 10, 64); err != nil {
+			return ""
+		}
+	}
+	r[segmentURL] = offset + length
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+}
+
+func parseExtInf(line string) float64 {
+	line = strings.TrimPrefix(line, "#EXTINF:")
+	if idx := strings.Index(line, ","); idx >= 0 {
+		line = line[:idx]
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(line), 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+func logf(format string, args ...interface{}) {
+	if curdhost.Log != nil {
+		curdhost.Log(fmt.Sprintf(format, args...))
+	}
+}
@@ -0,0 +1,71 @@
+package hlsproxy
+
+import "bytes"
+
+const (
+	tsPacketSize = 188
+	tsSyncByte   = 0x47
+	// tsSyncSearchLimit bounds how far into a disguised segment the real
+	// transport stream is looked for.
+	tsSyncSearchLimit = 64 << 10
+)
+
+var (
+	pngSignature  = []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}
+	pngIENDMarker = []byte{0x49, 0x45, 0x4E, 0x44, 0xAE, 0x42, 0x60, 0x82}
+)
+
+// Unwrap returns the media payload of a segment that some CDNs disguise by
+// prefixing it with an image. A PNG wrapper is cut at its IEND chunk; any
+// other prefix is skipped up to the first run of MPEG-TS packets. Segments
+// that are not wrapped are returned unchanged.
+func Unwrap(data []byte) []byte {
+	if isTSAt(data, 0) || isMP4(data) {
+		return data
+	}
+	if bytes.HasPrefix(data, pngSignature) {
+		if idx := bytes.Index(data, pngIENDMarker); idx >= 0 {
+			return data[idx+len(pngIENDMarker):]
+		}
+	}
+	if offset := findTSSync(data); offset > 0 {
+		return data[offset:]
+	}
+	return data
+}
+
+// isTSAt reports whether three consecutive transport stream packets start
+// at offset.
+func isTSAt(data []byte, offset int) bool {
+	for i := 0; i < 3; i++ {
+		pos := offset + i*tsPacketSize
+		if pos >= len(data) || data[pos] != tsSyncByte {
+			return false
+		}
+	}
+	return true
+}
+
+func findTSSync(data []byte) int {
+	limit := len(data)
+	if limit > tsSyncSearchLimit {
+		limit = tsSyncSearchLimit
+	}
+	for offset := 0; offset < limit; offset++ {
+		if data[offset] == tsSyncByte && isTSAt(data, offset) {
+			return offset
+		}
+	}
+	return -1
+}
+
+func isMP4(data []byte) bool {
+	if len(data) < 8 {
+		return false
+	}
+	switch string(data[4:8]) {
+	case "ftyp", "styp", "moof", "sidx", "moov":
+		return true
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package megaplay
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -17,8 +18,8 @@ var (
 	anilistGQLURL   = "https://graphql.anilist.co"
 )
 
-func newRequest(method, rawURL, referer string) (*http.Request, error) {
-	req, err := http.NewRequest(method, rawURL, nil)
+func newRequest(ctx context.Context, method, rawURL, referer string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -29,8 +30,8 @@ func newRequest(method, rawURL, referer string) (*http.Request, error) {
 	return req, nil
 }
 
-func fetchString(rawURL, referer string) (string, error) {
-	req, err := newRequest(http.MethodGet, rawURL, referer)
+func fetchString(ctx context.Context, rawURL, referer string) (string, error) {
+	req, err := newRequest(ctx, http.MethodGet, rawURL, referer)
 	if err != nil {
 		return "", err
 	}
@@ -50,8 +51,8 @@ func fetchString(rawURL, referer string) (string, error) {
 	return string(body), nil
 }
 
-func fetchJSON(rawURL, referer string, dest any) error {
-	req, err := newRequest(http.MethodGet, rawURL, referer)
+func fetchJSON(ctx context.Context, rawURL, referer string, dest any) error {
+	req, err := newRequest(ctx, http.MethodGet, rawURL, referer)
 	if err != nil {
 		return err
 	}
@@ -81,7 +82,7 @@ func fetchJSON(rawURL, referer string, dest any) error {
 	return nil
 }
 
-func searchAniList(query string) (*anilistResponse, error) {
+func searchAniList(ctx context.Context, query string) (*anilistResponse, error) {
 	queryEscaped, err := json.Marshal(query)
 	if err != nil {
 		return nil, err
@@ -97,7 +98,7 @@ func searchAniList(query string) (*anilistResponse, error) {
 		return nil, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, anilistGQLURL, bytes.NewReader(bodyBytes))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, anilistGQLURL, bytes.NewReader(bodyBytes))
 	if err != nil {
 		return nil, err
 	}
@@ -126,13 +127,13 @@ func searchAniList(query string) (*anilistResponse, error) {
 	return &result, nil
 }
 
-func fetchAniListByMalID(malID int) (*anilistMedia, error) {
+func fetchAniListByMalID(ctx context.Context, malID int) (*anilistMedia, error) {
 	gql := fmt.Sprintf(
 		`{"query":"query{Media(idMal:%d,type:ANIME){title{english romaji}idMal episodes}}"}`,
 		malID,
 	)
 
-	req, err := http.NewRequest(http.MethodPost, anilistGQLURL, bytes.NewReader([]byte(gql)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, anilistGQLURL, bytes.NewReader([]byte(gql)))
 	if err != nil {
 		return nil, err
 	}
@@ -1,12 +1,13 @@
 package megaplay
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
 )
 
-func episodesList(showID, mode string) ([]string, error) {
+func episodesList(ctx context.Context, showID, mode string) ([]string, error) {
 	malID, err := parseMalID(showID)
 	if err != nil {
 		return nil, err
@@ -14,7 +15,7 @@ func episodesList(showID, mode string) ([]string, error) {
 	_ = mode
 
 	// Query AniList by MAL ID to get the episode count.
-	media, err := fetchAniListByMalID(malID)
+	media, err := fetchAniListByMalID(ctx, malID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch episode count for mal id %d: %w", malID, err)
 	}
@@ -0,0 +1,104 @@
+package megaplay
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/wraient/curd/internal/providers"
+	"github.com/wraient/curd/internal/providers/cassette"
+	"github.com/wraient/curd/internal/providers/hlsproxy"
+)
+
+func TestFixtureSearchAnime(t *testing.T) {
+	cassette.Use(t, "search")
+
+	options, err := searchAnime(context.Background(), "Frieren (28 episodes) [mkissa]", "sub")
+	if err != nil {
+		t.Fatalf("searchAnime: %v", err)
+	}
+	if len(options) != 2 {
+		t.Fatalf("expected entries without MAL ids to be skipped, got %d options", len(options))
+	}
+	if options[0].Key != "52991" || options[0].Label != "Frieren: Beyond Journey's End · 28 eps" {
+		t.Fatalf("unexpected first option %+v", options[0])
+	}
+	if options[1].Key != "59978" || options[1].Label != "Frieren: Beyond Journey's End Season 2" {
+		t.Fatalf("unexpected second option %+v", options[1])
+	}
+}
+
+func TestFixtureEpisodesList(t *testing.T) {
+	cassette.Use(t, "episodes")
+
+	episodes, err := episodesList(context.Background(), "52991", "sub")
+	if err != nil {
+		t.Fatalf("episodesList: %v", err)
+	}
+	if len(episodes) != 28 || episodes[0] != "1" || episodes[27] != "28" {
+		t.Fatalf("unexpected episodes %v", episodes)
+	}
+}
+
+func TestFixtureEpisodeStreams(t *testing.T) {
+	cassette.Use(t, "streams")
+
+	links, hints, err := getEpisodeStreamsForMode(context.Background(), "52991", providers.PlaybackConfig{SubOrDub: "sub"}, 1)
+	if err != nil {
+		t.Fatalf("getEpisodeStreamsForMode: %v", err)
+	}
+	want := "https://cdn.dotstream.buzz/anime/8a1f4c/master.m3u8"
+	if len(links) != 1 || links[0] != want {
+		t.Fatalf("unexpected links %v", links)
+	}
+	hint := hints[want]
+	if hint.Subtitle != "https://cdn.dotstream.buzz/subs/8a1f4c/eng-2.vtt" || hint.Referrer != "https://megaplay.buzz/" {
+		t.Fatalf("unexpected hint %+v", hint)
+	}
+}
+
+func TestFixtureEpisodeStreamsProxiesAdStream(t *testing.T) {
+	cassette.Use(t, "streams_ads")
+	t.Cleanup(hlsproxy.ResetForTest)
+
+	links, _, err := getEpisodeStreamsForMode(context.Background(), "52991", providers.PlaybackConfig{SubOrDub: "sub"}, 2)
+	if err != nil {
+		t.Fatalf("getEpisodeStreamsForMode: %v", err)
+	}
+	if len(links) != 1 || !strings.HasPrefix(links[0], "http://127.0.0.1:") {
+		t.Fatalf("expected ad stream to be proxied locally, got %v", links)
+	}
+
+	master := fetchLocal(t, links[0])
+	variant := ""
+	for _, line := range strings.Split(master, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			variant = line
+			break
+		}
+	}
+	media := fetchLocal(t, variant)
+	if strings.Contains(media, "ibyteimg") || strings.Contains(media, "#EXT-X-DISCONTINUITY") {
+		t.Fatalf("ad segments not stripped:\n%s", media)
+	}
+	if got := strings.Count(media, "#EXTINF"); got != 1 {
+		t.Fatalf("expected the single content segment, got %d:\n%s", got, media)
+	}
+}
+
+// fetchLocal reads from the local proxy, which the cassette client can't reach.
+func fetchLocal(t *testing.T, rawURL string) string {
+	t.Helper()
+	resp, err := http.Get(rawURL)
+	if err != nil {
+		t.Fatalf("get %s: %v", rawURL, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("get %s: status %d: %s", rawURL, resp.StatusCode, body)
+	}
+	return string(body)
+}
@@ -1,6 +1,7 @@
 package megaplay
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -8,7 +9,7 @@ import (
 	"github.com/wraient/curd/internal/providers"
 )
 
-func searchAnime(query, mode string) ([]providers.SelectionOption, error) {
+func searchAnime(ctx context.Context, query, mode string) ([]providers.SelectionOption, error) {
 	query = strings.TrimSpace(query)
 	if query == "" {
 		return nil, fmt.Errorf("empty search query")
@@ -26,7 +27,7 @@ func searchAnime(query, mode string) ([]providers.SelectionOption, error) {
 		return nil, fmt.Errorf("empty search query after sanitization")
 	}
 
-	result, err := searchAniList(query)
+	result, err := searchAniList(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -60,6 +61,7 @@ func searchAnime(query, mode string) ([]providers.SelectionOption, error) {
 			Label:     label,
 			Title:     title,
 			Thumbnail: fmt.Sprintf("%d", media.ID),
+			Episodes:  episodes,
 			ExtraData: SearchItem{
 				MalID:    malID,
 				Title:    title,
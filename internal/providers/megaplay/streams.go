@@ -1,69 +1,94 @@
 package megaplay
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
 
 	"github.com/wraient/curd/internal/providers"
+	"github.com/wraient/curd/internal/providers/hlsproxy"
 )
 
 var dataIDRE = regexp.MustCompile(`data-id="(\d+)"`)
 
-func getEpisodeStreamsForMode(malIDStr string, config providers.PlaybackConfig, epNo int) ([]string, map[string]providers.StreamPlaybackHint, error) {
-	malID, err := parseMalID(malIDStr)
+func getEpisodeStreamsForMode(ctx context.Context, malIDStr string, config providers.PlaybackConfig, epNo int) ([]string, map[string]providers.StreamPlaybackHint, error) {
+	stream, err := resolveEpisodeStream(ctx, malIDStr, config, epNo)
 	if err != nil {
 		return nil, nil, err
 	}
+	links, hints := providers.LinksFromStreams([]providers.Stream{stream})
+	return links, hints, nil
+}
+
+func resolveEpisodeStream(ctx context.Context, malIDStr string, config providers.PlaybackConfig, epNo int) (providers.Stream, error) {
+	malID, err := parseMalID(malIDStr)
+	if err != nil {
+		return providers.Stream{}, err
+	}
 	if epNo <= 0 {
-		return nil, nil, fmt.Errorf("invalid episode number %d", epNo)
+		return providers.Stream{}, fmt.Errorf("invalid episode number %d", epNo)
 	}
 
 	mode := providers.NormalizeTranslationType(config.SubOrDub)
 
 	// Step 1: Fetch the stream page HTML.
 	streamPageURL := fmt.Sprintf("%s/stream/mal/%d/%d/%s", megaplayBaseURL, malID, epNo, mode)
-	html, err := fetchString(streamPageURL, megaplayBaseURL+"/")
+	html, err := fetchString(ctx, streamPageURL, megaplayBaseURL+"/")
 	if err != nil {
-		return nil, nil, fmt.Errorf("fetch stream page: %w", err)
+		return providers.Stream{}, fmt.Errorf("fetch stream page: %w", err)
 	}
 
 	// Step 2: Extract data-id from the HTML.
 	matches := dataIDRE.FindStringSubmatch(html)
 	if len(matches) < 2 {
-		return nil, nil, fmt.Errorf("megaplay data-id not found in stream page for mal %d ep %d %s", malID, epNo, mode)
+		return providers.Stream{}, fmt.Errorf("megaplay data-id not found in stream page for mal %d ep %d %s", malID, epNo, mode)
 	}
 	dataID := matches[1]
 
 	// Step 3: Fetch stream sources JSON.
 	sourcesURL := fmt.Sprintf("%s/stream/getSources?id=%s", megaplayBaseURL, dataID)
 	var payload megaplaySourcesResponse
-	if err := fetchJSON(sourcesURL, megaplayBaseURL+"/", &payload); err != nil {
-		return nil, nil, fmt.Errorf("fetch stream sources: %w", err)
+	if err := fetchJSON(ctx, sourcesURL, megaplayBaseURL+"/", &payload); err != nil {
+		return providers.Stream{}, fmt.Errorf("fetch stream sources: %w", err)
 	}
 
 	// Step 4: Extract HLS stream URL.
 	streamURL := strings.TrimSpace(payload.streamFile())
 	if streamURL == "" {
-		return nil, nil, fmt.Errorf("megaplay stream url missing for mal %d ep %d", malID, epNo)
+		return providers.Stream{}, fmt.Errorf("megaplay stream url missing for mal %d ep %d", malID, epNo)
 	}
 
-	// Step 5: Validate the HLS stream — megaplay CDN has been observed injecting
-	// PNG ad segments into sub-playlists that cause mpv to open with no video.
-	if err := validateHLSStream(streamURL); err != nil {
-		return nil, nil, err
+	// Step 5: Route streams with injected ad segments through the local proxy.
+	streamURL, err = hlsproxy.PlayableURL(streamURL, hlsproxy.Options{
+		Headers: map[string]string{
+			"User-Agent": userAgent,
+			"Referer":    megaplayBaseURL + "/",
+		},
+		Prefetch: 2,
+	})
+	if err != nil {
+		return providers.Stream{}, err
 	}
 
 	// Step 6: Pick the best subtitle track.
 	subtitle := pickSubtitleTrack(payload, mode)
 
-	hints := map[string]providers.StreamPlaybackHint{
-		streamURL: {
-			Referrer: megaplayBaseURL + "/",
-			Subtitle: subtitle,
-		},
+	stream := providers.Stream{
+		URL:       streamURL,
+		Container: providers.ContainerHLS,
+		AudioLang: "ja",
+		Subtitles: subtitleTracks(payload, mode, subtitle),
+		Headers:   map[string]string{"Referer": megaplayBaseURL + "/"},
+		Server:    "megaplay",
 	}
-	return []string{streamURL}, hints, nil
+	if mode == "dub" {
+		stream.AudioLang = "en"
+	}
+	if len(stream.Subtitles) > 0 {
+		stream.SubtitleMode = providers.SubtitlesSoft
+	}
+	return stream, nil
 }
 
 // pickSubtitleTrack selects the best English subtitle from the response.
@@ -125,3 +150,27 @@ func pickSubtitleTrack(payload megaplaySourcesResponse, mode string) string {
 	}
 	return first
 }
+
+// subtitleTracks lists the caption tracks with the picked one as default.
+func subtitleTracks(payload megaplaySourcesResponse, mode, picked string) []providers.SubtitleTrack {
+	if picked == "" {
+		return nil
+	}
+	tracks := []providers.SubtitleTrack{{URL: picked, Default: true}}
+	for _, track := range payload.allSubTracks() {
+		file := strings.TrimSpace(track.fileURL())
+		if file == "" {
+			continue
+		}
+		if file == picked {
+			tracks[0].Label = track.labelOrTitle()
+			continue
+		}
+		kind := strings.ToLower(strings.TrimSpace(track.kindOrType()))
+		if kind != "" && !strings.Contains(kind, "caption") && !strings.Contains(kind, "subtitle") && !strings.Contains(kind, "sub") {
+			continue
+		}
+		tracks = append(tracks, providers.SubtitleTrack{URL: file, Label: track.labelOrTitle()})
+	}
+	return tracks
+}
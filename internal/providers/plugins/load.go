@@ -0,0 +1,161 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wraient/curd/internal/providers"
+)
+
+// Manifest describes a provider plugin.
+type Manifest struct {
+	Name            string   `json:"name"`
+	Aliases         []string `json:"aliases,omitempty"`
+	Referrer        string   `json:"referrer,omitempty"`
+	DefaultDisabled bool     `json:"default_disabled,omitempty"`
+	DisableReason   string   `json:"disable_reason,omitempty"`
+	OptOutToken     string   `json:"opt_out_token,omitempty"`
+	FallbackPrompt  string   `json:"fallback_prompt,omitempty"`
+	// Executable is resolved relative to the manifest directory.
+	Executable     string   `json:"executable,omitempty"`
+	Args           []string `json:"args,omitempty"`
+	TimeoutSeconds float64  `json:"timeout_seconds,omitempty"`
+}
+
+// Meta converts the manifest into registry metadata.
+func (m Manifest) Meta() providers.Meta {
+	return providers.Meta{
+		Name:            m.Name,
+		Aliases:         m.Aliases,
+		Referrer:        m.Referrer,
+		DefaultDisabled: m.DefaultDisabled,
+		DisableReason:   m.DisableReason,
+		OptOutToken:     m.OptOutToken,
+		FallbackPrompt:  m.FallbackPrompt,
+	}
+}
+
+// ReadManifest parses the manifest at path and resolves its executable.
+func ReadManifest(path string) (Manifest, string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Manifest{}, "", err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return Manifest{}, "", fmt.Errorf("invalid provider plugin manifest %s: %w", path, err)
+	}
+	manifest.Name = strings.TrimSpace(manifest.Name)
+	if manifest.Name == "" {
+		return Manifest{}, "", fmt.Errorf("provider plugin manifest %s has no name", path)
+	}
+
+	executable := strings.TrimSpace(manifest.Executable)
+	if executable == "" {
+		executable = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if !filepath.IsAbs(executable) {
+		executable = filepath.Join(filepath.Dir(path), executable)
+	}
+	info, err := os.Stat(executable)
+	if err != nil {
+		return Manifest{}, "", fmt.Errorf("provider plugin %s: %w", manifest.Name, err)
+	}
+	if info.IsDir() {
+		return Manifest{}, "", fmt.Errorf("provider plugin %s: %s is a directory", manifest.Name, executable)
+	}
+	return manifest, executable, nil
+}
+
+var (
+	loadedMu sync.Mutex
+	loaded   = map[string]*Plugin{}
+)
+
+// LoadDir registers every plugin manifest in dir with the provider registry.
+// Plugins whose name is already registered are skipped so they cannot shadow
+// built-in providers. A missing directory is not an error. It returns the
+// canonical names of the plugins that were registered.
+func LoadDir(dir string, logf func(string)) ([]string, error) {
+	if logf == nil {
+		logf = func(string) {}
+	}
+	manifests, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(manifests)
+
+	var names []string
+	var problems []string
+	for _, path := range manifests {
+		manifest, executable, err := ReadManifest(path)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if _, exists := providers.MetaFor(manifest.Name); exists {
+			problems = append(problems, fmt.Sprintf("provider plugin %s skipped: a provider with that name is already registered", manifest.Name))
+			continue
+		}
+
+		plugin := newPlugin(manifest, executable, logf)
+		if err := register(manifest.Meta(), plugin); err != nil {
+			problems = append(problems, fmt.Sprintf("provider plugin %s skipped: %v", manifest.Name, err))
+			continue
+		}
+		canonical := providers.NormalizeName(manifest.Name)
+		plugin.manifest.Name = canonical
+
+		loadedMu.Lock()
+		loaded[canonical] = plugin
+		loadedMu.Unlock()
+
+		logf(fmt.Sprintf("Registered provider plugin %s (%s)", canonical, executable))
+		names = append(names, canonical)
+	}
+
+	if len(problems) > 0 {
+		return names, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return names, nil
+}
+
+// register adds plugin to the registry, turning Register's panics on
+// invalid names into errors.
+func register(meta providers.Meta, plugin *Plugin) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%v", recovered)
+		}
+	}()
+	providers.Register(meta, func() providers.Provider {
+		return &Provider{plugin: plugin}
+	})
+	return nil
+}
+
+// CloseAll stops every running plugin process.
+func CloseAll() {
+	loadedMu.Lock()
+	plugins := make([]*Plugin, 0, len(loaded))
+	for _, plugin := range loaded {
+		plugins = append(plugins, plugin)
+	}
+	loadedMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, plugin := range plugins {
+		wg.Add(1)
+		go func(plugin *Plugin) {
+			defer wg.Done()
+			plugin.Close()
+		}(plugin)
+	}
+	wg.Wait()
+}
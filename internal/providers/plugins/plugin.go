@@ -0,0 +1,286 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wraient/curd/internal/providers"
+)
+
+const (
+	// DefaultTimeout bounds a single plugin call when the manifest sets none.
+	DefaultTimeout = 30 * time.Second
+	// handshakeTimeout bounds the Name call made after start-up.
+	handshakeTimeout = 5 * time.Second
+	// maxCrashes is how many times in a row a plugin may fail before curd
+	// stops restarting it for the rest of the session.
+	maxCrashes = 3
+	stopGrace  = 2 * time.Second
+)
+
+// Plugin manages the process behind one provider plugin. The process is
+// started on first use and restarted after a crash or timeout, so a
+// misbehaving plugin only fails its own calls.
+type Plugin struct {
+	manifest Manifest
+	command  string
+	logf     func(string)
+
+	nextID int64
+
+	mu      sync.Mutex
+	proc    *process
+	crashes int
+}
+
+func newPlugin(manifest Manifest, command string, logf func(string)) *Plugin {
+	if logf == nil {
+		logf = func(string) {}
+	}
+	return &Plugin{manifest: manifest, command: command, logf: logf}
+}
+
+// Manifest returns the manifest the plugin was loaded from.
+func (pl *Plugin) Manifest() Manifest {
+	return pl.manifest
+}
+
+func (pl *Plugin) timeout() time.Duration {
+	if pl.manifest.TimeoutSeconds > 0 {
+		return time.Duration(pl.manifest.TimeoutSeconds * float64(time.Second))
+	}
+	return DefaultTimeout
+}
+
+// ensureProcess returns the running process, starting it if needed.
+func (pl *Plugin) ensureProcess() (*process, error) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	if pl.proc != nil && !pl.proc.exited() {
+		return pl.proc, nil
+	}
+	pl.proc = nil
+	if pl.crashes >= maxCrashes {
+		return nil, fmt.Errorf("provider plugin %s failed %d times in a row and was disabled for this session", pl.manifest.Name, pl.crashes)
+	}
+
+	cmd := exec.Command(pl.command, pl.manifest.Args...)
+	proc, err := startProcess(pl.manifest.Name, cmd, pl.logf)
+	if err != nil {
+		pl.crashes++
+		return nil, err
+	}
+
+	raw, err := proc.call(context.Background(), atomic.AddInt64(&pl.nextID, 1), MethodName, nil, handshakeTimeout)
+	if err != nil {
+		pl.crashes++
+		go proc.stop(0)
+		return nil, fmt.Errorf("provider plugin %s handshake failed: %w", pl.manifest.Name, err)
+	}
+	var reported string
+	if err := json.Unmarshal(raw, &reported); err == nil && reported != "" && providers.NormalizeName(reported) != pl.manifest.Name {
+		pl.logf(fmt.Sprintf("Provider plugin %s reports its name as %q", pl.manifest.Name, reported))
+	}
+
+	pl.proc = proc
+	return proc, nil
+}
+
+// call runs method on the plugin and decodes the result into dest.
+func (pl *Plugin) call(ctx context.Context, method string, params, dest interface{}) error {
+	proc, err := pl.ensureProcess()
+	if err != nil {
+		return err
+	}
+
+	raw, err := proc.call(ctx, atomic.AddInt64(&pl.nextID, 1), method, params, pl.timeout())
+	if err != nil {
+		if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+			// The caller gave up; the plugin itself may be fine.
+			return err
+		}
+		var rpcErr *RPCError
+		if errors.As(err, &rpcErr) {
+			// The plugin answered; it is healthy even if the call failed.
+			pl.resetCrashes()
+			return err
+		}
+		pl.discard(proc)
+		return err
+	}
+	pl.resetCrashes()
+	if dest == nil {
+		return nil
+	}
+	if err := json.Unmarshal(raw, dest); err != nil {
+		return fmt.Errorf("provider plugin %s returned an invalid %s result: %w", pl.manifest.Name, method, err)
+	}
+	return nil
+}
+
+func (pl *Plugin) resetCrashes() {
+	pl.mu.Lock()
+	pl.crashes = 0
+	pl.mu.Unlock()
+}
+
+// discard kills a process that crashed or stopped answering so the next call
+// starts a fresh one.
+func (pl *Plugin) discard(proc *process) {
+	pl.mu.Lock()
+	if pl.proc == proc {
+		pl.proc = nil
+	}
+	pl.crashes++
+	pl.mu.Unlock()
+	if !proc.exited() {
+		_ = proc.cmd.Process.Kill()
+	}
+}
+
+// Close stops the plugin process if it is running.
+func (pl *Plugin) Close() {
+	pl.mu.Lock()
+	proc := pl.proc
+	pl.proc = nil
+	pl.mu.Unlock()
+	if proc != nil {
+		proc.stop(stopGrace)
+	}
+}
+
+// Provider adapts a Plugin to the providers interfaces.
+type Provider struct {
+	plugin *Plugin
+}
+
+func (p *Provider) Name() string {
+	return p.plugin.manifest.Name
+}
+
+func (p *Provider) SearchAnime(query, mode string) ([]providers.SelectionOption, error) {
+	return p.SearchAnimeContext(context.Background(), query, mode)
+}
+
+func (p *Provider) SearchAnimeContext(ctx context.Context, query, mode string) ([]providers.SelectionOption, error) {
+	var options []Option
+	if err := p.plugin.call(ctx, MethodSearchAnime, SearchParams{Query: query, Mode: mode}, &options); err != nil {
+		return nil, err
+	}
+	result := make([]providers.SelectionOption, 0, len(options))
+	for _, option := range options {
+		if strings.TrimSpace(option.Key) == "" {
+			continue
+		}
+		result = append(result, providers.SelectionOption{
+			Key:       option.Key,
+			Label:     option.Label,
+			Title:     option.Title,
+			Thumbnail: option.Thumbnail,
+			Year:      option.Year,
+			Episodes:  option.Episodes,
+		})
+	}
+	return result, nil
+}
+
+// SearchListsModes reports true since the mode is passed on to the plugin,
+// whose results may depend on it.
+func (p *Provider) SearchListsModes() bool {
+	return true
+}
+
+func (p *Provider) EpisodesList(showID, mode string) ([]string, error) {
+	return p.EpisodesListContext(context.Background(), showID, mode)
+}
+
+func (p *Provider) EpisodesListContext(ctx context.Context, showID, mode string) ([]string, error) {
+	var episodes []string
+	if err := p.plugin.call(ctx, MethodEpisodesList, EpisodesParams{ShowID: showID, Mode: mode}, &episodes); err != nil {
+		return nil, err
+	}
+	return episodes, nil
+}
+
+func (p *Provider) GetEpisodeURL(config providers.PlaybackConfig, id string, epNo int) ([]string, error) {
+	return p.GetEpisodeURLContext(context.Background(), config, id, epNo)
+}
+
+func (p *Provider) GetEpisodeURLContext(ctx context.Context, config providers.PlaybackConfig, id string, epNo int) ([]string, error) {
+	return p.GetEpisodeURLForModeContext(ctx, config, id, epNo, config.SubOrDub)
+}
+
+func (p *Provider) GetEpisodeURLForMode(config providers.PlaybackConfig, id string, epNo int, mode string) ([]string, error) {
+	return p.GetEpisodeURLForModeContext(context.Background(), config, id, epNo, mode)
+}
+
+func (p *Provider) GetEpisodeURLForModeContext(ctx context.Context, config providers.PlaybackConfig, id string, epNo int, mode string) ([]string, error) {
+	links, _, err := p.GetEpisodeURLForModeWithHintsContext(ctx, config, id, epNo, mode)
+	return links, err
+}
+
+func (p *Provider) GetEpisodeURLForModeWithHints(config providers.PlaybackConfig, id string, epNo int, mode string) ([]string, map[string]providers.StreamPlaybackHint, error) {
+	return p.GetEpisodeURLForModeWithHintsContext(context.Background(), config, id, epNo, mode)
+}
+
+func (p *Provider) GetEpisodeURLForModeWithHintsContext(ctx context.Context, config providers.PlaybackConfig, id string, epNo int, mode string) ([]string, map[string]providers.StreamPlaybackHint, error) {
+	params := EpisodeURLParams{
+		Config:  PlaybackConfig{SubOrDub: config.SubOrDub, SubStyle: config.SubStyle},
+		ID:      id,
+		Episode: epNo,
+		Mode:    providers.NormalizeTranslationType(mode),
+	}
+	var result EpisodeURLResult
+	if err := p.plugin.call(ctx, MethodGetEpisodeURL, params, &result); err != nil {
+		return nil, nil, err
+	}
+	var hints map[string]providers.StreamPlaybackHint
+	if len(result.Hints) > 0 {
+		hints = make(map[string]providers.StreamPlaybackHint, len(result.Hints))
+		for link, hint := range result.Hints {
+			hints[link] = providers.StreamPlaybackHint{Referrer: hint.Referrer, Subtitle: hint.Subtitle}
+		}
+	}
+	if referrer := p.plugin.manifest.Referrer; referrer != "" {
+		for _, link := range result.Links {
+			if hints == nil {
+				hints = map[string]providers.StreamPlaybackHint{}
+			}
+			if hint := hints[link]; hint.Referrer == "" {
+				hint.Referrer = referrer
+				hints[link] = hint
+			}
+		}
+	}
+	return result.Links, hints, nil
+}
+
+// ResolveProviderID keeps providerID unchanged when the plugin does not
+// implement the method.
+func (p *Provider) ResolveProviderID(providerID, query string) (string, error) {
+	return p.ResolveProviderIDContext(context.Background(), providerID, query)
+}
+
+func (p *Provider) ResolveProviderIDContext(ctx context.Context, providerID, query string) (string, error) {
+	var resolved string
+	err := p.plugin.call(ctx, MethodResolveProviderID, ResolveIDParams{ProviderID: providerID, Query: query}, &resolved)
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) && rpcErr.Code == CodeMethodNotFound {
+		return providerID, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if resolved == "" {
+		return providerID, nil
+	}
+	return resolved, nil
+}
@@ -0,0 +1,229 @@
+package plugins_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wraient/curd/internal/providers"
+	"github.com/wraient/curd/internal/providers/plugins"
+)
+
+const helperEnv = "CURD_PLUGIN_TEST_HELPER"
+
+// fakeProvider is served by the test binary when it runs as a plugin.
+type fakeProvider struct{}
+
+func (fakeProvider) Name() string { return "fakeplugin" }
+
+func (fakeProvider) SearchAnime(query, mode string) ([]providers.SelectionOption, error) {
+	switch query {
+	case "crash":
+		os.Exit(3)
+	case "hang":
+		time.Sleep(time.Minute)
+	case "fail":
+		return nil, fmt.Errorf("site is down")
+	}
+	return []providers.SelectionOption{
+		{Key: "frieren-1", Label: query + " (" + mode + ")", Title: query, Year: 2023, Episodes: 28},
+		{Key: "", Label: "dropped"},
+	}, nil
+}
+
+func (fakeProvider) EpisodesList(showID, mode string) ([]string, error) {
+	return []string{"1", "2", "3"}, nil
+}
+
+func (fakeProvider) GetEpisodeURL(config providers.PlaybackConfig, id string, epNo int) ([]string, error) {
+	return nil, fmt.Errorf("unused")
+}
+
+func (fakeProvider) GetEpisodeURLForModeWithHints(config providers.PlaybackConfig, id string, epNo int, mode string) ([]string, map[string]providers.StreamPlaybackHint, error) {
+	link := fmt.Sprintf("https://cdn.example/%s/%d/%s.m3u8", id, epNo, mode)
+	other := link + "?backup=1"
+	return []string{link, other}, map[string]providers.StreamPlaybackHint{
+		link: {Subtitle: "https://cdn.example/en.vtt"},
+	}, nil
+}
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperEnv) == "1" {
+		if err := plugins.Serve(fakeProvider{}, os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// writeManifest points a plugin manifest at the test binary.
+func writeManifest(t *testing.T, dir, name string, extra map[string]interface{}) {
+	t.Helper()
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("executable: %v", err)
+	}
+	manifest := map[string]interface{}{
+		"name":       name,
+		"executable": executable,
+		"referrer":   "https://plugin.example/",
+	}
+	for key, value := range extra {
+		manifest[key] = value
+	}
+	raw, _ := json.Marshal(manifest)
+	if err := os.WriteFile(filepath.Join(dir, name+".json"), raw, 0644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+}
+
+func loadPlugin(t *testing.T, name string, extra map[string]interface{}) providers.Provider {
+	t.Helper()
+	t.Setenv(helperEnv, "1")
+	dir := t.TempDir()
+	writeManifest(t, dir, name, extra)
+
+	names, err := plugins.LoadDir(dir, nil)
+	if err != nil {
+		t.Fatalf("LoadDir: %v", err)
+	}
+	if len(names) != 1 || names[0] != name {
+		t.Fatalf("unexpected registered names %v", names)
+	}
+	t.Cleanup(plugins.CloseAll)
+
+	provider, err := providers.New(name)
+	if err != nil {
+		t.Fatalf("providers.New: %v", err)
+	}
+	return provider
+}
+
+func TestPluginRoundTrip(t *testing.T) {
+	provider := loadPlugin(t, "roundtrip", map[string]interface{}{"aliases": []string{"round trip"}})
+
+	if meta, ok := providers.MetaFor("round trip"); !ok || meta.Referrer != "https://plugin.example/" {
+		t.Fatalf("manifest meta not registered: %+v", meta)
+	}
+
+	options, err := provider.SearchAnime("Frieren", "sub")
+	if err != nil {
+		t.Fatalf("SearchAnime: %v", err)
+	}
+	if len(options) != 1 || options[0].Key != "frieren-1" || options[0].Label != "Frieren (sub)" {
+		t.Fatalf("unexpected options %+v", options)
+	}
+	if options[0].Year != 2023 || options[0].Episodes != 28 {
+		t.Fatalf("year and episodes not passed through: %+v", options[0])
+	}
+
+	episodes, err := provider.EpisodesList("frieren-1", "sub")
+	if err != nil || len(episodes) != 3 {
+		t.Fatalf("EpisodesList: %v %v", episodes, err)
+	}
+
+	links, hints, err := provider.(providers.HintResolver).GetEpisodeURLForModeWithHints(providers.PlaybackConfig{SubOrDub: "sub"}, "frieren-1", 2, "dub")
+	if err != nil {
+		t.Fatalf("GetEpisodeURLForModeWithHints: %v", err)
+	}
+	want := "https://cdn.example/frieren-1/2/dub.m3u8"
+	if len(links) != 2 || links[0] != want {
+		t.Fatalf("unexpected links %v", links)
+	}
+	if hint := hints[want]; hint.Subtitle != "https://cdn.example/en.vtt" || hint.Referrer != "https://plugin.example/" {
+		t.Fatalf("expected plugin hint plus manifest referrer, got %+v", hint)
+	}
+	if hint := hints[links[1]]; hint.Referrer != "https://plugin.example/" {
+		t.Fatalf("expected manifest referrer for unhinted link, got %+v", hint)
+	}
+
+	// The fake provider has no ResolveProviderID, so the id is kept as is.
+	resolved, err := provider.(providers.IDResolver).ResolveProviderID("frieren-1", "Frieren")
+	if err != nil || resolved != "frieren-1" {
+		t.Fatalf("ResolveProviderID: %q %v", resolved, err)
+	}
+}
+
+func TestPluginErrorsDoNotRestartProcess(t *testing.T) {
+	provider := loadPlugin(t, "failing", nil)
+
+	if _, err := provider.SearchAnime("fail", "sub"); err == nil || !strings.Contains(err.Error(), "site is down") {
+		t.Fatalf("expected plugin error, got %v", err)
+	}
+	if _, err := provider.SearchAnime("Frieren", "sub"); err != nil {
+		t.Fatalf("plugin should keep working after an error response: %v", err)
+	}
+}
+
+func TestPluginCrashIsIsolatedAndRestarted(t *testing.T) {
+	provider := loadPlugin(t, "crashing", nil)
+
+	if _, err := provider.SearchAnime("crash", "sub"); err == nil || !strings.Contains(err.Error(), "exited") {
+		t.Fatalf("expected crash error, got %v", err)
+	}
+	if _, err := provider.SearchAnime("Frieren", "sub"); err != nil {
+		t.Fatalf("plugin should restart after a crash: %v", err)
+	}
+}
+
+func TestPluginTimeout(t *testing.T) {
+	provider := loadPlugin(t, "hanging", map[string]interface{}{"timeout_seconds": 0.5})
+
+	start := time.Now()
+	if _, err := provider.SearchAnime("hang", "sub"); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("timeout took too long: %s", elapsed)
+	}
+	if _, err := provider.SearchAnime("Frieren", "sub"); err != nil {
+		t.Fatalf("plugin should restart after a timeout: %v", err)
+	}
+}
+
+func TestPluginCallStopsWhenCancelled(t *testing.T) {
+	provider := loadPlugin(t, "cancelled", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := providers.SearchAnime(ctx, provider, "hang", "sub"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("cancellation took too long: %s", elapsed)
+	}
+}
+
+func TestLoadDirSkipsBuiltInNamesAndBadManifests(t *testing.T) {
+	providers.Register(providers.Meta{Name: "builtinfake"}, func() providers.Provider { return fakeProvider{} })
+
+	dir := t.TempDir()
+	writeManifest(t, dir, "builtinfake", nil)
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "missing.json"), []byte(`{"name":"missing"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := plugins.LoadDir(dir, nil)
+	if len(names) != 0 {
+		t.Fatalf("nothing should be registered, got %v", names)
+	}
+	if err == nil || !strings.Contains(err.Error(), "already registered") || !strings.Contains(err.Error(), "broken.json") || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("expected all problems to be reported, got %v", err)
+	}
+
+	if names, err := plugins.LoadDir(filepath.Join(dir, "absent"), nil); err != nil || len(names) != 0 {
+		t.Fatalf("missing directory should be ignored: %v %v", names, err)
+	}
+}
@@ -0,0 +1,173 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// process is one running plugin executable.
+type process struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[int64]chan response
+	done    chan struct{}
+	err     error
+}
+
+func startProcess(name string, cmd *exec.Cmd, logf func(string)) (*process, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start provider plugin %s: %w", name, err)
+	}
+
+	proc := &process{
+		name:    name,
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: map[int64]chan response{},
+		done:    make(chan struct{}),
+	}
+	go proc.forwardStderr(stderr, logf)
+	go proc.readLoop(stdout)
+	return proc, nil
+}
+
+func (p *process) forwardStderr(stderr io.Reader, logf func(string)) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		logf(fmt.Sprintf("[plugin %s] %s", p.name, scanner.Text()))
+	}
+}
+
+func (p *process) readLoop(stdout io.Reader) {
+	reader := bufio.NewReader(stdout)
+	var readErr error
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var resp response
+			if jsonErr := json.Unmarshal(line, &resp); jsonErr == nil {
+				p.mu.Lock()
+				ch, ok := p.pending[resp.ID]
+				delete(p.pending, resp.ID)
+				p.mu.Unlock()
+				if ok {
+					ch <- resp
+				}
+			}
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+	}
+
+	waitErr := p.cmd.Wait()
+	p.mu.Lock()
+	switch {
+	case waitErr != nil:
+		p.err = fmt.Errorf("provider plugin %s exited: %w", p.name, waitErr)
+	case readErr != nil && !errors.Is(readErr, io.EOF):
+		p.err = fmt.Errorf("provider plugin %s output failed: %w", p.name, readErr)
+	default:
+		p.err = fmt.Errorf("provider plugin %s exited", p.name)
+	}
+	p.mu.Unlock()
+	close(p.done)
+}
+
+// exited reports whether the process has stopped.
+func (p *process) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// call sends one request and waits up to timeout for its response, or until
+// ctx is done. A late response to a cancelled call is dropped.
+func (p *process) call(ctx context.Context, id int64, method string, params interface{}, timeout time.Duration) (json.RawMessage, error) {
+	req := request{JSONRPC: "2.0", ID: id, Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		req.Params = raw
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan response, 1)
+	p.mu.Lock()
+	p.pending[id] = ch
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.pending, id)
+		p.mu.Unlock()
+	}()
+
+	p.writeMu.Lock()
+	_, err = p.stdin.Write(append(payload, '\n'))
+	p.writeMu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write to provider plugin %s: %w", p.name, err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		return resp.Result, nil
+	case <-p.done:
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return nil, p.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, fmt.Errorf("provider plugin %s timed out after %s on %s", p.name, timeout, method)
+	}
+}
+
+// stop closes stdin and kills the process if it does not exit promptly.
+func (p *process) stop(grace time.Duration) {
+	_ = p.stdin.Close()
+	select {
+	case <-p.done:
+	case <-time.After(grace):
+		_ = p.cmd.Process.Kill()
+		<-p.done
+	}
+}
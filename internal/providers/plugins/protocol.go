@@ -0,0 +1,141 @@
+// Package plugins runs streaming providers as external executables.
+//
+// A plugin is any executable that speaks JSON-RPC 2.0 over stdin/stdout, one
+// JSON object per line. curd starts the executable on first use, keeps it
+// running for the rest of the session and closes its stdin on exit; plugins
+// should exit when stdin reaches EOF. Anything the plugin writes to stderr is
+// copied to curd's debug log.
+//
+// Plugins live in StoragePath/providers/. Each plugin is described by a
+// manifest named <name>.json:
+//
+//	{
+//	  "name": "myprovider",
+//	  "aliases": ["my provider"],
+//	  "referrer": "https://example.com/",
+//	  "executable": "myprovider",
+//	  "args": [],
+//	  "timeout_seconds": 30
+//	}
+//
+// executable is resolved relative to the manifest and defaults to the manifest
+// name without the .json suffix. The remaining Meta fields
+// (default_disabled, disable_reason, opt_out_token, fallback_prompt) map onto
+// providers.Meta.
+//
+// Methods and their params/results:
+//
+//	Name                           {}                                         -> string
+//	SearchAnime                    {"query", "mode"}                          -> [{"key", "label", "title", "thumbnail"}]
+//	EpisodesList                   {"show_id", "mode"}                        -> ["1", "2", ...]
+//	GetEpisodeURLForModeWithHints  {"config": {"sub_or_dub", "sub_style"},
+//	                                "id", "episode", "mode"}                  -> {"links": [...], "hints": {"<link>": {"referrer", "subtitle"}}}
+//	ResolveProviderID              {"provider_id", "query"}                   -> string
+//
+// ResolveProviderID is optional; plugins that do not implement it should
+// answer with the standard method-not-found error (-32601). Name is called
+// once after start-up as a handshake. When the user backs out of a lookup curd
+// stops waiting and ignores the late response, so plugins need not support
+// cancellation themselves.
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// JSON-RPC method names.
+const (
+	MethodName              = "Name"
+	MethodSearchAnime       = "SearchAnime"
+	MethodEpisodesList      = "EpisodesList"
+	MethodGetEpisodeURL     = "GetEpisodeURLForModeWithHints"
+	MethodResolveProviderID = "ResolveProviderID"
+)
+
+// Standard JSON-RPC error codes used by the protocol.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int64           `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int64           `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError is a JSON-RPC error returned by a plugin.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("plugin error %d: %s", e.Code, e.Message)
+}
+
+// SearchParams are the params of SearchAnime.
+type SearchParams struct {
+	Query string `json:"query"`
+	Mode  string `json:"mode"`
+}
+
+// Option is a search result.
+type Option struct {
+	Key       string `json:"key"`
+	Label     string `json:"label"`
+	Title     string `json:"title,omitempty"`
+	Thumbnail string `json:"thumbnail,omitempty"`
+	Year      int    `json:"year,omitempty"`
+	Episodes  int    `json:"episodes,omitempty"`
+}
+
+// EpisodesParams are the params of EpisodesList.
+type EpisodesParams struct {
+	ShowID string `json:"show_id"`
+	Mode   string `json:"mode"`
+}
+
+// PlaybackConfig mirrors providers.PlaybackConfig on the wire.
+type PlaybackConfig struct {
+	SubOrDub string `json:"sub_or_dub"`
+	SubStyle string `json:"sub_style,omitempty"`
+}
+
+// EpisodeURLParams are the params of GetEpisodeURLForModeWithHints.
+type EpisodeURLParams struct {
+	Config  PlaybackConfig `json:"config"`
+	ID      string         `json:"id"`
+	Episode int            `json:"episode"`
+	Mode    string         `json:"mode"`
+}
+
+// Hint mirrors providers.StreamPlaybackHint on the wire.
+type Hint struct {
+	Referrer string `json:"referrer,omitempty"`
+	Subtitle string `json:"subtitle,omitempty"`
+}
+
+// EpisodeURLResult is the result of GetEpisodeURLForModeWithHints.
+type EpisodeURLResult struct {
+	Links []string        `json:"links"`
+	Hints map[string]Hint `json:"hints,omitempty"`
+}
+
+// ResolveIDParams are the params of ResolveProviderID.
+type ResolveIDParams struct {
+	ProviderID string `json:"provider_id"`
+	Query      string `json:"query"`
+}
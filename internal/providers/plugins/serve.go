@@ -0,0 +1,159 @@
+package plugins
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/wraient/curd/internal/providers"
+)
+
+// Serve answers plugin requests from in on out using p until in is closed.
+// It lets a provider written in Go be shipped as a plugin executable:
+//
+//	func main() { plugins.Serve(&myProvider{}, os.Stdin, os.Stdout) }
+func Serve(p providers.Provider, in io.Reader, out io.Writer) error {
+	reader := bufio.NewReader(in)
+	encoder := json.NewEncoder(out)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if encodeErr := encoder.Encode(handle(p, line)); encodeErr != nil {
+				return encodeErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func handle(p providers.Provider, line []byte) response {
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return errorResponse(0, CodeParseError, err.Error())
+	}
+
+	result, err := dispatch(p, req)
+	if err != nil {
+		if rpcErr, ok := err.(*RPCError); ok {
+			return errorResponse(req.ID, rpcErr.Code, rpcErr.Message)
+		}
+		return errorResponse(req.ID, CodeInternalError, err.Error())
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, CodeInternalError, err.Error())
+	}
+	return response{JSONRPC: "2.0", ID: req.ID, Result: raw}
+}
+
+func dispatch(p providers.Provider, req request) (interface{}, error) {
+	switch req.Method {
+	case MethodName:
+		return p.Name(), nil
+
+	case MethodSearchAnime:
+		var params SearchParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		options, err := p.SearchAnime(params.Query, params.Mode)
+		if err != nil {
+			return nil, err
+		}
+		result := make([]Option, 0, len(options))
+		for _, option := range options {
+			result = append(result, Option{
+				Key:       option.Key,
+				Label:     option.Label,
+				Title:     option.Title,
+				Thumbnail: option.Thumbnail,
+				Year:      option.Year,
+				Episodes:  option.Episodes,
+			})
+		}
+		return result, nil
+
+	case MethodEpisodesList:
+		var params EpisodesParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		episodes, err := p.EpisodesList(params.ShowID, params.Mode)
+		if err != nil {
+			return nil, err
+		}
+		if episodes == nil {
+			episodes = []string{}
+		}
+		return episodes, nil
+
+	case MethodGetEpisodeURL:
+		var params EpisodeURLParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		config := providers.PlaybackConfig{SubOrDub: params.Config.SubOrDub, SubStyle: params.Config.SubStyle}
+		var (
+			links []string
+			hints map[string]providers.StreamPlaybackHint
+			err   error
+		)
+		switch resolver := p.(type) {
+		case providers.HintResolver:
+			links, hints, err = resolver.GetEpisodeURLForModeWithHints(config, params.ID, params.Episode, params.Mode)
+		case providers.ModeResolver:
+			links, err = resolver.GetEpisodeURLForMode(config, params.ID, params.Episode, params.Mode)
+		default:
+			config.SubOrDub = params.Mode
+			links, err = p.GetEpisodeURL(config, params.ID, params.Episode)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result := EpisodeURLResult{Links: links}
+		if result.Links == nil {
+			result.Links = []string{}
+		}
+		if len(hints) > 0 {
+			result.Hints = make(map[string]Hint, len(hints))
+			for link, hint := range hints {
+				result.Hints[link] = Hint{Referrer: hint.Referrer, Subtitle: hint.Subtitle}
+			}
+		}
+		return result, nil
+
+	case MethodResolveProviderID:
+		resolver, ok := p.(providers.IDResolver)
+		if !ok {
+			return nil, &RPCError{Code: CodeMethodNotFound, Message: "method not found: " + req.Method}
+		}
+		var params ResolveIDParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return resolver.ResolveProviderID(params.ProviderID, params.Query)
+
+	default:
+		return nil, &RPCError{Code: CodeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+}
+
+func decodeParams(raw json.RawMessage, dest interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, dest); err != nil {
+		return &RPCError{Code: CodeInvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+	}
+	return nil
+}
+
+func errorResponse(id int64, code int, message string) response {
+	return response{JSONRPC: "2.0", ID: id, Error: &RPCError{Code: code, Message: message}}
+}
@@ -2,6 +2,7 @@ package senshi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,8 +16,8 @@ const userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36
 
 var baseURL = "https://senshi.live"
 
-func newRequest(method, rawURL string) (*http.Request, error) {
-	req, err := http.NewRequest(method, rawURL, nil)
+func newRequest(ctx context.Context, method, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -25,7 +26,7 @@ func newRequest(method, rawURL string) (*http.Request, error) {
 	return req, nil
 }
 
-func fetchJSON(method, rawURL string, payload any, dest any) error {
+func fetchJSON(ctx context.Context, method, rawURL string, payload any, dest any) error {
 	var body io.Reader
 	if payload != nil {
 		encoded, err := json.Marshal(payload)
@@ -35,7 +36,7 @@ func fetchJSON(method, rawURL string, payload any, dest any) error {
 		body = bytes.NewReader(encoded)
 	}
 
-	req, err := http.NewRequest(method, rawURL, body)
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
 	if err != nil {
 		return err
 	}
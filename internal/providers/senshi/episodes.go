@@ -1,6 +1,7 @@
 package senshi
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sort"
@@ -8,7 +9,7 @@ import (
 	"strings"
 )
 
-func episodesList(showID, mode string) ([]string, error) {
+func episodesList(ctx context.Context, showID, mode string) ([]string, error) {
 	malID, err := parseMalID(showID)
 	if err != nil {
 		return nil, err
@@ -16,7 +17,7 @@ func episodesList(showID, mode string) ([]string, error) {
 	_ = mode
 
 	var episodes []episodeItem
-	if err := fetchJSON(http.MethodGet, fmt.Sprintf("%s/episodes/%d", baseURL, malID), nil, &episodes); err != nil {
+	if err := fetchJSON(ctx, http.MethodGet, fmt.Sprintf("%s/episodes/%d", baseURL, malID), nil, &episodes); err != nil {
 		return nil, err
 	}
 	if len(episodes) == 0 {
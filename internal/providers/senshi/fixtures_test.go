@@ -0,0 +1,75 @@
+package senshi
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/wraient/curd/internal/providers"
+	"github.com/wraient/curd/internal/providers/cassette"
+)
+
+func TestFixtureSearchAnime(t *testing.T) {
+	cassette.Use(t, "search")
+
+	options, err := searchAnime(context.Background(), "frieren", "sub")
+	if err != nil {
+		t.Fatalf("searchAnime: %v", err)
+	}
+	if len(options) != 2 {
+		t.Fatalf("expected 2 options, got %d", len(options))
+	}
+	first := options[0]
+	if first.Key != "52991" || first.Title != "Frieren: Beyond Journey's End" {
+		t.Fatalf("unexpected first option %+v", first)
+	}
+	if first.Label != "Frieren: Beyond Journey's End · TV · 2023 · 28 eps" {
+		t.Fatalf("unexpected label %q", first.Label)
+	}
+	if first.Thumbnail != "https://senshi.live/posters/52991.webp" {
+		t.Fatalf("unexpected thumbnail %q", first.Thumbnail)
+	}
+	item, ok := first.ExtraData.(SearchItem)
+	if !ok || item.Episodes != 28 || item.Year != 2023 {
+		t.Fatalf("unexpected extra data %+v", first.ExtraData)
+	}
+	if options[1].Title != "Sousou no Frieren 2nd Season" {
+		t.Fatalf("expected romaji fallback title, got %q", options[1].Title)
+	}
+}
+
+func TestFixtureEpisodesList(t *testing.T) {
+	cassette.Use(t, "episodes")
+
+	episodes, err := episodesList(context.Background(), "52991", "sub")
+	if err != nil {
+		t.Fatalf("episodesList: %v", err)
+	}
+	if strings.Join(episodes, ",") != "1,2,3" {
+		t.Fatalf("unexpected episodes %v", episodes)
+	}
+}
+
+func TestFixtureEpisodeStreams(t *testing.T) {
+	cassette.Use(t, "streams")
+
+	links, hints, err := getEpisodeStreamsForMode(context.Background(), "52991", providers.PlaybackConfig{SubOrDub: "sub"}, 1)
+	if err != nil {
+		t.Fatalf("sub streams: %v", err)
+	}
+	want := "https://cdn.senshi.live/hls/52991/1/hardsub/master.m3u8"
+	if len(links) != 1 || links[0] != want {
+		t.Fatalf("unexpected sub links %v", links)
+	}
+	if hint := hints[want]; hint.Subtitle != "https://cdn.senshi.live/subs/52991/1/en-US.vtt" || hint.Referrer != "https://senshi.live/" {
+		t.Fatalf("unexpected sub hint %+v", hint)
+	}
+
+	links, _, err = getEpisodeStreamsForMode(context.Background(), "52991", providers.PlaybackConfig{SubOrDub: "dub"}, 1)
+	if err != nil {
+		t.Fatalf("dub streams: %v", err)
+	}
+	if len(links) != 1 || !strings.Contains(links[0], "/dub/") {
+		t.Fatalf("unexpected dub links %v", links)
+	}
+}
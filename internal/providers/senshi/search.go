@@ -1,6 +1,7 @@
 package senshi
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -9,7 +10,7 @@ import (
 	"github.com/wraient/curd/internal/providers"
 )
 
-func searchAnime(query, mode string) ([]providers.SelectionOption, error) {
+func searchAnime(ctx context.Context, query, mode string) ([]providers.SelectionOption, error) {
 	query = strings.TrimSpace(query)
 	if query == "" {
 		return nil, fmt.Errorf("empty search query")
@@ -17,7 +18,7 @@ func searchAnime(query, mode string) ([]providers.SelectionOption, error) {
 	_ = mode
 
 	var payload filterResponse
-	err := fetchJSON(http.MethodPost, baseURL+"/anime/filter", map[string]any{
+	err := fetchJSON(ctx, http.MethodPost, baseURL+"/anime/filter", map[string]any{
 		"searchTerm": query,
 		"page":       1,
 		"limit":      25,
@@ -40,12 +41,15 @@ func searchAnime(query, mode string) ([]providers.SelectionOption, error) {
 			title = strings.TrimSpace(item.Title)
 		}
 		label := formatSearchLabel(item)
+		extra := toSearchItem(item)
 		options = append(options, providers.SelectionOption{
 			Key:       strconv.Itoa(malID),
 			Label:     label,
 			Title:     title,
 			Thumbnail: posterURL(malID),
-			ExtraData: toSearchItem(item),
+			Year:      extra.Year,
+			Episodes:  extra.Episodes,
+			ExtraData: extra,
 		})
 	}
 	if len(options) == 0 {
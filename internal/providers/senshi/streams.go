@@ -1,6 +1,7 @@
 package senshi
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -9,13 +10,22 @@ import (
 	"github.com/wraient/curd/internal/providers"
 )
 
-func getEpisodeStreamsForMode(malIDStr string, config providers.PlaybackConfig, epNo int) ([]string, map[string]providers.StreamPlaybackHint, error) {
-	malID, err := parseMalID(malIDStr)
+func getEpisodeStreamsForMode(ctx context.Context, malIDStr string, config providers.PlaybackConfig, epNo int) ([]string, map[string]providers.StreamPlaybackHint, error) {
+	stream, err := resolveEpisodeStream(ctx, malIDStr, config, epNo)
 	if err != nil {
 		return nil, nil, err
 	}
+	links, hints := providers.LinksFromStreams([]providers.Stream{stream})
+	return links, hints, nil
+}
+
+func resolveEpisodeStream(ctx context.Context, malIDStr string, config providers.PlaybackConfig, epNo int) (providers.Stream, error) {
+	malID, err := parseMalID(malIDStr)
+	if err != nil {
+		return providers.Stream{}, err
+	}
 	if epNo <= 0 {
-		return nil, nil, fmt.Errorf("invalid episode number %d", epNo)
+		return providers.Stream{}, fmt.Errorf("invalid episode number %d", epNo)
 	}
 
 	mode := providers.NormalizeTranslationType(config.SubOrDub)
@@ -26,11 +36,11 @@ func getEpisodeStreamsForMode(malIDStr string, config providers.PlaybackConfig,
 
 	var embeds []embedItem
 	reqURL := fmt.Sprintf("%s/episode-embeds/%d/%d", baseURL, malID, epNo)
-	if err := fetchJSON(http.MethodGet, reqURL, nil, &embeds); err != nil {
-		return nil, nil, err
+	if err := fetchJSON(ctx, http.MethodGet, reqURL, nil, &embeds); err != nil {
+		return providers.Stream{}, err
 	}
 	if len(embeds) == 0 {
-		return nil, nil, fmt.Errorf("no streams found for episode %d", epNo)
+		return providers.Stream{}, fmt.Errorf("no streams found for episode %d", epNo)
 	}
 
 	for _, item := range embeds {
@@ -41,10 +51,17 @@ func getEpisodeStreamsForMode(malIDStr string, config providers.PlaybackConfig,
 		if streamURL == "" {
 			continue
 		}
-		hints := map[string]providers.StreamPlaybackHint{
-			streamURL: {
-				Referrer: baseURL + "/",
-			},
+		stream := providers.Stream{
+			URL:       streamURL,
+			Container: providers.GuessContainer(streamURL),
+			AudioLang: "ja",
+			Headers:   map[string]string{"Referer": baseURL + "/"},
+			Server:    "senshi",
+		}
+		if mode == "dub" {
+			stream.AudioLang = "en"
+		} else {
+			stream.SubtitleMode = providers.SubtitlesHard
 		}
 
 		if item.ServerFM != nil {
@@ -52,13 +69,11 @@ func getEpisodeStreamsForMode(malIDStr string, config providers.PlaybackConfig,
 				subInfoURL := u.Query().Get("sub.info")
 				if subInfoURL != "" {
 					var subs []subtitleItem
-					if err := fetchJSON(http.MethodGet, subInfoURL, nil, &subs); err == nil {
+					if err := fetchJSON(ctx, http.MethodGet, subInfoURL, nil, &subs); err == nil {
 						for _, sub := range subs {
 							// Prefer English subtitles
 							if strings.Contains(strings.ToLower(sub.Label), "eng") || sub.Default {
-								hint := hints[streamURL]
-								hint.Subtitle = sub.Src
-								hints[streamURL] = hint
+								stream.Subtitles = []providers.SubtitleTrack{{URL: sub.Src, Label: sub.Label, Default: true}}
 								break
 							}
 						}
@@ -67,8 +82,8 @@ func getEpisodeStreamsForMode(malIDStr string, config providers.PlaybackConfig,
 			}
 		}
 
-		return []string{streamURL}, hints, nil
+		return stream, nil
 	}
 
-	return nil, nil, fmt.Errorf("no %s streams found for episode %d", mode, epNo)
+	return providers.Stream{}, fmt.Errorf("no %s streams found for episode %d", mode, epNo)
 }
@@ -0,0 +1,159 @@
+package providers
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Stream containers.
+const (
+	ContainerHLS = "hls"
+	ContainerMP4 = "mp4"
+)
+
+// Subtitle delivery for a stream.
+const (
+	SubtitlesHard = "hard"
+	SubtitlesSoft = "soft"
+)
+
+// SubtitleTrack is an external subtitle file offered with a stream.
+type SubtitleTrack struct {
+	URL     string
+	Lang    string
+	Label   string
+	Default bool
+}
+
+// Stream is a resolved playable URL with whatever the provider knows about it.
+// Zero values mean unknown.
+type Stream struct {
+	URL string
+	// Resolution is the vertical resolution, e.g. 1080.
+	Resolution int
+	// Bitrate is the advertised bandwidth in bits per second.
+	Bitrate int
+	// Container is ContainerHLS or ContainerMP4.
+	Container string
+	AudioLang string
+	// SubtitleMode is SubtitlesHard when subtitles are burned in and
+	// SubtitlesSoft when they come as separate tracks.
+	SubtitleMode string
+	Subtitles    []SubtitleTrack
+	// Headers must be sent when fetching the stream, e.g. Referer.
+	Headers map[string]string
+	// Server names the host or mirror the stream came from.
+	Server string
+}
+
+// Referrer returns the Referer header the stream needs, if any.
+func (s Stream) Referrer() string {
+	for key, value := range s.Headers {
+		if strings.EqualFold(key, "Referer") {
+			return value
+		}
+	}
+	return ""
+}
+
+// SubtitleURL returns the default subtitle track, or the first one.
+func (s Stream) SubtitleURL() string {
+	for _, track := range s.Subtitles {
+		if track.Default && track.URL != "" {
+			return track.URL
+		}
+	}
+	for _, track := range s.Subtitles {
+		if track.URL != "" {
+			return track.URL
+		}
+	}
+	return ""
+}
+
+// Hint returns the MPV playback hint for the stream.
+func (s Stream) Hint() StreamPlaybackHint {
+	return StreamPlaybackHint{Referrer: s.Referrer(), Subtitle: s.SubtitleURL()}
+}
+
+// StreamResolver resolves episode streams with typed metadata. It is the
+// richer alternative to HintResolver.
+type StreamResolver interface {
+	GetEpisodeStreams(config PlaybackConfig, id string, epNo int, mode string) ([]Stream, error)
+}
+
+var resolutionInURLRE = regexp.MustCompile(`(?i)(?:^|[^0-9])(2160|1440|1080|720|480|360|240)p`)
+
+// GuessStream builds a Stream from a bare link by looking at its URL.
+func GuessStream(link string) Stream {
+	stream := Stream{URL: link, Container: GuessContainer(link)}
+	if match := resolutionInURLRE.FindStringSubmatch(link); len(match) == 2 {
+		stream.Resolution, _ = strconv.Atoi(match[1])
+	}
+	if parsed, err := url.Parse(link); err == nil {
+		stream.Server = parsed.Hostname()
+	}
+	return stream
+}
+
+// GuessContainer reports the container implied by a link's path.
+func GuessContainer(link string) string {
+	path := link
+	if parsed, err := url.Parse(link); err == nil {
+		path = parsed.Path
+	}
+	path = strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(path, ".m3u8"):
+		return ContainerHLS
+	case strings.HasSuffix(path, ".mp4"):
+		return ContainerMP4
+	}
+	return ""
+}
+
+// StreamsFromLinks converts the legacy links and hints into streams.
+func StreamsFromLinks(links []string, hints map[string]StreamPlaybackHint) []Stream {
+	streams := make([]Stream, 0, len(links))
+	for _, link := range links {
+		stream := GuessStream(link)
+		if hint, ok := hints[link]; ok {
+			if hint.Referrer != "" {
+				stream.Headers = map[string]string{"Referer": hint.Referrer}
+			}
+			if hint.Subtitle != "" {
+				stream.Subtitles = []SubtitleTrack{{URL: hint.Subtitle, Default: true}}
+				stream.SubtitleMode = SubtitlesSoft
+			}
+		}
+		streams = append(streams, stream)
+	}
+	return streams
+}
+
+// LinksFromStreams converts streams back into links and hints, keeping their
+// order and dropping duplicate URLs.
+func LinksFromStreams(streams []Stream) ([]string, map[string]StreamPlaybackHint) {
+	links := make([]string, 0, len(streams))
+	var hints map[string]StreamPlaybackHint
+	seen := make(map[string]struct{}, len(streams))
+	for _, stream := range streams {
+		if stream.URL == "" {
+			continue
+		}
+		if _, ok := seen[stream.URL]; ok {
+			continue
+		}
+		seen[stream.URL] = struct{}{}
+		links = append(links, stream.URL)
+		if hint := stream.Hint(); hint != (StreamPlaybackHint{}) {
+			if hints == nil {
+				hints = make(map[string]StreamPlaybackHint)
+			}
+			hints[stream.URL] = hint
+		}
+	}
+	return links, hints
+}
@@ -0,0 +1,117 @@
+// Package streamrank orders provider streams by the user's quality and
+// server preferences.
+package streamrank
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wraient/curd/internal/providers"
+)
+
+// Preferences drive Rank.
+type Preferences struct {
+	// PreferredQuality is "best", "worst" or a target such as "720p". With a
+	// target, the highest stream at or below it wins, then the lowest above.
+	PreferredQuality string
+	// MaxResolution ranks streams above it last. Zero means no limit.
+	MaxResolution int
+	// PreferredServers are matched against a stream's server name or host,
+	// earlier entries first. Unmatched servers rank after matched ones.
+	PreferredServers []string
+}
+
+// ParseQuality turns "best", "worst", "720p" or "720" into a target height.
+// Best is 0 and worst is -1.
+func ParseQuality(quality string) int {
+	quality = strings.ToLower(strings.TrimSpace(quality))
+	switch quality {
+	case "", "best", "highest":
+		return 0
+	case "worst", "lowest":
+		return -1
+	}
+	if height, err := strconv.Atoi(strings.TrimSuffix(quality, "p")); err == nil && height > 0 {
+		return height
+	}
+	return 0
+}
+
+// Rank returns streams ordered best first. Streams that compare equal keep
+// the provider's order.
+func Rank(streams []providers.Stream, prefs Preferences) []providers.Stream {
+	ranked := append([]providers.Stream(nil), streams...)
+	target := ParseQuality(prefs.PreferredQuality)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if overA, overB := overLimit(a, prefs.MaxResolution), overLimit(b, prefs.MaxResolution); overA != overB {
+			return !overA
+		}
+		if sa, sb := serverRank(a, prefs.PreferredServers), serverRank(b, prefs.PreferredServers); sa != sb {
+			return sa < sb
+		}
+		if qa, qb := qualityRank(a.Resolution, target), qualityRank(b.Resolution, target); qa != qb {
+			return qa < qb
+		}
+		return bitrateRank(a.Bitrate, target) < bitrateRank(b.Bitrate, target)
+	})
+	return ranked
+}
+
+func overLimit(stream providers.Stream, max int) bool {
+	return max > 0 && stream.Resolution > max
+}
+
+func serverRank(stream providers.Stream, preferred []string) int {
+	if len(preferred) == 0 {
+		return 0
+	}
+	server := strings.ToLower(stream.Server)
+	host := ""
+	if parsed, err := url.Parse(stream.URL); err == nil {
+		host = strings.ToLower(parsed.Hostname())
+	}
+	for i, name := range preferred {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if server == name || strings.Contains(host, name) {
+			return i
+		}
+	}
+	return len(preferred)
+}
+
+// bitrateRank is lower for better streams. Unknown bitrates rank last.
+func bitrateRank(bitrate, target int) int {
+	switch {
+	case bitrate <= 0:
+		return 1 << 30
+	case target == -1:
+		return bitrate
+	default:
+		return -bitrate
+	}
+}
+
+// qualityRank is lower for better matches. Unknown resolutions rank last.
+func qualityRank(resolution, target int) int {
+	const unknown = 1 << 30
+	if resolution <= 0 {
+		return unknown
+	}
+	switch {
+	case target == 0:
+		return -resolution
+	case target == -1:
+		return resolution
+	case resolution <= target:
+		return target - resolution
+	default:
+		// Above the target: after everything at or below it, closest first.
+		return unknown/2 + resolution - target
+	}
+}
@@ -0,0 +1,91 @@
+package streamrank_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wraient/curd/internal/providers"
+	"github.com/wraient/curd/internal/providers/streamrank"
+)
+
+func urls(streams []providers.Stream) []string {
+	result := make([]string, 0, len(streams))
+	for _, stream := range streams {
+		result = append(result, stream.URL)
+	}
+	return result
+}
+
+var sample = []providers.Stream{
+	{URL: "https://a.example/unknown.m3u8"},
+	{URL: "https://a.example/480.m3u8", Resolution: 480},
+	{URL: "https://b.example/1080.m3u8", Resolution: 1080, Server: "Default"},
+	{URL: "https://a.example/720.m3u8", Resolution: 720},
+	{URL: "https://c.example/2160.mp4", Resolution: 2160},
+}
+
+func TestRankQuality(t *testing.T) {
+	tests := []struct {
+		name  string
+		prefs streamrank.Preferences
+		want  []string
+	}{
+		{
+			name:  "best",
+			prefs: streamrank.Preferences{},
+			want:  []string{"https://c.example/2160.mp4", "https://b.example/1080.m3u8", "https://a.example/720.m3u8", "https://a.example/480.m3u8", "https://a.example/unknown.m3u8"},
+		},
+		{
+			name:  "worst",
+			prefs: streamrank.Preferences{PreferredQuality: "worst"},
+			want:  []string{"https://a.example/480.m3u8", "https://a.example/720.m3u8", "https://b.example/1080.m3u8", "https://c.example/2160.mp4", "https://a.example/unknown.m3u8"},
+		},
+		{
+			name:  "target falls back below then above",
+			prefs: streamrank.Preferences{PreferredQuality: "900p"},
+			want:  []string{"https://a.example/720.m3u8", "https://a.example/480.m3u8", "https://b.example/1080.m3u8", "https://c.example/2160.mp4", "https://a.example/unknown.m3u8"},
+		},
+		{
+			name:  "max resolution",
+			prefs: streamrank.Preferences{MaxResolution: 1080},
+			want:  []string{"https://b.example/1080.m3u8", "https://a.example/720.m3u8", "https://a.example/480.m3u8", "https://a.example/unknown.m3u8", "https://c.example/2160.mp4"},
+		},
+		{
+			name:  "preferred servers by name and host",
+			prefs: streamrank.Preferences{PreferredServers: []string{"default", "a.example"}},
+			want:  []string{"https://b.example/1080.m3u8", "https://a.example/720.m3u8", "https://a.example/480.m3u8", "https://a.example/unknown.m3u8", "https://c.example/2160.mp4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := urls(streamrank.Rank(sample, tt.prefs))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Rank() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankKeepsProviderOrderForTies(t *testing.T) {
+	streams := []providers.Stream{
+		{URL: "first", Resolution: 720, Bitrate: 1000},
+		{URL: "second", Resolution: 720},
+		{URL: "third", Resolution: 720, Bitrate: 3000},
+	}
+	got := urls(streamrank.Rank(streams, streamrank.Preferences{}))
+	want := []string{"third", "first", "second"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Rank() = %v, want %v", got, want)
+	}
+	if streams[0].URL != "first" {
+		t.Fatal("Rank must not reorder its input")
+	}
+}
+
+func TestParseQuality(t *testing.T) {
+	for input, want := range map[string]int{"": 0, "best": 0, "Worst": -1, "720p": 720, "1080": 1080, "nonsense": 0} {
+		if got := streamrank.ParseQuality(input); got != want {
+			t.Errorf("ParseQuality(%q) = %d, want %d", input, got, want)
+		}
+	}
+}
@@ -6,6 +6,11 @@ type SelectionOption struct {
 	Label     string
 	Title     string
 	Thumbnail string
+	// Year and Episodes are the release year and episode count of a search
+	// result, zero when the provider does not know them. Stacked searches
+	// use them to tell apart titles with the same name.
+	Year      int
+	Episodes  int
 	ExtraData any
 }
 
@@ -40,6 +45,14 @@ type HintResolver interface {
 	GetEpisodeURLForModeWithHints(config PlaybackConfig, id string, epNo int, mode string) ([]string, map[string]StreamPlaybackHint, error)
 }
 
+// ModeSearcher is implemented by providers whose search results depend on
+// the sub/dub mode. Only these are searched in the other mode to learn which
+// titles carry both; the results of other providers carry whatever audio
+// the show has.
+type ModeSearcher interface {
+	SearchListsModes() bool
+}
+
 // IDResolver refreshes or validates a provider-specific show ID.
 type IDResolver interface {
 	ResolveProviderID(providerID, query string) (string, error)
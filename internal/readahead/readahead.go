@@ -0,0 +1,35 @@
+// Package readahead runs the background download loops that keep a stream
+// buffered past the point the player is reading.
+package readahead
+
+import "sync/atomic"
+
+// Worker keeps one background download loop running per stream and
+// restarts it from wherever the player last read. The zero value is ready
+// to use.
+type Worker struct {
+	running  int32
+	position int64
+}
+
+// Kick moves the loop to position, starting it when it is not running.
+// fill downloads from from onwards and should return early once moved
+// reports that the player has read somewhere else, after which it is
+// called again from the new position.
+func (w *Worker) Kick(position int, fill func(from int, moved func() bool)) {
+	atomic.StoreInt64(&w.position, int64(position))
+	if !atomic.CompareAndSwapInt32(&w.running, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&w.running, 0)
+		for {
+			from := atomic.LoadInt64(&w.position)
+			moved := func() bool { return atomic.LoadInt64(&w.position) != from }
+			fill(int(from), moved)
+			if !moved() {
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,50 @@
+package readahead
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkerRestartsFromLatestPosition(t *testing.T) {
+	var w Worker
+	var mu sync.Mutex
+	var starts []int
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	fill := func(from int, moved func() bool) {
+		mu.Lock()
+		starts = append(starts, from)
+		first := len(starts) == 1
+		mu.Unlock()
+		if first {
+			close(started)
+			<-release
+			return
+		}
+		if !moved() {
+			close(done)
+		}
+	}
+
+	w.Kick(1, fill)
+	<-started
+	// Both kicks land while the first fill runs: one loop, restarted once
+	// from the last position.
+	w.Kick(5, fill)
+	w.Kick(9, fill)
+	close(release)
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("loop was not restarted")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(starts) != 2 || starts[0] != 1 || starts[1] != 9 {
+		t.Fatalf("fill started from %v; want [1 9]", starts)
+	}
+}
@@ -5,34 +5,22 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
+
+	"github.com/wraient/curd/internal/menu"
 )
 
+// GetTokenFromRofi logs in to AniList by pasting the redirect URL into rofi
 func GetTokenFromRofi() (string, error) {
-	// The URL to open - using authorization code flow for consistency
-	url := "https://anilist.co/api/v2/oauth/authorize?client_id=20686&response_type=code&redirect_uri=http://localhost:8000/oauth/callback"
-
-	// Use rofi to display a prompt with the URL
-	message := "Press enter to open the anilist token page in your browser"
-	_, err := GetUserInputFromRofi(message)
+	code, err := readAuthorizationCodeRofi("AniList", anilistAuthorizeURL())
 	if err != nil {
 		return "", err
 	}
-
-	// Open the URL in the default browser
-	err = exec.Command("xdg-open", url).Start()
+	token, err := exchangeAnilistCode(code)
 	if err != nil {
 		return "", err
 	}
-
-	// Use rofi again to get the token input from the user
-	token, err := GetUserInputFromRofi("Enter the token (from the redirect URL after 'access_token=')")
-	if err != nil {
-		return "", err
-	}
-
-	return token, nil
+	return saveAnilistLogin(GetGlobalConfig(), token)
 }
 
 // GetUserInputFromRofi prompts the user for input using Rofi with a custom message
@@ -42,7 +30,7 @@ func GetUserInputFromRofi(message string) (string, error) {
 		userCurdConfig.StoragePath = os.ExpandEnv("${HOME}/.local/share/curd")
 	}
 	// Create the Rofi command
-	cmd := exec.Command("rofi", "-dmenu", "-theme", filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "userinput.rasi"), "-p", "Input", "-mesg", message)
+	cmd := exec.Command("rofi", "-dmenu", "-theme", rofiThemePath(userCurdConfig, "userinput.rasi"), "-p", "Input", "-mesg", message)
 
 	// Set up pipes for output
 	var out bytes.Buffer
@@ -59,3 +47,76 @@ func GetUserInputFromRofi(message string) (string, error) {
 
 	return userInput, nil
 }
+
+// rofiMenu is rofi as a menu backend, themed with curd's rasi files.
+type rofiMenu struct{}
+
+// run runs rofi in dmenu mode with the theme file name. Rofi exits with 1
+// when the user pressed escape.
+func (rofiMenu) run(theme, stdin string, args ...string) (string, error) {
+	userCurdConfig := GetGlobalConfig()
+	if userCurdConfig.StoragePath == "" {
+		userCurdConfig.StoragePath = os.ExpandEnv("${HOME}/.local/share/curd")
+	}
+	cmd := exec.Command("rofi", append([]string{"-dmenu", "-theme", rofiThemePath(userCurdConfig, theme)}, args...)...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
+			return "", menu.ErrCancelled
+		}
+		Log(fmt.Sprintf("Rofi stderr: %s", stderr.String()))
+		return "", fmt.Errorf("failed to run Rofi: %w", err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// pick matches the line rofi returned back to the first item labelled with it.
+func (r rofiMenu) pick(theme string, items []menu.Item, withIcons bool, args ...string) (menu.Item, error) {
+	var input strings.Builder
+	for _, item := range items {
+		input.WriteString(item.Label)
+		if withIcons && item.Image != "" {
+			input.WriteString("\x00icon\x1f" + item.Image)
+		}
+		input.WriteString("\n")
+	}
+	selected, err := r.run(theme, input.String(), args...)
+	if err != nil {
+		return menu.Item{}, err
+	}
+	if selected == "" {
+		return menu.Item{}, menu.ErrCancelled
+	}
+	for _, item := range items {
+		if item.Label == selected {
+			return item, nil
+		}
+	}
+	return menu.Item{}, fmt.Errorf("selected option not found in original list")
+}
+
+func (r rofiMenu) Select(prompt string, items []menu.Item) (menu.Item, error) {
+	return r.pick("selectanime.rasi", items, false, "-i", "-p", prompt)
+}
+
+// MultiSelect lets the user pick a single item: rofi's multi-select does
+// not fit curd's themes.
+func (r rofiMenu) MultiSelect(prompt string, items []menu.Item) ([]menu.Item, error) {
+	item, err := r.Select(prompt, items)
+	if err != nil {
+		return nil, err
+	}
+	return []menu.Item{item}, nil
+}
+
+func (r rofiMenu) Preview(prompt string, items []menu.Item) (menu.Item, error) {
+	return r.pick("selectanimepreview.rasi", items, true, "-show-icons", "-p", prompt, "-i", "-no-custom")
+}
+
+func (rofiMenu) Input(prompt string) (string, error) {
+	return GetUserInputFromRofi(prompt)
+}
@@ -1,11 +1,10 @@
 package internal
 
 import (
-	"bytes"
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -15,6 +14,10 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/wraient/curd/internal/fuzzy"
+	"github.com/wraient/curd/internal/keymap"
+	"github.com/wraient/curd/internal/menu"
+	"github.com/wraient/curd/internal/theme"
 )
 
 // Model represents the application state for the selection prompt
@@ -29,42 +32,100 @@ type Model struct {
 	addNewOption   bool
 	multiSelect    bool
 	selectedItems  map[string]bool
+	keepOrder      bool
+	// matches holds the matched runes of each filtered label, for
+	// highlighting. It is nil where the label itself did not match.
+	matches    [][]int
+	keys       keymap.Map
+	navigating bool
+	// actions are the single-key actions the caller handles on the
+	// highlighted item; action is the one the menu ended with.
+	actions []keymap.Action
+	action  keymap.Action
 }
 
 var (
-	// Style definitions
+	// Style definitions, set from the theme by applyMenuTheme
+	titleStyle        lipgloss.Style
+	filterLabelStyle  lipgloss.Style
+	filterTextStyle   lipgloss.Style
+	selectedItemStyle lipgloss.Style
+	regularItemStyle  lipgloss.Style
+	noMatchesStyle    lipgloss.Style
+	quitHintStyle     lipgloss.Style
+	matchStyle        lipgloss.Style
+
+	activeProgram *tea.Program
+)
+
+// applyMenuTheme sets the selection prompt's styles from p
+func applyMenuTheme(p theme.Palette) {
 	titleStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#7CB9E8")). // Light blue
-			Bold(true)
+		Foreground(theme.Color(p.Title)).
+		Bold(true)
 
 	filterLabelStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#FF69B4")). // Hot pink
-				Bold(true)
+		Foreground(theme.Color(p.Prompt)).
+		Bold(true)
 
 	filterTextStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#98FB98")) // Pale green
+		Foreground(theme.Color(p.Input))
 
 	selectedItemStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#FFFFFF")). // White text
-				Background(lipgloss.Color("#4A90E2")). // Softer blue background
-				Bold(true).
-				Padding(0, 1).
-				Border(lipgloss.NormalBorder(), false, false, false, true). // Left border only
-				BorderForeground(lipgloss.Color("#FFFFFF"))                 // White border
+		Foreground(theme.Color(p.SelectedText)).
+		Background(theme.Color(p.SelectedBackground)).
+		Bold(true).
+		Padding(0, 1).
+		Border(lipgloss.NormalBorder(), false, false, false, true). // Left border only
+		BorderForeground(theme.Color(p.SelectedText))
+	if p.SelectedBackground == "" {
+		// Without colours the highlighted item still has to stand out
+		selectedItemStyle = selectedItemStyle.Reverse(true)
+	}
 
 	regularItemStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#E6E6FA")). // Light lavender
-				Padding(0, 1)
+		Foreground(theme.Color(p.Text)).
+		Padding(0, 1)
 
 	noMatchesStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FF6B6B")). // Coral red
-			Italic(true)
+		Foreground(theme.Color(p.Error)).
+		Italic(true)
 
 	quitHintStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FFD700")) // Gold
+		Foreground(theme.Color(p.Hint))
 
-	activeProgram *tea.Program
-)
+	matchStyle = lipgloss.NewStyle().
+		Foreground(theme.Color(p.Match)).
+		Bold(true).
+		Underline(true)
+}
+
+// newSelectionModel returns the selection prompt for options, with the
+// user's key bindings.
+func newSelectionModel(options []SelectionOption, keepOrder bool) *Model {
+	model := &Model{
+		allOptions: options,
+		keepOrder:  keepOrder,
+		keys:       menuKeys(),
+	}
+	model.filterOptions() // Initialize filtered options
+	return model
+}
+
+// menuKeys returns the key bindings of MenuKeys, or the built-in ones when
+// they do not parse.
+func menuKeys() keymap.Map {
+	config := GetGlobalConfig()
+	if config == nil {
+		return keymap.Default()
+	}
+	keys, err := keymap.Parse(parseStringArray(config.MenuKeys))
+	if err != nil {
+		Log(fmt.Sprintf("Ignoring MenuKeys: %v", err))
+		return keymap.Default()
+	}
+	return keys
+}
 
 func QuitActiveSelectionMenu() {
 	if activeProgram != nil {
@@ -95,29 +156,9 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 		case "backspace":
 			if len(m.filter) > 0 {
-				m.filter = m.filter[:len(m.filter)-1]
+				m.filter = keymap.Backspace(m.filter)
 				updateFilter = true
 			}
-		case "down", "tab", "ctrl+n":
-			// Move the selection cursor down
-			if m.selected < len(m.filteredKeys)-1 {
-				m.selected++
-			}
-
-			// Scroll the view if necessary
-			if m.selected >= m.scrollOffset+m.visibleItemsCount() {
-				m.scrollOffset++
-			}
-		case "up", "shift+tab", "ctrl+p":
-			// Move the selection cursor up
-			if m.selected > 0 {
-				m.selected--
-			}
-
-			// Scroll the view if necessary
-			if m.selected < m.scrollOffset {
-				m.scrollOffset--
-			}
 		case " ":
 			// Toggle selection in multi-select mode
 			if m.multiSelect && m.selected < len(m.filteredKeys) {
@@ -139,7 +180,12 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, tea.Quit
 		default:
-			if len(msg.String()) == 1 && msg.String() >= " " && msg.String() <= "~" {
+			if action, ok := m.keys.Action(msg.String(), !m.navigating); ok {
+				return m, m.runAction(action)
+			}
+			if keymap.Printable(msg.String()) {
+				// Typing while navigating goes back to the filter
+				m.navigating = false
 				m.filter += msg.String()
 				updateFilter = true
 			}
@@ -155,6 +201,75 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// runAction performs a bound action, ending the menu for actions on the
+// highlighted item.
+func (m *Model) runAction(action keymap.Action) tea.Cmd {
+	switch action {
+	case keymap.Up:
+		m.moveTo(m.selected - 1)
+	case keymap.Down:
+		m.moveTo(m.selected + 1)
+	case keymap.PageUp:
+		m.moveTo(m.selected - m.pageSize())
+	case keymap.PageDown:
+		m.moveTo(m.selected + m.pageSize())
+	case keymap.Top:
+		m.moveTo(0)
+	case keymap.Bottom:
+		m.moveTo(len(m.filteredKeys) - 1)
+	case keymap.Navigate:
+		m.navigating = true
+	case keymap.Filter:
+		m.navigating = false
+	default:
+		if !m.offersAction(action) || m.selected >= len(m.filteredKeys) {
+			return nil
+		}
+		switch m.filteredKeys[m.selected].Key {
+		case "back", "add_new", "-1":
+			return nil
+		}
+		m.action = action
+		return tea.Quit
+	}
+	return nil
+}
+
+func (m *Model) offersAction(action keymap.Action) bool {
+	for _, a := range m.actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// moveTo moves the selection cursor to index, clamped to the list, and
+// scrolls it into view.
+func (m *Model) moveTo(index int) {
+	if index >= len(m.filteredKeys) {
+		index = len(m.filteredKeys) - 1
+	}
+	if index < 0 {
+		index = 0
+	}
+	m.selected = index
+
+	visible := m.pageSize()
+	if m.selected < m.scrollOffset {
+		m.scrollOffset = m.selected
+	} else if m.selected >= m.scrollOffset+visible {
+		m.scrollOffset = m.selected - visible + 1
+	}
+}
+
+func (m Model) pageSize() int {
+	if n := m.visibleItemsCount(); n > 0 {
+		return n
+	}
+	return 1
+}
+
 // View renders the UI and only shows as many options as fit in the terminal
 func (m Model) View() string {
 	var b strings.Builder
@@ -169,6 +284,9 @@ func (m Model) View() string {
 		b.WriteString(titleStyle.Render("Search") + " (Press " +
 			quitHintStyle.Render("Ctrl+C") + " to quit):\n")
 	}
+	if hint := m.keyHint(); hint != "" {
+		b.WriteString(hint + "\n")
+	}
 
 	b.WriteString(filterLabelStyle.Render("Filter: ") +
 		filterTextStyle.Render(m.filter) + "\n\n") // Added extra newline for spacing
@@ -194,35 +312,71 @@ func (m Model) View() string {
 				}
 			}
 
-			label := checkbox + m.filteredKeys[i].Label
-
+			style := regularItemStyle
 			if i == m.selected {
-				b.WriteString(selectedItemStyle.Render(label) + "\n")
-			} else {
-				b.WriteString(regularItemStyle.Render(label) + "\n")
+				style = selectedItemStyle
 			}
+			b.WriteString(style.Render(checkbox+m.highlightedLabel(i, style)) + "\n")
 		}
 	}
 
 	return b.String()
 }
 
+// highlightedLabel renders the label of filtered option i with the runes
+// the filter matched picked out, in the colours of the row's style.
+func (m Model) highlightedLabel(i int, row lipgloss.Style) string {
+	label := m.filteredKeys[i].Label
+	if i >= len(m.matches) || len(m.matches[i]) == 0 {
+		return label
+	}
+	text := row.UnsetPadding().UnsetBorderStyle().UnsetBorderLeft()
+	match := matchStyle.Inherit(text)
+	return fuzzy.Highlight(label, m.matches[i],
+		func(s string) string { return match.Render(s) },
+		func(s string) string { return text.Render(s) })
+}
+
+// keyHint tells how to switch between filtering and the key bindings, and
+// which actions the highlighted item takes.
+func (m Model) keyHint() string {
+	first := func(action keymap.Action) string {
+		if keys := m.keys.Keys(action); len(keys) > 0 {
+			return keys[0]
+		}
+		return ""
+	}
+	var parts []string
+	if m.navigating {
+		if key := first(keymap.Filter); key != "" {
+			parts = append(parts, quitHintStyle.Render(key)+": filter")
+		}
+	} else if key := first(keymap.Navigate); key != "" {
+		parts = append(parts, quitHintStyle.Render(key)+": keys")
+	}
+	if m.navigating {
+		for _, action := range m.actions {
+			if key := first(action); key != "" {
+				parts = append(parts, quitHintStyle.Render(key)+": "+string(action))
+			}
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
 // visibleItemsCount calculates how many options fit in the terminal
 func (m Model) visibleItemsCount() int {
-	// Leave space for the filter and other UI elements
-	return m.terminalHeight - 4 // Adjust this number based on your terminal layout
+	// Leave space for the filter, the key hint and other UI elements
+	return m.terminalHeight - 5 // Adjust this number based on your terminal layout
 }
 
-// filterOptions filters and sorts options based on the search term
+// filterOptions fuzzy-matches the options against the search term, looking
+// at their other titles as well, and ranks the best matches first
 func (m *Model) filterOptions() {
 	m.filteredKeys = nil
-	for _, opt := range m.allOptions {
-		if strings.Contains(strings.ToLower(opt.Label), strings.ToLower(m.filter)) {
-			m.filteredKeys = append(m.filteredKeys, opt)
-		}
-	}
+	m.matches = nil
 
-	// Sort the filtered options alphabetically unless this is a menu selection
+	// Sort the options alphabetically unless this is a menu selection
 	isMenu := false
 	for _, opt := range m.allOptions {
 		if opt.Key == "ALL" || opt.Key == "CURRENT" {
@@ -230,12 +384,40 @@ func (m *Model) filterOptions() {
 			break
 		}
 	}
+	options := m.allOptions
+	if !isMenu && !m.keepOrder {
+		options = append([]SelectionOption(nil), options...)
+		sort.SliceStable(options, func(i, j int) bool {
+			return options[i].Label < options[j].Label
+		})
+	}
 
-	if !isMenu {
-		sort.Slice(m.filteredKeys, func(i, j int) bool {
-			return m.filteredKeys[i].Label < m.filteredKeys[j].Label
+	type match struct {
+		option    SelectionOption
+		score     int
+		positions []int
+	}
+	var matched []match
+	for _, opt := range options {
+		result, from, ok := fuzzy.Best(m.filter, append([]string{opt.Label}, opt.Aliases...)...)
+		if !ok {
+			continue
+		}
+		found := match{option: opt, score: result.Score}
+		if from == 0 {
+			found.positions = result.Positions
+		}
+		matched = append(matched, found)
+	}
+	if strings.TrimSpace(m.filter) != "" {
+		sort.SliceStable(matched, func(i, j int) bool {
+			return matched[i].score > matched[j].score
 		})
 	}
+	for _, found := range matched {
+		m.filteredKeys = append(m.filteredKeys, found.option)
+		m.matches = append(m.matches, found.positions)
+	}
 
 	// Add "Add new anime" option if enabled
 	if m.addNewOption {
@@ -249,89 +431,66 @@ func DynamicSelectPreview(options map[string]RofiSelectPreview, addnewoption boo
 }
 
 func DynamicSelectPreviewWithBack(options map[string]RofiSelectPreview, addnewoption bool, backText string) (SelectionOption, error) {
-	go preDownloadImages(options, 14)
-
-	userCurdConfig := GetGlobalConfig()
-	if userCurdConfig.StoragePath == "" {
-		userCurdConfig.StoragePath = os.ExpandEnv("${HOME}/.local/share/curd")
+	ordered := make([]PreviewOption, 0, len(options))
+	for id, opt := range options {
+		ordered = append(ordered, PreviewOption{Key: id, RofiSelectPreview: opt})
 	}
+	return DynamicSelectPreviewOrdered(ordered, addnewoption, backText)
+}
+
+// DynamicSelectPreviewOrdered is DynamicSelectPreviewWithBack for ranked
+// lists, shown in the order of options.
+func DynamicSelectPreviewOrdered(options []PreviewOption, addnewoption bool, backText string) (SelectionOption, error) {
+	go preDownloadImages(options, 14)
 
-	var rofiInput strings.Builder
-	selectionOptions := make([]SelectionOption, 0, len(options))
+	items := make([]menu.Item, 0, len(options)+3)
 
 	// Add back button if backText is provided
 	if backText != "" {
-		rofiInput.WriteString(fmt.Sprintf("%s\n", backText))
+		items = append(items, menu.Item{Key: "back", Label: backText})
 	}
 
-	// Prepare options and rofi input
-	for id, opt := range options {
+	// Cache the covers for the backend to show
+	for _, opt := range options {
 		cachePath, err := downloadToCache(opt.CoverImage)
 		if err != nil {
 			Log(fmt.Sprintf("Error caching image: %v", err))
 			continue
 		}
-		rofiInput.WriteString(fmt.Sprintf("%s\x00icon\x1f%s\n", opt.Title, cachePath))
-		selectionOptions = append(selectionOptions, SelectionOption{
-			Label: opt.Title,
-			Key:   id,
-		})
+		items = append(items, menu.Item{Key: opt.Key, Label: opt.Title, Image: cachePath})
 	}
 
 	if addnewoption {
-		rofiInput.WriteString("Add new anime\n")
+		items = append(items, menu.Item{Key: "add_new", Label: "Add new anime"})
 	}
-	rofiInput.WriteString("Quit\n")
-
-	// Run rofi
-	configPath := filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "selectanimepreview.rasi")
-	cmd := exec.Command("rofi", "-dmenu", "-theme", configPath, "-show-icons", "-p", "Select Anime", "-i", "-no-custom")
-	cmd.Stdin = strings.NewReader(rofiInput.String())
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	items = append(items, menu.Item{Key: "-1", Label: "Quit"})
 
-	if err := cmd.Run(); err != nil {
-		Log(fmt.Sprintf("Rofi stderr: %s", stderr.String()))
-		Log(fmt.Sprintf("Rofi stdout: %s", stdout.String()))
-		return SelectionOption{}, fmt.Errorf("failed to execute rofi: %w", err)
+	selected, err := activeMenu().Preview("Select Anime", items)
+	if errors.Is(err, menu.ErrCancelled) {
+		return SelectionOption{}, fmt.Errorf("no selection made")
+	}
+	if err != nil {
+		return SelectionOption{}, err
 	}
-
-	selected := strings.TrimSpace(stdout.String())
 
 	// Handle special cases
-	switch selected {
-	case "":
-		return SelectionOption{}, fmt.Errorf("no selection made")
-	case backText:
-		if backText != "" {
-			return SelectionOption{Label: "Back", Key: "back"}, nil
-		}
-		return SelectionOption{}, fmt.Errorf("no selection made")
-	case "Add new anime":
-		return SelectionOption{Label: "Add new anime", Key: "add_new"}, nil
-	case "Quit":
+	switch selected.Key {
+	case "back":
+		cancelNavigation()
+		return SelectionOption{Label: "Back", Key: "back"}, nil
+	case "-1":
+		cancelNavigation()
 		return SelectionOption{Label: "Quit", Key: "-1"}, nil
 	}
-
-	// Find matching option
-	for _, opt := range selectionOptions {
-		if opt.Label == selected {
-			return opt, nil
-		}
-	}
-
-	return SelectionOption{}, fmt.Errorf("selection not found in options")
+	return SelectionOption{Label: selected.Label, Key: selected.Key}, nil
 }
 
-func preDownloadImages(options map[string]RofiSelectPreview, count int) {
-	i := 0
-	for _, option := range options {
+func preDownloadImages(options []PreviewOption, count int) {
+	for i, option := range options {
 		if i >= count {
 			break
 		}
 		downloadToCache(option.CoverImage)
-		i++
 	}
 }
 
@@ -351,7 +510,7 @@ func downloadToCache(imageURL string) (string, error) {
 	}
 
 	// Download the image
-	resp, err := http.Get(imageURL)
+	resp, err := sharedHTTPClient.Get(imageURL)
 	if err != nil {
 		return "", err
 	}
@@ -388,96 +547,90 @@ func showCachedImagePreview(imageURL string) error {
 	return nil
 }
 
+// RofiSelect lets the user pick one of options in rofi, with a Quit entry.
 func RofiSelect(options []SelectionOption) (SelectionOption, error) {
-	userCurdConfig := GetGlobalConfig()
-	if userCurdConfig.StoragePath == "" {
-		userCurdConfig.StoragePath = os.ExpandEnv("${HOME}/.local/share/curd")
-	}
+	return selectFromMenu(rofiMenu{}, "Select", options)
+}
 
-	// Create ordered list of options
-	var optionsList []string
-	for _, opt := range options {
-		optionsList = append(optionsList, opt.Label)
+func DynamicSelectFromSlice(options []SelectionOption) (SelectionOption, error) {
+	if backend := activeMenu(); !isTUIMenu(backend) {
+		return selectFromMenu(backend, "Select", options)
 	}
 
-	// Add "Quit" option at the end
-	optionsList = append(optionsList, "Quit")
-	optionsString := strings.Join(optionsList, "\n")
-
-	// Prepare and run rofi
-	configPath := filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "selectanime.rasi")
-	cmd := exec.Command("rofi", "-dmenu", "-theme", configPath, "-i", "-p", "Select")
-	cmd.Stdin = strings.NewReader(optionsString)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
+	finalSelectionModel, err := runSelectionModel(newSelectionModel(options, false))
 	if err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
-			return SelectionOption{Key: "-1", Label: ""}, nil
-		}
-		return SelectionOption{}, fmt.Errorf("failed to run Rofi: %v", err)
-	}
-
-	selected := strings.TrimSpace(stdout.String())
-
-	// Handle special cases
-	switch selected {
-	case "":
-		return SelectionOption{Key: "-1", Label: ""}, nil
-	case "Quit":
-		return SelectionOption{Label: "Quit", Key: "-1"}, nil
+		return SelectionOption{}, err
 	}
 
-	// Find the matching SelectionOption
-	for _, opt := range options {
-		if opt.Label == selected {
-			return opt, nil
-		}
+	if finalSelectionModel.selected < len(finalSelectionModel.filteredKeys) {
+		return finalSelectionModel.filteredKeys[finalSelectionModel.selected], nil
 	}
-
-	return SelectionOption{}, fmt.Errorf("selected option not found in original list")
+	return SelectionOption{}, nil
 }
 
-func DynamicSelectFromSlice(options []SelectionOption) (SelectionOption, error) {
-	if GetGlobalConfig().RofiSelection {
-		return RofiSelect(options)
-	}
-
-	model := &Model{
-		allOptions: options,
-	}
-	model.filterOptions() // Initialize filtered options
-
+// runSelectionModel runs the selection prompt until the user leaves it.
+func runSelectionModel(model *Model) (*Model, error) {
 	p := tea.NewProgram(model)
 	activeProgram = p
 	defer func() { activeProgram = nil }()
-
 	finalModel, err := p.Run()
 	if err != nil {
-		return SelectionOption{}, err
+		return nil, err
 	}
 
-	// resetting terminal state
-	fmt.Print("\033[?25h") // show cursor
-	fmt.Print("\033[?7h")  // line wrapping
+	fmt.Print("\033[?25h") // Show cursor
+	fmt.Print("\033[?7h")  // Enable line wrapping
 
 	finalSelectionModel, ok := finalModel.(*Model)
 	if !ok {
-		return SelectionOption{}, fmt.Errorf("unexpected model type")
-	}
-
-	if finalSelectionModel.selected < len(finalSelectionModel.filteredKeys) {
-		return finalSelectionModel.filteredKeys[finalSelectionModel.selected], nil
+		return nil, fmt.Errorf("unexpected model type")
 	}
-	return SelectionOption{}, nil
+	return finalSelectionModel, nil
 }
 
 // DynamicSelect displays a simple selection prompt without extra features
 func DynamicSelect(options []SelectionOption) (SelectionOption, error) {
-	if GetGlobalConfig().RofiSelection {
-		return RofiSelect(options)
+	return dynamicSelect(options, false)
+}
+
+// DynamicSelectOrdered is DynamicSelect for ranked lists whose order must be
+// kept instead of being sorted alphabetically.
+func DynamicSelectOrdered(options []SelectionOption) (SelectionOption, error) {
+	return dynamicSelect(options, true)
+}
+
+// DynamicSelectWithActions is DynamicSelect for lists whose highlighted
+// item also takes single-key actions, such as downloading the highlighted
+// anime. action is the one the user pressed, empty when they picked an
+// option normally. Rofi offers no actions.
+func DynamicSelectWithActions(options []SelectionOption, actions ...keymap.Action) (SelectionOption, keymap.Action, error) {
+	selected, action, err := runDynamicSelect(options, false, actions)
+	if action == "" {
+		cancelNavigationOnLeave(selected)
+	}
+	return selected, action, err
+}
+
+// DynamicSelectOrderedWithActions is DynamicSelectWithActions for lists
+// whose order must be kept.
+func DynamicSelectOrderedWithActions(options []SelectionOption, actions ...keymap.Action) (SelectionOption, keymap.Action, error) {
+	selected, action, err := runDynamicSelect(options, true, actions)
+	if action == "" {
+		cancelNavigationOnLeave(selected)
+	}
+	return selected, action, err
+}
+
+func dynamicSelect(options []SelectionOption, keepOrder bool) (SelectionOption, error) {
+	selected, _, err := runDynamicSelect(options, keepOrder, nil)
+	cancelNavigationOnLeave(selected)
+	return selected, err
+}
+
+func runDynamicSelect(options []SelectionOption, keepOrder bool, actions []keymap.Action) (SelectionOption, keymap.Action, error) {
+	if backend := activeMenu(); !isTUIMenu(backend) {
+		selected, err := selectFromMenu(backend, "Select", options)
+		return selected, "", err
 	}
 
 	// Sort options if this contains menu categories
@@ -490,8 +643,17 @@ func DynamicSelect(options []SelectionOption) (SelectionOption, error) {
 			}
 
 			sorted := make([]SelectionOption, 0, len(options))
+			placed := make(map[string]bool, len(options))
 			for _, key := range menuOrder {
-				if opt, exists := optMap[key]; exists {
+				key = strings.TrimSpace(key)
+				if opt, exists := optMap[key]; exists && !placed[key] {
+					sorted = append(sorted, opt)
+					placed[key] = true
+				}
+			}
+			// Keep categories missing from an older MenuOrder instead of dropping them
+			for _, opt := range options {
+				if !placed[opt.Key] {
 					sorted = append(sorted, opt)
 				}
 			}
@@ -500,76 +662,45 @@ func DynamicSelect(options []SelectionOption) (SelectionOption, error) {
 		}
 	}
 
-	model := &Model{
-		allOptions: options,
-	}
-	model.filterOptions() // Initialize filtered options
-
-	p := tea.NewProgram(model)
-	activeProgram = p
-	defer func() { activeProgram = nil }()
-	finalModel, err := p.Run()
-	if err != nil {
-		return SelectionOption{}, err
-	}
-
-	fmt.Print("\033[?25h") // Show cursor
-	fmt.Print("\033[?7h")  // Enable line wrapping
-
-	finalSelectionModel, ok := finalModel.(*Model)
-	if !ok {
-		return SelectionOption{}, fmt.Errorf("unexpected model type")
-	}
+	model := newSelectionModel(options, keepOrder)
+	model.actions = actions
 
-	// Check if the program was quit with Ctrl+C
+	finalSelectionModel, err := runSelectionModel(model)
 	if err != nil {
-		return SelectionOption{Key: "-1", Label: "Quit"}, nil
+		return SelectionOption{}, "", err
 	}
 
 	if finalSelectionModel.selected < len(finalSelectionModel.filteredKeys) {
-		return finalSelectionModel.filteredKeys[finalSelectionModel.selected], nil
+		return finalSelectionModel.filteredKeys[finalSelectionModel.selected], finalSelectionModel.action, nil
 	}
-	return SelectionOption{}, nil
+	return SelectionOption{}, "", nil
 }
 
 // DynamicMultiSelect allows selecting multiple options using space key
 // Returns a slice of selected options sorted by key (episode number)
 func DynamicMultiSelect(options []SelectionOption) ([]SelectionOption, error) {
-	if GetGlobalConfig().RofiSelection {
-		// Rofi doesn't support multi-select in this context, fall back to single select
-		selected, err := RofiSelect(options)
-		if err != nil {
-			return nil, err
-		}
-		return []SelectionOption{selected}, nil
-	}
+	return dynamicMultiSelect(options, false)
+}
 
-	model := &Model{
-		allOptions:    options,
-		multiSelect:   true,
-		selectedItems: make(map[string]bool),
-	}
-	model.filterOptions() // Initialize filtered options
+// DynamicMultiSelectOrdered is DynamicMultiSelect for lists shown in their
+// own order, such as episodes, instead of sorted alphabetically.
+func DynamicMultiSelectOrdered(options []SelectionOption) ([]SelectionOption, error) {
+	return dynamicMultiSelect(options, true)
+}
 
-	p := tea.NewProgram(model)
-	activeProgram = p
-	defer func() { activeProgram = nil }()
-	finalModel, err := p.Run()
-	if err != nil {
-		return nil, err
+func dynamicMultiSelect(options []SelectionOption, keepOrder bool) ([]SelectionOption, error) {
+	if backend := activeMenu(); !isTUIMenu(backend) {
+		// Backends without multi-select let the user pick a single option
+		return multiSelectFromMenu(backend, "Select", options)
 	}
 
-	fmt.Print("\033[?25h") // Show cursor
-	fmt.Print("\033[?7h")  // Enable line wrapping
+	model := newSelectionModel(options, keepOrder)
+	model.multiSelect = true
+	model.selectedItems = make(map[string]bool)
 
-	finalSelectionModel, ok := finalModel.(*Model)
-	if !ok {
-		return nil, fmt.Errorf("unexpected model type")
-	}
-
-	// If user pressed Ctrl+C, return empty selection
+	finalSelectionModel, err := runSelectionModel(model)
 	if err != nil {
-		return []SelectionOption{}, nil
+		return nil, err
 	}
 
 	// Collect selected items
@@ -0,0 +1,286 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wraient/curd/internal/providers"
+)
+
+// streamPickerMessage is the script-message mpv sends when the stream picker
+// key is pressed.
+const streamPickerMessage = "curd-pick-stream"
+
+var (
+	streamServersMu     sync.Mutex
+	streamServers       map[string]string
+	streamPickerRunning int32
+)
+
+func streamServersPath() string {
+	cfg := GetGlobalConfig()
+	if cfg == nil || cfg.StoragePath == "" {
+		return ""
+	}
+	return filepath.Join(os.ExpandEnv(cfg.StoragePath), "stream_servers.json")
+}
+
+// loadStreamServersLocked reads the remembered servers once per session.
+func loadStreamServersLocked() {
+	if streamServers != nil {
+		return
+	}
+	streamServers = map[string]string{}
+	path := streamServersPath()
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &streamServers); err != nil {
+		Log(fmt.Sprintf("Ignoring invalid %s: %v", path, err))
+		streamServers = map[string]string{}
+	}
+}
+
+// RememberedStreamServer returns the server last picked for a provider.
+func RememberedStreamServer(providerName string) string {
+	if providerName == "" {
+		return ""
+	}
+	streamServersMu.Lock()
+	defer streamServersMu.Unlock()
+	loadStreamServersLocked()
+	return streamServers[providerName]
+}
+
+// RememberStreamServer stores the server picked for a provider so later
+// episodes rank it first.
+func RememberStreamServer(providerName, server string) error {
+	if providerName == "" || server == "" {
+		return nil
+	}
+	streamServersMu.Lock()
+	defer streamServersMu.Unlock()
+	loadStreamServersLocked()
+	if streamServers[providerName] == server {
+		return nil
+	}
+	streamServers[providerName] = server
+
+	path := streamServersPath()
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(streamServers, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// streamLabel describes a stream for the picker menu.
+func streamLabel(stream providers.Stream, playing bool) string {
+	parts := make([]string, 0, 6)
+	if stream.Resolution > 0 {
+		parts = append(parts, fmt.Sprintf("%dp", stream.Resolution))
+	} else {
+		parts = append(parts, "unknown quality")
+	}
+	if stream.Container != "" {
+		parts = append(parts, strings.ToUpper(stream.Container))
+	}
+	if stream.Server != "" {
+		parts = append(parts, stream.Server)
+	}
+	switch stream.SubtitleMode {
+	case providers.SubtitlesHard:
+		parts = append(parts, "hardsub")
+	case providers.SubtitlesSoft:
+		parts = append(parts, "softsub")
+	}
+	if stream.AudioLang != "" {
+		parts = append(parts, stream.AudioLang+" audio")
+	}
+	if stream.Bitrate > 0 {
+		parts = append(parts, fmt.Sprintf("%.1f Mbps", float64(stream.Bitrate)/1e6))
+	}
+	label := strings.Join(parts, " · ")
+	if playing {
+		label += " (playing)"
+	}
+	return label
+}
+
+// PromptStreamSelection lists every resolved stream of the current episode.
+// It returns false when there is nothing to pick or the user backed out.
+func PromptStreamSelection(anime *Anime) (providers.Stream, bool) {
+	if anime == nil || len(anime.Ep.Links) == 0 {
+		return providers.Stream{}, false
+	}
+	streams, providerName := linkStreams(anime.Ep.Links)
+	if providerName == "" {
+		providerName = CurrentAnimeProviderName(anime)
+	}
+	streams = RankStreams(GetGlobalConfig(), providerName, streams)
+
+	options := make([]SelectionOption, 0, len(streams))
+	for i, stream := range streams {
+		options = append(options, SelectionOption{
+			Key:   strconv.Itoa(i),
+			Label: streamLabel(stream, stream.URL == anime.Ep.StreamURL),
+		})
+	}
+	CurdOut(fmt.Sprintf("Select a stream for %s episode %d:", GetAnimeName(*anime), anime.Ep.Number))
+	selected, err := DynamicSelectOrdered(options)
+	if err != nil {
+		Log(fmt.Sprintf("Stream selection failed: %v", err))
+		return providers.Stream{}, false
+	}
+	index, err := strconv.Atoi(selected.Key)
+	if err != nil || index < 0 || index >= len(streams) {
+		return providers.Stream{}, false
+	}
+
+	stream := streams[index]
+	if err := RememberStreamServer(providerName, stream.Server); err != nil {
+		Log(fmt.Sprintf("Failed to remember stream server: %v", err))
+	}
+	return stream, true
+}
+
+// applyStream makes stream the one the episode plays.
+func applyStream(anime *Anime, stream providers.Stream) {
+	hint := stream.Hint()
+	anime.Ep.StreamURL = stream.URL
+	anime.Ep.StreamReferrer = hint.Referrer
+	anime.Ep.SubtitleURL = hint.Subtitle
+}
+
+// selectPlaybackLink returns the link to start playback with, asking the
+// user first when StreamPicker is enabled and there is a choice to make.
+func selectPlaybackLink(config *CurdConfig, anime *Anime) string {
+	link := PrioritizeLink(anime.Ep.Links)
+	if config == nil || !config.StreamPicker || len(anime.Ep.Links) < 2 {
+		return link
+	}
+	if stream, ok := PromptStreamSelection(anime); ok {
+		applyStream(anime, stream)
+		return stream.URL
+	}
+	return link
+}
+
+// SwitchStream replaces the file playing in mpv with stream and resumes at
+// the current position.
+func SwitchStream(anime *Anime, stream providers.Stream) error {
+	socket := anime.Ep.Player.SocketPath
+	if socket == "" || !IsMPVRunning(socket) {
+		return fmt.Errorf("mpv is not running")
+	}
+	if stream.URL == anime.Ep.StreamURL {
+		return nil
+	}
+
+	position := anime.Ep.Player.PlaybackTime
+	if timePos, err := MPVSendCommand(socket, []interface{}{"get_property", "time-pos"}); err == nil {
+		if pos, ok := timePos.(float64); ok {
+			position = int(pos + 0.5)
+		}
+	}
+	return loadStream(anime, stream, position)
+}
+
+// loadStream loads stream into the running mpv starting at position.
+func loadStream(anime *Anime, stream providers.Stream, position int) error {
+	socket := anime.Ep.Player.SocketPath
+	applyStream(anime, stream)
+	referrer := anime.Ep.StreamReferrer
+	if referrer == "" && isHTTPStreamLink(stream.URL) {
+		referrer = streamReferrerForLink(stream.URL, CurrentAnimeProviderName(anime))
+	}
+	if referrer != "" {
+		if _, err := MPVSendCommand(socket, []interface{}{"set_property", "referrer", normalizeReferrerValue(referrer)}); err != nil {
+			Log(fmt.Sprintf("Failed to set referrer property: %v", err))
+		}
+	}
+	// The new file starts at position itself; seeking once it reports a
+	// position would race with the time-pos of the file it replaces. The
+	// index argument is required before the options since mpv 0.38.
+	played := warmCachedLink(stream.URL, referrer)
+	command := []interface{}{"loadfile", played, "replace", -1, fmt.Sprintf("start=%d", position)}
+	if _, err := MPVSendCommand(socket, command); err != nil {
+		return fmt.Errorf("failed to load stream in mpv: %w", err)
+	}
+	replacePlayingStream(anime, stream.URL, played)
+	if subtitleURL := anime.Ep.SubtitleURL; subtitleURL != "" {
+		if _, err := MPVSendCommand(socket, []interface{}{"sub-add", subtitleURL, "select"}); err != nil {
+			Log(fmt.Sprintf("Failed to load subtitle for switched stream: %v", err))
+		}
+	}
+
+	anime.Ep.Player.PlaybackTime = position
+	anime.Ep.Resume = false
+	Log(fmt.Sprintf("Switched stream to %s at %ds", streamLabel(stream, false), position))
+	return nil
+}
+
+// watchStreamPickerKey binds the configured key in a freshly started mpv so
+// the stream picker can be opened mid-episode.
+func watchStreamPickerKey(config *CurdConfig, anime *Anime, socket string) {
+	key := strings.TrimSpace(config.StreamPickerKey)
+	if key == "" || socket == "" || socket == "android-intent" {
+		return
+	}
+	if _, err := MPVSendCommand(socket, []interface{}{"keybind", key, "script-message " + streamPickerMessage}); err != nil {
+		// keybind needs a recent mpv; the pre-play picker still works.
+		Log(fmt.Sprintf("Failed to bind stream picker key %s: %v", key, err))
+		return
+	}
+	err := StartMPVEventListener(socket, func(event string, data interface{}) {
+		if event != "client-message" || !isStreamPickerMessage(data) {
+			return
+		}
+		if !atomic.CompareAndSwapInt32(&streamPickerRunning, 0, 1) {
+			return
+		}
+		go func() {
+			defer atomic.StoreInt32(&streamPickerRunning, 0)
+			stream, ok := PromptStreamSelection(anime)
+			if !ok {
+				return
+			}
+			if err := SwitchStream(anime, stream); err != nil {
+				Log(fmt.Sprintf("Stream switch failed: %v", err))
+				CurdOut("Failed to switch stream: " + err.Error())
+			}
+		}()
+	})
+	if err != nil {
+		Log(fmt.Sprintf("Stream picker listener failed: %v", err))
+	}
+}
+
+func isStreamPickerMessage(data interface{}) bool {
+	event, ok := data.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	args, ok := event["args"].([]interface{})
+	if !ok || len(args) == 0 {
+		return false
+	}
+	name, _ := args[0].(string)
+	return name == streamPickerMessage
+}
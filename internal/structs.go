@@ -23,7 +23,9 @@ type Anime struct {
 	ProviderName   string // newly added for history migration
 	FillerEpisodes []int
 	IsAiring       bool
-	SkipRemoteSync bool `json:"-"`
+	SkipRemoteSync bool   `json:"-"`
+	IsAdult        bool   `json:"-"`
+	Season         string `json:"-"` // e.g. "Fall 2023", for Discord presence
 }
 
 type FuzzyDate struct {
@@ -51,6 +53,7 @@ type Episode struct {
 	Started        bool         `json:"started"`
 	Duration       int          `json:"duration"`
 	Links          []string     `json:"links"`
+	StreamURL      string       `json:"-"`
 	StreamReferrer string       `json:"-"`
 	SubtitleURL    string       `json:"-"`
 	NextEpisode    NextEpisode  `json:"next_episode"`
@@ -61,6 +64,8 @@ type Episode struct {
 	ContinueLast   bool
 	LastWasSkipped bool // used in filler check
 	IsCompleted    bool
+	Playlist       []int `json:"-"` // episodes picked in the episode browser, in play order
+	PlaylistBase   int   `json:"-"` // tracked progress when the playlist started
 }
 
 type NextEpisode struct {
@@ -152,6 +157,19 @@ type Entry struct {
 	UpdatedAt   time.Time `json:"updatedAt"`
 }
 
+// AnimeRelation is an AniList media node reached through a relation edge.
+type AnimeRelation struct {
+	RelationType string
+	ID           int
+	MalID        int
+	Title        AnimeTitle
+	Format       string
+	Episodes     int
+	Status       string
+	CoverImage   string
+	Year         int
+}
+
 type AnimeList struct {
 	Watching   []Entry `json:"watching"`
 	Completed  []Entry `json:"completed"`
@@ -166,6 +184,12 @@ type RofiSelectPreview struct {
 	CoverImage string `json:"coverImage"`
 }
 
+// PreviewOption is an entry of a preview menu whose order matters.
+type PreviewOption struct {
+	Key string
+	RofiSelectPreview
+}
+
 type SelectionOptionImage struct {
 	Key        string
 	Label      string
@@ -178,5 +202,10 @@ type SelectionOption struct {
 	Label     string
 	Key       string
 	Thumbnail string
+	Year      int
+	Episodes  int
 	ExtraData interface{}
+	// Aliases are other names the filter finds the option by, such as a
+	// show's titles in other languages.
+	Aliases []string
 }
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wraient/curd/internal/dashboard"
+	"github.com/wraient/curd/internal/theme"
+)
+
+// activeTheme is the palette the menus are coloured with, and the one rofi
+// themes are generated from.
+var activeTheme = theme.Dark
+
+func init() {
+	applyMenuTheme(theme.Dark)
+}
+
+// ApplyTheme colours the terminal menus with the configured theme. NO_COLOR
+// turns colours off whatever the theme. On error the current theme stays.
+func ApplyTheme(config *CurdConfig) error {
+	file := os.ExpandEnv(strings.TrimSpace(config.ThemeFile))
+	p, err := theme.Load(config.Theme, file, os.Getenv("NO_COLOR") != "")
+	if err != nil {
+		return fmt.Errorf("failed to load theme: %w", err)
+	}
+	activeTheme = p
+	applyMenuTheme(p)
+	dashboard.SetTheme(p)
+	return nil
+}
+
+// themedRofiDir holds the rofi themes recoloured with the active theme.
+func themedRofiDir(config *CurdConfig) string {
+	return filepath.Join(os.ExpandEnv(config.StoragePath), "themed")
+}
+
+// GenerateRofiThemes writes a copy of each of the rofi theme files in the
+// storage directory recoloured with the active theme.
+func GenerateRofiThemes(config *CurdConfig, files []string) error {
+	dir := themedRofiDir(config)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create themed rofi directory: %w", err)
+	}
+	for _, name := range files {
+		template, err := os.ReadFile(filepath.Join(os.ExpandEnv(config.StoragePath), name))
+		if err != nil {
+			return fmt.Errorf("failed to read rofi theme %s: %w", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(theme.Rasi(string(template), activeTheme)), 0644); err != nil {
+			return fmt.Errorf("failed to write rofi theme %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// rofiThemePath returns the rofi theme file to use for name: the recoloured
+// copy when there is one, else the downloaded original.
+func rofiThemePath(config *CurdConfig, name string) string {
+	themed := filepath.Join(themedRofiDir(config), name)
+	if _, err := os.Stat(themed); err == nil {
+		return themed
+	}
+	return filepath.Join(os.ExpandEnv(config.StoragePath), name)
+}
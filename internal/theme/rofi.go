@@ -0,0 +1,67 @@
+package theme
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// rasiVariable matches the colour variables curd's rofi themes define
+	// in their "* { }" section.
+	rasiVariable = regexp.MustCompile(`(?m)^(\s*)(bg|hv|ug|border-color|white):(\s*)[^;\n]+;`)
+	// rasiBackgroundAlpha and rasiForegroundAlpha match the see-through
+	// window backgrounds and placeholders written out as rgba.
+	rasiBackgroundAlpha = regexp.MustCompile(`rgba\(\s*31,\s*31,\s*31,\s*([0-9.]+)\s*\)`)
+	rasiForegroundAlpha = regexp.MustCompile(`rgba\(\s*255,\s*255,\s*255,\s*([0-9.]+)\s*\)`)
+)
+
+// Rasi recolours one of curd's rofi themes with p. Colours p leaves empty
+// keep the template's.
+func Rasi(template string, p Palette) string {
+	values := map[string]string{
+		"bg":           p.Background,
+		"hv":           p.Surface,
+		"ug":           p.Accent,
+		"border-color": p.Accent,
+		"white":        p.Foreground,
+	}
+	out := rasiVariable.ReplaceAllStringFunc(template, func(match string) string {
+		parts := rasiVariable.FindStringSubmatch(match)
+		value := values[parts[2]]
+		if value == "" {
+			return match
+		}
+		return fmt.Sprintf("%s%s:%s%s;", parts[1], parts[2], parts[3], value)
+	})
+	out = replaceAlpha(out, rasiBackgroundAlpha, p.Background)
+	return replaceAlpha(out, rasiForegroundAlpha, p.Foreground)
+}
+
+func replaceAlpha(text string, pattern *regexp.Regexp, colour string) string {
+	r, g, b, ok := rgb(colour)
+	if !ok {
+		return text
+	}
+	return pattern.ReplaceAllStringFunc(text, func(match string) string {
+		alpha := pattern.FindStringSubmatch(match)[1]
+		return fmt.Sprintf("rgba(%d, %d, %d, %s)", r, g, b, alpha)
+	})
+}
+
+// rgb splits a hex colour into its components.
+func rgb(colour string) (r, g, b int, ok bool) {
+	if !hexColour.MatchString(colour) {
+		return 0, 0, 0, false
+	}
+	hex := strings.TrimPrefix(colour, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return int(n >> 16 & 0xFF), int(n >> 8 & 0xFF), int(n & 0xFF), true
+}
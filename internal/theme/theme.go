@@ -0,0 +1,264 @@
+// Package theme holds the colour palettes of curd's terminal menus and the
+// rofi themes generated from them.
+//
+// A palette is picked by name from the built-in ones and may be adjusted by
+// a JSON file of colour overrides, such as
+//
+//	{"base": "light", "accent": "#d7005f", "match": "#005fd7"}
+//
+// Colours are hex ("#RRGGBB" or "#RGB") or ANSI numbers ("0" to "255"), and
+// "none" leaves a colour to the terminal. The rofi colours (background,
+// surface, accent and foreground) must be hex.
+package theme
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Palette is the set of colours a theme is made of. An empty colour is left
+// to the terminal.
+type Palette struct {
+	Name string
+
+	// Menus
+	Title              string
+	Prompt             string
+	Input              string
+	Text               string
+	Dim                string
+	SelectedText       string
+	SelectedBackground string
+	Border             string
+	Match              string
+	Hint               string
+	Error              string
+	Filler             string
+	Recap              string
+	StatusText         string
+	StatusBackground   string
+
+	// Rofi
+	Background string
+	Surface    string
+	Accent     string
+	Foreground string
+}
+
+// Dark is the default theme, curd's original colours.
+var Dark = Palette{
+	Name:               "dark",
+	Title:              "#7CB9E8",
+	Prompt:             "#FF69B4",
+	Input:              "#98FB98",
+	Text:               "#E6E6FA",
+	Dim:                "#8A8AA3",
+	SelectedText:       "#FFFFFF",
+	SelectedBackground: "#4A90E2",
+	Border:             "#5C5C7A",
+	Match:              "#FFB347",
+	Hint:               "#FFD700",
+	Error:              "#FF6B6B",
+	Filler:             "#FFD700",
+	Recap:              "#FF69B4",
+	StatusText:         "#E6E6FA",
+	StatusBackground:   "#2E2E48",
+	Background:         "#1F1F1F",
+	Surface:            "#2A2A2A",
+	Accent:             "#E0744C",
+	Foreground:         "#FFFFFF",
+}
+
+// Light suits terminals with a light background.
+var Light = Palette{
+	Name:               "light",
+	Title:              "#1F5FA8",
+	Prompt:             "#B0306A",
+	Input:              "#2E7D32",
+	Text:               "#2B2B3A",
+	Dim:                "#6B6B80",
+	SelectedText:       "#FFFFFF",
+	SelectedBackground: "#2F6FC0",
+	Border:             "#A0A0B8",
+	Match:              "#C25400",
+	Hint:               "#8A6D00",
+	Error:              "#C62828",
+	Filler:             "#8A6D00",
+	Recap:              "#B0306A",
+	StatusText:         "#2B2B3A",
+	StatusBackground:   "#E2E2EE",
+	Background:         "#F5F5F5",
+	Surface:            "#E4E4E4",
+	Accent:             "#C8552B",
+	Foreground:         "#1F1F1F",
+}
+
+// HighContrast uses only black, white and pure colours.
+var HighContrast = Palette{
+	Name:               "high-contrast",
+	Title:              "#FFFFFF",
+	Prompt:             "#FFFF00",
+	Input:              "#00FF00",
+	Text:               "#FFFFFF",
+	Dim:                "#C0C0C0",
+	SelectedText:       "#000000",
+	SelectedBackground: "#FFFF00",
+	Border:             "#FFFFFF",
+	Match:              "#00FFFF",
+	Hint:               "#FFFF00",
+	Error:              "#FF0000",
+	Filler:             "#FFFF00",
+	Recap:              "#FF00FF",
+	StatusText:         "#000000",
+	StatusBackground:   "#FFFFFF",
+	Background:         "#000000",
+	Surface:            "#404040",
+	Accent:             "#FFFF00",
+	Foreground:         "#FFFFFF",
+}
+
+// None leaves every colour of the menus to the terminal, for NO_COLOR. Rofi
+// cannot go without colours, so it gets black and white.
+var None = Palette{
+	Name:       "none",
+	Background: "#000000",
+	Surface:    "#404040",
+	Accent:     "#FFFFFF",
+	Foreground: "#FFFFFF",
+}
+
+var builtin = map[string]Palette{
+	Dark.Name:         Dark,
+	Light.Name:        Light,
+	HighContrast.Name: HighContrast,
+	None.Name:         None,
+}
+
+// Names returns the names of the built-in themes.
+func Names() []string {
+	names := make([]string, 0, len(builtin))
+	for name := range builtin {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the built-in theme called name.
+func Lookup(name string) (Palette, bool) {
+	p, ok := builtin[strings.ToLower(strings.TrimSpace(name))]
+	return p, ok
+}
+
+// Load returns the theme called name, an empty name being dark, adjusted by
+// the overrides in file if it exists. With noColor, as when NO_COLOR is set,
+// it returns None whatever the settings.
+func Load(name, file string, noColor bool) (Palette, error) {
+	if noColor {
+		return None, nil
+	}
+	if strings.TrimSpace(name) == "" {
+		name = Dark.Name
+	}
+
+	var overrides map[string]string
+	if file != "" {
+		data, err := os.ReadFile(file)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return Palette{}, fmt.Errorf("failed to read theme file: %w", err)
+		default:
+			if err := json.Unmarshal(data, &overrides); err != nil {
+				return Palette{}, fmt.Errorf("invalid theme file %s: %w", file, err)
+			}
+		}
+	}
+	if base, ok := overrides["base"]; ok {
+		name = base
+		delete(overrides, "base")
+	}
+
+	p, ok := Lookup(name)
+	if !ok {
+		return Palette{}, fmt.Errorf("unknown theme %q (built-in themes: %s)", name, strings.Join(Names(), ", "))
+	}
+	if len(overrides) == 0 {
+		return p, nil
+	}
+
+	fields := p.fields()
+	for key, value := range overrides {
+		field, ok := fields[strings.ToLower(key)]
+		if !ok {
+			return Palette{}, fmt.Errorf("unknown colour %q in %s", key, file)
+		}
+		value = strings.TrimSpace(value)
+		if strings.EqualFold(value, "none") {
+			value = ""
+		}
+		if err := validate(value, rofiColours[strings.ToLower(key)]); err != nil {
+			return Palette{}, fmt.Errorf("colour %q in %s: %w", key, file, err)
+		}
+		*field = value
+	}
+	return p, nil
+}
+
+var rofiColours = map[string]bool{"background": true, "surface": true, "accent": true, "foreground": true}
+
+// fields maps the JSON names of p's colours to them.
+func (p *Palette) fields() map[string]*string {
+	return map[string]*string{
+		"title":               &p.Title,
+		"prompt":              &p.Prompt,
+		"input":               &p.Input,
+		"text":                &p.Text,
+		"dim":                 &p.Dim,
+		"selected_text":       &p.SelectedText,
+		"selected_background": &p.SelectedBackground,
+		"border":              &p.Border,
+		"match":               &p.Match,
+		"hint":                &p.Hint,
+		"error":               &p.Error,
+		"filler":              &p.Filler,
+		"recap":               &p.Recap,
+		"status_text":         &p.StatusText,
+		"status_background":   &p.StatusBackground,
+		"background":          &p.Background,
+		"surface":             &p.Surface,
+		"accent":              &p.Accent,
+		"foreground":          &p.Foreground,
+	}
+}
+
+var hexColour = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+func validate(value string, hexOnly bool) error {
+	if value == "" || hexColour.MatchString(value) {
+		return nil
+	}
+	if n, err := strconv.Atoi(value); err == nil && n >= 0 && n <= 255 && !hexOnly {
+		return nil
+	}
+	if hexOnly {
+		return fmt.Errorf("%q is not a hex colour", value)
+	}
+	return fmt.Errorf("%q is neither a hex colour nor an ANSI colour number", value)
+}
+
+// Color returns c for lipgloss, leaving an empty colour to the terminal.
+func Color(c string) lipgloss.TerminalColor {
+	if c == "" {
+		return lipgloss.NoColor{}
+	}
+	return lipgloss.Color(c)
+}
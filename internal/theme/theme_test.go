@@ -0,0 +1,76 @@
+package theme
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.json")
+
+	if p, err := Load("", missing, false); err != nil || p != Dark {
+		t.Fatalf("expected the dark theme by default, got %+v, %v", p, err)
+	}
+	if p, err := Load("High-Contrast", missing, false); err != nil || p != HighContrast {
+		t.Fatalf("expected the high-contrast theme, got %+v, %v", p, err)
+	}
+	if p, _ := Load("light", missing, true); p != None {
+		t.Fatal("NO_COLOR should win over the configured theme")
+	}
+	if _, err := Load("solarized", missing, false); err == nil {
+		t.Fatal("an unknown theme should fail")
+	}
+
+	file := filepath.Join(dir, "theme.json")
+	os.WriteFile(file, []byte(`{"base": "light", "match": "202", "Accent": "#d70", "dim": "none"}`), 0644)
+	p, err := Load("dark", file, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "light" || p.Match != "202" || p.Accent != "#d70" || p.Dim != "" || p.Title != Light.Title {
+		t.Fatalf("overrides were not applied on the file's base: %+v", p)
+	}
+
+	for _, bad := range []string{`{"accent": "202"}`, `{"title": "blue"}`, `{"sparkle": "#fff"}`, `not json`} {
+		os.WriteFile(file, []byte(bad), 0644)
+		if _, err := Load("dark", file, false); err == nil {
+			t.Errorf("Load should reject %s", bad)
+		}
+	}
+}
+
+func TestRasi(t *testing.T) {
+	template := `* {
+    bg:                   #1F1F1F;
+    hv:                   #2A2A2A;
+    ug:                   #e0744c;
+    white:                rgb(255, 255, 255);
+    background-color:     @bg;
+}
+window {
+    background-color: rgba(31, 31, 31, 0.95);
+}
+entry {
+    placeholder-color: rgba(255, 255, 255, 0.5);
+}`
+	out := Rasi(template, Light)
+	for _, want := range []string{
+		"bg:                   #F5F5F5;",
+		"hv:                   #E4E4E4;",
+		"ug:                   #C8552B;",
+		"white:                #1F1F1F;",
+		"background-color:     @bg;",
+		"rgba(245, 245, 245, 0.95)",
+		"rgba(31, 31, 31, 0.5)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("themed rasi is missing %q:\n%s", want, out)
+		}
+	}
+	if Rasi(template, Palette{}) != template {
+		t.Fatal("an empty palette should leave the template alone")
+	}
+}
@@ -68,7 +68,11 @@ func SearchAnimeUnified(query, token string, config *CurdConfig, withPreview boo
 
 // UpdateAnimeProgressUnified updates anime progress on the configured tracking service
 // If DualTracking is enabled, updates both MAL and AniList
-func UpdateAnimeProgressUnified(token string, mediaID, progress int, config *CurdConfig) error {
+func UpdateAnimeProgressUnified(token string, mediaID, progress int, config *CurdConfig) (err error) {
+	if err := guardTrackerUpdate(config); err != nil {
+		return err
+	}
+	defer func() { fireServiceTrackerHook(config, "progress", mediaID, progress, "", err) }()
 	service := GetTrackingService(config)
 	var primaryErr error
 
@@ -91,7 +95,11 @@ func UpdateAnimeProgressUnified(token string, mediaID, progress int, config *Cur
 }
 
 // UpdateAnimeProgressDual updates progress on both services when dual tracking is enabled
-func UpdateAnimeProgressDual(anilistToken, malToken string, anilistID, malID, progress int, config *CurdConfig) error {
+func UpdateAnimeProgressDual(anilistToken, malToken string, anilistID, malID, progress int, config *CurdConfig) (err error) {
+	if err := guardTrackerUpdate(config); err != nil {
+		return err
+	}
+	defer func() { fireTrackerHook(config, true, "progress", anilistID, malID, progress, "", err) }()
 	Log(fmt.Sprintf("UpdateAnimeProgressDual called: anilistID=%d, malID=%d, progress=%d", anilistID, malID, progress))
 	Log(fmt.Sprintf("Token status: anilistToken length=%d, malToken length=%d", len(anilistToken), len(malToken)))
 
@@ -144,7 +152,11 @@ func UpdateAnimeProgressDual(anilistToken, malToken string, anilistID, malID, pr
 }
 
 // UpdateAnimeStatusUnified updates anime status on the configured tracking service
-func UpdateAnimeStatusUnified(token string, mediaID int, status string, config *CurdConfig) error {
+func UpdateAnimeStatusUnified(token string, mediaID int, status string, config *CurdConfig) (err error) {
+	if err := guardTrackerUpdate(config); err != nil {
+		return err
+	}
+	defer func() { fireServiceTrackerHook(config, "status", mediaID, 0, status, err) }()
 	service := GetTrackingService(config)
 	if service == "mal" {
 		return UpdateMALAnimeStatus(token, mediaID, status)
@@ -153,7 +165,11 @@ func UpdateAnimeStatusUnified(token string, mediaID int, status string, config *
 }
 
 // UpdateAnimeStatusDual updates status on both services when dual tracking is enabled
-func UpdateAnimeStatusDual(anilistToken, malToken string, anilistID, malID int, status string, config *CurdConfig) error {
+func UpdateAnimeStatusDual(anilistToken, malToken string, anilistID, malID int, status string, config *CurdConfig) (err error) {
+	if err := guardTrackerUpdate(config); err != nil {
+		return err
+	}
+	defer func() { fireTrackerHook(config, true, "status", anilistID, malID, 0, status, err) }()
 	if !config.DualTracking {
 		// Not dual tracking, use the unified function
 		service := GetTrackingService(config)
@@ -193,7 +209,11 @@ func UpdateAnimeStatusDual(anilistToken, malToken string, anilistID, malID int,
 }
 
 // RateAnimeUnified rates anime on the configured tracking service
-func RateAnimeUnified(token string, mediaID int, config *CurdConfig) error {
+func RateAnimeUnified(token string, mediaID int, config *CurdConfig) (err error) {
+	if err := guardTrackerUpdate(config); err != nil {
+		return err
+	}
+	defer func() { fireServiceTrackerHook(config, "score", mediaID, 0, "", err) }()
 	service := GetTrackingService(config)
 	if service == "mal" {
 		return RateAnimeMAL(token, mediaID)
@@ -202,7 +222,11 @@ func RateAnimeUnified(token string, mediaID int, config *CurdConfig) error {
 }
 
 // RateAnimeDual rates anime on both services when dual tracking is enabled
-func RateAnimeDual(anilistToken, malToken string, anilistID, malID int, config *CurdConfig) error {
+func RateAnimeDual(anilistToken, malToken string, anilistID, malID int, config *CurdConfig) (err error) {
+	if err := guardTrackerUpdate(config); err != nil {
+		return err
+	}
+	defer func() { fireTrackerHook(config, true, "score", anilistID, malID, 0, "", err) }()
 	if !config.DualTracking {
 		// Not dual tracking, use the unified function
 		service := GetTrackingService(config)
@@ -242,7 +266,11 @@ func RateAnimeDual(anilistToken, malToken string, anilistID, malID int, config *
 }
 
 // AddAnimeToWatchingListUnified adds anime to watching list on the configured tracking service
-func AddAnimeToWatchingListUnified(animeID int, token string, config *CurdConfig) error {
+func AddAnimeToWatchingListUnified(animeID int, token string, config *CurdConfig) (err error) {
+	if err := guardTrackerUpdate(config); err != nil {
+		return err
+	}
+	defer func() { fireServiceTrackerHook(config, "add", animeID, 0, "CURRENT", err) }()
 	service := GetTrackingService(config)
 	if service == "mal" {
 		return AddAnimeToMALWatchingList(animeID, token)
@@ -250,6 +278,19 @@ func AddAnimeToWatchingListUnified(animeID int, token string, config *CurdConfig
 	return AddAnimeToWatchingList(animeID, token)
 }
 
+// AddAnimeToPlanningListUnified adds anime to the planning list on the configured tracking service
+func AddAnimeToPlanningListUnified(animeID int, token string, config *CurdConfig) (err error) {
+	if err := guardTrackerUpdate(config); err != nil {
+		return err
+	}
+	defer func() { fireServiceTrackerHook(config, "add", animeID, 0, "PLANNING", err) }()
+	service := GetTrackingService(config)
+	if service == "mal" {
+		return AddAnimeToMALPlanningList(animeID, token)
+	}
+	return AddAnimeToPlanningList(animeID, token)
+}
+
 // GetAnimeDataByIDUnified gets anime details from the configured tracking service
 func GetAnimeDataByIDUnified(id int, token string, config *CurdConfig) (Anime, error) {
 	service := GetTrackingService(config)
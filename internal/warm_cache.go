@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/wraient/curd/internal/providers/hlsproxy"
+	"github.com/wraient/curd/internal/warmcache"
+)
+
+// warmNextDelay is how long after the current episode starts the next one
+// begins buffering, so both don't compete for bandwidth at the start.
+const warmNextDelay = 90 * time.Second
+
+// configureWarmCache applies the WarmCache settings and reports whether the
+// cache is enabled. Android hands the stream to another app, which can't be
+// relied on to reach curd's local proxy.
+func configureWarmCache(config *CurdConfig) bool {
+	if config == nil || !config.WarmCache || config.WarmCacheSize <= 0 || runtime.GOOS == "android" {
+		return false
+	}
+	warmcache.Configure(warmcache.Config{
+		Dir:      os.ExpandEnv(config.WarmCacheDir),
+		MaxBytes: int64(config.WarmCacheSize) << 20,
+		Ahead:    warmCacheLength(config),
+	})
+	return true
+}
+
+func warmCacheLength(config *CurdConfig) time.Duration {
+	minutes := config.WarmCacheMinutes
+	if minutes <= 0 {
+		minutes = 3
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func warmCacheHeaders(referrer string) map[string]string {
+	if referrer = normalizeReferrerValue(referrer); referrer != "" {
+		return map[string]string{"Referer": referrer}
+	}
+	return nil
+}
+
+// warmCachedLink returns the URL mpv should open for link: the warm cache
+// proxy when it is enabled, otherwise link itself.
+func warmCachedLink(link, referrer string) string {
+	if !isHTTPStreamLink(link) || !configureWarmCache(GetGlobalConfig()) {
+		return link
+	}
+	local, err := warmcache.Register(link, warmCacheHeaders(referrer))
+	if err != nil {
+		Log(fmt.Sprintf("Warm cache unavailable, playing directly: %v", err))
+		return link
+	}
+	return local
+}
+
+// playingStream holds the local proxy sessions behind the file mpv plays,
+// so they are released when it is replaced instead of piling up over a
+// binge.
+var playingStream struct {
+	sync.Mutex
+	episode string
+	// provided are the hlsproxy URLs providers handed out for the episode.
+	// They stay until the episode changes, since the stream picker can go
+	// back to them.
+	provided []string
+	// served is the session the warm cache registered for the file; the
+	// warm cache registers it again when the stream is played again.
+	served string
+}
+
+// replacePlayingStream records that mpv now plays link through played,
+// releasing the sessions of the stream it replaces.
+func replacePlayingStream(anime *Anime, link, played string) {
+	episode := fmt.Sprintf("%d/%d", anime.AnilistId, anime.Ep.Number)
+	playingStream.Lock()
+	defer playingStream.Unlock()
+
+	if playingStream.served != "" && playingStream.served != played {
+		warmcache.Release(playingStream.served)
+	}
+	playingStream.served = ""
+	if played != link {
+		playingStream.served = played
+	}
+
+	if playingStream.episode != episode {
+		for _, provided := range playingStream.provided {
+			if provided != link {
+				hlsproxy.Release(provided)
+			}
+		}
+		playingStream.episode, playingStream.provided = episode, nil
+	}
+	if hlsproxy.IsLocal(link) && !slices.Contains(playingStream.provided, link) {
+		playingStream.provided = append(playingStream.provided, link)
+	}
+}
+
+// warmNextEpisode buffers the start of the prefetched next episode once the
+// current one has been playing for a while. It gives up when ctx, the
+// current episode's playback, ends first.
+func warmNextEpisode(ctx context.Context, config *CurdConfig, next NextEpisode) {
+	if !configureWarmCache(config) || len(next.Links) == 0 {
+		return
+	}
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(warmNextDelay):
+	}
+
+	link := PrioritizeLink(next.Links)
+	if !isHTTPStreamLink(link) {
+		return
+	}
+	referrer := next.LinkHints[link].Referrer
+	if referrer == "" {
+		referrer = streamReferrerForLink(link, next.ProviderName)
+	}
+
+	start := time.Now()
+	if err := warmcache.Warm(link, warmCacheHeaders(referrer), warmCacheLength(config)); err != nil {
+		Log(fmt.Sprintf("Warm cache: failed to buffer episode %d: %v", next.Number, err))
+		return
+	}
+	Log(fmt.Sprintf("Warm cache: buffered the start of episode %d in %s", next.Number, time.Since(start).Round(time.Second)))
+}
@@ -0,0 +1,280 @@
+// Package warmcache buffers streams to a bounded disk cache through a local
+// HTTP proxy, so episode transitions and seeks don't wait on the network.
+//
+// Register returns a 127.0.0.1 URL that serves the stream from the cache,
+// downloading what is missing. Progressive files are served with range
+// support in fixed-size blocks. HLS streams are handed to hlsproxy, which
+// Configure points at the same cache. While the player reads, the next
+// Config.Ahead of the stream is kept downloaded, and Warm fills the cache
+// with the start of a stream before it is played.
+package warmcache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wraient/curd/internal/curdhost"
+	"github.com/wraient/curd/internal/providers/hlsproxy"
+)
+
+// Config controls the shared cache.
+type Config struct {
+	// Dir is where cached blobs are stored.
+	Dir string
+	// MaxBytes bounds the size of Dir.
+	MaxBytes int64
+	// Ahead is how much of a playing stream is kept downloaded past the
+	// point the player is reading.
+	Ahead time.Duration
+}
+
+const (
+	// blockSize is the unit progressive files are fetched and cached in.
+	blockSize = 2 << 20
+	// assumedBitrate estimates how many bits a second of a progressive file
+	// takes, since its duration is unknown until the player opens it.
+	assumedBitrate = 2500000
+)
+
+type proxy struct {
+	config   Config
+	store    *Store
+	baseURL  string
+	server   *http.Server
+	mu       sync.Mutex
+	sessions map[string]*session
+	byURL    map[string]*session
+}
+
+var (
+	proxyMu     sync.Mutex
+	proxyServer *proxy
+	current     Config
+	store       *Store
+)
+
+// Configure sets the cache used by later calls and by hlsproxy. Changing
+// the directory or size restarts the proxy.
+func Configure(config Config) {
+	proxyMu.Lock()
+	defer proxyMu.Unlock()
+	if config == current {
+		return
+	}
+	current = config
+	stopLocked()
+	store = nil
+	if config.Dir != "" && config.MaxBytes > 0 {
+		store = NewStore(config.Dir, config.MaxBytes)
+		hlsproxy.SetStore(store, config.Ahead)
+	} else {
+		hlsproxy.SetStore(nil, 0)
+	}
+}
+
+// ResetForTest stops the proxy so tests start from a clean state.
+func ResetForTest() {
+	proxyMu.Lock()
+	defer proxyMu.Unlock()
+	stopLocked()
+	current = Config{}
+	store = nil
+	hlsproxy.SetStore(nil, 0)
+}
+
+func stopLocked() {
+	if proxyServer != nil && proxyServer.server != nil {
+		_ = proxyServer.server.Close()
+	}
+	proxyServer = nil
+}
+
+func getProxy() (*proxy, error) {
+	proxyMu.Lock()
+	defer proxyMu.Unlock()
+
+	if proxyServer != nil {
+		return proxyServer, nil
+	}
+	if store == nil {
+		return nil, fmt.Errorf("warm cache is not configured")
+	}
+
+	p := &proxy{
+		config:   current,
+		store:    store,
+		sessions: map[string]*session{},
+		byURL:    map[string]*session{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/w/", p.handle)
+	server := &http.Server{Handler: mux}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("start warm cache listener: %w", err)
+	}
+	p.server = server
+	p.baseURL = "http://" + listener.Addr().String()
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	proxyServer = p
+	return p, nil
+}
+
+// Register returns the local URL serving rawURL through the cache. headers
+// are sent with every upstream request. Registering the same URL again
+// returns the same local URL, so a warmed stream is played from the cache.
+// HLS playlists are registered with hlsproxy instead, and URLs hlsproxy
+// already serves are returned as they are.
+func Register(rawURL string, headers map[string]string) (string, error) {
+	if hlsproxy.IsLocal(rawURL) {
+		return rawURL, nil
+	}
+	if isPlaylistName(rawURL) {
+		return hlsproxy.Register(rawURL, hlsproxy.Options{Headers: headers})
+	}
+	p, err := getProxy()
+	if err != nil {
+		return "", err
+	}
+	s, err := p.session(rawURL, headers)
+	if err != nil {
+		return "", err
+	}
+	return p.baseURL + "/w/" + s.id + "/" + entryName(rawURL), nil
+}
+
+// Release drops the session behind localURL, a URL returned by Register,
+// once the player has moved on from it. What it cached stays in the store.
+func Release(localURL string) {
+	if hlsproxy.IsLocal(localURL) {
+		hlsproxy.Release(localURL)
+		return
+	}
+	proxyMu.Lock()
+	p := proxyServer
+	proxyMu.Unlock()
+	if p == nil {
+		return
+	}
+	rest, ok := strings.CutPrefix(localURL, p.baseURL+"/w/")
+	if !ok {
+		return
+	}
+	id, _, _ := strings.Cut(rest, "/")
+	p.mu.Lock()
+	s, ok := p.sessions[id]
+	if ok {
+		delete(p.sessions, id)
+		delete(p.byURL, s.url)
+	}
+	p.mu.Unlock()
+	if ok {
+		s.mu.Lock()
+		hlsURL := s.hlsURL
+		s.mu.Unlock()
+		hlsproxy.Release(hlsURL)
+	}
+}
+
+func (p *proxy) session(rawURL string, headers map[string]string) (*session, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.byURL[rawURL]; ok {
+		s.setHeaders(headers)
+		return s, nil
+	}
+	id, err := randomSessionID()
+	if err != nil {
+		return nil, err
+	}
+	s := newSession(p, id, rawURL, headers)
+	p.sessions[id] = s
+	p.byURL[rawURL] = s
+	return s, nil
+}
+
+// entryName keeps the file name of the upstream URL so the player can guess
+// the format from the local one.
+func entryName(rawURL string) string {
+	name := rawURL
+	if idx := strings.IndexAny(name, "?#"); idx >= 0 {
+		name = name[:idx]
+	}
+	name = name[strings.LastIndex(name, "/")+1:]
+	if name == "" {
+		return "stream"
+	}
+	return name
+}
+
+func isPlaylistName(rawURL string) bool {
+	return strings.Contains(strings.ToLower(entryName(rawURL)), ".m3u8")
+}
+
+// handle serves /w/{session}/{name} for the registered URL.
+func (p *proxy) handle(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/w/"), "/")
+	p.mu.Lock()
+	s, ok := p.sessions[parts[0]]
+	p.mu.Unlock()
+	if !ok || len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	s.serveEntry(w, r)
+}
+
+// fetch downloads rawURL through the host HTTP client. rangeHeader is sent
+// as the Range header when set.
+func fetch(rawURL string, headers map[string]string, rangeHeader string) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	resp, err := curdhost.HTTPClient().Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !curdhost.HTTPStatusOK(resp.StatusCode) {
+		return nil, nil, curdhost.HTTPStatusError("warm cache request", resp.StatusCode, body)
+	}
+	return resp, body, nil
+}
+
+func logf(format string, args ...interface{}) {
+	if curdhost.Log != nil {
+		curdhost.Log(fmt.Sprintf(format, args...))
+	}
+}
+
+func randomSessionID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,318 @@
+package warmcache
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wraient/curd/internal/curdhost"
+	"github.com/wraient/curd/internal/providers/hlsproxy"
+	"github.com/wraient/curd/internal/readahead"
+)
+
+const (
+	kindUnknown = iota
+	kindHLS
+	kindProgressive
+	// kindPassthrough is a file whose server ignores range requests. It is
+	// proxied as is, without caching.
+	kindPassthrough
+)
+
+var contentRangeRE = regexp.MustCompile(`/(\d+)\s*$`)
+
+type session struct {
+	p   *proxy
+	id  string
+	url string
+
+	detectMu sync.Mutex
+
+	mu          sync.Mutex
+	headers     map[string]string
+	kind        int
+	size        int64
+	contentType string
+	// hlsURL is where hlsproxy serves the stream once it turned out to be
+	// HLS.
+	hlsURL string
+
+	ahead readahead.Worker
+}
+
+func newSession(p *proxy, id, rawURL string, headers map[string]string) *session {
+	return &session{p: p, id: id, url: rawURL, headers: headers}
+}
+
+func (s *session) setHeaders(headers map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(headers) > 0 {
+		s.headers = headers
+	}
+}
+
+func (s *session) requestHeaders() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.headers
+}
+
+// detect works out how the registered URL has to be served. Progressive
+// files learn their size from a first block request, which is cached.
+func (s *session) detect() (int, error) {
+	s.detectMu.Lock()
+	defer s.detectMu.Unlock()
+
+	s.mu.Lock()
+	kind := s.kind
+	s.mu.Unlock()
+	if kind != kindUnknown {
+		return kind, nil
+	}
+
+	if isPlaylistName(s.url) {
+		kind = kindHLS
+	} else {
+		resp, body, err := fetch(s.url, s.requestHeaders(), fmt.Sprintf("bytes=0-%d", blockSize-1))
+		if err != nil {
+			return kindUnknown, err
+		}
+		total := int64(-1)
+		if match := contentRangeRE.FindStringSubmatch(resp.Header.Get("Content-Range")); len(match) == 2 {
+			total, _ = strconv.ParseInt(match[1], 10, 64)
+		}
+		switch {
+		case strings.HasPrefix(string(body), "#EXTM3U"):
+			kind = kindHLS
+		case resp.StatusCode != http.StatusPartialContent || total <= 0:
+			kind = kindPassthrough
+		default:
+			kind = kindProgressive
+			s.mu.Lock()
+			s.size = total
+			s.contentType = resp.Header.Get("Content-Type")
+			s.mu.Unlock()
+			if err := s.p.store.Put(blockKey(s.url, 0), body); err != nil {
+				logf("Warm cache write failed: %v", err)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.kind = kind
+	s.mu.Unlock()
+	return kind, nil
+}
+
+func (s *session) serveEntry(w http.ResponseWriter, r *http.Request) {
+	kind, err := s.detect()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	switch kind {
+	case kindHLS:
+		s.redirectHLS(w, r)
+	case kindProgressive:
+		s.serveRange(w, r)
+	default:
+		s.servePassthrough(w, r)
+	}
+}
+
+// redirectHLS sends the player to hlsproxy for a stream whose URL did not
+// give away that it is HLS.
+func (s *session) redirectHLS(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	local := s.hlsURL
+	s.mu.Unlock()
+	if local == "" || !hlsproxy.IsLocal(local) {
+		var err error
+		local, err = hlsproxy.Register(s.url, hlsproxy.Options{Headers: s.requestHeaders()})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		s.mu.Lock()
+		s.hlsURL = local
+		s.mu.Unlock()
+	}
+	http.Redirect(w, r, local, http.StatusFound)
+}
+
+func blockKey(rawURL string, block int64) string {
+	return fmt.Sprintf("%s#block=%d", rawURL, block)
+}
+
+// block returns one blockSize chunk of a progressive file.
+func (s *session) block(block int64) ([]byte, error) {
+	s.mu.Lock()
+	size := s.size
+	s.mu.Unlock()
+	start := block * blockSize
+	if start >= size {
+		return nil, fmt.Errorf("block %d is past the end of the file", block)
+	}
+	end := start + blockSize - 1
+	if end >= size {
+		end = size - 1
+	}
+	return s.p.store.Fetch(blockKey(s.url, block), func() ([]byte, error) {
+		_, data, err := fetch(s.url, s.requestHeaders(), fmt.Sprintf("bytes=%d-%d", start, end))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) != end-start+1 {
+			return nil, fmt.Errorf("short read for bytes %d-%d: got %d bytes", start, end, len(data))
+		}
+		return data, nil
+	})
+}
+
+func (s *session) lastBlock() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return (s.size - 1) / blockSize
+}
+
+func aheadBlocks(seconds float64) int64 {
+	return int64(math.Ceil(seconds * assumedBitrate / 8 / blockSize))
+}
+
+// fillBlocks downloads the blocks covering Ahead of playback after from.
+func (s *session) fillBlocks(from int, moved func() bool) {
+	last := s.lastBlock()
+	end := int64(from) + aheadBlocks(s.p.config.Ahead.Seconds())
+	if end > last {
+		end = last
+	}
+	for block := int64(from) + 1; block <= end; block++ {
+		if moved() {
+			return
+		}
+		if s.p.store.Has(blockKey(s.url, block)) {
+			continue
+		}
+		if _, err := s.block(block); err != nil {
+			logf("Warm cache prefetch failed: %v", err)
+			return
+		}
+	}
+}
+
+// parseRange reads a single "bytes=" range against size.
+func parseRange(header string, size int64) (int64, int64, bool) {
+	spec := strings.TrimSpace(strings.TrimPrefix(header, "bytes="))
+	if header == "" || spec == header || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	startText, endText, _ := strings.Cut(spec, "-")
+	if startText == "" {
+		suffix, err := strconv.ParseInt(endText, 10, 64)
+		if err != nil || suffix <= 0 {
+			return 0, 0, false
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, size - 1, true
+	}
+	start, err := strconv.ParseInt(startText, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if endText != "" {
+		if end, err = strconv.ParseInt(endText, 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, true
+}
+
+// serveRange serves a progressive file from cached blocks.
+func (s *session) serveRange(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	size := s.size
+	contentType := s.contentType
+	s.mu.Unlock()
+
+	start, end := int64(0), size-1
+	status := http.StatusOK
+	if header := r.Header.Get("Range"); header != "" {
+		var ok bool
+		start, end, ok = parseRange(header, size)
+		if !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			http.Error(w, "invalid range", http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		status = http.StatusPartialContent
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	}
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	for offset := start; offset <= end; {
+		block := offset / blockSize
+		data, err := s.block(block)
+		if err != nil {
+			logf("Warm cache read failed: %v", err)
+			return
+		}
+		s.ahead.Kick(int(block), s.fillBlocks)
+		from := offset - block*blockSize
+		to := int64(len(data))
+		if limit := end - block*blockSize + 1; limit < to {
+			to = limit
+		}
+		if _, err := w.Write(data[from:to]); err != nil {
+			return
+		}
+		offset = block*blockSize + to
+	}
+}
+
+// servePassthrough proxies a request straight to the upstream file.
+func (s *session) servePassthrough(w http.ResponseWriter, r *http.Request) {
+	req, err := http.NewRequest(r.Method, s.url, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	for key, value := range s.requestHeaders() {
+		req.Header.Set(key, value)
+	}
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	resp, err := curdhost.HTTPClient().Do(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for _, name := range []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges"} {
+		if value := resp.Header.Get(name); value != "" {
+			w.Header().Set(name, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
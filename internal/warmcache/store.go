@@ -0,0 +1,194 @@
+package warmcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store is a size-bounded directory of cached blobs. The least recently
+// used blobs are removed once the total size passes the limit.
+type Store struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	scanned bool
+	total   int64
+	flights map[string]*flight
+}
+
+type flight struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// partialPrefix marks blobs that are still being written.
+const partialPrefix = ".part-"
+
+func isPartial(name string) bool {
+	return strings.HasPrefix(name, partialPrefix)
+}
+
+// NewStore returns a store in dir holding at most maxBytes.
+func NewStore(dir string, maxBytes int64) *Store {
+	return &Store{dir: dir, maxBytes: maxBytes, flights: map[string]*flight{}}
+}
+
+func (s *Store) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
+
+// Has reports whether key is cached.
+func (s *Store) Has(key string) bool {
+	_, err := os.Stat(s.path(key))
+	return err == nil
+}
+
+// Get returns the blob cached under key.
+func (s *Store) Get(key string) ([]byte, bool) {
+	path := s.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return data, true
+}
+
+// Put caches data under key and evicts old blobs if the store is full.
+func (s *Store) Put(key string, data []byte) error {
+	if int64(len(data)) > s.maxBytes {
+		return nil
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	path := s.path(key)
+	tmp, err := os.CreateTemp(s.dir, partialPrefix+"*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scanLocked()
+	if info, err := os.Stat(path); err == nil {
+		s.total -= info.Size()
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	s.total += int64(len(data))
+	if s.total > s.maxBytes {
+		s.evictLocked(path)
+	}
+	return nil
+}
+
+// Fetch returns the blob under key, calling load and caching its result
+// when it is missing. Concurrent calls for one key share a single load.
+func (s *Store) Fetch(key string, load func() ([]byte, error)) ([]byte, error) {
+	if data, ok := s.Get(key); ok {
+		return data, nil
+	}
+
+	s.mu.Lock()
+	if f, ok := s.flights[key]; ok {
+		s.mu.Unlock()
+		<-f.done
+		return f.data, f.err
+	}
+	f := &flight{done: make(chan struct{})}
+	s.flights[key] = f
+	s.mu.Unlock()
+
+	f.data, f.err = load()
+	if f.err == nil {
+		if err := s.Put(key, f.data); err != nil {
+			logf("Warm cache write failed: %v", err)
+		}
+	}
+	close(f.done)
+
+	s.mu.Lock()
+	delete(s.flights, key)
+	s.mu.Unlock()
+	return f.data, f.err
+}
+
+// scanLocked totals the blobs already on disk the first time it is needed.
+func (s *Store) scanLocked() {
+	if s.scanned {
+		return
+	}
+	s.scanned = true
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if isPartial(entry.Name()) {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+			s.total += info.Size()
+		}
+	}
+}
+
+// evictLocked removes the least recently used blobs until the store is back
+// under 90% of its limit. keep is never removed.
+func (s *Store) evictLocked(keep string) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	type blob struct {
+		path string
+		size int64
+		used time.Time
+	}
+	blobs := make([]blob, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || isPartial(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(s.dir, entry.Name())
+		if path == keep {
+			continue
+		}
+		blobs = append(blobs, blob{path: path, size: info.Size(), used: info.ModTime()})
+	}
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].used.Before(blobs[j].used) })
+
+	target := s.maxBytes / 10 * 9
+	for _, b := range blobs {
+		if s.total <= target {
+			return
+		}
+		if err := os.Remove(b.path); err == nil {
+			s.total -= b.size
+		}
+	}
+}
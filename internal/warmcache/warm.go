@@ -0,0 +1,52 @@
+package warmcache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wraient/curd/internal/providers/hlsproxy"
+)
+
+// Warm downloads the first length of the stream at rawURL into the cache so
+// that playing it later through Register starts from disk. HLS streams are
+// warmed by hlsproxy.
+func Warm(rawURL string, headers map[string]string, length time.Duration) error {
+	if hlsproxy.IsLocal(rawURL) || isPlaylistName(rawURL) {
+		return hlsproxy.Warm(rawURL, hlsproxy.Options{Headers: headers}, length)
+	}
+	p, err := getProxy()
+	if err != nil {
+		return err
+	}
+	s, err := p.session(rawURL, headers)
+	if err != nil {
+		return err
+	}
+
+	kind, err := s.detect()
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case kindHLS:
+		return hlsproxy.Warm(rawURL, hlsproxy.Options{Headers: headers}, length)
+	case kindProgressive:
+		return s.warmBlocks(length)
+	default:
+		return fmt.Errorf("%s does not support range requests", entryName(rawURL))
+	}
+}
+
+func (s *session) warmBlocks(length time.Duration) error {
+	last := s.lastBlock()
+	end := aheadBlocks(length.Seconds())
+	if end > last {
+		end = last
+	}
+	for block := int64(0); block <= end; block++ {
+		if _, err := s.block(block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
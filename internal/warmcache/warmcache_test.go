@@ -0,0 +1,387 @@
+package warmcache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wraient/curd/internal/curdhost"
+	"github.com/wraient/curd/internal/providers/hlsproxy"
+)
+
+type hitCounter struct {
+	mu   sync.Mutex
+	hits map[string]int
+}
+
+func (c *hitCounter) add(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hits == nil {
+		c.hits = map[string]int{}
+	}
+	c.hits[path]++
+}
+
+func (c *hitCounter) get(path string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits[path]
+}
+
+func setup(t *testing.T, client *http.Client, ahead time.Duration) string {
+	t.Helper()
+	previous := curdhost.HTTPClient
+	t.Cleanup(func() {
+		curdhost.HTTPClient = previous
+		ResetForTest()
+	})
+	curdhost.HTTPClient = func() *http.Client { return client }
+	dir := t.TempDir()
+	ResetForTest()
+	Configure(Config{Dir: dir, MaxBytes: 64 << 20, Ahead: ahead})
+	return dir
+}
+
+func get(t *testing.T, rawURL, rangeHeader string) (*http.Response, []byte) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, rawURL, nil)
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("get %s: %v", rawURL, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		t.Fatalf("get %s: status %d: %s", rawURL, resp.StatusCode, body)
+	}
+	return resp, body
+}
+
+func firstURI(playlist string) string {
+	for _, line := range strings.Split(playlist, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+func TestStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir, 100)
+	blob := bytes.Repeat([]byte("x"), 40)
+
+	if err := store.Put("a", blob); err != nil {
+		t.Fatalf("put a: %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	_ = os.Chtimes(store.path("a"), old, old)
+	if err := store.Put("b", blob); err != nil {
+		t.Fatalf("put b: %v", err)
+	}
+	if err := store.Put("c", blob); err != nil {
+		t.Fatalf("put c: %v", err)
+	}
+
+	if store.Has("a") {
+		t.Fatalf("least recently used blob was kept")
+	}
+	if !store.Has("b") || !store.Has("c") {
+		t.Fatalf("recent blobs were evicted")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 files in %s, got %d", filepath.Base(dir), len(entries))
+	}
+}
+
+func TestWarmHLSServesFromCache(t *testing.T) {
+	var hits hitCounter
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.add(r.URL.Path)
+		if r.Header.Get("Referer") != "https://example.org/" {
+			http.Error(w, "missing referer", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/master.m3u8":
+			_, _ = io.WriteString(w, "#EXTM3U\n"+
+				"#EXT-X-STREAM-INF:BANDWIDTH=800000\nlow.m3u8\n"+
+				"#EXT-X-STREAM-INF:BANDWIDTH=3000000\nhigh.m3u8\n")
+		case "/high.m3u8":
+			_, _ = io.WriteString(w, "#EXTM3U\n#EXTINF:10,\nhigh0.ts\n#EXTINF:10,\nhigh1.ts\n#EXTINF:10,\nhigh2.ts\n#EXT-X-ENDLIST\n")
+		case "/low.m3u8":
+			_, _ = io.WriteString(w, "#EXTM3U\n#EXTINF:10,\nlow0.ts\n#EXT-X-ENDLIST\n")
+		default:
+			_, _ = io.WriteString(w, "segment "+r.URL.Path)
+		}
+	}))
+	defer upstream.Close()
+	setup(t, upstream.Client(), 0)
+
+	headers := map[string]string{"Referer": "https://example.org/"}
+	if err := Warm(upstream.URL+"/master.m3u8", headers, 15*time.Second); err != nil {
+		t.Fatalf("Warm: %v", err)
+	}
+	if hits.get("/high0.ts") != 1 || hits.get("/high1.ts") != 1 || hits.get("/high2.ts") != 0 {
+		t.Fatalf("expected the first 15s of the best variant, got %v", hits.hits)
+	}
+	if hits.get("/low.m3u8") != 0 {
+		t.Fatalf("warmed the wrong variant")
+	}
+
+	local, err := Register(upstream.URL+"/master.m3u8", headers)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	_, master := get(t, local, "")
+	var variant string
+	for _, line := range strings.Split(string(master), "\n") {
+		if strings.HasSuffix(line, "/high.m3u8") {
+			variant = line
+		}
+	}
+	if variant == "" {
+		t.Fatalf("variant not rewritten:\n%s", master)
+	}
+	_, media := get(t, variant, "")
+	_, segment := get(t, firstURI(string(media)), "")
+	if string(segment) != "segment /high0.ts" {
+		t.Fatalf("unexpected segment %q", segment)
+	}
+	if hits.get("/high0.ts") != 1 || hits.get("/master.m3u8") != 1 || hits.get("/high.m3u8") != 1 {
+		t.Fatalf("warmed stream fetched again: %v", hits.hits)
+	}
+}
+
+func TestHLSKeepsAheadOfPlayback(t *testing.T) {
+	var hits hitCounter
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.add(r.URL.Path)
+		if r.URL.Path == "/index.m3u8" {
+			_, _ = io.WriteString(w, "#EXTM3U\n#EXTINF:10,\n0.ts\n#EXTINF:10,\n1.ts\n#EXTINF:10,\n2.ts\n#EXTINF:10,\n3.ts\n#EXT-X-ENDLIST\n")
+			return
+		}
+		_, _ = io.WriteString(w, r.URL.Path)
+	}))
+	defer upstream.Close()
+	setup(t, upstream.Client(), 20*time.Second)
+
+	local, err := Register(upstream.URL+"/index.m3u8", nil)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	_, media := get(t, local, "")
+	get(t, firstURI(string(media)), "")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for hits.get("/2.ts") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("segments ahead of playback were not fetched: %v", hits.hits)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if hits.get("/3.ts") != 0 {
+		t.Fatalf("fetched past the ahead window: %v", hits.hits)
+	}
+}
+
+func TestProxiedHLSIsWarmedInPlace(t *testing.T) {
+	var hits hitCounter
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.add(r.URL.Path)
+		if r.Header.Get("Referer") != "https://example.org/" {
+			http.Error(w, "missing referer", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/index.m3u8" {
+			_, _ = io.WriteString(w, "#EXTM3U\n#EXTINF:10,\n0.ts\n#EXTINF:10,\n1.ts\n#EXT-X-ENDLIST\n")
+			return
+		}
+		_, _ = io.WriteString(w, "segment "+r.URL.Path)
+	}))
+	defer upstream.Close()
+	setup(t, upstream.Client(), 0)
+
+	// A provider that proxies its own streams hands over an hlsproxy URL.
+	proxied, err := hlsproxy.Register(upstream.URL+"/index.m3u8", hlsproxy.Options{
+		Headers: map[string]string{"Referer": "https://example.org/"},
+	})
+	if err != nil {
+		t.Fatalf("hlsproxy.Register: %v", err)
+	}
+	if err := Warm(proxied, nil, 5*time.Second); err != nil {
+		t.Fatalf("Warm: %v", err)
+	}
+	local, err := Register(proxied, nil)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if local != proxied {
+		t.Fatalf("proxied stream was proxied again: %s", local)
+	}
+	_, media := get(t, local, "")
+	if _, segment := get(t, firstURI(string(media)), ""); string(segment) != "segment /0.ts" {
+		t.Fatalf("unexpected segment %q", segment)
+	}
+	if hits.get("/0.ts") != 1 || hits.get("/1.ts") != 0 {
+		t.Fatalf("warmed segment fetched again: %v", hits.hits)
+	}
+}
+
+func TestUnnamedHLSIsHandedToHLSProxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stream" {
+			_, _ = io.WriteString(w, "#EXTM3U\n#EXTINF:10,\n0.ts\n#EXT-X-ENDLIST\n")
+			return
+		}
+		_, _ = io.WriteString(w, "segment "+r.URL.Path)
+	}))
+	defer upstream.Close()
+	setup(t, upstream.Client(), 0)
+
+	local, err := Register(upstream.URL+"/stream", nil)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	resp, media := get(t, local, "")
+	if !hlsproxy.IsLocal(resp.Request.URL.String()) {
+		t.Fatalf("expected a redirect to hlsproxy, ended at %s", resp.Request.URL)
+	}
+	if _, segment := get(t, firstURI(string(media)), ""); string(segment) != "segment /0.ts" {
+		t.Fatalf("unexpected segment %q", segment)
+	}
+}
+
+func TestReleaseDropsSessionAndItsHLS(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "#EXTM3U\n#EXTINF:10,\n0.ts\n#EXT-X-ENDLIST\n")
+	}))
+	defer upstream.Close()
+	setup(t, upstream.Client(), 0)
+
+	local, err := Register(upstream.URL+"/stream", nil)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	resp, _ := get(t, local, "")
+	hlsURL := resp.Request.URL.String()
+	Release(local)
+
+	if hlsproxy.IsLocal(hlsURL) {
+		t.Fatal("the hlsproxy stream behind a released session is still served")
+	}
+	released, err := http.Get(local)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	released.Body.Close()
+	if released.StatusCode != http.StatusNotFound {
+		t.Fatalf("released session answered %d", released.StatusCode)
+	}
+
+	again, err := Register(upstream.URL+"/stream", nil)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if again == local {
+		t.Fatal("registering after a release reused the released session")
+	}
+	get(t, again, "")
+}
+
+func TestProgressiveRangesUseBlocks(t *testing.T) {
+	data := make([]byte, blockSize*2+1234)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	var hits hitCounter
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.add(r.Header.Get("Range"))
+		http.ServeContent(w, r, "episode.mp4", time.Time{}, bytes.NewReader(data))
+	}))
+	defer upstream.Close()
+	setup(t, upstream.Client(), 0)
+
+	local, err := Register(upstream.URL+"/episode.mp4", nil)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if !strings.HasSuffix(local, "/episode.mp4") {
+		t.Fatalf("local url lost the file name: %s", local)
+	}
+
+	start := blockSize - 10
+	resp, body := get(t, local, fmt.Sprintf("bytes=%d-%d", start, start+19))
+	if resp.StatusCode != http.StatusPartialContent || !bytes.Equal(body, data[start:start+20]) {
+		t.Fatalf("range across blocks returned status %d and %d bytes", resp.StatusCode, len(body))
+	}
+	if got := resp.Header.Get("Content-Range"); got != fmt.Sprintf("bytes %d-%d/%d", start, start+19, len(data)) {
+		t.Fatalf("unexpected Content-Range %q", got)
+	}
+
+	_, body = get(t, local, "")
+	if !bytes.Equal(body, data) {
+		t.Fatalf("full read returned %d bytes, want %d", len(body), len(data))
+	}
+	for key, count := range hits.hits {
+		if count > 1 {
+			t.Fatalf("range %q fetched %d times", key, count)
+		}
+	}
+}
+
+func TestPassthroughWithoutRangeSupport(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "whole file")
+	}))
+	defer upstream.Close()
+	setup(t, upstream.Client(), 0)
+
+	local, err := Register(upstream.URL+"/video", nil)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if _, body := get(t, local, ""); string(body) != "whole file" {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if err := Warm(upstream.URL+"/video", nil, time.Minute); err == nil {
+		t.Fatalf("expected warming a file without range support to fail")
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	cases := []struct {
+		header     string
+		start, end int64
+		ok         bool
+	}{
+		{"bytes=0-", 0, 99, true},
+		{"bytes=10-19", 10, 19, true},
+		{"bytes=90-200", 90, 99, true},
+		{"bytes=-10", 90, 99, true},
+		{"bytes=100-", 0, 0, false},
+		{"bytes=5-1", 0, 0, false},
+		{"bytes=0-1,5-6", 0, 0, false},
+		{"items=0-1", 0, 0, false},
+	}
+	for _, tc := range cases {
+		start, end, ok := parseRange(tc.header, 100)
+		if ok != tc.ok || (ok && (start != tc.start || end != tc.end)) {
+			t.Errorf("parseRange(%q) = %d, %d, %v", tc.header, start, end, ok)
+		}
+	}
+}